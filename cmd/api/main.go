@@ -1,15 +1,21 @@
 package main
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/hulupay/istar-api/config"
 	"github.com/hulupay/istar-api/internal/api"
 	"github.com/hulupay/istar-api/internal/client"
 	"github.com/hulupay/istar-api/internal/handlers"
+	"github.com/hulupay/istar-api/internal/metrics"
 	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
 	"github.com/hulupay/istar-api/internal/repositories"
 	"github.com/hulupay/istar-api/internal/services"
+	"github.com/hulupay/istar-api/pkg/cache"
 	"github.com/hulupay/istar-api/pkg/logging"
+	"github.com/hulupay/istar-api/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.uber.org/zap"
@@ -17,8 +23,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
-	"time"
 )
 
 // @title           iStar API
@@ -47,44 +53,130 @@ func main() {
 
 	cfg := config.Load()
 	// Initialize logger
-	logger, err := zap.NewProduction()
+	logger, err := logging.New(logging.Config{Level: cfg.LogLevel, Format: cfg.LogFormat})
 	if err != nil {
-		logger.Fatal("Failed to initialize zap logger", zap.Error(err))
+		fmt.Fprintln(os.Stderr, "Failed to initialize logger:", err)
+		os.Exit(1)
 	}
 	defer logger.Sync()
 	sugar := logger.Sugar()
 
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("Invalid configuration", zap.Error(err))
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingEndpoint)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	if cfg.TracingEndpoint != "" {
+		logger.Info("Exporting traces", zap.String("endpoint", cfg.TracingEndpoint))
+	}
+
 	//set up gin router
 	router := gin.Default()
+	// ClientIP, which rate limits and IP allowlists key on, only believes
+	// X-Forwarded-For from these proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
 	router.Use(gin.Recovery())
+	router.Use(middleware.Tracing(tracing.ServiceName))
+	router.Use(middleware.CORS(cfg.CORSAllowedOrigins))
+	router.Use(middleware.RequestID())
 	router.Use(logging.LoggerMiddleware(sugar))
+	router.Use(middleware.Metrics())
+	slowRequests := middleware.NewSlowRequestLog(cfg.SlowRequestThreshold, cfg.SlowRequestHistory)
+	router.Use(middleware.SlowRequests(slowRequests, logger))
 	router.Use(middleware.ErrorHandler(logger))
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
 	router.GET("/", func(c *gin.Context) {
 		c.String(http.StatusOK, "Hello, World!")
 	})
 
-	istarClient := client.NewIStarClient(cfg.IStarConfigVar, logger)
-	orderRepo := repositories.NewOrderRepository( /*db.Pool,*/ logger)
-	orderService := services.NewOrderService(orderRepo, istarClient, logger)
+	if cfg.IStarConfigVar.DebugBodies && cfg.Environment == "production" {
+		logger.Warn("Ignoring ISTAR_DEBUG_BODIES in production")
+		cfg.IStarConfigVar.DebugBodies = false
+	}
+	logStartupSummary(logger, cfg)
+
+	istarClient, err := client.NewIStarClient(cfg.IStarConfigVar, logger)
+	if err != nil {
+		logger.Fatal("Failed to create iStar client", zap.Error(err))
+	}
+	var orderRepo repositories.OrderRepository
+	if cfg.DBDriver == "memory" {
+		logger.Warn("Using the in-memory order store; orders are lost on restart")
+		orderRepo = repositories.NewInMemoryOrderRepository()
+	} else {
+		orderRepo = repositories.NewOrderRepository( /*db.Pool,*/ logger)
+	}
+	// Cancelled on SIGINT/SIGTERM to stop background goroutines (cache janitors, poller)
+	backgroundCtx, stopBackground := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopBackground()
+	// Tracks background workers so shutdown can wait for them to finish
+	var workers sync.WaitGroup
 
-	starHandler := handlers.NewStarHandler(orderService, istarClient, logger)
-	premiumHandler := handlers.NewPremiumHandler(orderService, istarClient, logger)
+	orderService := services.NewOrderService(backgroundCtx, orderRepo, istarClient, cfg.Orders, &workers, logger)
+
+	starSearchCache := cache.NewLRU[string, *models.StarRecipientResponse](cfg.RecipientCacheTTL, cfg.RecipientCacheMaxEntries)
+	premiumSearchCache := cache.NewLRU[string, *models.PremiumRecipientResponse](cfg.RecipientCacheTTL, cfg.RecipientCacheMaxEntries)
+	walletTypes := make(models.WalletTypes, len(cfg.WalletTypes))
+	for i, t := range cfg.WalletTypes {
+		walletTypes[i] = models.WalletType(t)
+	}
+	recipientAllowlist := models.NewUsernameAllowlist(cfg.RecipientAllowlist)
+	if len(recipientAllowlist) > 0 {
+		logger.Warn("Gifts are restricted to the recipient allowlist", zap.Int("usernames", len(recipientAllowlist)))
+	}
+	starHandler := handlers.NewStarHandler(orderService, istarClient, cfg.RecipientNotFoundOnEmpty, starSearchCache, walletTypes, recipientAllowlist, logger)
+	premiumHandler := handlers.NewPremiumHandler(orderService, istarClient, cfg.RecipientNotFoundOnEmpty, premiumSearchCache, walletTypes, recipientAllowlist, logger)
 	walletHandler := handlers.NewWalletHandler(istarClient, logger)
-	webhookHandler := handlers.NewWebhookHandler(orderRepo, cfg.WebhookSecret, logger)
+	orderHandler := handlers.NewOrderHandler(orderService, logger)
+	replayAttempts := 0
+	if cfg.WebhookReplayInterval > 0 {
+		replayAttempts = cfg.WebhookReplayMaxAttempts
+	}
+	webhookService := services.NewWebhookService(orderRepo, services.UnknownEventPolicy(cfg.WebhookUnknownEvents), replayAttempts, cfg.WebhookWriteTimeout, logger)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, cfg.WebhookSecret, cfg.WebhookTimestampTolerance, cfg.WebhookRequireTimestamp, logger)
+	reconciliationService := services.NewReconciliationService(orderRepo, istarClient, logger)
+	adminHandler := handlers.NewAdminHandler(orderService, reconciliationService, webhookService, cfg.WebhookSecret, slowRequests, logger)
 
-	router = api.SetupRouter(router, starHandler, premiumHandler, walletHandler, webhookHandler)
+	router = api.SetupRouter(router, cfg, logger, starHandler, premiumHandler, walletHandler, orderHandler, webhookHandler, adminHandler)
 
 	// Register health check endpoint
 	router.GET("/health", healthCheck)
 
-	// Configure server with timeouts
-	srv := &http.Server{
-		Addr:         ":" + cfg.ServerPort,
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	readinessChecks := map[string]handlers.DependencyCheck{
+		"database": orderRepo.Ping,
+	}
+	if cfg.HealthCheckIStar {
+		readinessChecks["istar"] = istarClient.Ping
+	}
+	healthHandler := handlers.NewHealthHandler(readinessChecks, cfg.HealthCheckTimeout, cfg.HealthCheckCacheTTL, logger)
+	router.GET("/health/ready", healthHandler.ReadinessHandler)
+
+	srv := newHTTPServer(cfg, router)
+
+	// Reconcile pending orders whose webhooks may have been missed
+	if cfg.OrderPollInterval > 0 {
+		poller := services.NewOrderStatusPoller(orderService, orderRepo, cfg.OrderPollInterval, cfg.OrderPollStaleAfter, logger)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			poller.Run(backgroundCtx)
+		}()
+	}
+
+	// Retry webhooks whose local processing failed
+	if replayAttempts > 0 {
+		replayWorker := services.NewWebhookReplayWorker(webhookService, orderRepo, cfg.WebhookReplayInterval, replayAttempts, logger)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			replayWorker.Run(backgroundCtx)
+		}()
 	}
 
 	// Graceful shutdown setup
@@ -94,25 +186,58 @@ func main() {
 		}
 	}()
 
-	logger.Info("Server started", zap.String("port", "8080"))
+	logger.Info("Server started", zap.String("port", cfg.ServerPort))
 
 	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	<-backgroundCtx.Done()
+	stopBackground()
 	logger.Info("Shutting down server...")
 
 	// Create shutdown context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
+	// Shutdown waits for in-flight requests, and with them their iStar calls
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
+	if err := waitGroupContext(ctx, &workers); err != nil {
+		logger.Error("Background workers did not stop in time", zap.Error(err))
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
+	}
 
 	logger.Info("Server exited properly")
 }
 
+// newHTTPServer configures the server for handler with the port and timeouts from cfg
+func newHTTPServer(cfg *config.AppConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         ":" + cfg.ServerPort,
+		Handler:      handler,
+		ReadTimeout:  cfg.ServerReadTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
+		IdleTimeout:  cfg.ServerIdleTimeout,
+	}
+}
+
+// waitGroupContext waits for wg, giving up when ctx is done
+func waitGroupContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // HealthCheck godoc
 // @Summary      Show the status of server
 // @Description  Retrieve the current status of the server
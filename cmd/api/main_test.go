@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"github.com/hulupay/istar-api/internal/services"
+	"go.uber.org/zap"
+)
+
+func TestShutdownWaitsForThePoller(t *testing.T) {
+	background, stop := context.WithCancel(context.Background())
+	defer stop()
+	var workers sync.WaitGroup
+	repo := repositories.NewInMemoryOrderRepository()
+	orderService := services.NewOrderService(background, repo, &clientmock.IStarAPI{}, config.OrderConfig{}, &workers, zap.NewNop())
+	poller := services.NewOrderStatusPoller(orderService, repo, time.Millisecond, time.Minute, zap.NewNop())
+
+	exited := make(chan struct{})
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		poller.Run(background)
+		close(exited)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	stop()
+	deadline, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := waitGroupContext(deadline, &workers); err != nil {
+		t.Fatalf("waitGroupContext = %v, want the poller to exit before the deadline", err)
+	}
+	select {
+	case <-exited:
+	default:
+		t.Error("waitGroupContext returned before the poller exited")
+	}
+}
+
+func TestShutdownGivesUpOnAStuckWorker(t *testing.T) {
+	var workers sync.WaitGroup
+	release := make(chan struct{})
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		<-release
+	}()
+	defer close(release)
+
+	deadline, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := waitGroupContext(deadline, &workers); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waitGroupContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestHTTPServerTimeoutsComeFromEnv(t *testing.T) {
+	tests := []struct {
+		name              string
+		env               map[string]string
+		read, write, idle time.Duration
+	}{
+		{"defaults", nil, 15 * time.Second, 30 * time.Second, 60 * time.Second},
+		{"overridden", map[string]string{
+			"SERVER_READ_TIMEOUT":  "5s",
+			"SERVER_WRITE_TIMEOUT": "2m",
+			"SERVER_IDLE_TIMEOUT":  "90s",
+		}, 5 * time.Second, 2 * time.Minute, 90 * time.Second},
+		{"write only", map[string]string{"SERVER_WRITE_TIMEOUT": "45s"}, 15 * time.Second, 45 * time.Second, 60 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PORT", "9090")
+			for _, key := range []string{"SERVER_READ_TIMEOUT", "SERVER_WRITE_TIMEOUT", "SERVER_IDLE_TIMEOUT"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			srv := newHTTPServer(config.Load(), http.NotFoundHandler())
+
+			if srv.Addr != ":9090" {
+				t.Errorf("Addr = %q, want :9090", srv.Addr)
+			}
+			if srv.ReadTimeout != tt.read || srv.WriteTimeout != tt.write || srv.IdleTimeout != tt.idle {
+				t.Errorf("timeouts = %v/%v/%v, want %v/%v/%v", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout, tt.read, tt.write, tt.idle)
+			}
+		})
+	}
+}
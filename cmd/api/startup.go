@@ -0,0 +1,54 @@
+package main
+
+import (
+	"cmp"
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/pkg/version"
+	"go.uber.org/zap"
+	"net/url"
+)
+
+// logStartupSummary logs the effective configuration as one line, so an
+// operator can check a deployment at a glance. Only settings that are safe to
+// share appear: the iStar base URL is cut down to its host, and keys, secrets,
+// the proxy URL and the webhook path (which may be kept secret) are left out.
+func logStartupSummary(logger *zap.Logger, cfg *config.AppConfig) {
+	istar := cfg.IStarConfigVar
+	var istarHost string
+	if u, err := url.Parse(istar.BaseURL); err == nil {
+		istarHost = u.Host
+	}
+
+	logger.Info("Starting iStar API",
+		zap.String("environment", cmp.Or(cfg.Environment, "unset")),
+		zap.String("version", version.Version),
+		zap.String("port", cfg.ServerPort),
+		zap.String("db_driver", cfg.DBDriver),
+		zap.String("istar_host", istarHost),
+		zap.Dict("istar_timeouts",
+			zap.Duration("default", istar.Timeout),
+			zap.Duration("search", cmp.Or(istar.SearchTimeout, istar.Timeout)),
+			zap.Duration("sync_order", cmp.Or(istar.SyncOrderTimeout, istar.Timeout)),
+			zap.Duration("async_order", cmp.Or(istar.AsyncOrderTimeout, istar.Timeout))),
+		zap.Int("istar_max_retries", istar.MaxRetries),
+		zap.Int("istar_max_in_flight", istar.MaxInFlight),
+		zap.Dict("server_timeouts",
+			zap.Duration("read", cfg.ServerReadTimeout),
+			zap.Duration("write", cfg.ServerWriteTimeout),
+			zap.Duration("idle", cfg.ServerIdleTimeout),
+			zap.Duration("shutdown", cfg.ShutdownTimeout)),
+		zap.Int("admin_sign_rate_per_minute", cfg.AdminSignRatePerMinute),
+		zap.Dict("features",
+			zap.Bool("metrics", true),
+			zap.Bool("tracing", cfg.TracingEndpoint != ""),
+			zap.Bool("order_poller", cfg.OrderPollInterval > 0),
+			zap.Bool("webhook_replay", cfg.WebhookReplayInterval > 0),
+			zap.Bool("webhook_ip_allowlist", len(cfg.WebhookAllowedCIDRs) > 0),
+			zap.Bool("admin_ip_allowlist", len(cfg.AdminAllowedCIDRs) > 0),
+			zap.Bool("recipient_allowlist", len(cfg.RecipientAllowlist) > 0),
+			zap.Bool("recipient_cache", cfg.RecipientCacheTTL > 0 && cfg.RecipientCacheMaxEntries > 0),
+			zap.Bool("istar_request_signing", istar.SigningSecret != ""),
+			zap.Bool("istar_health_check", cfg.HealthCheckIStar),
+			zap.Bool("slow_request_log", cfg.SlowRequestThreshold > 0),
+			zap.Bool("istar_debug_bodies", istar.DebugBodies)))
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// setRequiredEnv sets every variable Validate insists on, so a test can unset
+// or break one at a time
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("PORT", "8080")
+	t.Setenv("ISTAR_API_KEY", "istar-key")
+	t.Setenv("CLIENT_API_KEYS", "client-key-a,client-key-b")
+	t.Setenv("ISTAR_BASE_URL", "https://api.example.com/v1")
+	t.Setenv("WEBHOOK_SECRET", "webhook-secret")
+}
+
+func TestValidateAcceptsCompleteConfig(t *testing.T) {
+	setRequiredEnv(t)
+
+	if err := Load().Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestValidateReportsMissingRequiredSettings(t *testing.T) {
+	tests := []struct {
+		unset string
+		want  string
+	}{
+		{"ISTAR_API_KEY", "ISTAR_API_KEY is required"},
+		{"CLIENT_API_KEYS", "CLIENT_API_KEYS is required"},
+		{"ISTAR_BASE_URL", "ISTAR_BASE_URL is required"},
+		{"WEBHOOK_SECRET", "WEBHOOK_SECRET is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.unset, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv(tt.unset, "")
+
+			err := Load().Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblemAtOnce(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("ISTAR_API_KEY", "")
+	t.Setenv("WEBHOOK_SECRET", "")
+
+	err := Load().Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error")
+	}
+	for _, want := range []string{"ISTAR_API_KEY is required", "WEBHOOK_SECRET is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestValidateRejectsMalformedValues(t *testing.T) {
+	tests := []struct {
+		key, value string
+	}{
+		{"ORDER_DAILY_LIMIT", "1O0"},
+		{"ISTAR_MAX_RETRIES", "three"},
+		{"ISTAR_TIMEOUT", "10"},
+		{"HEALTH_CHECK_ISTAR", "maybe"},
+		{"ISTAR_RETRY_STATUSES", "429,abc"},
+		{"ISTAR_DEFAULT_HEADERS", "X-Partner"},
+		{"ORDER_MIN_AMOUNTS", "ton=-1"},
+		{"ORDER_MIN_AMOUNTS", "ton=1e3"},
+		{"ORDER_MAX_AMOUNT", "0.0000000001"},
+		{"DB_DRIVER", "sqlite"},
+		{"WEBHOOK_ALLOWED_CIDRS", "203.0.113.0/24,10.0.0.0/33"},
+		{"ADMIN_ALLOWED_CIDRS", "office"},
+		{"ISTAR_PROXY_URL", "proxy.internal:3128"},
+		{"SERVER_READ_TIMEOUT", "0s"},
+		{"SERVER_WRITE_TIMEOUT", "-30s"},
+		{"SERVER_IDLE_TIMEOUT", "0s"},
+		{"ISTAR_MAX_IN_FLIGHT", "-1"},
+		{"WEBHOOK_PATH", "webhooks/istar"},
+		{"WEBHOOK_PATH", "/webhooks/:provider"},
+		{"SLOW_REQUEST_THRESHOLD", "-1s"},
+		{"SLOW_REQUEST_HISTORY", "-5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv(tt.key, tt.value)
+
+			err := Load().Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Fatalf("Validate() = %v, want it to name %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoadParsesWellFormedValues(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("ISTAR_MAX_RETRIES", " 5 ")
+	t.Setenv("ISTAR_TIMEOUT", "3s")
+	t.Setenv("HEALTH_CHECK_ISTAR", "false")
+	t.Setenv("ISTAR_RETRY_STATUSES", "429, 503")
+	t.Setenv("ORDER_MAX_AMOUNT", "1000.1")
+	t.Setenv("ORDER_DAILY_LIMIT", "0.3")
+	t.Setenv("ORDER_MIN_AMOUNTS", "ton=0.5, usdt=1")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+	t.Setenv("WALLET_TYPES", "ton,usdt,stars")
+	t.Setenv("ISTAR_DEFAULT_HEADERS", "X-Partner=hulupay, X-Env = prod")
+	t.Setenv("RECIPIENT_ALLOWLIST", "alice_1, @bob_test")
+
+	cfg := Load()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	if cfg.IStarConfigVar.MaxRetries != 5 {
+		t.Errorf("MaxRetries = %d, want 5", cfg.IStarConfigVar.MaxRetries)
+	}
+	if cfg.IStarConfigVar.Timeout != 3*time.Second {
+		t.Errorf("Timeout = %v, want 3s", cfg.IStarConfigVar.Timeout)
+	}
+	if cfg.HealthCheckIStar {
+		t.Error("HealthCheckIStar = true, want false")
+	}
+	if got := cfg.IStarConfigVar.RetryStatuses; len(got) != 2 || got[0] != 429 || got[1] != 503 {
+		t.Errorf("RetryStatuses = %v, want [429 503]", got)
+	}
+	if got := cfg.Orders.MaxOrderAmount.String(); got != "1000.1" {
+		t.Errorf("MaxOrderAmount = %s, want 1000.1", got)
+	}
+	if got := cfg.Orders.DailyLimit.String(); got != "0.3" {
+		t.Errorf("DailyLimit = %s, want 0.3", got)
+	}
+	if got := cfg.Orders.MinAmountByWallet; len(got) != 2 || got["ton"].String() != "0.5" || got["usdt"].String() != "1" {
+		t.Errorf("MinAmountByWallet = %v, want ton=0.5 usdt=1", got)
+	}
+	if got := cfg.CORSAllowedOrigins; len(got) != 2 || got[0] != "https://a.example.com" || got[1] != "https://b.example.com" {
+		t.Errorf("CORSAllowedOrigins = %q, want both origins", got)
+	}
+	if got := cfg.WalletTypes; len(got) != 3 || got[2] != "stars" {
+		t.Errorf("WalletTypes = %q, want ton, usdt and stars", got)
+	}
+	if got := cfg.IStarConfigVar.DefaultHeaders; len(got) != 2 || got["X-Partner"] != "hulupay" || got["X-Env"] != "prod" {
+		t.Errorf("DefaultHeaders = %v, want X-Partner and X-Env", got)
+	}
+	if got := cfg.RecipientAllowlist; len(got) != 2 || got[0] != "alice_1" || got[1] != "@bob_test" {
+		t.Errorf("RecipientAllowlist = %q, want alice_1 and @bob_test", got)
+	}
+}
@@ -2,32 +2,93 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/config"
 	"github.com/hulupay/istar-api/internal/handlers"
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/pkg/jsonlimit"
+	"go.uber.org/zap"
 )
 
 func SetupRouter(
 	route *gin.Engine,
+	cfg *config.AppConfig,
+	logger *zap.Logger,
 	starHandler *handlers.StarHandler,
 	premiumHandler *handlers.PremiumHandler,
 	walletHandler *handlers.WalletHandler,
-	webhookHandler *handlers.WebhookHandler) *gin.Engine {
+	orderHandler *handlers.OrderHandler,
+	webhookHandler *handlers.WebhookHandler,
+	adminHandler *handlers.AdminHandler) *gin.Engine {
+
+	route.HandleMethodNotAllowed = true
+	// Lets a *gin.Context stand in for the request context, so values such
+	// as the client identity reach the service even where c is passed as ctx
+	route.ContextWithFallback = true
+	route.NoRoute(middleware.NotFound())
+	route.NoMethod(middleware.MethodNotAllowed())
+
+	// Webhooks read their body under their own, larger cap
+	route.Use(middleware.MaxBodySize(cfg.MaxBodyBytes, cfg.WebhookPath))
+
+	requireJSON := middleware.RequireJSON()
+	bodyLimits := middleware.JSONLimits(jsonlimit.Limits{
+		MaxBytes:    cfg.JSONMaxBytes,
+		MaxDepth:    cfg.JSONMaxDepth,
+		MaxElements: cfg.JSONMaxElements,
+	})
+
+	// Integrator routes; each API key only reaches its own orders
+	client := route.Group("", middleware.APIKeyAuth(cfg.ClientAPIKeys, logger))
 
 	// Star Gifting
-	route.GET("/star/recipient/search", starHandler.SearchStarRecipientHandler)
-	route.POST("/orders/star", starHandler.CreateStarGiftAsyncHandler)
-	route.POST("/orders/star/sync", starHandler.CreateStarGiftSyncHandler)
+	client.GET("/star/recipient/search", starHandler.SearchStarRecipientHandler)
+	client.POST("/orders/star", requireJSON, bodyLimits, starHandler.CreateStarGiftAsyncHandler)
+	client.POST("/orders/star/sync", requireJSON, bodyLimits, starHandler.CreateStarGiftSyncHandler)
+	client.POST("/orders/star/batch", requireJSON, bodyLimits, starHandler.CreateStarGiftBatchHandler)
+	client.POST("/orders/star/quote", requireJSON, bodyLimits, starHandler.QuoteStarOrderHandler)
 
 	// Premium Gifts
-	route.GET("/premium/recipient/search", premiumHandler.SearchPremiumRecipientHandler)
-	route.POST("/orders/premium", premiumHandler.CreatePremiumGiftAsyncHandler)
-	route.POST("/orders/premium/sync", premiumHandler.CreatePremiumGiftSyncHandler)
-	route.GET("/premium/packages", premiumHandler.GetPremiumPackagesHandler)
+	client.GET("/premium/recipient/search", premiumHandler.SearchPremiumRecipientHandler)
+	client.POST("/orders/premium", requireJSON, bodyLimits, premiumHandler.CreatePremiumGiftAsyncHandler)
+	client.POST("/orders/premium/sync", requireJSON, bodyLimits, premiumHandler.CreatePremiumGiftSyncHandler)
+	client.POST("/orders/premium/quote", requireJSON, bodyLimits, premiumHandler.QuotePremiumOrderHandler)
+	getAndHead(client, "/premium/packages", premiumHandler.GetPremiumPackagesHandler)
+
+	// Orders
+	client.GET("/orders", orderHandler.ListOrdersHandler)
+	client.GET("/orders/export", orderHandler.ExportOrdersHandler)
+	getAndHead(client, "/orders/:id", orderHandler.GetOrderHandler)
+	client.GET("/orders/:id/audit", orderHandler.GetOrderAuditHandler)
+	client.POST("/orders/:id/cancel", orderHandler.CancelOrderHandler)
+	client.POST("/orders/:id/refund", orderHandler.RefundOrderHandler)
+	client.POST("/orders/:id/resync", orderHandler.ResyncOrderHandler)
+	client.GET("/orders/:id/refund-eligibility", orderHandler.GetRefundEligibilityHandler)
+	client.GET("/orders/by-tx/:hash", orderHandler.GetOrdersByTxHashHandler)
+	client.GET("/orders/by-istar/:id", orderHandler.GetOrderByIStarIDHandler)
 
 	// Wallet
-	route.GET("/wallet/balance", walletHandler.GetWalletBalanceHandler)
+	getAndHead(client, "/wallet/balance", walletHandler.GetWalletBalanceHandler)
+	client.GET("/wallet/transactions", walletHandler.GetWalletTransactionsHandler)
 
 	// Webhooks
-	route.POST("/webhooks/istar", webhookHandler.HandleWebhookHandler)
+	route.POST(cfg.WebhookPath, middleware.IPAllowlist(cfg.WebhookAllowedCIDRs), requireJSON, bodyLimits, webhookHandler.HandleWebhookHandler)
+
+	// Admin
+	admin := route.Group("/admin", middleware.IPAllowlist(cfg.AdminAllowedCIDRs), middleware.AdminAuth(cfg.AdminAPIKey, logger))
+	admin.POST("/orders/:id/fail", requireJSON, adminHandler.ForceFailOrderHandler)
+	admin.PATCH("/orders/:id", requireJSON, adminHandler.UpdateOrderHandler)
+	admin.POST("/orders/reconcile", adminHandler.ReconcilePendingOrdersHandler)
+	admin.GET("/reconciliation/report", adminHandler.ReconciliationReportHandler)
+	admin.GET("/webhooks/pending", adminHandler.PendingWebhooksHandler)
+	admin.GET("/slow-requests", adminHandler.SlowRequestsHandler)
+	admin.POST("/webhooks/sign", middleware.RateLimit(cfg.AdminSignRatePerMinute, 1), adminHandler.SignWebhookPreviewHandler)
 
 	return route
 }
+
+// getAndHead registers a resource for both GET and HEAD, with ETag and
+// Content-Length computed from the rendered body
+func getAndHead(route gin.IRoutes, path string, handler gin.HandlerFunc) {
+	route.GET(path, middleware.ETag(), handler)
+	route.HEAD(path, middleware.ETag(), handler)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/handlers"
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"github.com/hulupay/istar-api/internal/services"
+	"go.uber.org/zap"
+)
+
+// newTestAPIRouter sets up every route from the environment, with webhookPath
+// as WEBHOOK_PATH when it is not empty
+func newTestAPIRouter(t *testing.T, webhookPath string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ISTAR_API_KEY", "istar-key")
+	t.Setenv("CLIENT_API_KEYS", "client-key")
+	t.Setenv("ISTAR_BASE_URL", "https://api.example.com/v1")
+	t.Setenv("WEBHOOK_SECRET", "webhook-secret")
+	if webhookPath != "" {
+		t.Setenv("WEBHOOK_PATH", webhookPath)
+	}
+	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	logger := zap.NewNop()
+	istar := &clientmock.IStarAPI{}
+	repo := repositories.NewInMemoryOrderRepository()
+	background, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	orderService := services.NewOrderService(background, repo, istar, cfg.Orders, &sync.WaitGroup{}, logger)
+	webhookService := services.NewWebhookService(repo, services.UnknownEventIgnore, 0, time.Second, logger)
+	reconciliationService := services.NewReconciliationService(repo, istar, logger)
+
+	// main installs the error handler ahead of SetupRouter
+	engine := gin.New()
+	engine.Use(middleware.ErrorHandler(logger))
+	return SetupRouter(engine, cfg, logger,
+		handlers.NewStarHandler(orderService, istar, false, nil, nil, nil, logger),
+		handlers.NewPremiumHandler(orderService, istar, false, nil, nil, nil, logger),
+		handlers.NewWalletHandler(istar, logger),
+		handlers.NewOrderHandler(orderService, logger),
+		handlers.NewWebhookHandler(webhookService, cfg.WebhookSecret, time.Minute, false, logger),
+		handlers.NewAdminHandler(orderService, reconciliationService, webhookService, cfg.WebhookSecret, nil, logger))
+}
+
+// postUnsignedWebhook posts an unsigned delivery to path and returns the
+// status and error code
+func postUnsignedWebhook(r http.Handler, path string) (int, string) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"event_id":"evt-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Code
+}
+
+func TestWebhookRouteFollowsConfiguredPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		webhookPath string
+		served      string
+		notServed   string
+	}{
+		{"default", "", "/webhooks/istar", "/hooks/v2/abc"},
+		{"overridden", "/hooks/v2/abc", "/hooks/v2/abc", "/webhooks/istar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestAPIRouter(t, tt.webhookPath)
+
+			// An unsigned delivery reaching the handler is refused by the
+			// signature check, so a 401 shows the route is registered
+			if status, code := postUnsignedWebhook(r, tt.served); status != http.StatusUnauthorized || code != models.CodeUnauthorized {
+				t.Errorf("POST %s = %d %s, want %d %s", tt.served, status, code, http.StatusUnauthorized, models.CodeUnauthorized)
+			}
+			if status, code := postUnsignedWebhook(r, tt.notServed); status != http.StatusNotFound || code != models.CodeNotFound {
+				t.Errorf("POST %s = %d %s, want %d %s", tt.notServed, status, code, http.StatusNotFound, models.CodeNotFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"github.com/hulupay/istar-api/internal/models"
+	"net/http"
+)
+
+// IStarAPI is the part of the iStar client used by services and handlers.
+// *IStarClient implements it; tests can substitute clientmock.IStarAPI.
+type IStarAPI interface {
+	DoRequest(ctx context.Context, method, path string, payload []byte) (*http.Response, error)
+	Ping(ctx context.Context) error
+
+	SearchStarRecipient(ctx context.Context, username string, quantity int) (*models.StarRecipientResponse, error)
+	SearchPremiumRecipient(ctx context.Context, username string, months int) (*models.PremiumRecipientResponse, error)
+
+	GetPremiumPackages(ctx context.Context) (*models.PremiumPackagesResponse, error)
+
+	QuoteStarOrder(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error)
+	QuotePremiumOrder(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.OrderQuoteResponse, error)
+	CreateStarOrderAsync(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error)
+	CreateStarOrderSync(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error)
+	CreatePremiumOrderAsync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error)
+	CreatePremiumOrderSync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error)
+
+	GetOrder(ctx context.Context, orderID string) (*models.OrderStatusResponse, error)
+	GetRefundEligibility(ctx context.Context, orderID string) (*models.RefundEligibilityResponse, error)
+	RefundOrder(ctx context.Context, orderID string) (*models.RefundResponse, error)
+	CancelOrder(ctx context.Context, orderID string) error
+
+	TransactionURL(walletType models.WalletType, txHash string) string
+	VerifyTransaction(ctx context.Context, walletType models.WalletType, txHash string) (bool, error)
+
+	GetWalletBalance(ctx context.Context) (*models.WalletBalance, error)
+	GetWalletTransactions(ctx context.Context, filter models.WalletTransactionFilter) (*models.WalletTransactionPage, error)
+	StreamWalletTransactions(ctx context.Context, fn func(models.WalletTransaction) error) error
+}
+
+var _ IStarAPI = (*IStarClient)(nil)
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hulupay/istar-api/config"
+	"go.uber.org/zap"
+)
+
+func TestRequestURLJoinsBaseAndPath(t *testing.T) {
+	var gotURI string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURI = r.RequestURI
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		base, path, want string
+	}{
+		{srv.URL + "/v1", "/orders/star", "/v1/orders/star"},
+		{srv.URL + "/v1/", "/orders/star", "/v1/orders/star"},
+		{srv.URL + "/v1//", "/orders/star", "/v1/orders/star"},
+		{srv.URL + "/v1/", "orders/star", "/v1/orders/star"},
+		{srv.URL, "/orders/star?dry_run=1", "/orders/star?dry_run=1"},
+		{srv.URL + "/", "/orders/star", "/orders/star"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.base[len(srv.URL):]+" + "+tt.path, func(t *testing.T) {
+			cfg := testConfig(srv)
+			cfg.BaseURL = tt.base
+			resp, err := newTestClientFromConfig(t, cfg).DoRequest(context.Background(), http.MethodGet, tt.path, nil)
+			if err != nil {
+				t.Fatalf("DoRequest: %v", err)
+			}
+			resp.Body.Close()
+
+			if gotURI != tt.want {
+				t.Errorf("request URI = %s, want %s", gotURI, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewIStarClientRejectsBadBaseURL(t *testing.T) {
+	for _, base := range []string{"", "api.example.com/v1", "ftp://api.example.com", "https://api.example.com/v1?env=prod"} {
+		cfg := config.IStarConfig{APIKey: "test-key", BaseURL: base}
+		if _, err := NewIStarClient(cfg, zap.NewNop()); !errors.Is(err, ErrInvalidBaseURL) {
+			t.Errorf("NewIStarClient(%q) = %v, want ErrInvalidBaseURL", base, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrResponseTooLarge is returned while reading an upstream response body that
+// exceeds the configured maximum size
+var ErrResponseTooLarge = errors.New("upstream response body too large")
+
+// limitedBody fails reads once more than max bytes have come from body, so a
+// misbehaving upstream cannot make us buffer an unbounded response
+type limitedBody struct {
+	body io.ReadCloser
+	max  int64
+	read int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.read > b.max {
+		return 0, ErrResponseTooLarge
+	}
+	// Allow one byte past the limit so an exactly-sized body still reaches EOF
+	if room := b.max - b.read + 1; int64(len(p)) > room {
+		p = p[:room]
+	}
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+	if b.read > b.max {
+		return n - int(b.read-b.max), ErrResponseTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+func TestLimitedBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"under the limit", "abc", nil},
+		{"exactly the limit", "abcd", nil},
+		{"over the limit", "abcde", ErrResponseTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &limitedBody{body: io.NopCloser(strings.NewReader(tt.body)), max: 4}
+
+			got, err := io.ReadAll(b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadAll error = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) > 4 {
+				t.Errorf("read %d bytes, want at most 4", len(got))
+			}
+		})
+	}
+}
+
+// oversizedOrder is a valid order status body padded past 1KB
+var oversizedOrder = `{"order_id":"istar-1","status":"completed","note":"` + strings.Repeat("x", 1024) + `"}`
+
+func TestOversizedResponseIsRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, oversizedOrder)
+	}))
+	defer srv.Close()
+	cfg := testConfig(srv)
+	cfg.MaxResponseBytes = 512
+
+	_, err := newTestClientFromConfig(t, cfg).GetOrder(context.Background(), "istar-1")
+
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("GetOrder error = %v, want a 500 APIError", err)
+	}
+
+	cfg.MaxResponseBytes = int64(len(oversizedOrder))
+	if _, err := newTestClientFromConfig(t, cfg).GetOrder(context.Background(), "istar-1"); err != nil {
+		t.Errorf("GetOrder with room for the body: %v", err)
+	}
+}
+
+func TestOversizedErrorBodyKeepsStatusMapping(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, strings.Repeat("x", 4096))
+	}))
+	defer srv.Close()
+	cfg := testConfig(srv)
+	cfg.MaxResponseBytes = 512
+
+	_, err := newTestClientFromConfig(t, cfg).GetOrder(context.Background(), "istar-1")
+
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != models.CodeNotFound {
+		t.Errorf("GetOrder error = %v, want %s", err, models.CodeNotFound)
+	}
+}
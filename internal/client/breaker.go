@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/hulupay/istar-api/config"
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// newBreaker builds the circuit breaker guarding every outbound iStar call.
+// It opens after cfg.BreakerFailureThreshold consecutive failures, rejects
+// calls until cfg.BreakerResetTimeout has elapsed, then lets a single probe through.
+// The breaker keeps no timers or goroutines: the move to half-open is decided
+// on the next call, so there is nothing to stop on shutdown.
+func newBreaker(cfg config.IStarConfig, logger *zap.Logger) *gobreaker.TwoStepCircuitBreaker {
+	threshold := uint32(max(cfg.BreakerFailureThreshold, 1))
+
+	return gobreaker.NewTwoStepCircuitBreaker(gobreaker.Settings{
+		Name:        "istar",
+		MaxRequests: 1,
+		Timeout:     cfg.BreakerResetTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= threshold
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			logger.Warn("Circuit breaker state changed",
+				zap.String("breaker", name),
+				zap.String("from", from.String()),
+				zap.String("to", to.String()))
+		},
+	})
+}
+
+// requestSucceeded decides whether an upstream call counts towards tripping
+// the breaker. Transport errors and 5xx responses are failures; a caller
+// cancelling its own request says nothing about iStar's health.
+func requestSucceeded(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(ctx.Err(), context.Canceled)
+	}
+	return resp.StatusCode < http.StatusInternalServerError
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/sony/gobreaker"
+)
+
+func TestBreakerFailsFastThenRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, `{"order_id":"istar-1","status":"pending"}`)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(srv)
+	cfg.BreakerFailureThreshold = 2
+	cfg.BreakerResetTimeout = 50 * time.Millisecond
+	c := newTestClientFromConfig(t, cfg)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := c.GetOrder(ctx, "istar-1"); err == nil {
+			t.Fatal("GetOrder succeeded against a failing upstream")
+		}
+	}
+	if state := c.breaker.State(); state != gobreaker.StateOpen {
+		t.Fatalf("breaker = %s after 2 failures, want open", state)
+	}
+
+	_, err := c.GetOrder(ctx, "istar-1")
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != models.CodeUnavailable {
+		t.Errorf("GetOrder with the breaker open = %v, want %s", err, models.CodeUnavailable)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("upstream hits = %d, want 2: an open breaker must not reach iStar", n)
+	}
+
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.GetOrder(ctx, "istar-1"); err != nil {
+		t.Fatalf("GetOrder after the reset timeout: %v", err)
+	}
+	if state := c.breaker.State(); state != gobreaker.StateClosed {
+		t.Errorf("breaker = %s after a successful probe, want closed", state)
+	}
+}
+
+func TestRequestSucceeded(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{"ok", context.Background(), &http.Response{StatusCode: http.StatusOK}, nil, true},
+		{"client error", context.Background(), &http.Response{StatusCode: http.StatusNotFound}, nil, true},
+		{"server error", context.Background(), &http.Response{StatusCode: http.StatusBadGateway}, nil, false},
+		{"transport error", context.Background(), nil, errors.New("connection refused"), false},
+		{"caller cancelled", cancelled, nil, context.Canceled, true},
+		{"deadline exceeded", expired, nil, context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		if got := requestSucceeded(tt.ctx, tt.resp, tt.err); got != tt.want {
+			t.Errorf("%s: requestSucceeded = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
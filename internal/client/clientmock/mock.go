@@ -0,0 +1,180 @@
+package clientmock
+
+import (
+	"context"
+	"errors"
+	"github.com/hulupay/istar-api/internal/client"
+	"github.com/hulupay/istar-api/internal/models"
+	"net/http"
+)
+
+// ErrNotConfigured is returned by any IStarAPI method whose Func field is nil
+var ErrNotConfigured = errors.New("clientmock: method not configured")
+
+// IStarAPI is a hand-written client.IStarAPI for tests. Set the Func field of
+// each method a test expects to be called; the rest return ErrNotConfigured.
+type IStarAPI struct {
+	DoRequestFunc                func(context.Context, string, string, []byte) (*http.Response, error)
+	PingFunc                     func(context.Context) error
+	SearchStarRecipientFunc      func(context.Context, string, int) (*models.StarRecipientResponse, error)
+	SearchPremiumRecipientFunc   func(context.Context, string, int) (*models.PremiumRecipientResponse, error)
+	GetPremiumPackagesFunc       func(context.Context) (*models.PremiumPackagesResponse, error)
+	QuoteStarOrderFunc           func(context.Context, models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error)
+	QuotePremiumOrderFunc        func(context.Context, models.CreatePremiumOrderRequest) (*models.OrderQuoteResponse, error)
+	CreateStarOrderAsyncFunc     func(context.Context, models.CreateStarOrderRequest) (*models.StarOrderResponse, error)
+	CreateStarOrderSyncFunc      func(context.Context, models.CreateStarOrderRequest) (*models.StarOrderResponse, error)
+	CreatePremiumOrderAsyncFunc  func(context.Context, models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error)
+	CreatePremiumOrderSyncFunc   func(context.Context, models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error)
+	GetOrderFunc                 func(context.Context, string) (*models.OrderStatusResponse, error)
+	GetRefundEligibilityFunc     func(context.Context, string) (*models.RefundEligibilityResponse, error)
+	RefundOrderFunc              func(context.Context, string) (*models.RefundResponse, error)
+	CancelOrderFunc              func(context.Context, string) error
+	TransactionURLFunc           func(models.WalletType, string) string
+	VerifyTransactionFunc        func(context.Context, models.WalletType, string) (bool, error)
+	GetWalletBalanceFunc         func(context.Context) (*models.WalletBalance, error)
+	GetWalletTransactionsFunc    func(context.Context, models.WalletTransactionFilter) (*models.WalletTransactionPage, error)
+	StreamWalletTransactionsFunc func(context.Context, func(models.WalletTransaction) error) error
+}
+
+var _ client.IStarAPI = (*IStarAPI)(nil)
+
+func (m *IStarAPI) DoRequest(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	if m.DoRequestFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.DoRequestFunc(ctx, method, path, payload)
+}
+
+func (m *IStarAPI) Ping(ctx context.Context) error {
+	if m.PingFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.PingFunc(ctx)
+}
+
+func (m *IStarAPI) SearchStarRecipient(ctx context.Context, username string, quantity int) (*models.StarRecipientResponse, error) {
+	if m.SearchStarRecipientFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.SearchStarRecipientFunc(ctx, username, quantity)
+}
+
+func (m *IStarAPI) SearchPremiumRecipient(ctx context.Context, username string, months int) (*models.PremiumRecipientResponse, error) {
+	if m.SearchPremiumRecipientFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.SearchPremiumRecipientFunc(ctx, username, months)
+}
+
+func (m *IStarAPI) GetPremiumPackages(ctx context.Context) (*models.PremiumPackagesResponse, error) {
+	if m.GetPremiumPackagesFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetPremiumPackagesFunc(ctx)
+}
+
+func (m *IStarAPI) QuoteStarOrder(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+	if m.QuoteStarOrderFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.QuoteStarOrderFunc(ctx, req)
+}
+
+func (m *IStarAPI) QuotePremiumOrder(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.OrderQuoteResponse, error) {
+	if m.QuotePremiumOrderFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.QuotePremiumOrderFunc(ctx, req)
+}
+
+func (m *IStarAPI) CreateStarOrderAsync(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+	if m.CreateStarOrderAsyncFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.CreateStarOrderAsyncFunc(ctx, req)
+}
+
+func (m *IStarAPI) CreateStarOrderSync(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+	if m.CreateStarOrderSyncFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.CreateStarOrderSyncFunc(ctx, req)
+}
+
+func (m *IStarAPI) CreatePremiumOrderAsync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error) {
+	if m.CreatePremiumOrderAsyncFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.CreatePremiumOrderAsyncFunc(ctx, req)
+}
+
+func (m *IStarAPI) CreatePremiumOrderSync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error) {
+	if m.CreatePremiumOrderSyncFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.CreatePremiumOrderSyncFunc(ctx, req)
+}
+
+func (m *IStarAPI) GetOrder(ctx context.Context, orderID string) (*models.OrderStatusResponse, error) {
+	if m.GetOrderFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetOrderFunc(ctx, orderID)
+}
+
+func (m *IStarAPI) GetRefundEligibility(ctx context.Context, orderID string) (*models.RefundEligibilityResponse, error) {
+	if m.GetRefundEligibilityFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetRefundEligibilityFunc(ctx, orderID)
+}
+
+func (m *IStarAPI) RefundOrder(ctx context.Context, orderID string) (*models.RefundResponse, error) {
+	if m.RefundOrderFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.RefundOrderFunc(ctx, orderID)
+}
+
+func (m *IStarAPI) CancelOrder(ctx context.Context, orderID string) error {
+	if m.CancelOrderFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CancelOrderFunc(ctx, orderID)
+}
+
+// TransactionURL returns "" when TransactionURLFunc is nil
+func (m *IStarAPI) TransactionURL(walletType models.WalletType, txHash string) string {
+	if m.TransactionURLFunc == nil {
+		return ""
+	}
+	return m.TransactionURLFunc(walletType, txHash)
+}
+
+func (m *IStarAPI) VerifyTransaction(ctx context.Context, walletType models.WalletType, txHash string) (bool, error) {
+	if m.VerifyTransactionFunc == nil {
+		return false, ErrNotConfigured
+	}
+	return m.VerifyTransactionFunc(ctx, walletType, txHash)
+}
+
+func (m *IStarAPI) GetWalletBalance(ctx context.Context) (*models.WalletBalance, error) {
+	if m.GetWalletBalanceFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetWalletBalanceFunc(ctx)
+}
+
+func (m *IStarAPI) GetWalletTransactions(ctx context.Context, filter models.WalletTransactionFilter) (*models.WalletTransactionPage, error) {
+	if m.GetWalletTransactionsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetWalletTransactionsFunc(ctx, filter)
+}
+
+func (m *IStarAPI) StreamWalletTransactions(ctx context.Context, fn func(models.WalletTransaction) error) error {
+	if m.StreamWalletTransactionsFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.StreamWalletTransactionsFunc(ctx, fn)
+}
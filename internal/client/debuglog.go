@@ -0,0 +1,122 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secret header values and JSON fields in debug logs
+const redacted = "[REDACTED]"
+
+// secretHeaderWords mark a header as secret when its lowercased name contains one
+var secretHeaderWords = []string{"key", "secret", "token", "signature", "authorization", "cookie"}
+
+// secretJSONField matches string values of JSON fields that commonly carry
+// secrets, including a value cut off by the end of a truncated body
+var secretJSONField = regexp.MustCompile(`(?i)("(?:api[_-]?key|secret|signature|token|password|authorization)"\s*:\s*)"(?:[^"\\]|\\.)*(?:"|$)`)
+
+// debugEnabled reports whether request and response bodies should be logged:
+// only when body logging is configured and the logger is at debug level
+func (c *IStarClient) debugEnabled() bool {
+	return c.debugBodyBytes > 0 && c.logger.Core().Enabled(zap.DebugLevel)
+}
+
+// logRequest logs an outbound request with secrets redacted
+func (c *IStarClient) logRequest(req *http.Request, payload []byte) {
+	body, truncated := capBody(redactBody(payload), c.debugBodyBytes)
+	c.logger.Debug("iStar request",
+		zap.String("method", req.Method),
+		zap.String("url", req.URL.String()),
+		zap.Any("headers", redactHeaders(req.Header)),
+		zap.String("body", body),
+		zap.Bool("body_truncated", truncated))
+}
+
+// logResponse arranges for resp to be logged, body included, once the caller
+// has read or closed it. The body is captured as it is read, so streamed
+// responses are neither buffered nor delayed.
+func (c *IStarClient) logResponse(req *http.Request, resp *http.Response) {
+	status := resp.StatusCode
+	resp.Body = &debugBody{
+		body:  resp.Body,
+		limit: c.debugBodyBytes,
+		log: func(captured []byte, truncated bool) {
+			c.logger.Debug("iStar response",
+				zap.String("method", req.Method),
+				zap.String("url", req.URL.String()),
+				zap.Int("status", status),
+				zap.Any("headers", redactHeaders(resp.Header)),
+				zap.String("body", redactBody(captured)),
+				zap.Bool("body_truncated", truncated))
+		},
+	}
+}
+
+// debugBody captures up to limit bytes of body and logs them once, at EOF or on Close
+type debugBody struct {
+	body      io.ReadCloser
+	limit     int
+	buf       bytes.Buffer
+	truncated bool
+	once      sync.Once
+	log       func(captured []byte, truncated bool)
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if room := b.limit - b.buf.Len(); n > room {
+		b.buf.Write(p[:room])
+		b.truncated = true
+	} else {
+		b.buf.Write(p[:n])
+	}
+	if errors.Is(err, io.EOF) {
+		b.flush()
+	}
+	return n, err
+}
+
+func (b *debugBody) Close() error {
+	b.flush()
+	return b.body.Close()
+}
+
+func (b *debugBody) flush() {
+	b.once.Do(func() { b.log(b.buf.Bytes(), b.truncated) })
+}
+
+// capBody returns at most limit bytes of body and whether it was cut
+func capBody(body string, limit int) (string, bool) {
+	if len(body) > limit {
+		return body[:limit], true
+	}
+	return body, false
+}
+
+// redactHeaders flattens h for logging, hiding values of secret-looking headers
+func redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		value := strings.Join(values, ", ")
+		lower := strings.ToLower(name)
+		for _, word := range secretHeaderWords {
+			if strings.Contains(lower, word) {
+				value = redacted
+				break
+			}
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// redactBody hides the values of secret-looking JSON string fields
+func redactBody(body []byte) string {
+	return secretJSONField.ReplaceAllString(string(body), `${1}"`+redacted+`"`)
+}
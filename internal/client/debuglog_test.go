@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// debugServer answers every request with a JSON body carrying a token
+func debugServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=upstream-cookie")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order_id":"o-1","token":"upstream-token","note":"` + strings.Repeat("x", 64) + `"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// sendLogged sends one POST through a client logging to an observer at level
+// and returns what was logged
+func sendLogged(t *testing.T, debugBodies bool, level zapcore.Level) *observer.ObservedLogs {
+	t.Helper()
+	srv := debugServer(t)
+	cfg := testConfig(srv)
+	cfg.APIKey = "secret-api-key"
+	cfg.SigningSecret = "signing-secret"
+	cfg.DebugBodies = debugBodies
+	cfg.DebugBodyBytes = 48
+
+	core, logs := observer.New(level)
+	c, err := NewIStarClient(cfg, zap.New(core))
+	if err != nil {
+		t.Fatalf("NewIStarClient: %v", err)
+	}
+
+	payload := []byte(`{"username":"alice","password":"hunter2","quantity":50}`)
+	resp, err := c.send(context.Background(), http.MethodPost, "/star/order", "/star/order", payload)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return logs
+}
+
+func TestDebugLogRedactsSecrets(t *testing.T) {
+	logs := sendLogged(t, true, zapcore.DebugLevel)
+
+	requests := logs.FilterMessage("iStar request").All()
+	if len(requests) != 1 {
+		t.Fatalf("logged %d request entries, want 1", len(requests))
+	}
+	req := requests[0].ContextMap()
+	headers, _ := req["headers"].(map[string]string)
+	for _, name := range []string{"Api-Key", "X-Signature"} {
+		if got := headers[name]; got != redacted {
+			t.Errorf("request header %s = %q, want %q", name, got, redacted)
+		}
+	}
+	if got := headers["Content-Type"]; got != "application/json" {
+		t.Errorf("request header Content-Type = %q, want it logged as is", got)
+	}
+	body, _ := req["body"].(string)
+	if !strings.Contains(body, `"password":"`+redacted+`"`) || !strings.Contains(body, `"username":"alice"`) {
+		t.Errorf("request body = %q, want the password redacted and the username kept", body)
+	}
+	if req["body_truncated"] != true || len(body) != 48 {
+		t.Errorf("request body is %d bytes, truncated=%v; want it cut to 48", len(body), req["body_truncated"])
+	}
+
+	responses := logs.FilterMessage("iStar response").All()
+	if len(responses) != 1 {
+		t.Fatalf("logged %d response entries, want 1", len(responses))
+	}
+	resp := responses[0].ContextMap()
+	if got := resp["headers"].(map[string]string)["Set-Cookie"]; got != redacted {
+		t.Errorf("response header Set-Cookie = %q, want %q", got, redacted)
+	}
+	if body, _ := resp["body"].(string); !strings.Contains(body, `"token":"`+redacted+`"`) {
+		t.Errorf("response body = %q, want the token redacted", body)
+	}
+	if resp["body_truncated"] != true {
+		t.Error("response body_truncated = false, want true")
+	}
+
+	for _, entry := range logs.All() {
+		for _, secret := range []string{"secret-api-key", "signing-secret", "hunter2", "upstream-token", "upstream-cookie"} {
+			for key, value := range entry.ContextMap() {
+				if strings.Contains(stringify(value), secret) {
+					t.Errorf("%q field %s leaks %q", entry.Message, key, secret)
+				}
+			}
+		}
+	}
+}
+
+func TestDebugLogSilentWhenDisabled(t *testing.T) {
+	tests := []struct {
+		name        string
+		debugBodies bool
+		level       zapcore.Level
+	}{
+		{"body logging off", false, zapcore.DebugLevel},
+		{"logger above debug", true, zapcore.InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := sendLogged(t, tt.debugBodies, tt.level)
+			for _, msg := range []string{"iStar request", "iStar response"} {
+				if n := logs.FilterMessage(msg).Len(); n != 0 {
+					t.Errorf("logged %d %q entries, want none", n, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{"api key", `{"api_key":"k1","id":"1"}`, `{"api_key":"[REDACTED]","id":"1"}`},
+		{"mixed case", `{"Authorization" : "Bearer x"}`, `{"Authorization" : "[REDACTED]"}`},
+		{"escaped quote", `{"secret":"a\"b","n":1}`, `{"secret":"[REDACTED]","n":1}`},
+		{"cut off by truncation", `{"token":"abcd`, `{"token":"[REDACTED]"`},
+		{"non-string value", `{"token":null}`, `{"token":null}`},
+		{"nothing secret", `{"username":"alice"}`, `{"username":"alice"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactBody([]byte(tt.body)); got != tt.want {
+				t.Errorf("redactBody(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+// stringify renders a logged field value for a substring search
+func stringify(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]string:
+		var b strings.Builder
+		for k, s := range v {
+			b.WriteString(k + "=" + s + ";")
+		}
+		return b.String()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hulupay/istar-api/internal/models"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrTxVerificationUnavailable is returned by VerifyTransaction when verification
+// is disabled or no explorer API is configured for the wallet type
+var ErrTxVerificationUnavailable = errors.New("transaction verification unavailable")
+
+// explorers holds the block explorer bases per on-chain wallet type
+type explorers struct {
+	// links are bases for human-readable transaction pages
+	links map[models.WalletType]string
+	// apis are bases queried to check that a transaction exists
+	apis map[models.WalletType]string
+	// verify enables VerifyTransaction
+	verify bool
+}
+
+func newExplorers(links, apis map[string]string, verify bool) explorers {
+	e := explorers{
+		links:  make(map[models.WalletType]string),
+		apis:   make(map[models.WalletType]string),
+		verify: verify,
+	}
+	for w, base := range links {
+		e.links[models.WalletType(w)] = strings.TrimRight(base, "/")
+	}
+	for w, base := range apis {
+		e.apis[models.WalletType(w)] = strings.TrimRight(base, "/")
+	}
+	return e
+}
+
+// TransactionURL returns the block explorer page for txHash, or "" when the
+// wallet type is off-chain or has no explorer configured
+func (c *IStarClient) TransactionURL(walletType models.WalletType, txHash string) string {
+	base, ok := c.explorers.links[walletType]
+	if !ok || base == "" || txHash == "" || !walletType.OnChain() {
+		return ""
+	}
+	return base + "/" + url.PathEscape(txHash)
+}
+
+// VerifyTransaction asks the wallet type's explorer API whether txHash exists.
+// It does not go through the iStar circuit breaker or retries: the explorer is
+// a separate service and a failed check only leaves the order unverified.
+func (c *IStarClient) VerifyTransaction(ctx context.Context, walletType models.WalletType, txHash string) (bool, error) {
+	base, ok := c.explorers.apis[walletType]
+	if !c.explorers.verify || !ok || base == "" || !walletType.OnChain() {
+		return false, ErrTxVerificationUnavailable
+	}
+
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/"+url.PathEscape(txHash), nil)
+	if err != nil {
+		return false, fmt.Errorf("creating explorer request failed: %w", err)
+	}
+	req.Header.Set("User-Agent", c.defaultHeaders.Get("User-Agent"))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Warn("Explorer request failed", zap.Error(err), zap.String("wallet_type", string(walletType)))
+		return false, fmt.Errorf("explorer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	default:
+		c.logger.Warn("Unexpected explorer status", zap.Int("status", resp.StatusCode), zap.String("wallet_type", string(walletType)))
+		return false, fmt.Errorf("explorer answered %d", resp.StatusCode)
+	}
+}
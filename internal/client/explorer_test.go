@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+// newExplorerClient returns a client whose explorer API for TON and USDT is
+// apiURL, with verification switched on or off
+func newExplorerClient(t *testing.T, apiURL string, verify bool) *IStarClient {
+	t.Helper()
+	return newTestClientFromConfig(t, config.IStarConfig{
+		APIKey:  "test-key",
+		BaseURL: "https://istar.example.com/v1",
+		Timeout: 5 * time.Second,
+		ExplorerURLs: map[string]string{
+			"ton":      "https://tonviewer.example.com/transaction",
+			"usdt":     "https://tronscan.example.com/#/transaction/",
+			"internal": "https://ledger.example.com/tx",
+		},
+		ExplorerAPIURLs: map[string]string{
+			"ton":      apiURL,
+			"internal": apiURL,
+		},
+		VerifyTxHashes: verify,
+	})
+}
+
+func TestTransactionURL(t *testing.T) {
+	c := newExplorerClient(t, "https://toncenter.example.com/tx", false)
+
+	tests := []struct {
+		name       string
+		walletType models.WalletType
+		txHash     string
+		want       string
+	}{
+		{"ton", models.WalletTON, "abc123", "https://tonviewer.example.com/transaction/abc123"},
+		{"usdt base with trailing slash", models.WalletUSDT, "def456", "https://tronscan.example.com/#/transaction/def456"},
+		{"hash is path escaped", models.WalletTON, "a/b c", "https://tonviewer.example.com/transaction/a%2Fb%20c"},
+		{"off-chain wallet", models.WalletInternal, "abc123", ""},
+		{"no hash", models.WalletTON, "", ""},
+		{"unconfigured wallet", models.WalletType("btc"), "abc123", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.TransactionURL(tt.walletType, tt.txHash); got != tt.want {
+				t.Errorf("TransactionURL(%s, %q) = %q, want %q", tt.walletType, tt.txHash, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTransaction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("Accept") != "application/json" {
+			t.Errorf("explorer got %s with Accept %q, want a JSON GET", r.Method, r.Header.Get("Accept"))
+		}
+		switch r.URL.Path {
+		case "/tx/known":
+			w.Write([]byte(`{"ok":true}`))
+		case "/tx/unknown":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	c := newExplorerClient(t, srv.URL+"/tx/", true)
+
+	tests := []struct {
+		txHash  string
+		want    bool
+		wantErr bool
+	}{
+		{"known", true, false},
+		{"unknown", false, false},
+		{"broken", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.txHash, func(t *testing.T) {
+			got, err := c.VerifyTransaction(context.Background(), models.WalletTON, tt.txHash)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("VerifyTransaction(%q) = %v, %v; want %v, error %v", tt.txHash, got, err, tt.want, tt.wantErr)
+			}
+			if errors.Is(err, ErrTxVerificationUnavailable) {
+				t.Errorf("VerifyTransaction(%q) = %v, want an explorer error", tt.txHash, err)
+			}
+		})
+	}
+}
+
+func TestVerifyTransactionUnavailable(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		verify     bool
+		walletType models.WalletType
+	}{
+		{"verification off", false, models.WalletTON},
+		{"off-chain wallet", true, models.WalletInternal},
+		{"no explorer API", true, models.WalletUSDT},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newExplorerClient(t, srv.URL, tt.verify)
+			_, err := c.VerifyTransaction(context.Background(), tt.walletType, "abc123")
+			if !errors.Is(err, ErrTxVerificationUnavailable) {
+				t.Errorf("VerifyTransaction = %v, want ErrTxVerificationUnavailable", err)
+			}
+		})
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("explorer was called %d times, want never", n)
+	}
+}
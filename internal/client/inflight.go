@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hulupay/istar-api/internal/metrics"
+)
+
+// inFlightLimiter bounds how many requests may be awaiting iStar at once. A
+// nil limiter imposes no bound.
+type inFlightLimiter chan struct{}
+
+// newInFlightLimiter returns a limiter with max slots, or nil when max is not positive
+func newInFlightLimiter(max int) inFlightLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return make(inFlightLimiter, max)
+}
+
+// acquire waits for a free slot, giving up when ctx ends. The returned
+// release must be called once the response headers are in.
+func (l inFlightLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l != nil {
+		select {
+		case l <- struct{}{}:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("sending request failed: %w: %w", ErrRequestNotSent, ctx.Err())
+		}
+	}
+
+	metrics.IStarInFlightRequests.Inc()
+	return func() {
+		metrics.IStarInFlightRequests.Dec()
+		if l != nil {
+			<-l
+		}
+	}, nil
+}
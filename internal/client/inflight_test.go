@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// heldServer counts concurrent requests and holds each one until release is closed
+func heldServer(t *testing.T, release <-chan struct{}) (srv *httptest.Server, current, peak *atomic.Int32, arrived <-chan struct{}) {
+	t.Helper()
+	current, peak = new(atomic.Int32), new(atomic.Int32)
+	arrivals := make(chan struct{}, 64)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		arrivals <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, current, peak, arrivals
+}
+
+func TestInFlightLimitBoundsConcurrentRequests(t *testing.T) {
+	const limit, callers = 3, 10
+	release := make(chan struct{})
+	srv, current, peak, arrived := heldServer(t, release)
+	cfg := testConfig(srv)
+	cfg.MaxInFlight = limit
+	c := newTestClientFromConfig(t, cfg)
+	base := testutil.ToFloat64(metrics.IStarInFlightRequests)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.Ping(context.Background())
+		}()
+	}
+
+	for range limit {
+		<-arrived
+	}
+	// Give any caller that slipped past the limit time to show up
+	time.Sleep(50 * time.Millisecond)
+	if n := current.Load(); n != limit {
+		t.Errorf("iStar is serving %d requests, want %d", n, limit)
+	}
+	if got := testutil.ToFloat64(metrics.IStarInFlightRequests) - base; got != limit {
+		t.Errorf("in-flight gauge = %v, want %d", got, limit)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Ping: %v", err)
+		}
+	}
+	if p := peak.Load(); p != limit {
+		t.Errorf("peak concurrency = %d, want exactly the limit of %d", p, limit)
+	}
+	if got := testutil.ToFloat64(metrics.IStarInFlightRequests); got != base {
+		t.Errorf("in-flight gauge = %v after all requests, want %v", got, base)
+	}
+}
+
+func TestInFlightWaitRespectsContext(t *testing.T) {
+	release := make(chan struct{})
+	srv, _, peak, arrived := heldServer(t, release)
+	defer close(release)
+	cfg := testConfig(srv)
+	cfg.MaxInFlight = 1
+	c := newTestClientFromConfig(t, cfg)
+
+	go c.Ping(context.Background())
+	<-arrived
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c.Ping(ctx)
+
+	if !errors.Is(err, ErrRequestNotSent) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Ping = %v, want ErrRequestNotSent wrapping the deadline", err)
+	}
+	if p := peak.Load(); p != 1 {
+		t.Errorf("peak concurrency = %d, want the waiting request never sent", p)
+	}
+}
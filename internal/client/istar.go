@@ -4,55 +4,455 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/metrics"
 	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+	"github.com/hulupay/istar-api/pkg/version"
+	"github.com/sony/gobreaker"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type IStarClient struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
-	logger     *zap.Logger
+	timeouts   operationTimeouts
+	breaker    *gobreaker.TwoStepCircuitBreaker
+	maxRetries int
+	// maxRetryAfter caps how long an upstream Retry-After may delay a retry
+	maxRetryAfter time.Duration
+	// maxResponseBytes caps how much of an upstream response body is read
+	maxResponseBytes int64
+	// signingSecret, when set, signs every outbound request
+	signingSecret string
+	// defaultHeaders are sent on every request unless the client sets the header itself
+	defaultHeaders http.Header
+	// explorers resolve and verify on-chain transaction hashes
+	explorers explorers
+	// debugBodyBytes, when positive, logs request and response bodies up to
+	// that size at debug level
+	debugBodyBytes int
+	// inFlight bounds concurrent requests awaiting iStar
+	inFlight inFlightLimiter
+	logger   *zap.Logger
+
+	// ShouldRetry decides whether a failed attempt is retried. It is taken from
+	// the config, defaulting to DefaultShouldRetry, and may be replaced before
+	// the client is used.
+	ShouldRetry RetryFunc
+}
+
+// operationTimeouts holds the deadline applied to each kind of upstream call
+type operationTimeouts struct {
+	defaultTimeout time.Duration
+	search         time.Duration
+	syncOrder      time.Duration
+	asyncOrder     time.Duration
 }
 
-func NewIStarClient(cfg config.IStarConfig, logger *zap.Logger) *IStarClient {
+// ErrRequestNotSent marks a failure that happened before the request left for
+// iStar, so it cannot have taken effect upstream
+var ErrRequestNotSent = errors.New("request not sent")
+
+// ErrInvalidBaseURL is returned by NewIStarClient when the configured base URL
+// is not an absolute http or https URL
+var ErrInvalidBaseURL = errors.New("invalid iStar base URL")
+
+// NewIStarClient builds a client for cfg. The base URL is validated once here so
+// misconfiguration fails at startup instead of on every request.
+func NewIStarClient(cfg config.IStarConfig, logger *zap.Logger) (*IStarClient, error) {
+	baseURL, err := parseBaseURL(cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyFunc(cfg.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts := operationTimeouts{
+		defaultTimeout: cfg.Timeout,
+		search:         orDefault(cfg.SearchTimeout, cfg.Timeout),
+		syncOrder:      orDefault(cfg.SyncOrderTimeout, cfg.Timeout),
+		asyncOrder:     orDefault(cfg.AsyncOrderTimeout, cfg.Timeout),
+	}
+
+	logger = logger.Named("istar_client")
+
 	return &IStarClient{
-		baseURL: cfg.BaseURL,
+		baseURL: baseURL,
 		apiKey:  cfg.APIKey,
 		httpClient: &http.Client{
-			Timeout: cfg.Timeout,
-			Transport: &http.Transport{
-				MaxIdleConnsPerHost: 20,
-			},
+			// Per-call deadlines come from the context; this is only a backstop
+			// so it must not be shorter than the slowest operation.
+			Timeout:   max(timeouts.defaultTimeout, timeouts.search, timeouts.syncOrder, timeouts.asyncOrder),
+			Transport: newTransport(cfg, proxy),
 		},
-		logger: logger.Named("istar_client"),
+		timeouts:         timeouts,
+		breaker:          newBreaker(cfg, logger),
+		maxRetries:       max(cfg.MaxRetries, 0),
+		maxRetryAfter:    cfg.MaxRetryAfter,
+		maxResponseBytes: cfg.MaxResponseBytes,
+		signingSecret:    cfg.SigningSecret,
+		defaultHeaders:   defaultHeaders(cfg.DefaultHeaders),
+		explorers:        newExplorers(cfg.ExplorerURLs, cfg.ExplorerAPIURLs, cfg.VerifyTxHashes),
+		debugBodyBytes:   debugBodyBytes(cfg),
+		inFlight:         newInFlightLimiter(cfg.MaxInFlight),
+		logger:           logger,
+
+		ShouldRetry: retryClassifier(cfg),
+	}, nil
+}
+
+// defaultHeaders builds the headers sent on every request: a User-Agent naming
+// this service and its version, then any configured extras, which may override it
+func defaultHeaders(extra map[string]string) http.Header {
+	headers := http.Header{}
+	headers.Set("User-Agent", version.UserAgent())
+	for name, value := range extra {
+		headers.Set(name, value)
+	}
+	return headers
+}
+
+// parseBaseURL checks raw is an absolute http(s) URL and returns it without a
+// trailing slash, ready to have request paths appended
+func parseBaseURL(raw string) (string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return "", fmt.Errorf("%w: base URL is empty", ErrInvalidBaseURL)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidBaseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%w: %q must use http or https", ErrInvalidBaseURL, raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("%w: %q has no host", ErrInvalidBaseURL, raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%w: %q must not contain a query or fragment", ErrInvalidBaseURL, raw)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// joinURL appends path to base with exactly one slash between them, whether
+// or not path starts with one. parseBaseURL already trimmed base.
+func joinURL(base, path string) string {
+	return base + "/" + strings.TrimLeft(path, "/")
+}
+
+// debugBodyBytes is the body logging cap, or zero when body logging is off
+func debugBodyBytes(cfg config.IStarConfig) int {
+	if !cfg.DebugBodies {
+		return 0
+	}
+	return max(cfg.DebugBodyBytes, 1)
+}
+
+// orDefault returns d, or def when d is not positive
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// withTimeout derives a context bounded by the given operation timeout.
+// A zero timeout leaves ctx unchanged.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, timeout)
 }
 
+// DoRequest sends a request to iStar, retrying up to the configured MaxRetries
+// times while ShouldRetry approves. Only idempotent methods are resent: a POST
+// that failed may still have taken effect upstream. Responses that are not
+// retried are returned as-is for the caller to inspect.
 func (c *IStarClient) DoRequest(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
-	url := c.baseURL + path
+	return c.doRequest(ctx, method, path, payload, c.maxResponseBytes, idempotentMethod(method))
+}
+
+// doRequest is DoRequest with an explicit cap on the response body size and
+// an explicit say on whether the request may be resent. maxResponseBytes <= 0
+// leaves the body unbounded for streaming endpoints.
+func (c *IStarClient) doRequest(ctx context.Context, method, path string, payload []byte, maxResponseBytes int64, resendable bool) (*http.Response, error) {
+	pathLabel := metrics.PathLabel(path)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, pathLabel, payload)
+
+		var apiErr *models.APIError
+		retry := resendable && !errors.As(err, &apiErr) && attempt < c.maxRetries && c.ShouldRetry(resp, err, attempt)
+
+		var delay time.Duration
+		if retry {
+			now := time.Now()
+			delay = retryDelay(resp, attempt, c.maxRetryAfter, now)
+			if exceedsDeadline(ctx, delay, now) {
+				// Waiting would outlive the caller; hand back what we have instead
+				c.logger.Warn("Not retrying iStar request past the deadline",
+					zap.String("method", method),
+					zap.String("path", pathLabel),
+					zap.Duration("delay", delay),
+					zap.String("request_id", requestctx.RequestID(ctx)))
+				retry = false
+			}
+		}
+
+		if !retry {
+			if err != nil {
+				return nil, err
+			}
+			if maxResponseBytes > 0 {
+				resp.Body = &limitedBody{body: resp.Body, max: maxResponseBytes}
+			}
+			return resp, nil
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		c.logger.Warn("Retrying iStar request",
+			zap.String("method", method),
+			zap.String("path", pathLabel),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.String("request_id", requestctx.RequestID(ctx)))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("sending request failed: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// send performs a single attempt of a request through the circuit breaker
+func (c *IStarClient) send(ctx context.Context, method, path, pathLabel string, payload []byte) (*http.Response, error) {
+	url := joinURL(c.baseURL, path)
+	// payload stays untouched, so every attempt reads it afresh. A bytes.Reader
+	// body also gets GetBody set, letting net/http resend it on a 307/308
+	// redirect or when a reused connection drops before the request is written.
 	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
 	if err != nil {
 		c.logger.Error("Failed to create request", zap.Error(err))
-		return nil, fmt.Errorf("creating request failed: %w", err)
+		return nil, models.InternalServerError("Failed to create upstream request")
 	}
+	req.Header = c.defaultHeaders.Clone()
 	req.Header.Set("API-Key", c.apiKey)
 	req.Header.Set("Content-Type", "application/json")
+	if requestID := requestctx.RequestID(ctx); requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	if c.signingSecret != "" {
+		signRequest(req, c.signingSecret, payload, time.Now())
+	}
+
+	debug := c.debugEnabled()
+	if debug {
+		c.logRequest(req, payload)
+	}
+
+	// A caller that has already gone away must not reach iStar or count against the breaker
+	if err := ctx.Err(); err != nil {
+		c.logger.Debug("Request cancelled before sending", zap.Error(err), zap.String("path", pathLabel))
+		return nil, fmt.Errorf("sending request failed: %w: %w", ErrRequestNotSent, err)
+	}
+
+	// The slot is held only until the response headers arrive; a streamed
+	// body being read afterwards does not count against the limit
+	release, err := c.inFlight.acquire(ctx)
+	if err != nil {
+		c.logger.Warn("Gave up waiting for an iStar request slot", zap.Error(err), zap.String("path", pathLabel))
+		return nil, err
+	}
+	defer release()
+
+	done, err := c.breaker.Allow()
+	if err != nil {
+		metrics.IStarRequestErrorsTotal.WithLabelValues(method, pathLabel).Inc()
+		c.logger.Warn("Circuit breaker rejected request", zap.Error(err), zap.String("path", pathLabel))
+		return nil, models.ServiceUnavailableError("iStar is temporarily unavailable")
+	}
+
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
+	done(requestSucceeded(ctx, resp, err))
+	metrics.IStarRequestDuration.WithLabelValues(method, pathLabel).Observe(time.Since(start).Seconds())
 	if err != nil {
-		c.logger.Error("Failed to send request", zap.Error(err))
+		metrics.IStarRequestErrorsTotal.WithLabelValues(method, pathLabel).Inc()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// Report the cancellation or deadline rather than the transport's wrapping of it
+			c.logger.Warn("Request aborted", zap.Error(ctxErr), zap.String("request_id", requestctx.RequestID(ctx)))
+			return nil, fmt.Errorf("sending request failed: %w", ctxErr)
+		}
+		c.logger.Error("Failed to send request", zap.Error(err), zap.String("request_id", requestctx.RequestID(ctx)))
 		return nil, fmt.Errorf("sending request failed: %w", err)
 	}
+	metrics.IStarRequestsTotal.WithLabelValues(method, pathLabel, strconv.Itoa(resp.StatusCode)).Inc()
+	if debug {
+		c.logResponse(req, resp)
+	}
 	return resp, nil
 }
 
+// Ping makes a single request to the iStar base URL to check connectivity.
+// Any response below 500 means iStar is reachable; retries are not attempted.
+func (c *IStarClient) Ping(ctx context.Context) error {
+	resp, err := c.send(ctx, "GET", "/", metrics.PathLabel("/"), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("iStar answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// errorFromResponse logs an unexpected upstream response and maps its status
+// code to the matching typed API error
+func (c *IStarClient) errorFromResponse(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	c.logger.Error("Unexpected status code", zap.Int("status", resp.StatusCode), zap.String("body", string(body)))
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return models.ValidationError("Invalid request parameters")
+	case http.StatusUnauthorized:
+		return models.UnauthorizedError("Invalid API key")
+	case http.StatusNotFound:
+		return models.NotFoundError("Resource not found")
+	case http.StatusConflict:
+		return models.ConflictError("iStar rejected the request as conflicting with an earlier one")
+	case http.StatusTooManyRequests:
+		return models.RateLimitedError("iStar rate limit exceeded")
+	default:
+		return models.InternalServerError(fmt.Sprintf("Unexpected status code: %d", resp.StatusCode))
+	}
+}
+
+func (c *IStarClient) SearchStarRecipient(ctx context.Context, username string, quantity int) (*models.StarRecipientResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.search)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("username", username)
+	query.Set("quantity", strconv.Itoa(quantity))
+
+	resp, err := c.DoRequest(ctx, "GET", "/star/recipient/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.StarRecipientResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+
+	return &response, nil
+}
+
+func (c *IStarClient) SearchPremiumRecipient(ctx context.Context, username string, months int) (*models.PremiumRecipientResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.search)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("username", username)
+	query.Set("months", strconv.Itoa(months))
+
+	resp, err := c.DoRequest(ctx, "GET", "/premium/recipient/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.PremiumRecipientResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+
+	return &response, nil
+}
+
+// QuoteStarOrder asks iStar what a star order would cost without placing it
+func (c *IStarClient) QuoteStarOrder(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+	return c.quote(ctx, "/orders/star/quote", req.ForIStar())
+}
+
+// QuotePremiumOrder asks iStar what a premium order would cost without placing it
+func (c *IStarClient) QuotePremiumOrder(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.OrderQuoteResponse, error) {
+	return c.quote(ctx, "/orders/premium/quote", req.ForIStar())
+}
+
+func (c *IStarClient) quote(ctx context.Context, path string, req any) (*models.OrderQuoteResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		c.logger.Error("Failed to marshal request", zap.Error(err))
+		return nil, models.InternalServerError("Failed to marshal request")
+	}
+
+	// A quote places nothing, so it is as safe to resend as a GET
+	resp, err := c.doRequest(ctx, "POST", path, payload, c.maxResponseBytes, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.OrderQuoteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+
+	return &response, nil
+}
+
 func (c *IStarClient) CreateStarOrderAsync(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.asyncOrder)
+	defer cancel()
+
 	path := "/orders/star"
-	payload, err := json.Marshal(req)
+	payload, err := json.Marshal(struct {
+		models.CreateStarOrderRequest
+		ClientOrderID string `json:"client_order_id,omitempty"`
+	}{req.ForIStar(), req.ClientOrderID})
 	if err != nil {
 		c.logger.Error("Failed to marshal request", zap.Error(err))
 		return nil, models.InternalServerError("Failed to marshal request")
@@ -65,18 +465,7 @@ func (c *IStarClient) CreateStarOrderAsync(ctx context.Context, req models.Creat
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusAccepted {
-		body, _ := io.ReadAll(resp.Body)
-		c.logger.Error("Unexpected status code", zap.Int("status", resp.StatusCode), zap.String("body", string(body)))
-		switch resp.StatusCode {
-		case http.StatusBadRequest:
-			return nil, models.ValidationError("Invalid request parameters")
-		case http.StatusUnauthorized:
-			return nil, models.UnauthorizedError("Invalid API key")
-		case http.StatusNotFound:
-			return nil, models.NotFoundError("Resource not found")
-		default:
-			return nil, models.InternalServerError(fmt.Sprintf("Unexpected status code: %d", resp.StatusCode))
-		}
+		return nil, c.errorFromResponse(resp)
 	}
 
 	var response models.StarOrderResponse
@@ -90,8 +479,14 @@ func (c *IStarClient) CreateStarOrderAsync(ctx context.Context, req models.Creat
 }
 
 func (c *IStarClient) CreateStarOrderSync(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.syncOrder)
+	defer cancel()
+
 	path := "/orders/star/sync"
-	payload, err := json.Marshal(req)
+	payload, err := json.Marshal(struct {
+		models.CreateStarOrderRequest
+		ClientOrderID string `json:"client_order_id,omitempty"`
+	}{req.ForIStar(), req.ClientOrderID})
 	if err != nil {
 		c.logger.Error("Failed to marshal request", zap.Error(err))
 		return nil, models.InternalServerError("Failed to marshal request")
@@ -104,18 +499,7 @@ func (c *IStarClient) CreateStarOrderSync(ctx context.Context, req models.Create
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		c.logger.Error("Unexpected status code", zap.Int("status", resp.StatusCode), zap.String("body", string(body)))
-		switch resp.StatusCode {
-		case http.StatusBadRequest:
-			return nil, models.ValidationError("Invalid request parameters")
-		case http.StatusUnauthorized:
-			return nil, models.UnauthorizedError("Invalid API key")
-		case http.StatusNotFound:
-			return nil, models.NotFoundError("Resource not found")
-		default:
-			return nil, models.InternalServerError(fmt.Sprintf("Unexpected status code: %d", resp.StatusCode))
-		}
+		return nil, c.errorFromResponse(resp)
 	}
 
 	var response models.StarOrderResponse
@@ -129,8 +513,14 @@ func (c *IStarClient) CreateStarOrderSync(ctx context.Context, req models.Create
 }
 
 func (c *IStarClient) CreatePremiumOrderAsync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.asyncOrder)
+	defer cancel()
+
 	path := "/orders/premium"
-	payload, err := json.Marshal(req)
+	payload, err := json.Marshal(struct {
+		models.CreatePremiumOrderRequest
+		ClientOrderID string `json:"client_order_id,omitempty"`
+	}{req.ForIStar(), req.ClientOrderID})
 	if err != nil {
 		c.logger.Error("Failed to marshal request", zap.Error(err))
 		return nil, models.InternalServerError("Failed to marshal request")
@@ -143,18 +533,7 @@ func (c *IStarClient) CreatePremiumOrderAsync(ctx context.Context, req models.Cr
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusAccepted {
-		body, _ := io.ReadAll(resp.Body)
-		c.logger.Error("Unexpected status code", zap.Int("status", resp.StatusCode), zap.String("body", string(body)))
-		switch resp.StatusCode {
-		case http.StatusBadRequest:
-			return nil, models.ValidationError("Invalid request parameters")
-		case http.StatusUnauthorized:
-			return nil, models.UnauthorizedError("Invalid API key")
-		case http.StatusNotFound:
-			return nil, models.NotFoundError("Resource not found")
-		default:
-			return nil, models.InternalServerError(fmt.Sprintf("Unexpected status code: %d", resp.StatusCode))
-		}
+		return nil, c.errorFromResponse(resp)
 	}
 
 	var response models.PremiumOrderResponse
@@ -168,8 +547,14 @@ func (c *IStarClient) CreatePremiumOrderAsync(ctx context.Context, req models.Cr
 }
 
 func (c *IStarClient) CreatePremiumOrderSync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.syncOrder)
+	defer cancel()
+
 	path := "/orders/premium/sync"
-	payload, err := json.Marshal(req)
+	payload, err := json.Marshal(struct {
+		models.CreatePremiumOrderRequest
+		ClientOrderID string `json:"client_order_id,omitempty"`
+	}{req.ForIStar(), req.ClientOrderID})
 	if err != nil {
 		c.logger.Error("Failed to marshal request", zap.Error(err))
 		return nil, models.InternalServerError("Failed to marshal request")
@@ -182,18 +567,7 @@ func (c *IStarClient) CreatePremiumOrderSync(ctx context.Context, req models.Cre
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		c.logger.Error("Unexpected status code", zap.Int("status", resp.StatusCode), zap.String("body", string(body)))
-		switch resp.StatusCode {
-		case http.StatusBadRequest:
-			return nil, models.ValidationError("Invalid request parameters")
-		case http.StatusUnauthorized:
-			return nil, models.UnauthorizedError("Invalid API key")
-		case http.StatusNotFound:
-			return nil, models.NotFoundError("Resource not found")
-		default:
-			return nil, models.InternalServerError(fmt.Sprintf("Unexpected status code: %d", resp.StatusCode))
-		}
+		return nil, c.errorFromResponse(resp)
 	}
 
 	var response models.PremiumOrderResponse
@@ -205,3 +579,231 @@ func (c *IStarClient) CreatePremiumOrderSync(ctx context.Context, req models.Cre
 	c.logger.Info("Premium order created (sync)", zap.String("order_id", response.OrderID))
 	return &response, nil
 }
+
+// GetOrder fetches an order's status from iStar. orderID is normally iStar's
+// id; for a sync create that timed out it is the client_order_id the create
+// carried, on the assumption that iStar resolves that id too.
+func (c *IStarClient) GetOrder(ctx context.Context, orderID string) (*models.OrderStatusResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	path := "/orders/" + url.PathEscape(orderID)
+
+	resp, err := c.DoRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.OrderStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+
+	c.logger.Debug("Order status fetched", zap.String("order_id", orderID), zap.String("status", response.Status))
+	return &response, nil
+}
+
+// GetPremiumPackages returns the premium packages iStar currently offers
+func (c *IStarClient) GetPremiumPackages(ctx context.Context) (*models.PremiumPackagesResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	resp, err := c.DoRequest(ctx, "GET", "/premium/packages", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.PremiumPackagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+	if response.Packages == nil {
+		response.Packages = []models.PremiumPackage{}
+	}
+
+	c.logger.Debug("Premium packages fetched", zap.Int("count", len(response.Packages)))
+	return &response, nil
+}
+
+// GetWalletBalance returns the partner wallet balance
+func (c *IStarClient) GetWalletBalance(ctx context.Context) (*models.WalletBalance, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	resp, err := c.DoRequest(ctx, "GET", "/wallet/balance", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.WalletBalance
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+
+	return &response, nil
+}
+
+// GetWalletTransactions returns one page of the wallet transaction history,
+// newest first. Given paging parameters, iStar answers with a page envelope
+// rather than the bare array StreamWalletTransactions reads.
+func (c *IStarClient) GetWalletTransactions(ctx context.Context, filter models.WalletTransactionFilter) (*models.WalletTransactionPage, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(filter.Limit))
+	if filter.Cursor != "" {
+		query.Set("cursor", filter.Cursor)
+	}
+	if filter.Type != "" {
+		query.Set("type", filter.Type)
+	}
+
+	resp, err := c.DoRequest(ctx, "GET", "/wallet/transactions?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.WalletTransactionPage
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+	if response.Transactions == nil {
+		response.Transactions = []models.WalletTransaction{}
+	}
+
+	return &response, nil
+}
+
+// StreamWalletTransactions streams the wallet transaction history, calling fn
+// for each transaction as it is decoded from the upstream response
+func (c *IStarClient) StreamWalletTransactions(ctx context.Context, fn func(models.WalletTransaction) error) error {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	// The history can be long and is decoded incrementally, so it is exempt
+	// from the response size cap
+	resp, err := c.doRequest(ctx, "GET", "/wallet/transactions", nil, 0, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return c.errorFromResponse(resp)
+	}
+
+	count := 0
+	var callbackErr error
+	err = streamJSONArray(resp.Body, func(tx models.WalletTransaction) error {
+		count++
+		callbackErr = fn(tx)
+		return callbackErr
+	})
+	if callbackErr != nil {
+		return callbackErr
+	}
+	if err != nil {
+		c.logger.Error("Failed to decode wallet transactions", zap.Error(err), zap.Int("decoded", count))
+		return models.InternalServerError("Failed to decode response")
+	}
+
+	c.logger.Debug("Wallet transactions streamed", zap.Int("count", count))
+	return nil
+}
+
+// GetRefundEligibility asks iStar whether an order can be refunded and for how much
+func (c *IStarClient) GetRefundEligibility(ctx context.Context, orderID string) (*models.RefundEligibilityResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	path := "/orders/" + url.PathEscape(orderID) + "/refund-eligibility"
+
+	resp, err := c.DoRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.RefundEligibilityResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+
+	return &response, nil
+}
+
+// RefundOrder asks iStar to refund a completed order
+func (c *IStarClient) RefundOrder(ctx context.Context, orderID string) (*models.RefundResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	path := "/orders/" + url.PathEscape(orderID) + "/refund"
+
+	resp, err := c.DoRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.errorFromResponse(resp)
+	}
+
+	var response models.RefundResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.logger.Error("Failed to decode response", zap.Error(err))
+		return nil, models.InternalServerError("Failed to decode response")
+	}
+
+	return &response, nil
+}
+
+// CancelOrder asks iStar to cancel an order that has not settled yet
+func (c *IStarClient) CancelOrder(ctx context.Context, orderID string) error {
+	ctx, cancel := withTimeout(ctx, c.timeouts.defaultTimeout)
+	defer cancel()
+
+	path := "/orders/" + url.PathEscape(orderID) + "/cancel"
+
+	resp, err := c.DoRequest(ctx, "POST", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return c.errorFromResponse(resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,639 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+	"github.com/hulupay/istar-api/pkg/version"
+	"go.uber.org/zap"
+)
+
+// testConfig returns a client configuration for srv with a breaker that
+// tolerates the failures a test provokes
+func testConfig(srv *httptest.Server) config.IStarConfig {
+	return config.IStarConfig{
+		APIKey:                  "test-key",
+		BaseURL:                 srv.URL,
+		Timeout:                 5 * time.Second,
+		BreakerFailureThreshold: 10,
+		BreakerResetTimeout:     time.Minute,
+	}
+}
+
+// newTestClientFromConfig builds a client from cfg, failing the test on error
+func newTestClientFromConfig(t *testing.T, cfg config.IStarConfig) *IStarClient {
+	t.Helper()
+	c, err := NewIStarClient(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewIStarClient: %v", err)
+	}
+	return c
+}
+
+// newTestClient returns a client for srv that retries up to maxRetries times
+func newTestClient(t *testing.T, srv *httptest.Server, maxRetries int) *IStarClient {
+	t.Helper()
+	cfg := testConfig(srv)
+	cfg.MaxRetries = maxRetries
+	return newTestClientFromConfig(t, cfg)
+}
+
+func TestCreateStarOrderAsyncIsSentOnce(t *testing.T) {
+	var mu sync.Mutex
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ClientOrderID string `json:"client_order_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		ids = append(ids, body.ClientOrderID)
+		mu.Unlock()
+
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := newTestClient(t, srv, 2)
+	_, err := c.CreateStarOrderAsync(context.Background(), models.CreateStarOrderRequest{
+		Username:      "alice_1",
+		RecipientHash: "hash",
+		Quantity:      50,
+		WalletType:    "ton",
+		ClientOrderID: "order-1",
+	})
+	if err == nil {
+		t.Fatal("CreateStarOrderAsync succeeded against a 503")
+	}
+
+	// iStar may have placed the order before answering 503, so resending
+	// the create could place it twice
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ids) != 1 || ids[0] != "order-1" {
+		t.Errorf("client_order_id per attempt = %q, want a single order-1", ids)
+	}
+}
+
+func TestErrorFromResponseMapsStatuses(t *testing.T) {
+	tests := []struct {
+		upstream int
+		want     int
+		code     string
+	}{
+		{http.StatusBadRequest, http.StatusBadRequest, models.CodeValidation},
+		{http.StatusUnauthorized, http.StatusUnauthorized, models.CodeUnauthorized},
+		{http.StatusNotFound, http.StatusNotFound, models.CodeNotFound},
+		{http.StatusConflict, http.StatusConflict, models.CodeConflict},
+		{http.StatusTooManyRequests, http.StatusTooManyRequests, models.CodeRateLimited},
+		{http.StatusForbidden, http.StatusInternalServerError, models.CodeInternal},
+		{http.StatusInternalServerError, http.StatusInternalServerError, models.CodeInternal},
+		{http.StatusBadGateway, http.StatusInternalServerError, models.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.upstream), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.upstream)
+			}))
+			defer srv.Close()
+
+			_, err := newTestClient(t, srv, 0).GetOrder(context.Background(), "istar-1")
+
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("GetOrder error = %v, want an APIError", err)
+			}
+			if apiErr.StatusCode != tt.want || apiErr.Code != tt.code {
+				t.Errorf("got %d %s, want %d %s", apiErr.StatusCode, apiErr.Code, tt.want, tt.code)
+			}
+		})
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	var gotMethod, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.EscapedPath()
+		io.WriteString(w, `{"order_id":"istar/1","type":"star","status":"completed","amount":1.5,"tx_hash":"tx-1","completed_at":"2026-01-02T03:04:05Z"}`)
+	}))
+	defer srv.Close()
+
+	got, err := newTestClient(t, srv, 0).GetOrder(context.Background(), "istar/1")
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if gotMethod != http.MethodGet || gotPath != "/orders/istar%2F1" {
+		t.Errorf("request = %s %s, want GET /orders/istar%%2F1", gotMethod, gotPath)
+	}
+	if got.OrderID != "istar/1" || got.Status != "completed" || got.Amount.String() != "1.5" || got.TxHash == nil || *got.TxHash != "tx-1" {
+		t.Errorf("GetOrder = %+v, want the decoded order", got)
+	}
+}
+
+func TestGetOrderRejectsMalformedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"order_id":`)
+	}))
+	defer srv.Close()
+
+	_, err := newTestClient(t, srv, 0).GetOrder(context.Background(), "istar-1")
+
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("GetOrder error = %v, want a 500 APIError", err)
+	}
+}
+
+func TestSlowUpstreamTripsSyncTimeoutOnly(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		if r.URL.Path == "/star/recipient/search" {
+			io.WriteString(w, `{"recipients":[]}`)
+			return
+		}
+		io.WriteString(w, `{"order_id":"istar-1","status":"completed"}`)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(srv)
+	cfg.SearchTimeout = 2 * time.Second
+	cfg.SyncOrderTimeout = 50 * time.Millisecond
+	c := newTestClientFromConfig(t, cfg)
+
+	if _, err := c.SearchStarRecipient(context.Background(), "alice_1", 50); err != nil {
+		t.Errorf("SearchStarRecipient: %v, want it to outlast the slow upstream", err)
+	}
+	_, err := c.CreateStarOrderSync(context.Background(), models.CreateStarOrderRequest{
+		Username: "alice_1", RecipientHash: "hash", Quantity: 50, WalletType: "ton",
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CreateStarOrderSync error = %v, want the sync timeout", err)
+	}
+	if errors.Is(err, ErrRequestNotSent) {
+		t.Error("a request that reached iStar was reported as not sent")
+	}
+}
+
+func TestOperationTimeoutsFallBackToDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	cfg := testConfig(srv)
+	cfg.Timeout = 3 * time.Second
+	cfg.SearchTimeout = time.Second
+	c := newTestClientFromConfig(t, cfg)
+
+	want := operationTimeouts{
+		defaultTimeout: 3 * time.Second,
+		search:         time.Second,
+		syncOrder:      3 * time.Second,
+		asyncOrder:     3 * time.Second,
+	}
+	if c.timeouts != want {
+		t.Errorf("timeouts = %+v, want %+v", c.timeouts, want)
+	}
+}
+
+func TestRequestIDIsForwardedToIStar(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Request-ID")
+		io.WriteString(w, `{"order_id":"istar-1","status":"pending"}`)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv, 0)
+
+	if _, err := c.GetOrder(requestctx.WithRequestID(context.Background(), "req-1"), "istar-1"); err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want req-1", got)
+	}
+
+	if _, err := c.GetOrder(context.Background(), "istar-1"); err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if got != "" {
+		t.Errorf("X-Request-ID = %q without a request id, want none", got)
+	}
+}
+
+func TestGetWalletBalance(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		io.WriteString(w, `{"wallet_type":"ton","currency":"TON","available":12.5,"pending":0.25,"updated_at":"2026-01-02T03:04:05Z"}`)
+	}))
+	defer srv.Close()
+
+	got, err := newTestClient(t, srv, 0).GetWalletBalance(context.Background())
+	if err != nil {
+		t.Fatalf("GetWalletBalance: %v", err)
+	}
+	if gotPath != "/wallet/balance" {
+		t.Errorf("path = %s, want /wallet/balance", gotPath)
+	}
+	if got.WalletType != "ton" || got.Currency != "TON" || got.Available.String() != "12.5" || got.Pending.String() != "0.25" {
+		t.Errorf("GetWalletBalance = %+v, want the decoded balance", got)
+	}
+}
+
+func TestGetWalletBalanceMapsErrors(t *testing.T) {
+	tests := []struct {
+		upstream int
+		code     string
+	}{
+		{http.StatusUnauthorized, models.CodeUnauthorized},
+		{http.StatusTooManyRequests, models.CodeRateLimited},
+		{http.StatusInternalServerError, models.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.upstream), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.upstream)
+			}))
+			defer srv.Close()
+
+			_, err := newTestClient(t, srv, 0).GetWalletBalance(context.Background())
+
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.code {
+				t.Errorf("GetWalletBalance error = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestGetPremiumPackages(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		io.WriteString(w, `{"packages":[
+			{"package_id":"premium-3m","months":3,"price":11.99,"currency":"USD","description":"Three months"},
+			{"package_id":"premium-6m","months":6,"price":15.99,"currency":"USD"},
+			{"package_id":"premium-12m","months":12,"price":28.99,"currency":"USD","description":"One year"}
+		]}`)
+	}))
+	defer srv.Close()
+
+	got, err := newTestClient(t, srv, 0).GetPremiumPackages(context.Background())
+	if err != nil {
+		t.Fatalf("GetPremiumPackages: %v", err)
+	}
+	if gotPath != "/premium/packages" {
+		t.Errorf("path = %s, want /premium/packages", gotPath)
+	}
+	if len(got.Packages) != 3 {
+		t.Fatalf("packages = %+v, want 3", got.Packages)
+	}
+	first, second := got.Packages[0], got.Packages[1]
+	if first.PackageID != "premium-3m" || first.Months != 3 || first.Price.String() != "11.99" || first.Currency != "USD" || first.Description != "Three months" {
+		t.Errorf("first package = %+v, want premium-3m for 11.99 USD", first)
+	}
+	if second.Months != 6 || second.Description != "" {
+		t.Errorf("second package = %+v, want six months without a description", second)
+	}
+}
+
+func TestGetPremiumPackagesEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantCode string
+	}{
+		{"no packages", http.StatusOK, `{}`, ""},
+		{"malformed body", http.StatusOK, `{"packages":[{"months":"three"}]}`, models.CodeInternal},
+		{"unauthorized", http.StatusUnauthorized, `{"error":"bad key"}`, models.CodeUnauthorized},
+		{"not found", http.StatusNotFound, `{"error":"no such route"}`, models.CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			got, err := newTestClient(t, srv, 0).GetPremiumPackages(context.Background())
+
+			if tt.wantCode == "" {
+				if err != nil || got.Packages == nil || len(got.Packages) != 0 {
+					t.Errorf("GetPremiumPackages = %+v, %v; want an empty, non-nil list", got, err)
+				}
+				return
+			}
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.wantCode {
+				t.Errorf("GetPremiumPackages error = %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCreateStarOrderSyncKeepsFailureReason(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"order_id":"istar-1","status":"failed","error":"Recipient cannot receive gifts","created_at":"2026-01-02T03:04:05Z"}`)
+	}))
+	defer srv.Close()
+
+	got, err := newTestClient(t, srv, 0).CreateStarOrderSync(context.Background(), models.CreateStarOrderRequest{
+		Username:      "alice_1",
+		RecipientHash: "hash",
+		Quantity:      50,
+		WalletType:    "ton",
+	})
+	if err != nil {
+		t.Fatalf("CreateStarOrderSync: %v", err)
+	}
+	if got.Status != "failed" || got.Error == nil || *got.Error != "Recipient cannot receive gifts" {
+		t.Errorf("CreateStarOrderSync = %+v, want the failure reason", got)
+	}
+}
+
+func TestSearchStarRecipientEncodesQuery(t *testing.T) {
+	var gotUsername, gotQuantity string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUsername, gotQuantity = r.URL.Query().Get("username"), r.URL.Query().Get("quantity")
+		io.WriteString(w, `{"recipients":[]}`)
+	}))
+	defer srv.Close()
+
+	if _, err := newTestClient(t, srv, 0).SearchStarRecipient(context.Background(), "a&quantity=1 b", 50); err != nil {
+		t.Fatalf("SearchStarRecipient: %v", err)
+	}
+	if gotUsername != "a&quantity=1 b" || gotQuantity != "50" {
+		t.Errorf("iStar saw username=%q quantity=%q, want the username intact and quantity 50", gotUsername, gotQuantity)
+	}
+}
+
+func TestQuotePremiumOrder(t *testing.T) {
+	var gotPath string
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		io.WriteString(w, `{"quote_id":"quote-1","amount":3.25,"wallet_type":"ton","expires_at":"2026-01-02T03:04:05Z"}`)
+	}))
+	defer srv.Close()
+
+	got, err := newTestClient(t, srv, 0).QuotePremiumOrder(context.Background(), models.CreatePremiumOrderRequest{
+		Username:      "alice_1",
+		RecipientHash: "hash",
+		Months:        3,
+		WalletType:    "ton",
+	})
+	if err != nil {
+		t.Fatalf("QuotePremiumOrder: %v", err)
+	}
+	if gotPath != "/orders/premium/quote" || gotBody["months"] != float64(3) {
+		t.Errorf("request = %s %v, want the premium order posted to /orders/premium/quote", gotPath, gotBody)
+	}
+	if got.QuoteID != "quote-1" || got.Amount.String() != "3.25" || got.ExpiresAt != "2026-01-02T03:04:05Z" {
+		t.Errorf("QuotePremiumOrder = %+v, want the decoded quote", got)
+	}
+}
+
+func TestDefaultHeadersAreSent(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		io.WriteString(w, `{"order_id":"istar-1","status":"pending"}`)
+	}))
+	defer srv.Close()
+	cfg := testConfig(srv)
+	cfg.DefaultHeaders = map[string]string{
+		"X-Partner":    "hulupay",
+		"Content-Type": "text/plain",
+		"API-Key":      "default-key",
+	}
+	ctx := requestctx.WithRequestID(context.Background(), "req-1")
+
+	if _, err := newTestClientFromConfig(t, cfg).GetOrder(ctx, "istar-1"); err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+
+	if ua := got.Get("User-Agent"); ua != version.UserAgent() || !strings.HasPrefix(ua, "istar-api/") {
+		t.Errorf("User-Agent = %q, want %q", ua, version.UserAgent())
+	}
+	if got.Get("X-Partner") != "hulupay" {
+		t.Errorf("X-Partner = %q, want the configured default", got.Get("X-Partner"))
+	}
+	if got.Get("Content-Type") != "application/json" || got.Get("API-Key") != "test-key" {
+		t.Errorf("Content-Type = %q, API-Key = %q, want the request's own values over the defaults",
+			got.Get("Content-Type"), got.Get("API-Key"))
+	}
+	if got.Get("X-Request-ID") != "req-1" {
+		t.Errorf("X-Request-ID = %q, want req-1", got.Get("X-Request-ID"))
+	}
+}
+
+func TestCancelledContextAbortsOutboundCall(t *testing.T) {
+	aborted := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := newTestClient(t, srv, 2).GetOrder(ctx, "istar-1")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetOrder error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetOrder took %v, want it to return on cancellation", elapsed)
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Error("iStar never saw the request aborted")
+	}
+}
+
+func TestCancelledContextIsNotSent(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := newTestClient(t, srv, 2).GetOrder(ctx, "istar-1")
+
+	if !errors.Is(err, ErrRequestNotSent) || !errors.Is(err, context.Canceled) {
+		t.Errorf("GetOrder error = %v, want ErrRequestNotSent wrapping context.Canceled", err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("iStar calls = %d, want 0", n)
+	}
+}
+
+func TestGetWalletTransactions(t *testing.T) {
+	var gotPath string
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.Query()
+		io.WriteString(w, `{
+			"transactions": [
+				{"id":"tx-1","type":"debit","amount":1.25,"currency":"TON","wallet_type":"ton","order_id":"istar-9","tx_hash":"0xabc","description":"Star gift","created_at":"2026-01-02T03:04:05Z"},
+				{"id":"tx-2","type":"credit","amount":"100","currency":"TON","wallet_type":"ton","created_at":"2026-01-01T00:00:00Z"}
+			],
+			"next_cursor": "page-3"
+		}`)
+	}))
+	defer srv.Close()
+
+	page, err := newTestClient(t, srv, 0).GetWalletTransactions(context.Background(), models.WalletTransactionFilter{Limit: 2, Cursor: "page-2", Type: "debit"})
+	if err != nil {
+		t.Fatalf("GetWalletTransactions: %v", err)
+	}
+
+	if gotPath != "/wallet/transactions" || gotQuery.Get("limit") != "2" || gotQuery.Get("cursor") != "page-2" || gotQuery.Get("type") != "debit" {
+		t.Errorf("request = %s?%s, want the filter forwarded to /wallet/transactions", gotPath, gotQuery.Encode())
+	}
+	if page.NextCursor != "page-3" || len(page.Transactions) != 2 {
+		t.Fatalf("page = %+v, want two transactions and next cursor page-3", page)
+	}
+	first := page.Transactions[0]
+	if first.ID != "tx-1" || first.Type != "debit" || first.Amount.String() != "1.25" || first.WalletType != "ton" ||
+		first.OrderID == nil || *first.OrderID != "istar-9" || first.TxHash == nil || *first.TxHash != "0xabc" || first.CreatedAt != "2026-01-02T03:04:05Z" {
+		t.Errorf("first transaction = %+v, want every field decoded", first)
+	}
+	if second := page.Transactions[1]; second.Amount.String() != "100" || second.OrderID != nil || second.TxHash != nil {
+		t.Errorf("second transaction = %+v, want a string amount decoded and no order or hash", second)
+	}
+}
+
+func TestGetWalletTransactionsEdgeCases(t *testing.T) {
+	tests := []struct {
+		name, body string
+		status     int
+		wantCode   string
+	}{
+		{"last page without transactions", `{}`, http.StatusOK, ""},
+		{"malformed page", `{"transactions":{}}`, http.StatusOK, models.CodeInternal},
+		{"unauthorized", ``, http.StatusUnauthorized, models.CodeUnauthorized},
+		{"rate limited", ``, http.StatusTooManyRequests, models.CodeRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			page, err := newTestClient(t, srv, 0).GetWalletTransactions(context.Background(), models.WalletTransactionFilter{Limit: 50})
+
+			if tt.wantCode == "" {
+				if err != nil || page.Transactions == nil || len(page.Transactions) != 0 || page.NextCursor != "" {
+					t.Errorf("GetWalletTransactions = %+v, %v; want an empty, non-nil list", page, err)
+				}
+				return
+			}
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.wantCode {
+				t.Errorf("GetWalletTransactions error = %v, want %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSearchPremiumRecipient(t *testing.T) {
+	var gotPath string
+	var gotQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.Query()
+		io.WriteString(w, `{"username":"alice_1","months":6,"recipients":[{"recipient_hash":"hash-alice","username":"alice_1","name":"Alice","photo":"https://t.me/a.jpg"}]}`)
+	}))
+	defer srv.Close()
+
+	got, err := newTestClient(t, srv, 0).SearchPremiumRecipient(context.Background(), "alice_1&months=12", 6)
+	if err != nil {
+		t.Fatalf("SearchPremiumRecipient: %v", err)
+	}
+
+	if gotPath != "/premium/recipient/search" || gotQuery.Get("username") != "alice_1&months=12" || gotQuery.Get("months") != "6" {
+		t.Errorf("request = %s?%s, want the username escaped and months 6", gotPath, gotQuery.Encode())
+	}
+	want := models.Recipient{RecipientHash: "hash-alice", Username: "alice_1", Name: "Alice", Photo: "https://t.me/a.jpg"}
+	if got.Username != "alice_1" || got.Months != 6 || len(got.Recipients) != 1 || got.Recipients[0] != want {
+		t.Errorf("SearchPremiumRecipient = %+v, want the decoded recipient", got)
+	}
+}
+
+func TestSearchPremiumRecipientMapsErrors(t *testing.T) {
+	tests := []struct {
+		name, body string
+		status     int
+		code       string
+	}{
+		{"malformed body", `{"recipients":"alice"}`, http.StatusOK, models.CodeInternal},
+		{"bad request", `{"error":"months not offered"}`, http.StatusBadRequest, models.CodeValidation},
+		{"rate limited", ``, http.StatusTooManyRequests, models.CodeRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			_, err := newTestClient(t, srv, 0).SearchPremiumRecipient(context.Background(), "alice_1", 3)
+
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.code {
+				t.Errorf("SearchPremiumRecipient error = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestOrderMetadataIsNotSentToIStar(t *testing.T) {
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `{"order_id":"istar-1","status":"pending","quantity":50,"amount":1,"created_at":"2026-01-02T03:04:05Z"}`)
+	}))
+	defer srv.Close()
+
+	req := models.CreateStarOrderRequest{Username: "alice_1", RecipientHash: "h", Quantity: 50, WalletType: "ton", Metadata: map[string]string{"invoice_id": "INV-1"}}
+	if _, err := newTestClient(t, srv, 0).CreateStarOrderAsync(context.Background(), req); err != nil {
+		t.Fatalf("CreateStarOrderAsync: %v", err)
+	}
+
+	if _, sent := body["metadata"]; sent || body["username"] != "alice_1" {
+		t.Errorf("iStar received %v, want the order without metadata", body)
+	}
+	if req.Metadata["invoice_id"] != "INV-1" {
+		t.Error("the caller's metadata was cleared")
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/hulupay/istar-api/config"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// retryBaseDelay is the wait before the first retry; it doubles per attempt
+	retryBaseDelay = 200 * time.Millisecond
+	// retryMaxDelay caps the wait between two attempts
+	retryMaxDelay = 5 * time.Second
+)
+
+// RetryFunc reports whether a request should be sent again. resp is nil when
+// err is set; attempt is zero for the first try. Replace IStarClient.ShouldRetry
+// with a RetryFunc, or set IStarConfig.ShouldRetry or RetryStatuses, to change
+// which failures are retried, e.g.
+//
+//	istarClient.ShouldRetry = func(resp *http.Response, err error, attempt int) bool {
+//		return err == nil && resp.StatusCode == http.StatusInternalServerError
+//	}
+type RetryFunc func(resp *http.Response, err error, attempt int) bool
+
+// defaultRetryStatuses are the responses that signal a transient upstream
+// condition. Other failures, such as 400, 401, 404 or a 501 for an operation
+// iStar does not support, will not change on a second try.
+var defaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultShouldRetry retries network and timeout errors and the 429, 502, 503
+// and 504 responses that signal a transient upstream condition. A request
+// cancelled by its caller is never retried. It judges only the outcome:
+// DoRequest does not consult it for an order create, cancel or refund that may
+// have reached iStar, since iStar is not known to deduplicate those.
+func DefaultShouldRetry(resp *http.Response, err error, attempt int) bool {
+	return defaultRetryFunc(resp, err, attempt)
+}
+
+// defaultRetryFunc is built once so DefaultShouldRetry does not rebuild its status set
+var defaultRetryFunc = RetryOnStatuses(defaultRetryStatuses...)
+
+// RetryOnStatuses returns a RetryFunc that retries network and timeout errors
+// and responses with one of statuses. A request cancelled by its caller is
+// never retried.
+func RetryOnStatuses(statuses ...int) RetryFunc {
+	retryable := make(map[int]bool, len(statuses))
+	for _, status := range statuses {
+		retryable[status] = true
+	}
+	return func(resp *http.Response, err error, attempt int) bool {
+		if err != nil {
+			return !errors.Is(err, context.Canceled)
+		}
+		return retryable[resp.StatusCode]
+	}
+}
+
+// retryClassifier picks the RetryFunc for cfg: its ShouldRetry when set, else
+// one retrying its RetryStatuses, else DefaultShouldRetry
+func retryClassifier(cfg config.IStarConfig) RetryFunc {
+	switch {
+	case cfg.ShouldRetry != nil:
+		return cfg.ShouldRetry
+	case len(cfg.RetryStatuses) > 0:
+		return RetryOnStatuses(cfg.RetryStatuses...)
+	default:
+		return DefaultShouldRetry
+	}
+}
+
+// idempotentMethod reports whether sending a request with method twice has the
+// same effect as sending it once
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryBackoff returns the exponential delay before retrying after attempt
+func retryBackoff(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay <= 0 || delay > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return delay
+}
+
+// retryDelay is how long to wait before retrying after attempt. A Retry-After
+// header on resp, in delta-seconds or HTTP-date form, wins over exponential
+// backoff and is capped at maxRetryAfter.
+func retryDelay(resp *http.Response, attempt int, maxRetryAfter time.Duration, now time.Time) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			if maxRetryAfter > 0 && d > maxRetryAfter {
+				return maxRetryAfter
+			}
+			return d
+		}
+	}
+	return retryBackoff(attempt)
+}
+
+// parseRetryAfter reads a Retry-After value. Dates in the past mean no wait;
+// unparseable values report false so the caller falls back to backoff.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// exceedsDeadline reports whether waiting delay would run past ctx's deadline
+func exceedsDeadline(ctx context.Context, delay time.Duration, now time.Time) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && now.Add(delay).After(deadline)
+}
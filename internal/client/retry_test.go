@@ -0,0 +1,322 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/config"
+)
+
+func TestDefaultShouldRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		err  error
+		want bool
+	}{
+		{"network error", nil, errors.New("connection reset"), true},
+		{"timeout", nil, fmt.Errorf("sending request failed: %w", context.DeadlineExceeded), true},
+		{"cancelled by caller", nil, fmt.Errorf("sending request failed: %w", context.Canceled), false},
+		{"429", &http.Response{StatusCode: http.StatusTooManyRequests}, nil, true},
+		{"502", &http.Response{StatusCode: http.StatusBadGateway}, nil, true},
+		{"503", &http.Response{StatusCode: http.StatusServiceUnavailable}, nil, true},
+		{"504", &http.Response{StatusCode: http.StatusGatewayTimeout}, nil, true},
+		{"500", &http.Response{StatusCode: http.StatusInternalServerError}, nil, false},
+		{"501", &http.Response{StatusCode: http.StatusNotImplemented}, nil, false},
+		{"400", &http.Response{StatusCode: http.StatusBadRequest}, nil, false},
+		{"401", &http.Response{StatusCode: http.StatusUnauthorized}, nil, false},
+		{"404", &http.Response{StatusCode: http.StatusNotFound}, nil, false},
+		{"200", &http.Response{StatusCode: http.StatusOK}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultShouldRetry(tt.resp, tt.err, 0); got != tt.want {
+				t.Errorf("DefaultShouldRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryClassifierPrecedence(t *testing.T) {
+	status500 := &http.Response{StatusCode: http.StatusInternalServerError}
+	status502 := &http.Response{StatusCode: http.StatusBadGateway}
+
+	byStatus := retryClassifier(config.IStarConfig{RetryStatuses: []int{http.StatusInternalServerError}})
+	if !byStatus(status500, nil, 0) || byStatus(status502, nil, 0) {
+		t.Error("RetryStatuses should retry exactly the listed statuses")
+	}
+
+	never := func(*http.Response, error, int) bool { return false }
+	custom := retryClassifier(config.IStarConfig{ShouldRetry: never, RetryStatuses: []int{http.StatusInternalServerError}})
+	if custom(status500, nil, 0) {
+		t.Error("ShouldRetry should win over RetryStatuses")
+	}
+}
+
+func TestConfiguredClassifierDecidesAttempts(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		cfg    func(*config.IStarConfig)
+		want   int32
+	}{
+		{"default retries 503", http.StatusServiceUnavailable, func(*config.IStarConfig) {}, 3},
+		{"default gives up on 501", http.StatusNotImplemented, func(*config.IStarConfig) {}, 1},
+		{"listed status is retried", http.StatusInternalServerError, func(cfg *config.IStarConfig) {
+			cfg.RetryStatuses = []int{http.StatusInternalServerError}
+		}, 3},
+		{"unlisted status is not", http.StatusServiceUnavailable, func(cfg *config.IStarConfig) {
+			cfg.RetryStatuses = []int{http.StatusInternalServerError}
+		}, 1},
+		{"override never retries", http.StatusServiceUnavailable, func(cfg *config.IStarConfig) {
+			cfg.ShouldRetry = func(*http.Response, error, int) bool { return false }
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				// Retry straight away so the test does not sit through backoff
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			cfg := testConfig(srv)
+			cfg.MaxRetries = 2
+			tt.cfg(&cfg)
+			c := newTestClientFromConfig(t, cfg)
+
+			resp, err := c.DoRequest(context.Background(), http.MethodGet, "/orders/istar-1", nil)
+			if err != nil {
+				t.Fatalf("DoRequest: %v", err)
+			}
+			resp.Body.Close()
+
+			if n := calls.Load(); n != tt.want {
+				t.Errorf("iStar saw %d requests, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetriedPostResendsBodyIntact(t *testing.T) {
+	payload := []byte(`{"username":"alice_1","quantity":50}`)
+	var (
+		mu     sync.Mutex
+		bodies []string
+		sizes  []int64
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		sizes = append(sizes, r.ContentLength)
+		first := len(bodies) == 1
+		mu.Unlock()
+		switch {
+		case first:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/orders/star/quote":
+			// net/http replays the body itself on a 307
+			http.Redirect(w, r, "/orders/star/quote/v2", http.StatusTemporaryRedirect)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv, 2)
+
+	// A quote is the one POST the client resends
+	resp, err := c.doRequest(context.Background(), http.MethodPost, "/orders/star/quote", payload, 0, true)
+	if err != nil {
+		t.Fatalf("doRequest: %v", err)
+	}
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 3 {
+		t.Fatalf("iStar saw %d requests, want the first attempt, the retry and the redirect", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != string(payload) || sizes[i] != int64(len(payload)) {
+			t.Errorf("request %d sent %q (Content-Length %d), want %q (%d)", i+1, body, sizes[i], payload, len(payload))
+		}
+	}
+}
+
+func TestOnlyResendableRequestsAreRetried(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int32
+	}{
+		{"lookup", http.MethodGet, "/orders/istar-1", 3},
+		{"order create", http.MethodPost, "/orders/star", 1},
+		{"refund", http.MethodPost, "/orders/istar-1/refund", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer srv.Close()
+			c := newTestClient(t, srv, 2)
+
+			resp, err := c.DoRequest(context.Background(), tt.method, tt.path, nil)
+			if err != nil {
+				t.Fatalf("DoRequest: %v", err)
+			}
+			resp.Body.Close()
+
+			if n := calls.Load(); n != tt.want {
+				t.Errorf("iStar saw %d requests, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	withHeader := func(v string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{v}}}
+	}
+
+	tests := []struct {
+		name string
+		resp *http.Response
+		want time.Duration
+	}{
+		{"backoff without response", nil, retryBaseDelay << 2},
+		{"delta seconds", withHeader("3"), 3 * time.Second},
+		{"http date", withHeader(now.Add(7 * time.Second).Format(http.TimeFormat)), 7 * time.Second},
+		{"date in the past", withHeader(now.Add(-time.Minute).Format(http.TimeFormat)), 0},
+		{"capped", withHeader("120"), 30 * time.Second},
+		{"unparseable", withHeader("soon"), retryBaseDelay << 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryDelay(tt.resp, 2, 30*time.Second, now); got != tt.want {
+				t.Errorf("retryDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// retryAfterServer answers its first request 429 with the Retry-After value
+// retryAfter returns, then 200, and records when each request arrived
+func retryAfterServer(t *testing.T, retryAfter func() string) (*httptest.Server, func() []time.Time) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		first := len(arrivals) == 1
+		mu.Unlock()
+		if first {
+			w.Header().Set("Retry-After", retryAfter())
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return arrivals
+	}
+}
+
+func TestRetryWaitsForRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter func() string
+		minWait    time.Duration
+	}{
+		{"delta seconds", func() string { return "2" }, 2 * time.Second},
+		// HTTP dates have whole-second precision, so the wait may be a little short
+		{"http date", func() string { return time.Now().Add(3 * time.Second).UTC().Format(http.TimeFormat) }, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			srv, arrivals := retryAfterServer(t, tt.retryAfter)
+			c := newTestClient(t, srv, 1)
+
+			resp, err := c.DoRequest(context.Background(), http.MethodGet, "/orders/istar-1", nil)
+			if err != nil {
+				t.Fatalf("DoRequest: %v", err)
+			}
+			resp.Body.Close()
+
+			got := arrivals()
+			if resp.StatusCode != http.StatusOK || len(got) != 2 {
+				t.Fatalf("status = %d after %d requests, want 200 after a retry", resp.StatusCode, len(got))
+			}
+			if wait := got[1].Sub(got[0]); wait < tt.minWait-100*time.Millisecond {
+				t.Errorf("retried after %v, want about %v as Retry-After asked", wait, tt.minWait)
+			}
+		})
+	}
+}
+
+func TestRetryAfterIsCappedByConfig(t *testing.T) {
+	srv, arrivals := retryAfterServer(t, func() string { return "3600" })
+	cfg := testConfig(srv)
+	cfg.MaxRetries = 1
+	cfg.MaxRetryAfter = 50 * time.Millisecond
+	c := newTestClientFromConfig(t, cfg)
+
+	start := time.Now()
+	resp, err := c.DoRequest(context.Background(), http.MethodGet, "/orders/istar-1", nil)
+	if err != nil {
+		t.Fatalf("DoRequest: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); resp.StatusCode != http.StatusOK || len(arrivals()) != 2 || elapsed > 2*time.Second {
+		t.Errorf("status = %d after %d requests in %v, want 200 after a capped wait", resp.StatusCode, len(arrivals()), elapsed)
+	}
+}
+
+func TestRetryAfterPastDeadlineIsNotAwaited(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv, 3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.DoRequest(ctx, http.MethodGet, "/orders/istar-1", nil)
+	if err != nil {
+		t.Fatalf("DoRequest: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests || calls.Load() != 1 {
+		t.Errorf("status = %d after %d requests, want the 429 back without a retry", resp.StatusCode, calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("DoRequest took %v, want it to return without waiting", elapsed)
+	}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignRequest(t *testing.T) {
+	body := []byte(`{"quantity":50}`)
+	req := httptest.NewRequest(http.MethodPost, "https://api.example.com/v1/orders/star?dry_run=1", strings.NewReader(string(body)))
+
+	signRequest(req, "signing-secret", body, time.Unix(1767323045, 0))
+
+	if got := req.Header.Get("X-Timestamp"); got != "1767323045" {
+		t.Errorf("X-Timestamp = %q, want 1767323045", got)
+	}
+	// HMAC-SHA256 of "POST\n/v1/orders/star?dry_run=1\n1767323045\n{"quantity":50}"
+	want := "sha256=37f772e1a8710a4b0b7b7f74f51ed69ae75d0fb281bf32a1614ec0273c71a8ec"
+	if got := req.Header.Get("X-Signature"); got != want {
+		t.Errorf("X-Signature = %q, want %q", got, want)
+	}
+}
+
+func TestClientSignsRequestsWhenConfigured(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		signed bool
+	}{
+		{"with a secret", "signing-secret", true},
+		{"without a secret", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var signature, timestamp, wantSignature string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				signature, timestamp = r.Header.Get("X-Signature"), r.Header.Get("X-Timestamp")
+				mac := hmac.New(sha256.New, []byte(tt.secret))
+				mac.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + timestamp + "\n"))
+				mac.Write(body)
+				wantSignature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+				io.WriteString(w, `{"order_id":"istar-1","status":"pending","created_at":"2026-01-02T03:04:05Z"}`)
+			}))
+			defer srv.Close()
+			cfg := testConfig(srv)
+			cfg.SigningSecret = tt.secret
+
+			if _, err := newTestClientFromConfig(t, cfg).DoRequest(context.Background(), http.MethodPost, "/orders/star", []byte(`{"quantity":50}`)); err != nil {
+				t.Fatalf("DoRequest: %v", err)
+			}
+
+			if !tt.signed {
+				if signature != "" || timestamp != "" {
+					t.Errorf("headers = %q, %q, want none without a secret", signature, timestamp)
+				}
+				return
+			}
+			if signature != wantSignature {
+				t.Errorf("X-Signature = %q, want %q", signature, wantSignature)
+			}
+			if sent, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(sent, 0)).Abs() > time.Minute {
+				t.Errorf("X-Timestamp = %q, want the send time in Unix seconds", timestamp)
+			}
+		})
+	}
+}
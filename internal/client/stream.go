@@ -0,0 +1,37 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// streamJSONArray decodes a top-level JSON array from r one element at a time,
+// invoking fn for each, so large upstream lists are never held in memory at once.
+// Returning an error from fn stops the stream and is returned as-is.
+func streamJSONArray[T any](r io.Reader, fn func(T) error) error {
+	dec := json.NewDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("reading array start: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected JSON array, got %v", tok)
+	}
+
+	for dec.More() {
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			return fmt.Errorf("decoding array element: %w", err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("reading array end: %w", err)
+	}
+	return nil
+}
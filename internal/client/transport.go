@@ -0,0 +1,74 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Connection pool defaults used when the corresponding config field is zero
+const (
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 20
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// ErrInvalidProxyURL is returned by NewIStarClient when IStarConfig.ProxyURL is
+// set but is not an absolute http, https or socks5 URL
+var ErrInvalidProxyURL = errors.New("invalid iStar proxy URL")
+
+// proxyFunc returns the transport's Proxy setting. A configured ProxyURL is
+// used for every iStar request, with credentials from its user info sent as
+// Proxy-Authorization; otherwise HTTPS_PROXY, HTTP_PROXY and NO_PROXY apply.
+func proxyFunc(raw string) (func(*http.Request) (*url.URL, error), error) {
+	if strings.TrimSpace(raw) == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	// Errors leave the URL out: it may carry a password
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot be parsed", ErrInvalidProxyURL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" {
+		return nil, fmt.Errorf("%w: must use http, https or socks5", ErrInvalidProxyURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: has no host", ErrInvalidProxyURL)
+	}
+	return http.ProxyURL(u), nil
+}
+
+// newTransport builds the pooled transport shared by every iStar call, traced
+// so each attempt is a client span under the caller's span.
+func newTransport(cfg config.IStarConfig, proxy func(*http.Request) (*url.URL, error)) http.RoundTripper {
+	return otelhttp.NewTransport(newPooledTransport(cfg, proxy),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return "iStar " + r.Method + " " + metrics.PathLabel(r.URL.Path)
+		}))
+}
+
+// newPooledTransport builds the connection pool behind newTransport.
+// MaxConnsPerHost has no default: zero leaves connections to iStar unbounded.
+func newPooledTransport(cfg config.IStarConfig, proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.MaxIdleConns = orDefaultInt(cfg.MaxIdleConns, defaultMaxIdleConns)
+	transport.MaxIdleConnsPerHost = orDefaultInt(cfg.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost)
+	transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	transport.IdleConnTimeout = orDefault(cfg.IdleConnTimeout, defaultIdleConnTimeout)
+	transport.ForceAttemptHTTP2 = true
+	return transport
+}
+
+func orDefaultInt(n, def int) int {
+	if n > 0 {
+		return n
+	}
+	return def
+}
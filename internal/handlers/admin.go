@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/services"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSignPreviewBodySize caps the body accepted by the signing preview endpoint
+const maxSignPreviewBodySize = 1 << 20
+
+// adminActorHeader names the operator performing an admin action, for auditing
+const adminActorHeader = "X-Admin-Actor"
+
+// AdminHandler handles operator-only endpoints
+type AdminHandler struct {
+	orderService          services.OrderService
+	reconciliationService services.ReconciliationService
+	webhookService        services.WebhookService
+	webhookSecret         string
+	slowRequests          *middleware.SlowRequestLog
+	logger                *zap.Logger
+}
+
+// NewAdminHandler initializes a new AdminHandler
+func NewAdminHandler(orderService services.OrderService, reconciliationService services.ReconciliationService, webhookService services.WebhookService, webhookSecret string, slowRequests *middleware.SlowRequestLog, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		orderService:          orderService,
+		reconciliationService: reconciliationService,
+		webhookService:        webhookService,
+		webhookSecret:         webhookSecret,
+		slowRequests:          slowRequests,
+		logger:                logger.Named("admin_handler"),
+	}
+}
+
+// SignWebhookPreviewHandler godoc
+// @Summary      Preview a webhook signature
+// @Description  Returns the X-iStar-Signature value we would expect for the raw request body, so integrators can check their HMAC offline. With a timestamp, the signature covers "<timestamp>.<body>" as for X-iStar-Timestamp deliveries.
+// @Tags         admin
+// @Accept       octet-stream
+// @Produce      json
+// @Param        body       body      string  true   "Raw webhook body"
+// @Param        timestamp  query     int     false  "X-iStar-Timestamp value (Unix seconds) to sign with"
+// @Success      200   {object}  map[string]interface{}
+// @Failure      400   {object}  models.ErrorResponse
+// @Failure      401   {object}  models.ErrorResponse
+// @Failure      429   {object}  models.ErrorResponse
+// @Router       /admin/webhooks/sign [post]
+func (h *AdminHandler) SignWebhookPreviewHandler(c *gin.Context) {
+	if h.webhookSecret == "" {
+		h.logger.Error("Webhook secret is not configured")
+		c.Error(models.InternalServerError("Webhook secret is not configured"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignPreviewBodySize))
+	if err != nil {
+		h.logger.Error("Failed to read body to sign", zap.Error(err))
+		c.Error(models.ValidationError("Failed to read request body"))
+		return
+	}
+
+	timestamp := c.Query("timestamp")
+	if timestamp == "" {
+		h.logger.Info("Webhook signature preview computed", zap.Int("body_size", len(body)))
+		c.JSON(http.StatusOK, gin.H{
+			"header":    "X-iStar-Signature",
+			"signature": computeWebhookSignature(h.webhookSecret, body),
+		})
+		return
+	}
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		c.Error(models.ValidationError("timestamp must be Unix seconds"))
+		return
+	}
+
+	h.logger.Info("Webhook signature preview computed", zap.Int("body_size", len(body)), zap.String("timestamp", timestamp))
+	c.JSON(http.StatusOK, gin.H{
+		"header":           "X-iStar-Signature",
+		"signature":        computeWebhookSignature(h.webhookSecret, timestampedWebhookBody(timestamp, body)),
+		"timestamp_header": webhookTimestampHeader,
+		"timestamp":        timestamp,
+	})
+}
+
+// ForceFailOrderHandler godoc
+// @Summary      Force-fail a stuck order
+// @Description  Moves a pending order to failed with the given reason and records an audit event. Completed orders are rejected.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                        true   "Order ID"
+// @Param        request  body      models.ForceFailOrderRequest  true   "Failure reason"
+// @Param        X-Admin-Actor  header  string                   false  "Operator performing the action"
+// @Success      200      {object}  models.Order
+// @Failure      400      {object}  models.ErrorResponse
+// @Failure      404      {object}  models.ErrorResponse
+// @Router       /admin/orders/{id}/fail [post]
+func (h *AdminHandler) ForceFailOrderHandler(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ForceFailOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid request body", zap.Error(err))
+		c.Error(models.ValidationError("Invalid request body: " + err.Error()))
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.Error(models.ValidationError("reason is required"))
+		return
+	}
+
+	order, err := h.orderService.ForceFailOrder(c.Request.Context(), orderID, reason, adminActor(c))
+	if err != nil {
+		h.logger.Error("Failed to force-fail order", zap.Error(err), zap.String("order_id", orderID))
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// UpdateOrderHandler godoc
+// @Summary      Correct an order
+// @Description  Updates any of status, tx_hash, error_message and completed_at on an order; omitted fields are unchanged. Status changes must be legal transitions (e.g. completed cannot go back to pending) and refunds must use the refund endpoint. Every change is recorded in the audit log.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                          true   "Order ID"
+// @Param        request  body      models.AdminUpdateOrderRequest  true   "Fields to change"
+// @Param        X-Admin-Actor  header  string                     false  "Operator performing the action"
+// @Success      200      {object}  models.Order
+// @Failure      400      {object}  models.ErrorResponse
+// @Failure      404      {object}  models.ErrorResponse
+// @Router       /admin/orders/{id} [patch]
+func (h *AdminHandler) UpdateOrderHandler(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	var req models.AdminUpdateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid request body", zap.Error(err))
+		c.Error(bindingError(err))
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	order, err := h.orderService.AdminUpdateOrder(c.Request.Context(), orderID, req, adminActor(c))
+	if err != nil {
+		h.logger.Error("Failed to correct order", zap.Error(err), zap.String("order_id", orderID))
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// ReconcilePendingOrdersHandler godoc
+// @Summary      Reconcile stuck pending orders
+// @Description  Fetches every order pending for longer than older_than from iStar and applies its current status. Orders already being reconciled elsewhere are skipped.
+// @Tags         admin
+// @Produce      json
+// @Param        older_than  query     string  false  "Minimum pending age as a Go duration (default 15m)"
+// @Success      200         {object}  models.ReconcilePendingResponse
+// @Failure      400         {object}  models.ErrorResponse
+// @Failure      401         {object}  models.ErrorResponse
+// @Router       /admin/orders/reconcile [post]
+func (h *AdminHandler) ReconcilePendingOrdersHandler(c *gin.Context) {
+	olderThan := 15 * time.Minute
+	if raw := c.Query("older_than"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			c.Error(models.ValidationError("older_than must be a non-negative duration such as 15m"))
+			return
+		}
+		olderThan = d
+	}
+
+	reconciled, failed, err := h.orderService.ReconcilePending(c.Request.Context(), olderThan)
+	if err != nil {
+		h.logger.Error("Failed to reconcile pending orders", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	h.logger.Info("Pending orders reconciled by admin",
+		zap.String("actor", adminActor(c)),
+		zap.Int("reconciled", reconciled),
+		zap.Int("failed", failed))
+	c.JSON(http.StatusOK, models.ReconcilePendingResponse{Reconciled: reconciled, Failed: failed})
+}
+
+// ReconciliationReportHandler godoc
+// @Summary      Report drift between local and upstream orders
+// @Description  Samples orders created in [from, to), fetches their iStar status and lists mismatches. Read-only. Defaults to the last 24 hours; the window may not exceed 7 days.
+// @Tags         admin
+// @Produce      json
+// @Param        from  query     string  false  "Window start (RFC3339)"
+// @Param        to    query     string  false  "Window end (RFC3339)"
+// @Success      200   {object}  models.ReconciliationReport
+// @Failure      400   {object}  models.ErrorResponse
+// @Failure      401   {object}  models.ErrorResponse
+// @Router       /admin/reconciliation/report [get]
+func (h *AdminHandler) ReconciliationReportHandler(c *gin.Context) {
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.Error(models.ValidationError("to must be an RFC3339 timestamp"))
+			return
+		}
+		to = t
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.Error(models.ValidationError("from must be an RFC3339 timestamp"))
+			return
+		}
+		from = t
+	}
+
+	report, err := h.reconciliationService.Report(c.Request.Context(), from, to)
+	if err != nil {
+		h.logger.Error("Failed to build reconciliation report", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// PendingWebhooksHandler godoc
+// @Summary      Inspect the webhook replay queue
+// @Description  Counts webhooks whose local processing failed and are waiting to be replayed, and those that exhausted their attempts
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  models.WebhookQueueStats
+// @Failure      401  {object}  models.ErrorResponse
+// @Router       /admin/webhooks/pending [get]
+func (h *AdminHandler) PendingWebhooksHandler(c *gin.Context) {
+	stats, err := h.webhookService.ReplayQueueStats(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to read webhook replay queue", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// SlowRequestsHandler godoc
+// @Summary      List recent slow requests
+// @Description  Lists the most recent requests that took longer than SLOW_REQUEST_THRESHOLD, newest first. Up to SLOW_REQUEST_HISTORY are kept, in memory on this instance only.
+// @Tags         admin
+// @Produce      json
+// @Success      200  {object}  models.SlowRequestsResponse
+// @Failure      401  {object}  models.ErrorResponse
+// @Router       /admin/slow-requests [get]
+func (h *AdminHandler) SlowRequestsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SlowRequestsResponse{
+		ThresholdMs: h.slowRequests.Threshold().Milliseconds(),
+		Requests:    h.slowRequests.Recent(),
+	})
+}
+
+// adminActor identifies the operator behind an admin request
+func adminActor(c *gin.Context) string {
+	if actor := strings.TrimSpace(c.GetHeader(adminActorHeader)); actor != "" {
+		return actor
+	}
+	return "admin"
+}
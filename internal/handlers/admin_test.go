@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestUpdateOrderHandler(t *testing.T) {
+	svc, repo := newInMemoryOrderService(t, &clientmock.IStarAPI{})
+	h := NewAdminHandler(svc, nil, nil, "", nil, zap.NewNop())
+	r := newTestRouter("")
+	r.PATCH("/admin/orders/:id", h.UpdateOrderHandler)
+
+	now := time.Now()
+	order := &models.Order{
+		ID:           uuid.New(),
+		IStarOrderID: "istar-1",
+		Type:         models.OrderTypeStar,
+		Status:       models.StatusPending,
+		Username:     "alice_1",
+		WalletType:   "ton",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		ClientID:     "client-a",
+	}
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/admin/orders/"+order.ID.String(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(adminActorHeader, "ops@example.com")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := patch(`{"status":"failed","error_message":"stuck upstream","reason":"ticket 42"}`); w.Code != http.StatusOK {
+		t.Fatalf("valid correction = %d, want 200: %s", w.Code, w.Body)
+	}
+	stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+	if stored.Status != models.StatusFailed || stored.ErrorMessage == nil || *stored.ErrorMessage != "stuck upstream" {
+		t.Errorf("stored order = %s, error %v; want failed with the operator's message", stored.Status, stored.ErrorMessage)
+	}
+
+	w := patch(`{"status":"pending"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "cannot move to pending") {
+		t.Errorf("illegal transition = %d %s, want 400 naming the transition", w.Code, w.Body)
+	}
+
+	entries, _ := repo.ListAuditEntries(context.Background(), order.ID.String())
+	if len(entries) != 1 || entries[0].Actor != "ops@example.com" {
+		t.Errorf("audit entries = %+v, want only the valid correction, by ops@example.com", entries)
+	}
+}
+
+func TestSlowRequestsHandlerListsSlowRequests(t *testing.T) {
+	slow := middleware.NewSlowRequestLog(10*time.Millisecond, 5)
+	h := NewAdminHandler(nil, nil, nil, "", slow, zap.NewNop())
+	r := newTestRouter("")
+	r.Use(middleware.SlowRequests(slow, zap.NewNop()))
+	r.GET("/orders/:id", func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	r.GET("/admin/slow-requests", h.SlowRequestsHandler)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/order-1", nil))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/slow-requests", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp models.SlowRequestsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.ThresholdMs != 10 {
+		t.Errorf("threshold_ms = %d, want 10", resp.ThresholdMs)
+	}
+	if len(resp.Requests) != 1 {
+		t.Fatalf("listed %d slow requests, want the slow order lookup only: %s", len(resp.Requests), w.Body)
+	}
+	if got := resp.Requests[0]; got.Method != http.MethodGet || got.Route != "/orders/:id" || got.Path != "/orders/order-1" || got.DurationMs < 30 {
+		t.Errorf("listed %+v, want GET /orders/order-1 taking at least 30ms", got)
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/hulupay/istar-api/internal/models"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+func init() {
+	// Report fields by their JSON names rather than Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// bindingError turns a ShouldBindJSON error into a validation error. Failed
+// binding rules are listed per field in Details; malformed JSON keeps the
+// decoder's message. A body cut off by the size limit is reported as 413.
+func bindingError(err error) *models.APIError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return models.PayloadTooLargeError("Request body too large")
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return models.ValidationError("Invalid request body: " + err.Error())
+	}
+
+	apiErr := models.ValidationError("Invalid request body")
+	for _, fe := range verrs {
+		apiErr.Details = append(apiErr.Details, models.FieldError{
+			Field:   fieldPath(fe),
+			Rule:    fe.Tag(),
+			Message: fieldErrorMessage(fe),
+		})
+	}
+	return apiErr
+}
+
+// fieldPath is the field's JSON path without the top-level struct name, e.g. "items[2].quantity"
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	field := fieldPath(fe)
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "min", "max":
+		op, bound := ">=", "at least"
+		if fe.Tag() == "max" {
+			op, bound = "<=", "at most"
+		}
+		switch fe.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("%s must contain %s %s items", field, bound, fe.Param())
+		case reflect.String:
+			return fmt.Sprintf("%s must be %s %s characters", field, bound, fe.Param())
+		default:
+			return fmt.Sprintf("%s must be %s %s", field, op, fe.Param())
+		}
+	case "oneof":
+		return field + " must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	default:
+		return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+	}
+}
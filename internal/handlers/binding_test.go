@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestCreateHandlersReportEveryFieldError(t *testing.T) {
+	star := NewStarHandler(&fakeOrderService{}, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	premium := NewPremiumHandler(&fakeOrderService{}, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", star.CreateStarGiftAsyncHandler)
+	r.POST("/orders/star/batch", star.CreateStarGiftBatchHandler)
+	r.POST("/orders/premium", premium.CreatePremiumGiftAsyncHandler)
+
+	tests := []struct {
+		path, body string
+		want       []models.FieldError
+	}{
+		{
+			path: "/orders/star",
+			body: `{"quantity":10,"metadata":{"` + strings.Repeat("k", 41) + `":"v"}}`,
+			want: []models.FieldError{
+				{Field: "username", Rule: "required", Message: "username is required"},
+				{Field: "quantity", Rule: "min", Message: "quantity must be >= 50"},
+				{Field: "wallet_type", Rule: "required", Message: "wallet_type is required"},
+				{Field: "metadata[" + strings.Repeat("k", 41) + "]", Rule: "max", Message: "metadata[" + strings.Repeat("k", 41) + "] must be at most 40 characters"},
+			},
+		},
+		{
+			path: "/orders/premium",
+			body: `{"months":4,"wallet_type":"ton"}`,
+			want: []models.FieldError{
+				{Field: "username", Rule: "required", Message: "username is required"},
+				{Field: "months", Rule: "oneof", Message: "months must be one of 3, 6, 12"},
+			},
+		},
+		{
+			path: "/orders/star/batch",
+			body: `{"items":[],"total_quantity":10}`,
+			want: []models.FieldError{
+				{Field: "wallet_type", Rule: "required", Message: "wallet_type is required"},
+				{Field: "items", Rule: "min", Message: "items must contain at least 1 items"},
+				{Field: "total_quantity", Rule: "min", Message: "total_quantity must be >= 50"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+			var resp models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Code != models.CodeValidation {
+				t.Errorf("code = %s, want %s", resp.Code, models.CodeValidation)
+			}
+			for _, want := range tt.want {
+				if !slices.Contains(resp.Details, want) {
+					t.Errorf("details = %+v, want them to include %+v", resp.Details, want)
+				}
+			}
+			if len(resp.Details) != len(tt.want) {
+				t.Errorf("got %d field errors, want %d", len(resp.Details), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestBindingErrorKeepsDecoderMessage(t *testing.T) {
+	star := NewStarHandler(&fakeOrderService{}, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", star.CreateStarGiftAsyncHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/orders/star", strings.NewReader(`{"quantity":"fifty"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusBadRequest || !strings.HasPrefix(resp.Error, "Invalid request body: ") || len(resp.Details) != 0 {
+		t.Errorf("got %d %+v, want 400 with the decoder's message and no details", w.Code, resp)
+	}
+}
+
+func TestBindingErrorReportsBodyCutOffBySizeLimit(t *testing.T) {
+	star := NewStarHandler(&fakeOrderService{}, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.Use(middleware.MaxBodySize(32))
+	r.POST("/orders/star", star.CreateStarGiftAsyncHandler)
+
+	body := `{"username":"alice_1","quantity":50,"wallet_type":"ton"}`
+	req := httptest.NewRequest(http.MethodPost, "/orders/star", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp models.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusRequestEntityTooLarge || resp.Code != models.CodePayloadTooLarge {
+		t.Errorf("got %d %+v, want 413 %s", w.Code, resp, models.CodePayloadTooLarge)
+	}
+}
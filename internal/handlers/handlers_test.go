@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"github.com/hulupay/istar-api/internal/services"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+	"go.uber.org/zap"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fakeOrderService implements services.OrderService for handler tests. Only
+// the methods a test sets are usable; the rest panic on the nil interface.
+type fakeOrderService struct {
+	services.OrderService
+	createStarAsync    func(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error)
+	createPremiumAsync func(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.Order, error)
+}
+
+func (f *fakeOrderService) CreateStarOrderAsync(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error) {
+	return f.createStarAsync(ctx, req)
+}
+
+func (f *fakeOrderService) CreatePremiumOrderAsync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.Order, error) {
+	return f.createPremiumAsync(ctx, req)
+}
+
+// newInMemoryOrderService returns a real order service over a fresh in-memory
+// repository, for tests that exercise a handler together with the service
+func newInMemoryOrderService(t *testing.T, istar *clientmock.IStarAPI) (services.OrderService, repositories.OrderRepository) {
+	t.Helper()
+	background, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	t.Cleanup(func() {
+		cancel()
+		workers.Wait()
+	})
+	repo := repositories.NewInMemoryOrderRepository()
+	return services.NewOrderService(background, repo, istar, config.OrderConfig{}, &workers, zap.NewNop()), repo
+}
+
+// newTestRouter returns an engine with the error handler installed and every
+// request attributed to clientID
+func newTestRouter(clientID string) *gin.Engine {
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(middleware.ErrorHandler(zap.NewNop()))
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(requestctx.WithClientID(c.Request.Context(), clientID))
+		c.Next()
+	})
+	return r
+}
+
+func TestCreateStarGiftAsyncPassesIdempotencyKeyAndClient(t *testing.T) {
+	var gotClient, gotKey string
+	svc := &fakeOrderService{
+		createStarAsync: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error) {
+			gotClient = requestctx.ClientID(ctx)
+			gotKey = req.IdempotencyKey
+			return &models.Order{Status: models.StatusPending}, nil
+		},
+	}
+	h := NewStarHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", h.CreateStarGiftAsyncHandler)
+
+	body := `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"ton"}`
+	req := httptest.NewRequest(http.MethodPost, "/orders/star", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "  key-1 ")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body)
+	}
+	if gotClient != "client-a" {
+		t.Errorf("service saw client %q, want client-a", gotClient)
+	}
+	if gotKey != "key-1" {
+		t.Errorf("service saw idempotency key %q, want key-1", gotKey)
+	}
+}
+
+func TestCreateStarGiftAsyncMarksReplays(t *testing.T) {
+	svc := &fakeOrderService{
+		createStarAsync: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error) {
+			return &models.Order{Status: models.StatusPending, Replayed: true}, nil
+		},
+	}
+	h := NewStarHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", h.CreateStarGiftAsyncHandler)
+
+	body := `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"ton"}`
+	req := httptest.NewRequest(http.MethodPost, "/orders/star", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "key-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Idempotency-Replayed"); got != "true" {
+		t.Errorf("Idempotency-Replayed = %q, want true", got)
+	}
+}
+
+func TestReplayedHeaderOnlyOnRepeatedKey(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		CreateStarOrderAsyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			return &models.StarOrderResponse{OrderID: "istar-1", Status: "pending", Quantity: req.Quantity, Amount: models.Amount(100), CreatedAt: time.Now().UTC().Format(time.RFC3339)}, nil
+		},
+	}
+	svc, _ := newInMemoryOrderService(t, istar)
+	h := NewStarHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", h.CreateStarGiftAsyncHandler)
+
+	create := func() (*httptest.ResponseRecorder, map[string]any) {
+		body := `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"ton"}`
+		req := httptest.NewRequest(http.MethodPost, "/orders/star", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "key-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("body %q: %v", w.Body, err)
+		}
+		return w, resp.Data
+	}
+
+	first, firstBody := create()
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("first create status = %d, want 202: %s", first.Code, first.Body)
+	}
+	if _, ok := first.Header()["Idempotency-Replayed"]; ok {
+		t.Errorf("first create sent Idempotency-Replayed: %q", first.Header().Get("Idempotency-Replayed"))
+	}
+	if _, ok := firstBody["replayed"]; ok {
+		t.Errorf("first create body has replayed = %v, want the field omitted", firstBody["replayed"])
+	}
+
+	second, secondBody := create()
+	if second.Code != http.StatusAccepted || second.Header().Get("Idempotency-Replayed") != "true" {
+		t.Errorf("repeat create = %d with Idempotency-Replayed %q, want 202 and true", second.Code, second.Header().Get("Idempotency-Replayed"))
+	}
+	if secondBody["replayed"] != true || secondBody["id"] != firstBody["id"] {
+		t.Errorf("repeat create body = %v, want order %v marked replayed", secondBody, firstBody["id"])
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	h := NewStarHandler(&fakeOrderService{}, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", h.CreateStarGiftAsyncHandler)
+
+	body := `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"ton"}`
+	req := httptest.NewRequest(http.MethodPost, "/orders/star", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strings.Repeat("k", maxIdempotencyKeyLength+1))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestCreateHandlersRejectInvalidUsernames(t *testing.T) {
+	star := NewStarHandler(&fakeOrderService{}, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	premium := NewPremiumHandler(&fakeOrderService{}, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", star.CreateStarGiftAsyncHandler)
+	r.POST("/orders/star/sync", star.CreateStarGiftSyncHandler)
+	r.POST("/orders/star/quote", star.QuoteStarOrderHandler)
+	r.POST("/orders/star/batch", star.CreateStarGiftBatchHandler)
+	r.POST("/orders/premium", premium.CreatePremiumGiftAsyncHandler)
+	r.POST("/orders/premium/sync", premium.CreatePremiumGiftSyncHandler)
+	r.POST("/orders/premium/quote", premium.QuotePremiumOrderHandler)
+
+	starBody := `{"username":"bad name!","quantity":50,"wallet_type":"ton"}`
+	premiumBody := `{"username":"bad name!","months":3,"wallet_type":"ton"}`
+	tests := []struct{ path, body string }{
+		{"/orders/star", starBody},
+		{"/orders/star/sync", starBody},
+		{"/orders/star/quote", starBody},
+		{"/orders/star/batch", `{"wallet_type":"ton","items":[{"username":"alice_1","quantity":50},{"username":"ab","quantity":50}]}`},
+		{"/orders/premium", premiumBody},
+		{"/orders/premium/sync", premiumBody},
+		{"/orders/premium/quote", premiumBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreateHandlersValidateWalletType(t *testing.T) {
+	accepted := func() (*models.Order, error) { return &models.Order{Status: models.StatusPending}, nil }
+	svc := &fakeOrderService{
+		createStarAsync: func(context.Context, models.CreateStarOrderRequest) (*models.Order, error) {
+			return accepted()
+		},
+		createPremiumAsync: func(context.Context, models.CreatePremiumOrderRequest) (*models.Order, error) {
+			return accepted()
+		},
+	}
+	walletTypes := models.WalletTypes{"ton", "usdt"}
+	star := NewStarHandler(svc, nil, false, nil, walletTypes, nil, zap.NewNop())
+	premium := NewPremiumHandler(svc, nil, false, nil, walletTypes, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", star.CreateStarGiftAsyncHandler)
+	r.POST("/orders/premium", premium.CreatePremiumGiftAsyncHandler)
+
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		{"/orders/star", `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"usdt"}`, http.StatusAccepted},
+		{"/orders/star", `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"internal"}`, http.StatusBadRequest},
+		{"/orders/star", `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"TON"}`, http.StatusBadRequest},
+		{"/orders/premium", `{"username":"alice_1","recipient_hash":"h","months":3,"wallet_type":"ton"}`, http.StatusAccepted},
+		{"/orders/premium", `{"username":"alice_1","recipient_hash":"h","months":3,"wallet_type":"btc"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d: %s", tt.path, tt.body, w.Code, tt.want, w.Body)
+			continue
+		}
+		if tt.want == http.StatusBadRequest && !strings.Contains(w.Body.String(), "must be one of ton, usdt") {
+			t.Errorf("%s: body = %s, want it to list the allowed wallet types", tt.path, w.Body)
+		}
+	}
+}
+
+func TestCreateHandlersEnforceRecipientAllowlist(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowlist models.UsernameAllowlist
+		username  string
+		want      int
+	}{
+		{"allowed", models.NewUsernameAllowlist([]string{"alice_1"}), "alice_1", http.StatusAccepted},
+		{"not on the list", models.NewUsernameAllowlist([]string{"alice_1"}), "bob_2", http.StatusBadRequest},
+		{"no allowlist", nil, "bob_2", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			accepted := func() (*models.Order, error) {
+				calls++
+				return &models.Order{Status: models.StatusPending}, nil
+			}
+			svc := &fakeOrderService{
+				createStarAsync: func(context.Context, models.CreateStarOrderRequest) (*models.Order, error) {
+					return accepted()
+				},
+				createPremiumAsync: func(context.Context, models.CreatePremiumOrderRequest) (*models.Order, error) {
+					return accepted()
+				},
+			}
+			star := NewStarHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, tt.allowlist, zap.NewNop())
+			premium := NewPremiumHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, tt.allowlist, zap.NewNop())
+			r := newTestRouter("client-a")
+			r.POST("/orders/star", star.CreateStarGiftAsyncHandler)
+			r.POST("/orders/premium", premium.CreatePremiumGiftAsyncHandler)
+
+			bodies := map[string]string{
+				"/orders/star":    `{"username":"` + tt.username + `","recipient_hash":"h","quantity":50,"wallet_type":"ton"}`,
+				"/orders/premium": `{"username":"` + tt.username + `","recipient_hash":"h","months":3,"wallet_type":"ton"}`,
+			}
+			for path, body := range bodies {
+				req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != tt.want {
+					t.Errorf("%s: status = %d, want %d: %s", path, w.Code, tt.want, w.Body)
+				}
+				if tt.want == http.StatusBadRequest && !strings.Contains(w.Body.String(), "not on the recipient allowlist") {
+					t.Errorf("%s: body = %s, want it to name the allowlist", path, w.Body)
+				}
+			}
+			if wantCalls := map[int]int{http.StatusAccepted: 2, http.StatusBadRequest: 0}[tt.want]; calls != wantCalls {
+				t.Errorf("service called %d times, want %d", calls, wantCalls)
+			}
+		})
+	}
+}
+
+func TestCreateHandlerPassesRequestCancellation(t *testing.T) {
+	var sawCancel error
+	svc := &fakeOrderService{
+		createStarAsync: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error) {
+			sawCancel = ctx.Err()
+			return nil, ctx.Err()
+		},
+	}
+	h := NewStarHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", h.CreateStarGiftAsyncHandler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"ton"}`
+	req := httptest.NewRequest(http.MethodPost, "/orders/star", strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !errors.Is(sawCancel, context.Canceled) {
+		t.Errorf("service context error = %v, want the request's cancellation", sawCancel)
+	}
+}
+
+func TestOrderMetadataRoundTrips(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		CreateStarOrderAsyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			return &models.StarOrderResponse{OrderID: "istar-star", Status: "pending", Quantity: req.Quantity, Amount: models.Amount(100), CreatedAt: time.Now().UTC().Format(time.RFC3339)}, nil
+		},
+		CreatePremiumOrderAsyncFunc: func(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error) {
+			return &models.PremiumOrderResponse{OrderID: "istar-premium", Status: "pending", Months: req.Months, Amount: models.Amount(100), CreatedAt: time.Now().UTC().Format(time.RFC3339)}, nil
+		},
+	}
+	svc, _ := newInMemoryOrderService(t, istar)
+	star := NewStarHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	premium := NewPremiumHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	orders := NewOrderHandler(svc, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", star.CreateStarGiftAsyncHandler)
+	r.POST("/orders/premium", premium.CreatePremiumGiftAsyncHandler)
+	r.GET("/orders/:id", orders.GetOrderHandler)
+
+	serve := func(method, path, body string) (int, models.Order) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Data models.Order `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+
+	tests := []struct {
+		name, path, body string
+	}{
+		{"star", "/orders/star", `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"ton","metadata":{"invoice_id":"INV-1","note":"birthday gift"}}`},
+		{"premium", "/orders/premium", `{"username":"alice_1","recipient_hash":"h","months":3,"wallet_type":"ton","metadata":{"invoice_id":"INV-1","note":"birthday gift"}}`},
+	}
+	want := map[string]string{"invoice_id": "INV-1", "note": "birthday gift"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, created := serve(http.MethodPost, tt.path, tt.body)
+			if code != http.StatusAccepted {
+				t.Fatalf("create status = %d, want 202", code)
+			}
+			if !reflect.DeepEqual(created.Metadata, want) {
+				t.Errorf("created metadata = %v, want %v", created.Metadata, want)
+			}
+
+			code, fetched := serve(http.MethodGet, "/orders/"+created.ID.String(), "")
+			if code != http.StatusOK {
+				t.Fatalf("get status = %d, want 200", code)
+			}
+			if !reflect.DeepEqual(fetched.Metadata, want) {
+				t.Errorf("fetched metadata = %v, want %v", fetched.Metadata, want)
+			}
+		})
+	}
+}
+
+func TestOrderMetadataLimits(t *testing.T) {
+	svc := &fakeOrderService{
+		createStarAsync: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error) {
+			return &models.Order{Status: models.StatusPending, Metadata: req.Metadata}, nil
+		},
+	}
+	h := NewStarHandler(svc, nil, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.POST("/orders/star", h.CreateStarGiftAsyncHandler)
+
+	manyKeys := make(map[string]string)
+	for i := range 21 {
+		manyKeys["key_"+strconv.Itoa(i)] = "v"
+	}
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		want     int
+	}{
+		{"at the limits", map[string]string{strings.Repeat("k", 40): strings.Repeat("v", 500)}, http.StatusAccepted},
+		{"too many keys", manyKeys, http.StatusBadRequest},
+		{"value too long", map[string]string{"note": strings.Repeat("v", 501)}, http.StatusBadRequest},
+		{"key too long", map[string]string{strings.Repeat("k", 41): "v"}, http.StatusBadRequest},
+		{"empty key", map[string]string{"": "v"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]any{
+				"username": "alice_1", "recipient_hash": "h", "quantity": 50, "wallet_type": "ton", "metadata": tt.metadata,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/orders/star", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
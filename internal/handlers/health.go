@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DependencyCheck reports whether a dependency is usable; a nil error means healthy
+type DependencyCheck func(ctx context.Context) error
+
+// HealthHandler serves the readiness probe
+type HealthHandler struct {
+	checks   map[string]DependencyCheck
+	timeout  time.Duration
+	cacheTTL time.Duration
+	logger   *zap.Logger
+
+	// mu is held while the checks run, so probes arriving meanwhile wait for
+	// and share that result instead of starting their own
+	mu      sync.Mutex
+	cached  readiness
+	expires time.Time
+}
+
+// readiness is the outcome of one round of dependency checks
+type readiness struct {
+	healthy bool
+	results map[string]string
+}
+
+// NewHealthHandler initializes a HealthHandler that runs checks concurrently,
+// giving all of them together at most timeout. A result is reused for
+// cacheTTL plus up to a fifth again of jitter, so replicas started together
+// drift apart; zero runs the checks on every probe.
+func NewHealthHandler(checks map[string]DependencyCheck, timeout, cacheTTL time.Duration, logger *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		checks:   checks,
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+		logger:   logger.Named("health_handler"),
+	}
+}
+
+// ReadinessHandler godoc
+// @Summary      Readiness probe
+// @Description  Checks every dependency concurrently and reports a per-dependency status. Answers 503 when any dependency is down. Results are shared between probes for a short, configurable time.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Failure      503  {object}  map[string]interface{}
+// @Router       /health/ready [get]
+func (h *HealthHandler) ReadinessHandler(c *gin.Context) {
+	r := h.readiness(c.Request.Context())
+
+	status, code := "ok", http.StatusOK
+	if !r.healthy {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "dependencies": r.results})
+}
+
+// readiness returns the cached result while it is fresh, otherwise runs the
+// checks. A shared round is detached from the probe that started it, so one
+// caller hanging up does not fail it for everyone.
+func (h *HealthHandler) readiness(ctx context.Context) readiness {
+	if h.cacheTTL <= 0 {
+		return h.runChecks(ctx)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now := time.Now(); now.Before(h.expires) {
+		return h.cached
+	}
+
+	h.cached = h.runChecks(context.WithoutCancel(ctx))
+	jitter := time.Duration(rand.Int64N(int64(h.cacheTTL)/5 + 1))
+	h.expires = time.Now().Add(h.cacheTTL + jitter)
+	return h.cached
+}
+
+// runChecks runs every dependency check concurrently under the probe timeout
+func (h *HealthHandler) runChecks(ctx context.Context) readiness {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+		r  = readiness{healthy: true, results: make(map[string]string, len(h.checks))}
+	)
+	for name, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				h.logger.Warn("Dependency check failed", zap.String("dependency", name), zap.Error(err))
+				r.results[name] = "down: " + err.Error()
+				r.healthy = false
+				return
+			}
+			r.results[name] = "ok"
+		}()
+	}
+	wg.Wait()
+	return r
+}
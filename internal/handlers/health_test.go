@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// readinessBody is the JSON the readiness probe answers with
+type readinessBody struct {
+	Status       string            `json:"status"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// probeReadiness serves one GET /health/ready through h
+func probeReadiness(t *testing.T, h *HealthHandler) (int, readinessBody) {
+	t.Helper()
+	r := gin.New()
+	r.GET("/health/ready", h.ReadinessHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	var body readinessBody
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return w.Code, body
+}
+
+func healthy(context.Context) error { return nil }
+
+func TestReadinessReportsEachDependency(t *testing.T) {
+	tests := []struct {
+		name     string
+		checks   map[string]DependencyCheck
+		wantCode int
+		wantDown string
+	}{
+		{
+			name:     "all up",
+			checks:   map[string]DependencyCheck{"database": healthy, "istar": healthy},
+			wantCode: http.StatusOK,
+		},
+		{
+			name: "database down",
+			checks: map[string]DependencyCheck{
+				"database": func(context.Context) error { return errors.New("connection refused") },
+				"istar":    healthy,
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantDown: "database",
+		},
+		{
+			name: "istar down",
+			checks: map[string]DependencyCheck{
+				"database": healthy,
+				"istar":    func(context.Context) error { return errors.New("iStar answered 502") },
+			},
+			wantCode: http.StatusServiceUnavailable,
+			wantDown: "istar",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthHandler(tt.checks, time.Second, 0, zap.NewNop())
+
+			code, body := probeReadiness(t, h)
+
+			if code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", code, tt.wantCode)
+			}
+			for name, result := range body.Dependencies {
+				if down := strings.HasPrefix(result, "down: "); down != (name == tt.wantDown) {
+					t.Errorf("%s = %q, want down only for %q", name, result, tt.wantDown)
+				}
+			}
+			if len(body.Dependencies) != 2 {
+				t.Errorf("dependencies = %v, want both reported", body.Dependencies)
+			}
+		})
+	}
+}
+
+func TestReadinessGivesUpAtTheDeadline(t *testing.T) {
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	h := NewHealthHandler(map[string]DependencyCheck{"database": hang, "istar": hang}, 50*time.Millisecond, 0, zap.NewNop())
+
+	start := time.Now()
+	code, body := probeReadiness(t, h)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("probe took %v, want it to give up at the 50ms deadline", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || body.Status != "unavailable" {
+		t.Errorf("got %d %q, want 503 unavailable", code, body.Status)
+	}
+}
+
+func TestReadinessRunsChecksConcurrently(t *testing.T) {
+	// Each check waits for the other to start, so run one after the other
+	// both would hit the deadline
+	var started sync.WaitGroup
+	started.Add(2)
+	waitForOther := func(ctx context.Context) error {
+		started.Done()
+		done := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	h := NewHealthHandler(map[string]DependencyCheck{"database": waitForOther, "istar": waitForOther}, time.Second, 0, zap.NewNop())
+
+	if code, body := probeReadiness(t, h); code != http.StatusOK {
+		t.Errorf("status = %d, dependencies = %v, want 200", code, body.Dependencies)
+	}
+}
+
+func TestConcurrentReadinessProbesShareOneCheck(t *testing.T) {
+	var calls atomic.Int32
+	slow := func(context.Context) error {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	h := NewHealthHandler(map[string]DependencyCheck{"database": slow}, time.Second, time.Second, zap.NewNop())
+	r := gin.New()
+	r.GET("/health/ready", h.ReadinessHandler)
+
+	const probes = 50
+	codes := make(chan int, probes)
+	var wg sync.WaitGroup
+	for range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("status = %d, want 200", code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("database checked %d times, want once for %d probes within the TTL", n, probes)
+	}
+}
+
+func TestReadinessCacheExpires(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wantCalls int32
+	}{
+		{"uncached", 0, 3},
+		{"expires after the TTL and its jitter", 10 * time.Millisecond, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			count := func(context.Context) error {
+				calls.Add(1)
+				return nil
+			}
+			h := NewHealthHandler(map[string]DependencyCheck{"database": count}, time.Second, tt.ttl, zap.NewNop())
+
+			for range 3 {
+				probeReadiness(t, h)
+				// Past the TTL plus its largest jitter of a fifth
+				time.Sleep(tt.ttl * 13 / 10)
+			}
+
+			if n := calls.Load(); n != tt.wantCalls {
+				t.Errorf("database checked %d times, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachedReadinessKeepsFailures(t *testing.T) {
+	var calls atomic.Int32
+	down := func(context.Context) error {
+		calls.Add(1)
+		return errors.New("connection refused")
+	}
+	h := NewHealthHandler(map[string]DependencyCheck{"database": down}, time.Second, time.Minute, zap.NewNop())
+
+	for range 2 {
+		if code, _ := probeReadiness(t, h); code != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want 503 from the cached failure", code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("database checked %d times, want the failure cached too", n)
+	}
+}
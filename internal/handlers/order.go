@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/services"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header we are willing to store
+const maxIdempotencyKeyLength = 255
+
+// Page sizes for GET /orders
+const (
+	defaultOrderListLimit = 50
+	maxOrderListLimit     = 200
+)
+
+// orderCSVColumns is the header row of GET /orders/export
+var orderCSVColumns = []string{"id", "type", "status", "username", "amount", "wallet_type", "tx_hash", "created_at", "completed_at"}
+
+// OrderHandler handles order lookup endpoints
+type OrderHandler struct {
+	orderService services.OrderService
+	logger       *zap.Logger
+}
+
+// NewOrderHandler initializes a new OrderHandler
+func NewOrderHandler(orderService services.OrderService, logger *zap.Logger) *OrderHandler {
+	return &OrderHandler{
+		orderService: orderService,
+		logger:       logger.Named("order_handler"),
+	}
+}
+
+// GetOrderHandler godoc
+// @Summary      Get an order
+// @Description  Returns a locally stored order. HEAD returns the same headers (including ETag) without a body.
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      string  true  "Order ID"
+// @Success      200  {object}  models.SuccessResponse{data=models.Order}
+// @Success      304
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Router       /orders/{id} [get]
+// @Router       /orders/{id} [head]
+func (h *OrderHandler) GetOrderHandler(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.orderService.GetOrder(c.Request.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to get order", zap.Error(err), zap.String("order_id", orderID))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, order)
+}
+
+// GetOrderAuditHandler godoc
+// @Summary      Get an order's audit trail
+// @Description  Lists every recorded mutation of an order, oldest first: who made it (hashed API key or operator) and the status before and after.
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      string  true  "Order ID"
+// @Success      200  {object}  models.SuccessResponse{data=models.AuditLogResponse}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Router       /orders/{id}/audit [get]
+func (h *OrderHandler) GetOrderAuditHandler(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	audit, err := h.orderService.GetOrderAudit(c.Request.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to get order audit log", zap.Error(err), zap.String("order_id", orderID))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, audit)
+}
+
+// ListOrdersHandler godoc
+// @Summary      List orders
+// @Description  Returns locally stored orders, newest first. Page with the opaque cursor from next_cursor; offset is supported as a fallback but cannot be combined with cursor. A Link header carries first, prev and next page URLs.
+// @Tags         orders
+// @Produce      json
+// @Param        limit   query     int     false  "Page size (1-200, default 50)"
+// @Param        cursor  query     string  false  "next_cursor from the previous page"
+// @Param        offset  query     int     false  "Rows to skip when not using a cursor"
+// @Param        status  query     string  false  "Only orders in this status"
+// @Success      200     {object}  models.SuccessResponse{data=models.OrderListResponse}
+// @Header       200     {string}  Link  "RFC 8288 links to the first, prev and next pages"
+// @Failure      400     {object}  models.ErrorResponse
+// @Router       /orders [get]
+func (h *OrderHandler) ListOrdersHandler(c *gin.Context) {
+	q, err := parseOrderListQuery(c)
+	if err != nil {
+		h.logger.Error("Invalid order list query", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	resp, err := h.orderService.ListOrders(c.Request.Context(), q)
+	if err != nil {
+		h.logger.Error("Failed to list orders", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	c.Header("Link", orderListLinks(c.Request.URL, resp))
+	respondOK(c, resp)
+}
+
+// ExportOrdersHandler godoc
+// @Summary      Export orders as CSV
+// @Description  Streams every locally stored order matching the status filter as CSV, newest first. Rows are written page by page, so a failure part way through ends the download early.
+// @Tags         orders
+// @Produce      text/csv
+// @Param        status  query     string  false  "Only orders in this status"
+// @Success      200     {string}  string  "CSV with columns id, type, status, username, amount, wallet_type, tx_hash, created_at, completed_at"
+// @Failure      400     {object}  models.ErrorResponse
+// @Router       /orders/export [get]
+func (h *OrderHandler) ExportOrdersHandler(c *gin.Context) {
+	filter, err := parseOrderListQuery(c)
+	if err != nil {
+		h.logger.Error("Invalid order export query", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	q := models.OrderListQuery{Status: filter.Status, Limit: maxOrderListLimit}
+
+	// The first page is fetched before anything is written so that an early
+	// failure can still be answered with a JSON error
+	page, err := h.orderService.ListOrders(c.Request.Context(), q)
+	if err != nil {
+		h.logger.Error("Failed to export orders", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	filename := fmt.Sprintf("orders-%s.csv", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(orderCSVColumns)
+	for {
+		for _, order := range page.Orders {
+			w.Write(orderCSVRecord(order))
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			h.logger.Warn("Order export aborted by client", zap.Error(err))
+			return
+		}
+		c.Writer.Flush()
+
+		if page.NextCursor == "" {
+			return
+		}
+		q.After, _ = models.DecodeOrderCursor(page.NextCursor)
+		if page, err = h.orderService.ListOrders(c.Request.Context(), q); err != nil {
+			// Headers are gone, so all we can do is cut the download short
+			h.logger.Error("Failed to export orders", zap.Error(err))
+			c.Abort()
+			return
+		}
+	}
+}
+
+// CancelOrderHandler godoc
+// @Summary      Cancel a pending order
+// @Description  Cancels an order that has not settled yet. Completed or failed orders are rejected.
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      string  true  "Order ID"
+// @Success      200  {object}  models.SuccessResponse{data=models.Order}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Router       /orders/{id}/cancel [post]
+func (h *OrderHandler) CancelOrderHandler(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.orderService.CancelOrder(c.Request.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to cancel order", zap.Error(err), zap.String("order_id", orderID))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, order)
+}
+
+// ResyncOrderHandler godoc
+// @Summary      Resync an order with iStar
+// @Description  Fetches the current upstream status of a pending order and applies it locally, recovering orders whose webhook was lost. Settled orders are returned unchanged.
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      string  true  "Order ID"
+// @Success      200  {object}  models.SuccessResponse{data=models.Order}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Router       /orders/{id}/resync [post]
+func (h *OrderHandler) ResyncOrderHandler(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.orderService.ResyncOrder(c.Request.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to resync order", zap.Error(err), zap.String("order_id", orderID))
+		c.Error(err)
+		return
+	}
+
+	h.logger.Info("Order resynced", zap.String("order_id", orderID), zap.String("status", string(order.Status)))
+	respondOK(c, order)
+}
+
+// RefundOrderHandler godoc
+// @Summary      Refund a completed order
+// @Description  Refunds a completed order through iStar and records the refund on the order. Orders in any other status, or that iStar reports as ineligible, are rejected.
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      string  true  "Order ID"
+// @Success      200  {object}  models.SuccessResponse{data=models.Order}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      409  {object}  models.ErrorResponse
+// @Router       /orders/{id}/refund [post]
+func (h *OrderHandler) RefundOrderHandler(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.orderService.RefundOrder(c.Request.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to refund order", zap.Error(err), zap.String("order_id", orderID))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, order)
+}
+
+// GetRefundEligibilityHandler godoc
+// @Summary      Check refund eligibility
+// @Description  Reports whether an order can currently be refunded and the maximum refundable amount
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      string  true  "Order ID"
+// @Success      200  {object}  models.SuccessResponse{data=models.RefundEligibilityResponse}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Router       /orders/{id}/refund-eligibility [get]
+func (h *OrderHandler) GetRefundEligibilityHandler(c *gin.Context) {
+	orderID, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	eligibility, err := h.orderService.GetRefundEligibility(c.Request.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to get refund eligibility", zap.Error(err), zap.String("order_id", orderID))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, eligibility)
+}
+
+// GetOrdersByTxHashHandler godoc
+// @Summary      Find orders by transaction hash
+// @Description  Returns every order settled by the given transaction hash. A batched transaction may settle several orders.
+// @Tags         orders
+// @Produce      json
+// @Param        hash  path      string  true  "Transaction hash"
+// @Success      200   {object}   models.SuccessResponse{data=[]models.Order}
+// @Failure      400   {object}  models.ErrorResponse
+// @Failure      404   {object}  models.ErrorResponse
+// @Router       /orders/by-tx/{hash} [get]
+func (h *OrderHandler) GetOrdersByTxHashHandler(c *gin.Context) {
+	txHash := strings.TrimSpace(c.Param("hash"))
+	if txHash == "" {
+		h.logger.Error("Missing tx hash")
+		c.Error(models.ValidationError("Missing transaction hash"))
+		return
+	}
+
+	orders, err := h.orderService.GetOrdersByTxHash(c.Request.Context(), txHash)
+	if err != nil {
+		h.logger.Error("Failed to get orders by tx hash", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	h.logger.Info("Orders retrieved by tx hash", zap.String("tx_hash", txHash), zap.Int("count", len(orders)))
+	respondOK(c, orders)
+}
+
+// GetOrderByIStarIDHandler godoc
+// @Summary      Find an order by iStar order id
+// @Description  Returns the locally stored order that iStar knows by the given id, e.g. an order_id from a webhook or the iStar dashboard.
+// @Tags         orders
+// @Produce      json
+// @Param        id   path      string  true  "iStar order ID"
+// @Success      200  {object}  models.SuccessResponse{data=models.Order}
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Router       /orders/by-istar/{id} [get]
+func (h *OrderHandler) GetOrderByIStarIDHandler(c *gin.Context) {
+	istarOrderID := strings.TrimSpace(c.Param("id"))
+	if istarOrderID == "" {
+		c.Error(models.ValidationError("Missing iStar order ID"))
+		return
+	}
+
+	order, err := h.orderService.GetOrderByIStarID(c.Request.Context(), istarOrderID)
+	if err != nil {
+		h.logger.Error("Failed to get order by iStar id", zap.Error(err), zap.String("istar_order_id", istarOrderID))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, order)
+}
+
+// idempotencyKeyFromHeader reads the optional Idempotency-Key request header
+func idempotencyKeyFromHeader(c *gin.Context) (string, error) {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if len(key) > maxIdempotencyKeyLength {
+		return "", models.ValidationError("Idempotency-Key must be at most 255 characters")
+	}
+	return key, nil
+}
+
+// setReplayedHeader marks the response as a replay when the order was returned
+// for a repeated Idempotency-Key rather than created by this request
+func setReplayedHeader(c *gin.Context, order *models.Order) {
+	if order.Replayed {
+		c.Header("Idempotency-Replayed", "true")
+	}
+}
+
+// respondSyncOrder answers a sync create with 200 once the order has settled,
+// or 202 when iStar did not answer in time and the order is still pending
+func respondSyncOrder(c *gin.Context, order *models.Order) {
+	setReplayedHeader(c, order)
+	if order.Status == models.StatusPending {
+		respond(c, http.StatusAccepted, order)
+		return
+	}
+	respondOK(c, order)
+}
+
+// parseOrderListQuery reads the limit, cursor, offset and status query parameters
+func parseOrderListQuery(c *gin.Context) (models.OrderListQuery, error) {
+	q := models.OrderListQuery{Limit: defaultOrderListLimit}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxOrderListLimit {
+			return q, models.ValidationError("limit must be between 1 and 200")
+		}
+		q.Limit = limit
+	}
+
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return q, models.ValidationError("offset must be a non-negative integer")
+		}
+		q.Offset = offset
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		if q.Offset > 0 {
+			return q, models.ValidationError("cursor and offset cannot be combined")
+		}
+		cursor, err := models.DecodeOrderCursor(v)
+		if err != nil {
+			return q, models.ValidationError("Invalid cursor")
+		}
+		q.After = cursor
+	}
+
+	if v := c.Query("status"); v != "" {
+		q.Status = models.OrderStatus(v)
+		if !q.Status.Valid() {
+			return q, models.ValidationError("Invalid status " + v)
+		}
+	}
+
+	return q, nil
+}
+
+// orderListLinks builds the Link header for a page of orders from the request
+// URL, keeping its other query parameters. Offset pages link to first, prev
+// and next; cursor pages can only move forward, so they link to first and to
+// the next cursor.
+func orderListLinks(u *url.URL, resp *models.OrderListResponse) string {
+	link := func(rel string, set func(q url.Values)) string {
+		q := u.Query()
+		q.Del("cursor")
+		q.Del("offset")
+		q.Set("limit", strconv.Itoa(resp.Limit))
+		set(q)
+		return fmt.Sprintf("<%s?%s>; rel=%q", u.Path, q.Encode(), rel)
+	}
+
+	links := []string{link("first", func(url.Values) {})}
+	if u.Query().Get("cursor") != "" {
+		if resp.NextCursor != "" {
+			links = append(links, link("next", func(q url.Values) { q.Set("cursor", resp.NextCursor) }))
+		}
+		return strings.Join(links, ", ")
+	}
+
+	if resp.Offset > 0 {
+		prev := max(resp.Offset-resp.Limit, 0)
+		links = append(links, link("prev", func(q url.Values) { q.Set("offset", strconv.Itoa(prev)) }))
+	}
+	if next := resp.Offset + resp.Limit; next < resp.Total {
+		links = append(links, link("next", func(q url.Values) { q.Set("offset", strconv.Itoa(next)) }))
+	}
+	return strings.Join(links, ", ")
+}
+
+// orderCSVRecord renders order as one row of orderCSVColumns. Times are
+// RFC 3339 in UTC; absent values are empty.
+func orderCSVRecord(order *models.Order) []string {
+	var txHash, completedAt string
+	if order.TxHash != nil {
+		txHash = *order.TxHash
+	}
+	if order.CompletedAt != nil {
+		completedAt = order.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		order.ID.String(),
+		string(order.Type),
+		string(order.Status),
+		order.Username,
+		order.Amount.String(),
+		string(order.WalletType),
+		txHash,
+		order.CreatedAt.UTC().Format(time.RFC3339),
+		completedAt,
+	}
+}
+
+// parseOrderID validates the :id path parameter, reporting a validation error
+// on the context when it is not a UUID
+func parseOrderID(c *gin.Context) (string, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Error(models.ValidationError("Invalid order ID"))
+		return "", false
+	}
+	return id.String(), true
+}
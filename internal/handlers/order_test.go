@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/models"
+	"go.uber.org/zap"
+)
+
+// listQueryContext returns a gin context for GET /orders?rawQuery
+func listQueryContext(rawQuery string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/orders?"+rawQuery, nil)
+	return c
+}
+
+func TestParseOrderListQueryCursor(t *testing.T) {
+	cursor := models.OrderCursor{CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), ID: uuid.New()}
+
+	q, err := parseOrderListQuery(listQueryContext("limit=10&cursor=" + cursor.Encode()))
+	if err != nil {
+		t.Fatalf("parseOrderListQuery: %v", err)
+	}
+	if q.After == nil || q.After.ID != cursor.ID || q.Limit != 10 {
+		t.Errorf("query = %+v, want limit 10 after %s", q, cursor.ID)
+	}
+
+	for _, rawQuery := range []string{
+		"cursor=not-a-cursor",
+		"offset=5&cursor=" + cursor.Encode(),
+	} {
+		if _, err := parseOrderListQuery(listQueryContext(rawQuery)); err == nil {
+			t.Errorf("parseOrderListQuery(%q) = nil error, want it rejected", rawQuery)
+		}
+	}
+}
+
+func TestGetOrderByEitherID(t *testing.T) {
+	svc, repo := newInMemoryOrderService(t, &clientmock.IStarAPI{})
+	h := NewOrderHandler(svc, zap.NewNop())
+
+	now := time.Now()
+	order := &models.Order{
+		ID:           uuid.New(),
+		IStarOrderID: "istar-777",
+		Type:         models.OrderTypeStar,
+		Status:       models.StatusCompleted,
+		Username:     "alice_1",
+		WalletType:   "ton",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		ClientID:     "client-a",
+	}
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	get := func(clientID, path string) (int, map[string]any) {
+		r := newTestRouter(clientID)
+		r.GET("/orders/:id", h.GetOrderHandler)
+		r.GET("/orders/by-istar/:id", h.GetOrderByIStarIDHandler)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var resp struct {
+			Data map[string]any `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+
+	tests := []struct {
+		name, clientID, path string
+		want                 int
+	}{
+		{"our id", "client-a", "/orders/" + order.ID.String(), http.StatusOK},
+		{"iStar id", "client-a", "/orders/by-istar/istar-777", http.StatusOK},
+		{"our id as an iStar id", "client-a", "/orders/by-istar/" + order.ID.String(), http.StatusNotFound},
+		{"iStar id as our id", "client-a", "/orders/istar-777", http.StatusBadRequest},
+		{"another client's order by iStar id", "client-b", "/orders/by-istar/istar-777", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, data := get(tt.clientID, tt.path)
+			if code != tt.want {
+				t.Fatalf("status = %d, want %d", code, tt.want)
+			}
+			if code == http.StatusOK && (data["id"] != order.ID.String() || data["istar_order_id"] != "istar-777") {
+				t.Errorf("order = %v, want id %s and istar_order_id istar-777", data, order.ID)
+			}
+		})
+	}
+}
+
+func TestListOrdersLinkHeader(t *testing.T) {
+	svc, repo := newInMemoryOrderService(t, &clientmock.IStarAPI{})
+	h := NewOrderHandler(svc, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/orders", h.ListOrdersHandler)
+
+	now := time.Now()
+	for i := range 5 {
+		order := &models.Order{
+			ID:           uuid.New(),
+			IStarOrderID: "istar-" + strconv.Itoa(i),
+			Type:         models.OrderTypeStar,
+			Status:       models.StatusCompleted,
+			Username:     "alice_1",
+			WalletType:   "ton",
+			CreatedAt:    now.Add(-time.Duration(i) * time.Minute),
+			UpdatedAt:    now,
+			ClientID:     "client-a",
+		}
+		if err := repo.CreateOrder(context.Background(), order); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name, query string
+		wantOffset  int
+		wantLink    string
+	}{
+		{"first page", "status=completed&limit=2", 0,
+			`</orders?limit=2&status=completed>; rel="first", ` +
+				`</orders?limit=2&offset=2&status=completed>; rel="next"`},
+		{"middle page", "status=completed&limit=2&offset=2", 2,
+			`</orders?limit=2&status=completed>; rel="first", ` +
+				`</orders?limit=2&offset=0&status=completed>; rel="prev", ` +
+				`</orders?limit=2&offset=4&status=completed>; rel="next"`},
+		{"last page", "status=completed&limit=2&offset=4", 4,
+			`</orders?limit=2&status=completed>; rel="first", ` +
+				`</orders?limit=2&offset=2&status=completed>; rel="prev"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders?"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+			}
+
+			if got := w.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("Link = %s\nwant %s", got, tt.wantLink)
+			}
+			var resp struct {
+				Data models.OrderListResponse `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("body %q: %v", w.Body, err)
+			}
+			if got := resp.Data; got.Total != 5 || got.Limit != 2 || got.Offset != tt.wantOffset {
+				t.Errorf("total, limit, offset = %d, %d, %d; want 5, 2, %d", got.Total, got.Limit, got.Offset, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestOrderListLinksForCursorPages(t *testing.T) {
+	u, _ := url.Parse("/orders?limit=2&cursor=abc")
+
+	got := orderListLinks(u, &models.OrderListResponse{Limit: 2, NextCursor: "def"})
+	want := `</orders?limit=2>; rel="first", </orders?cursor=def&limit=2>; rel="next"`
+	if got != want {
+		t.Errorf("links = %s\nwant %s", got, want)
+	}
+
+	if got := orderListLinks(u, &models.OrderListResponse{Limit: 2}); got != `</orders?limit=2>; rel="first"` {
+		t.Errorf("links on the final cursor page = %s, want only first", got)
+	}
+}
+
+func TestExportOrdersCSV(t *testing.T) {
+	svc, repo := newInMemoryOrderService(t, &clientmock.IStarAPI{})
+	h := NewOrderHandler(svc, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/orders/export", h.ExportOrdersHandler)
+
+	created := time.Date(2026, 3, 4, 5, 6, 7, 0, time.FixedZone("EAT", 3*60*60))
+	completed := created.Add(90 * time.Second)
+	txHash := "0xabc"
+	amount, _ := models.ParseAmount("12.5")
+	done := &models.Order{
+		ID:           uuid.New(),
+		IStarOrderID: "istar-1",
+		Type:         models.OrderTypeStar,
+		Status:       models.StatusCompleted,
+		Username:     "alice_1",
+		Amount:       amount,
+		WalletType:   "ton",
+		TxHash:       &txHash,
+		CreatedAt:    created,
+		UpdatedAt:    completed,
+		CompletedAt:  &completed,
+		ClientID:     "client-a",
+	}
+	pending := &models.Order{
+		ID:           uuid.New(),
+		IStarOrderID: "istar-2",
+		Type:         models.OrderTypePremium,
+		Status:       models.StatusPending,
+		Username:     "bob_1",
+		WalletType:   "usdt",
+		CreatedAt:    created,
+		UpdatedAt:    created,
+		ClientID:     "client-a",
+	}
+	for _, order := range []*models.Order{done, pending} {
+		if err := repo.CreateOrder(context.Background(), order); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export?status=completed", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="orders-`) || !strings.HasSuffix(cd, `.csv"`) {
+		t.Errorf("Content-Disposition = %q, want an orders-*.csv attachment", cd)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	want := [][]string{
+		{"id", "type", "status", "username", "amount", "wallet_type", "tx_hash", "created_at", "completed_at"},
+		{done.ID.String(), "star", "completed", "alice_1", "12.5", "ton", "0xabc", "2026-03-04T02:06:07Z", "2026-03-04T02:07:37Z"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV = %q\nwant %q", records, want)
+	}
+}
+
+func TestExportOrdersSpansPages(t *testing.T) {
+	svc, repo := newInMemoryOrderService(t, &clientmock.IStarAPI{})
+	h := NewOrderHandler(svc, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/orders/export", h.ExportOrdersHandler)
+
+	now := time.Now()
+	total := maxOrderListLimit + 5
+	for i := range total {
+		order := &models.Order{
+			ID:           uuid.New(),
+			IStarOrderID: "istar-" + strconv.Itoa(i),
+			Type:         models.OrderTypeStar,
+			Status:       models.StatusPending,
+			WalletType:   "ton",
+			CreatedAt:    now.Add(-time.Duration(i) * time.Second),
+			UpdatedAt:    now,
+			ClientID:     "client-a",
+		}
+		if err := repo.CreateOrder(context.Background(), order); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/export", nil))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, record := range records[1:] {
+		seen[record[0]] = true
+	}
+	if len(records) != total+1 || len(seen) != total {
+		t.Errorf("exported %d rows (%d distinct), want all %d orders once", len(records)-1, len(seen), total)
+	}
+}
@@ -1,21 +1,27 @@
 package handlers
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/hulupay/istar-api/internal/client"
 	"github.com/hulupay/istar-api/internal/models"
 	"github.com/hulupay/istar-api/internal/services"
+	"github.com/hulupay/istar-api/pkg/cache"
 	"go.uber.org/zap"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 // PremiumHandler handles premium gift and package endpoints
 type PremiumHandler struct {
-	orderService services.OrderService
-	istarClient  *client.IStarClient
-	logger       *zap.Logger
+	orderService    services.OrderService
+	istarClient     client.IStarAPI
+	notFoundOnEmpty bool
+	searchCache     *cache.LRU[string, *models.PremiumRecipientResponse]
+	walletTypes     models.WalletTypes
+	allowlist       models.UsernameAllowlist
+	logger          *zap.Logger
 }
 
 // NewPremiumHandler initializes a new PremiumHandler
@@ -24,24 +30,31 @@ type PremiumHandler struct {
 // @Description  Handle operations related to premium gifting
 // @Tags         premium
 // @Router       /premium/recipient/search [get]
-func NewPremiumHandler(orderService services.OrderService, istarClient *client.IStarClient, logger *zap.Logger) *PremiumHandler {
+func NewPremiumHandler(orderService services.OrderService, istarClient client.IStarAPI, notFoundOnEmpty bool, searchCache *cache.LRU[string, *models.PremiumRecipientResponse], walletTypes models.WalletTypes, allowlist models.UsernameAllowlist, logger *zap.Logger) *PremiumHandler {
 	return &PremiumHandler{
-		orderService: orderService,
-		istarClient:  istarClient,
-		logger:       logger.Named("premium_handler"),
+		orderService:    orderService,
+		istarClient:     istarClient,
+		notFoundOnEmpty: notFoundOnEmpty,
+		searchCache:     searchCache,
+		walletTypes:     walletTypes,
+		allowlist:       allowlist,
+		logger:          logger.Named("premium_handler"),
 	}
 }
 
 // SearchPremiumRecipientHandler godoc
 // @Summary      Search for a premium recipient
-// @Description  Searches for a premium recipient by username and months
+// @Description  Searches for a premium recipient by username and months. When nobody matches, the list is empty, or the search answers 404 RECIPIENT_NOT_FOUND if RECIPIENT_NOT_FOUND_ON_EMPTY is set.
 // @Tags         premium
 // @Accept       json
 // @Produce      json
-// @Param        username  query     string  true  "Username of the recipient"
+// @Param        username  query     string  true  "Telegram username of the recipient (5-32 letters, digits or underscores)"
 // @Param        months    query     int     true  "Number of months (3, 6, or 12)"
-// @Success      200       {object}  models.PremiumRecipientResponse
+// @Param        nocache   query     bool    false "Skip the recipient cache"
+// @Success      200       {object}  models.SuccessResponse{data=models.PremiumRecipientResponse}
 // @Failure      400       {object}  models.ErrorResponse
+// @Failure      404       {object}  models.ErrorResponse
+// @Router       /premium/recipient/search [get]
 func (h *PremiumHandler) SearchPremiumRecipientHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 	username := c.Query("username")
@@ -53,6 +66,12 @@ func (h *PremiumHandler) SearchPremiumRecipientHandler(c *gin.Context) {
 		return
 	}
 
+	if !isValidUsername(username) {
+		h.logger.Error("Invalid username", zap.String("username", username))
+		c.Error(models.ValidationError("Username must be 5-32 letters, digits or underscores"))
+		return
+	}
+
 	months, err := strconv.Atoi(monthsStr)
 	if err != nil || !isValidMonths(months) {
 		h.logger.Error("Invalid months")
@@ -60,41 +79,87 @@ func (h *PremiumHandler) SearchPremiumRecipientHandler(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.istarClient.DoRequest(ctx, "GET", fmt.Sprintf("/premium/recipient/search?username=%s&months=%d", username, months), nil)
-	if err != nil {
-		h.logger.Error("Failed to search premium recipient", zap.Error(err))
-		c.Error(err)
-		return
+	cacheKey := recipientCacheKey(username, months)
+	resp, cached := h.searchCache.Get(cacheKey)
+	if !cached || bypassCache(c) {
+		resp, err = h.istarClient.SearchPremiumRecipient(ctx, username, months)
+		if err != nil {
+			h.logger.Error("Failed to search premium recipient", zap.Error(err))
+			c.Error(err)
+			return
+		}
+		if len(resp.Recipients) == 0 {
+			resp.Recipients = []models.Recipient{}
+		}
+		h.searchCache.Set(cacheKey, resp)
+	}
+
+	if len(resp.Recipients) == 0 {
+		h.logger.Info("No premium recipient found", zap.String("username", username))
+		if h.notFoundOnEmpty {
+			respondRecipientNotFound(c)
+			return
+		}
 	}
 
 	h.logger.Info("Premium recipient searched", zap.String("username", username))
-	c.JSON(http.StatusOK, resp)
+	respondOK(c, resp)
 }
 
 // CreatePremiumGiftAsyncHandler godoc
 // @Summary      Create a premium gift order (asynchronous)
-// @Description  Creates a premium gift order asynchronously
+// @Description  Creates a premium gift order asynchronously. recipient_hash may be omitted, in which case it is resolved by searching for username.
 // @Tags         premium
 // @Accept       json
 // @Produce      json
 // @Param        request  body     models.CreatePremiumOrderRequest  true  "Create premium order request"
-// @Success      202      {object}  models.CreatePremiumOrderResponse
+// @Success      202      {object}  models.SuccessResponse{data=models.CreatePremiumOrderResponse}
+// @Header       202      {string}  Idempotency-Replayed  "true when the order was returned for a repeated Idempotency-Key"
 // @Failure      400      {object}  models.ErrorResponse
+// @Failure      403      {object}  models.ErrorResponse
+// @Failure      404      {object}  models.ErrorResponse
+// @Failure      409      {object}  models.ErrorResponse
 func (h *PremiumHandler) CreatePremiumGiftAsyncHandler(c *gin.Context) {
 	var req models.CreatePremiumOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Invalid request body", zap.Error(err))
-		c.Error(models.ValidationError("Invalid request body: " + err.Error()))
+		c.Error(bindingError(err))
 		return
 	}
 
-	if req.Username == "" || req.RecipientHash == "" || !isValidMonths(req.Months) || req.WalletType == "" {
+	idempotencyKey, err := idempotencyKeyFromHeader(c)
+	if err != nil {
+		h.logger.Error("Invalid idempotency key", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	req.IdempotencyKey = idempotencyKey
+
+	if req.Username == "" || !isValidMonths(req.Months) || req.WalletType == "" {
 		h.logger.Error("Invalid request parameters")
-		c.Error(models.ValidationError("Invalid request parameters: username, recipient_hash, months (3, 6, 12), wallet_type required"))
+		c.Error(models.ValidationError("Invalid request parameters: username, months (3, 6, 12), wallet_type required"))
 		return
 	}
 
-	resp, err := h.orderService.CreatePremiumOrderAsync(c, req)
+	if !isValidUsername(req.Username) {
+		h.logger.Error("Invalid username", zap.String("username", req.Username))
+		c.Error(models.ValidationError("Username must be 5-32 letters, digits or underscores"))
+		return
+	}
+
+	if err := h.walletTypes.Validate(req.WalletType); err != nil {
+		h.logger.Error("Invalid wallet type", zap.String("wallet_type", string(req.WalletType)))
+		c.Error(err)
+		return
+	}
+
+	if err := h.allowlist.Validate(req.Username); err != nil {
+		h.logger.Warn("Recipient not on allowlist", zap.String("username", req.Username))
+		c.Error(err)
+		return
+	}
+
+	resp, err := h.orderService.CreatePremiumOrderAsync(c.Request.Context(), req)
 	if err != nil {
 		h.logger.Error("Failed to create premium gift order", zap.Error(err))
 		c.Error(err)
@@ -102,33 +167,111 @@ func (h *PremiumHandler) CreatePremiumGiftAsyncHandler(c *gin.Context) {
 	}
 
 	h.logger.Info("Premium gift order created (async)", zap.String("order_id", resp.ID.String()))
-	c.JSON(http.StatusAccepted, resp)
+	setReplayedHeader(c, resp)
+	respond(c, http.StatusAccepted, resp)
+}
+
+// QuotePremiumOrderHandler godoc
+// @Summary      Quote a premium gift order
+// @Description  Prices a premium gift order without placing it. Pass the returned quote_id when creating the order to lock the price until expires_at.
+// @Tags         premium
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.CreatePremiumOrderRequest  true  "Order to quote"
+// @Success      200      {object}  models.SuccessResponse{data=models.OrderQuoteResponse}
+// @Failure      400      {object}  models.ErrorResponse
+// @Router       /orders/premium/quote [post]
+func (h *PremiumHandler) QuotePremiumOrderHandler(c *gin.Context) {
+	var req models.CreatePremiumOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid request body", zap.Error(err))
+		c.Error(bindingError(err))
+		return
+	}
+
+	if req.Username == "" || !isValidMonths(req.Months) || req.WalletType == "" {
+		h.logger.Error("Invalid request parameters")
+		c.Error(models.ValidationError("Invalid request parameters: username, months (3, 6, 12), wallet_type required"))
+		return
+	}
+
+	if !isValidUsername(req.Username) {
+		h.logger.Error("Invalid username", zap.String("username", req.Username))
+		c.Error(models.ValidationError("Username must be 5-32 letters, digits or underscores"))
+		return
+	}
+
+	if err := h.walletTypes.Validate(req.WalletType); err != nil {
+		h.logger.Error("Invalid wallet type", zap.String("wallet_type", string(req.WalletType)))
+		c.Error(err)
+		return
+	}
+
+	quote, err := h.orderService.QuotePremiumOrder(c.Request.Context(), req)
+	if err != nil {
+		h.logger.Error("Failed to quote premium gift order", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, quote)
 }
 
 // CreatePremiumGiftSyncHandler godoc
 // @Summary      Create a premium gift order (synchronous)
-// @Description  Creates a premium gift order synchronously
+// @Description  Creates a premium gift order synchronously. recipient_hash may be omitted, in which case it is resolved by searching for username. If iStar does not answer in time the order is recorded as pending and 202 is returned; it then settles like an asynchronous order.
 // @Tags         premium
 // @Accept       json
 // @Produce      json
 // @Param        request  body     models.CreatePremiumOrderRequest  true  "Create premium order request"
-// @Success      200      {object}  models.CreatePremiumOrderResponse
+// @Success      200      {object}  models.SuccessResponse{data=models.CreatePremiumOrderResponse}
+// @Success      202      {object}  models.SuccessResponse{data=models.CreatePremiumOrderResponse}
+// @Header       200      {string}  Idempotency-Replayed  "true when the order was returned for a repeated Idempotency-Key"
 // @Failure      400      {object}  models.ErrorResponse
+// @Failure      403      {object}  models.ErrorResponse
+// @Failure      404      {object}  models.ErrorResponse
+// @Failure      409      {object}  models.ErrorResponse
 func (h *PremiumHandler) CreatePremiumGiftSyncHandler(c *gin.Context) {
 	var req models.CreatePremiumOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Invalid request body", zap.Error(err))
-		c.Error(models.ValidationError("Invalid request body: " + err.Error()))
+		c.Error(bindingError(err))
+		return
+	}
+
+	idempotencyKey, err := idempotencyKeyFromHeader(c)
+	if err != nil {
+		h.logger.Error("Invalid idempotency key", zap.Error(err))
+		c.Error(err)
 		return
 	}
+	req.IdempotencyKey = idempotencyKey
 
-	if req.Username == "" || req.RecipientHash == "" || !isValidMonths(req.Months) || req.WalletType == "" {
+	if req.Username == "" || !isValidMonths(req.Months) || req.WalletType == "" {
 		h.logger.Error("Invalid request parameters")
-		c.Error(models.ValidationError("Invalid request parameters: username, recipient_hash, months (3, 6, 12), wallet_type required"))
+		c.Error(models.ValidationError("Invalid request parameters: username, months (3, 6, 12), wallet_type required"))
 		return
 	}
 
-	resp, err := h.orderService.CreatePremiumOrderSync(c, req)
+	if !isValidUsername(req.Username) {
+		h.logger.Error("Invalid username", zap.String("username", req.Username))
+		c.Error(models.ValidationError("Username must be 5-32 letters, digits or underscores"))
+		return
+	}
+
+	if err := h.walletTypes.Validate(req.WalletType); err != nil {
+		h.logger.Error("Invalid wallet type", zap.String("wallet_type", string(req.WalletType)))
+		c.Error(err)
+		return
+	}
+
+	if err := h.allowlist.Validate(req.Username); err != nil {
+		h.logger.Warn("Recipient not on allowlist", zap.String("username", req.Username))
+		c.Error(err)
+		return
+	}
+
+	resp, err := h.orderService.CreatePremiumOrderSync(c.Request.Context(), req)
 	if err != nil {
 		h.logger.Error("Failed to create premium gift order", zap.Error(err))
 		c.Error(err)
@@ -136,7 +279,7 @@ func (h *PremiumHandler) CreatePremiumGiftSyncHandler(c *gin.Context) {
 	}
 
 	h.logger.Info("Premium gift order created (sync)", zap.String("order_id", resp.ID.String()))
-	c.JSON(http.StatusOK, resp)
+	respondSyncOrder(c, resp)
 }
 
 // GetPremiumPackagesHandler godoc
@@ -144,20 +287,43 @@ func (h *PremiumHandler) CreatePremiumGiftSyncHandler(c *gin.Context) {
 // @Description  Retrieves the available premium packages
 // @Tags         premium
 // @Produce      json
-// @Success      200      {object}  models.PremiumPackagesResponse
+// @Success      200      {object}  models.SuccessResponse{data=models.PremiumPackagesResponse}
 // @Failure      400      {object}  models.ErrorResponse
 // @Router       /premium/packages [get]
 func (h *PremiumHandler) GetPremiumPackagesHandler(c *gin.Context) {
-	ctx := c.Request.Context()
-	resp, err := h.istarClient.DoRequest(ctx, "GET", "/premium/packages", nil)
+	resp, err := h.istarClient.GetPremiumPackages(c.Request.Context())
 	if err != nil {
 		h.logger.Error("Failed to retrieve premium packages", zap.Error(err))
 		c.Error(err)
 		return
 	}
 
-	h.logger.Info("Premium packages retrieved")
-	c.JSON(http.StatusOK, resp)
+	h.logger.Info("Premium packages retrieved", zap.Int("count", len(resp.Packages)))
+	respondOK(c, resp)
+}
+
+// respondRecipientNotFound answers a recipient search that matched nobody
+func respondRecipientNotFound(c *gin.Context) {
+	c.Error(models.RecipientNotFoundError("Recipient not found"))
+}
+
+// recipientCacheKey identifies a recipient search; usernames are case-insensitive
+func recipientCacheKey(username string, n int) string {
+	return strings.ToLower(username) + "|" + strconv.Itoa(n)
+}
+
+// bypassCache reports whether the caller asked for a fresh upstream lookup
+func bypassCache(c *gin.Context) bool {
+	nocache, _ := strconv.ParseBool(c.Query("nocache"))
+	return nocache
+}
+
+// usernamePattern matches a Telegram username: 5-32 letters, digits or underscores
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{5,32}$`)
+
+// isValidUsername checks a username before it is sent to iStar
+func isValidUsername(username string) bool {
+	return usernamePattern.MatchString(username)
 }
 
 // isValidMonths checks if the given months value is valid (3, 6, or 12)
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/pkg/cache"
+	"go.uber.org/zap"
+)
+
+func TestGetPremiumPackagesHandler(t *testing.T) {
+	price, _ := models.ParseAmount("11.99")
+	istar := &clientmock.IStarAPI{
+		GetPremiumPackagesFunc: func(context.Context) (*models.PremiumPackagesResponse, error) {
+			return &models.PremiumPackagesResponse{Packages: []models.PremiumPackage{
+				{PackageID: "premium-3m", Months: 3, Price: price, Currency: "USD"},
+			}}, nil
+		},
+	}
+	h := NewPremiumHandler(&fakeOrderService{}, istar, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/premium/packages", h.GetPremiumPackagesHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/premium/packages", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data struct {
+			Packages []map[string]any `json:"packages"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Data.Packages) != 1 {
+		t.Fatalf("packages = %v, want one", resp.Data.Packages)
+	}
+	pkg := resp.Data.Packages[0]
+	if pkg["package_id"] != "premium-3m" || pkg["months"] != float64(3) || pkg["price"] != 11.99 || pkg["currency"] != "USD" {
+		t.Errorf("package = %v, want premium-3m for 11.99 USD", pkg)
+	}
+}
+
+func TestSearchPremiumRecipientHandler(t *testing.T) {
+	var searches atomic.Int32
+	istar := &clientmock.IStarAPI{
+		SearchPremiumRecipientFunc: func(ctx context.Context, username string, months int) (*models.PremiumRecipientResponse, error) {
+			searches.Add(1)
+			return &models.PremiumRecipientResponse{
+				Username:   username,
+				Months:     months,
+				Recipients: []models.Recipient{{RecipientHash: "hash-alice", Username: username}},
+			}, nil
+		},
+	}
+	searchCache := cache.NewLRU[string, *models.PremiumRecipientResponse](time.Minute, 10)
+	h := NewPremiumHandler(nil, istar, false, searchCache, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/premium/recipient/search", h.SearchPremiumRecipientHandler)
+
+	for _, months := range []string{"0", "1", "4", "24", "-3", "three", "6.0"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/premium/recipient/search?username=alice_1&months="+months, nil))
+
+		var resp models.ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusBadRequest || resp.Error != "Months must be 3, 6, or 12" {
+			t.Errorf("months=%s: got %d %s, want 400 naming the allowed months", months, w.Code, w.Body)
+		}
+	}
+	if n := searches.Load(); n != 0 {
+		t.Fatalf("iStar searches = %d, want invalid months refused before the call", n)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/premium/recipient/search?username=alice_1&months=6", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data models.PremiumRecipientResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Data.Months != 6 || len(resp.Data.Recipients) != 1 || resp.Data.Recipients[0].RecipientHash != "hash-alice" {
+		t.Errorf("data = %+v, want the typed search result", resp.Data)
+	}
+}
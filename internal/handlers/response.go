@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+// respondOK writes data with status 200 in the standard success envelope
+func respondOK(c *gin.Context, data interface{}) {
+	respond(c, http.StatusOK, data)
+}
+
+// respond writes data in the standard success envelope, tagged with the
+// request id. ETags are computed from data alone so the per-request id does
+// not defeat conditional requests.
+func respond(c *gin.Context, status int, data interface{}) {
+	c.Set(middleware.ETagSourceKey, data)
+	c.JSON(status, models.SuccessResponse{
+		Data:      data,
+		RequestID: c.GetString(middleware.RequestIDKey),
+	})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/middleware"
+)
+
+// envelopeRouter serves GET /thing through RequestID and ETag, answering with
+// respondOK
+func envelopeRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.ETag())
+	r.GET("/thing", func(c *gin.Context) {
+		respondOK(c, gin.H{"name": "thing", "count": 2})
+	})
+	return r
+}
+
+func TestRespondOKEnvelope(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+	}{
+		{"generated request id", ""},
+		{"client request id", "client-req-7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/thing", nil)
+			if tt.incoming != "" {
+				req.Header.Set(middleware.RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			envelopeRouter().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q: %v", w.Body, err)
+			}
+			if len(body) != 2 || body["data"] == nil || body["request_id"] == nil {
+				t.Fatalf("body = %s, want exactly data and request_id", w.Body)
+			}
+			if string(body["data"]) != `{"count":2,"name":"thing"}` {
+				t.Errorf("data = %s, want the payload unchanged", body["data"])
+			}
+
+			var requestID string
+			json.Unmarshal(body["request_id"], &requestID)
+			if requestID == "" || requestID != w.Header().Get(middleware.RequestIDHeader) {
+				t.Errorf("request_id = %q, want the %s header %q", requestID, middleware.RequestIDHeader, w.Header().Get(middleware.RequestIDHeader))
+			}
+			if tt.incoming != "" && requestID != tt.incoming {
+				t.Errorf("request_id = %q, want the client's %q", requestID, tt.incoming)
+			}
+		})
+	}
+}
+
+func TestRespondOKETagIgnoresRequestID(t *testing.T) {
+	r := envelopeRouter()
+	get := func(requestID, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/thing", nil)
+		req.Header.Set(middleware.RequestIDHeader, requestID)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("req-1", "")
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag on the first response")
+	}
+	if second := get("req-2", etag); second.Code != http.StatusNotModified {
+		t.Errorf("same data under another request id = %d, want 304", second.Code)
+	}
+}
@@ -1,11 +1,11 @@
 package handlers
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/hulupay/istar-api/internal/client"
 	"github.com/hulupay/istar-api/internal/models"
 	"github.com/hulupay/istar-api/internal/services"
+	"github.com/hulupay/istar-api/pkg/cache"
 	"go.uber.org/zap"
 	"net/http"
 	"strconv"
@@ -13,9 +13,13 @@ import (
 
 // StarHandler handles star gifting endpoints
 type StarHandler struct {
-	orderService services.OrderService
-	istarClient  *client.IStarClient
-	logger       *zap.Logger
+	orderService    services.OrderService
+	istarClient     client.IStarAPI
+	notFoundOnEmpty bool
+	searchCache     *cache.LRU[string, *models.StarRecipientResponse]
+	walletTypes     models.WalletTypes
+	allowlist       models.UsernameAllowlist
+	logger          *zap.Logger
 }
 
 // NewStarHandler godoc
@@ -25,30 +29,36 @@ type StarHandler struct {
 // @Accept       json
 // @Produce      json
 // @Param        orderService  query     services.OrderService  true  "Order service"
-// @Param        istarClient   query     *client.IStarClient     true  "iStar client"
+// @Param        istarClient   query     client.IStarAPI        true  "iStar client"
 // @Param        logger        query     *zap.Logger            true  "Logger"
 // @Success      200          {object}  handlers.StarHandler
 // @Failure      400          {object}  models.ErrorResponse
 // @Router       /star/handler [get]
 // NewStarHandler initializes a new StarHandler
-func NewStarHandler(orderService services.OrderService, istarClient *client.IStarClient, logger *zap.Logger) *StarHandler {
+func NewStarHandler(orderService services.OrderService, istarClient client.IStarAPI, notFoundOnEmpty bool, searchCache *cache.LRU[string, *models.StarRecipientResponse], walletTypes models.WalletTypes, allowlist models.UsernameAllowlist, logger *zap.Logger) *StarHandler {
 	return &StarHandler{
-		orderService: orderService,
-		istarClient:  istarClient,
-		logger:       logger.Named("star_handler"),
+		orderService:    orderService,
+		istarClient:     istarClient,
+		notFoundOnEmpty: notFoundOnEmpty,
+		searchCache:     searchCache,
+		walletTypes:     walletTypes,
+		allowlist:       allowlist,
+		logger:          logger.Named("star_handler"),
 	}
 }
 
 // SearchStarRecipientHandler godoc
 // @Summary      Search for star recipients
-// @Description  Retrieves a list of potential recipients for star gifting
+// @Description  Retrieves a list of potential recipients for star gifting. When nobody matches, the list is empty, or the search answers 404 RECIPIENT_NOT_FOUND if RECIPIENT_NOT_FOUND_ON_EMPTY is set.
 // @Tags         star
 // @Accept       json
 // @Produce      json
-// @Param        username  query     string  true  "Username to search for"
+// @Param        username  query     string  true  "Telegram username to search for (5-32 letters, digits or underscores)"
 // @Param        quantity  query     int     true  "Quantity of stars to gift (50-1,000,000)"
-// @Success      200       {array}   map[string]interface{}
-// @Failure      400       {object}
+// @Param        nocache   query     bool    false "Skip the recipient cache"
+// @Success      200       {object}  models.SuccessResponse{data=models.StarRecipientResponse}
+// @Failure      400       {object}  models.ErrorResponse
+// @Failure      404       {object}  models.ErrorResponse
 // @Router       /star/recipient/search [get]
 func (h *StarHandler) SearchStarRecipientHandler(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -61,6 +71,12 @@ func (h *StarHandler) SearchStarRecipientHandler(c *gin.Context) {
 		return
 	}
 
+	if !isValidUsername(username) {
+		h.logger.Error("Invalid username", zap.String("username", username))
+		c.Error(models.ValidationError("Username must be 5-32 letters, digits or underscores"))
+		return
+	}
+
 	quantity, err := strconv.Atoi(quantityStr)
 	if err != nil || quantity < 50 || quantity > 1000000 {
 		h.logger.Error("Invalid quantity")
@@ -68,42 +84,149 @@ func (h *StarHandler) SearchStarRecipientHandler(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.istarClient.DoRequest(ctx, "GET", fmt.Sprintf("/star/recipient/search?username=%s&quantity=%d", username, quantity), nil)
+	cacheKey := recipientCacheKey(username, quantity)
+	resp, cached := h.searchCache.Get(cacheKey)
+	if !cached || bypassCache(c) {
+		resp, err = h.istarClient.SearchStarRecipient(ctx, username, quantity)
+		if err != nil {
+			h.logger.Error("Failed to search star recipient", zap.Error(err))
+			c.Error(err)
+			return
+		}
+		if len(resp.Recipients) == 0 {
+			resp.Recipients = []models.Recipient{}
+		}
+		h.searchCache.Set(cacheKey, resp)
+	}
+
+	if len(resp.Recipients) == 0 {
+		h.logger.Info("No star recipient found", zap.String("username", username))
+		if h.notFoundOnEmpty {
+			respondRecipientNotFound(c)
+			return
+		}
+	}
+
+	h.logger.Info("Star recipient searched", zap.String("username", username))
+	respondOK(c, resp)
+}
+
+// CreateStarGiftBatchHandler godoc
+// @Summary      Create star gift orders in bulk
+// @Description  Creates one asynchronous star order per item. Items succeed or fail independently: 202 when every item was accepted, 207 with per-item errors otherwise. Instead of per-item quantities, a batch may give total_quantity and a weight per item; the total is split in proportion to the weights, with at least 50 stars per recipient, and a total that cannot be split that way is rejected with 400. An item's recipient_hash may be omitted, in which case it is resolved by searching for its username.
+// @Tags         star
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.BatchStarOrderRequest  true  "Batch star order request"
+// @Success      202      {object}  models.SuccessResponse{data=models.BatchOrderResponse}
+// @Success      207      {object}  models.SuccessResponse{data=models.BatchOrderResponse}
+// @Failure      400      {object}  models.ErrorResponse
+// @Router       /orders/star/batch [post]
+func (h *StarHandler) CreateStarGiftBatchHandler(c *gin.Context) {
+	var req models.BatchStarOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid request body", zap.Error(err))
+		c.Error(bindingError(err))
+		return
+	}
+
+	idempotencyKey, err := idempotencyKeyFromHeader(c)
 	if err != nil {
-		h.logger.Error("Failed to search star recipient", zap.Error(err))
+		h.logger.Error("Invalid idempotency key", zap.Error(err))
 		c.Error(err)
 		return
 	}
+	req.IdempotencyKey = idempotencyKey
 
-	h.logger.Info("Star recipient searched", zap.String("username", username))
-	c.JSON(http.StatusOK, resp)
+	if err := h.walletTypes.Validate(req.WalletType); err != nil {
+		h.logger.Error("Invalid wallet type", zap.String("wallet_type", string(req.WalletType)))
+		c.Error(err)
+		return
+	}
+
+	for i, item := range req.Items {
+		if !isValidUsername(item.Username) {
+			h.logger.Error("Invalid username", zap.Int("index", i), zap.String("username", item.Username))
+			c.Error(models.ValidationError("Item " + strconv.Itoa(i) + ": username must be 5-32 letters, digits or underscores"))
+			return
+		}
+		if err := h.allowlist.Validate(item.Username); err != nil {
+			h.logger.Warn("Recipient not on allowlist", zap.String("username", item.Username))
+			c.Error(err)
+			return
+		}
+	}
+
+	resp, err := h.orderService.CreateStarOrdersBatch(c.Request.Context(), req)
+	if err != nil {
+		h.logger.Error("Failed to create star gift batch", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	h.logger.Info("Star gift batch processed", zap.Int("succeeded", resp.Succeeded), zap.Int("failed", resp.Failed))
+
+	status := http.StatusAccepted
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	respond(c, status, resp)
 }
 
 // CreateStarGiftAsyncHandler godoc
 // @Summary      Create star gift order (asynchronous)
-// @Description  Creates a star gift order asynchronously
+// @Description  Creates a star gift order asynchronously. recipient_hash may be omitted, in which case it is resolved by searching for username.
 // @Tags         star
 // @Accept       json
 // @Produce      json
 // @Param        request  body     models.CreateStarOrderRequest  true  "Create star order request"
-// @Success      202      {object}  models.CreateStarOrderResponse
+// @Success      202      {object}  models.SuccessResponse{data=models.CreateStarOrderResponse}
+// @Header       202      {string}  Idempotency-Replayed  "true when the order was returned for a repeated Idempotency-Key"
 // @Failure      400      {object}  models.ErrorResponse
+// @Failure      403      {object}  models.ErrorResponse
+// @Failure      404      {object}  models.ErrorResponse
+// @Failure      409      {object}  models.ErrorResponse
 // @Router       /star/gift/async [post]
 func (h *StarHandler) CreateStarGiftAsyncHandler(c *gin.Context) {
 	var req models.CreateStarOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Invalid request body", zap.Error(err))
-		c.Error(models.ValidationError("Invalid request body: " + err.Error()))
+		c.Error(bindingError(err))
 		return
 	}
 
-	if req.Username == "" || req.RecipientHash == "" || req.Quantity < 50 || req.Quantity > 1000000 || req.WalletType == "" {
+	idempotencyKey, err := idempotencyKeyFromHeader(c)
+	if err != nil {
+		h.logger.Error("Invalid idempotency key", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	req.IdempotencyKey = idempotencyKey
+
+	if req.Username == "" || req.Quantity < 50 || req.Quantity > 1000000 || req.WalletType == "" {
 		h.logger.Error("Invalid request parameters")
-		c.Error(models.ValidationError("Invalid request parameters: username, recipient_hash, quantity (50-1,000,000), wallet_type required"))
+		c.Error(models.ValidationError("Invalid request parameters: username, quantity (50-1,000,000), wallet_type required"))
+		return
+	}
+
+	if !isValidUsername(req.Username) {
+		h.logger.Error("Invalid username", zap.String("username", req.Username))
+		c.Error(models.ValidationError("Username must be 5-32 letters, digits or underscores"))
 		return
 	}
 
-	resp, err := h.orderService.CreateStarOrderAsync(c, req)
+	if err := h.walletTypes.Validate(req.WalletType); err != nil {
+		h.logger.Error("Invalid wallet type", zap.String("wallet_type", string(req.WalletType)))
+		c.Error(err)
+		return
+	}
+
+	if err := h.allowlist.Validate(req.Username); err != nil {
+		h.logger.Warn("Recipient not on allowlist", zap.String("username", req.Username))
+		c.Error(err)
+		return
+	}
+
+	resp, err := h.orderService.CreateStarOrderAsync(c.Request.Context(), req)
 	if err != nil {
 		h.logger.Error("Failed to create star gift order", zap.Error(err))
 		c.Error(err)
@@ -111,34 +234,112 @@ func (h *StarHandler) CreateStarGiftAsyncHandler(c *gin.Context) {
 	}
 
 	h.logger.Info("Star gift order created (async)", zap.String("order_id", resp.ID.String()))
-	c.JSON(http.StatusAccepted, resp)
+	setReplayedHeader(c, resp)
+	respond(c, http.StatusAccepted, resp)
+}
+
+// QuoteStarOrderHandler godoc
+// @Summary      Quote a star gift order
+// @Description  Prices a star gift order without placing it. Pass the returned quote_id when creating the order to lock the price until expires_at.
+// @Tags         star
+// @Accept       json
+// @Produce      json
+// @Param        request  body      models.CreateStarOrderRequest  true  "Order to quote"
+// @Success      200      {object}  models.SuccessResponse{data=models.OrderQuoteResponse}
+// @Failure      400      {object}  models.ErrorResponse
+// @Router       /orders/star/quote [post]
+func (h *StarHandler) QuoteStarOrderHandler(c *gin.Context) {
+	var req models.CreateStarOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid request body", zap.Error(err))
+		c.Error(bindingError(err))
+		return
+	}
+
+	if req.Username == "" || req.Quantity < 50 || req.Quantity > 1000000 || req.WalletType == "" {
+		h.logger.Error("Invalid request parameters")
+		c.Error(models.ValidationError("Invalid request parameters: username, quantity (50-1,000,000), wallet_type required"))
+		return
+	}
+
+	if !isValidUsername(req.Username) {
+		h.logger.Error("Invalid username", zap.String("username", req.Username))
+		c.Error(models.ValidationError("Username must be 5-32 letters, digits or underscores"))
+		return
+	}
+
+	if err := h.walletTypes.Validate(req.WalletType); err != nil {
+		h.logger.Error("Invalid wallet type", zap.String("wallet_type", string(req.WalletType)))
+		c.Error(err)
+		return
+	}
+
+	quote, err := h.orderService.QuoteStarOrder(c.Request.Context(), req)
+	if err != nil {
+		h.logger.Error("Failed to quote star gift order", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, quote)
 }
 
 // CreateStarGiftSyncHandler godoc
 // @Summary      Create star gift order (synchronous)
-// @Description  Creates a star gift order synchronously
+// @Description  Creates a star gift order synchronously. recipient_hash may be omitted, in which case it is resolved by searching for username. If iStar does not answer in time the order is recorded as pending and 202 is returned; it then settles like an asynchronous order.
 // @Tags         star
 // @Accept       json
 // @Produce      json
 // @Param        request  body     models.CreateStarOrderRequest  true  "Create star order request"
-// @Success      200      {object}  models.CreateStarOrderResponse
+// @Success      200      {object}  models.SuccessResponse{data=models.CreateStarOrderResponse}
+// @Success      202      {object}  models.SuccessResponse{data=models.CreateStarOrderResponse}
+// @Header       200      {string}  Idempotency-Replayed  "true when the order was returned for a repeated Idempotency-Key"
 // @Failure      400      {object}  models.ErrorResponse
+// @Failure      403      {object}  models.ErrorResponse
+// @Failure      404      {object}  models.ErrorResponse
+// @Failure      409      {object}  models.ErrorResponse
 // @Router       /star/gift/sync [post]
 func (h *StarHandler) CreateStarGiftSyncHandler(c *gin.Context) {
 	var req models.CreateStarOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		h.logger.Error("Invalid request body", zap.Error(err))
-		c.Error(models.ValidationError("Invalid request body: " + err.Error()))
+		c.Error(bindingError(err))
 		return
 	}
 
-	if req.Username == "" || req.RecipientHash == "" || req.Quantity < 50 || req.Quantity > 1000000 || req.WalletType == "" {
+	idempotencyKey, err := idempotencyKeyFromHeader(c)
+	if err != nil {
+		h.logger.Error("Invalid idempotency key", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	req.IdempotencyKey = idempotencyKey
+
+	if req.Username == "" || req.Quantity < 50 || req.Quantity > 1000000 || req.WalletType == "" {
 		h.logger.Error("Invalid request parameters")
-		c.Error(models.ValidationError("Invalid request parameters: username, recipient_hash, quantity (50-1,000,000), wallet_type required"))
+		c.Error(models.ValidationError("Invalid request parameters: username, quantity (50-1,000,000), wallet_type required"))
+		return
+	}
+
+	if !isValidUsername(req.Username) {
+		h.logger.Error("Invalid username", zap.String("username", req.Username))
+		c.Error(models.ValidationError("Username must be 5-32 letters, digits or underscores"))
+		return
+	}
+
+	if err := h.walletTypes.Validate(req.WalletType); err != nil {
+		h.logger.Error("Invalid wallet type", zap.String("wallet_type", string(req.WalletType)))
+		c.Error(err)
+		return
+	}
+
+	if err := h.allowlist.Validate(req.Username); err != nil {
+		h.logger.Warn("Recipient not on allowlist", zap.String("username", req.Username))
+		c.Error(err)
 		return
 	}
 
-	resp, err := h.orderService.CreateStarOrderSync(c, req)
+	resp, err := h.orderService.CreateStarOrderSync(c.Request.Context(), req)
 	if err != nil {
 		h.logger.Error("Failed to create star gift order", zap.Error(err))
 		c.Error(err)
@@ -146,7 +347,7 @@ func (h *StarHandler) CreateStarGiftSyncHandler(c *gin.Context) {
 	}
 
 	h.logger.Info("Star gift order created (sync)", zap.String("order_id", resp.ID.String()))
-	c.JSON(http.StatusOK, resp)
+	respondSyncOrder(c, resp)
 }
 
 /*
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/pkg/cache"
+	"go.uber.org/zap"
+)
+
+func TestStarRecipientSearchIsCached(t *testing.T) {
+	var searches atomic.Int32
+	istar := &clientmock.IStarAPI{
+		SearchStarRecipientFunc: func(ctx context.Context, username string, quantity int) (*models.StarRecipientResponse, error) {
+			searches.Add(1)
+			return &models.StarRecipientResponse{Recipients: []models.Recipient{{RecipientHash: "hash-alice", Username: username}}}, nil
+		},
+	}
+	searchCache := cache.NewLRU[string, *models.StarRecipientResponse](time.Minute, 10)
+	h := NewStarHandler(nil, istar, false, searchCache, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/star/recipient/search", h.SearchStarRecipientHandler)
+
+	steps := []struct {
+		query        string
+		wantSearches int32
+	}{
+		{"username=alice_1&quantity=50", 1},
+		{"username=alice_1&quantity=50", 1},
+		{"username=ALICE_1&quantity=50", 1},
+		{"username=alice_1&quantity=100", 2},
+		{"username=alice_1&quantity=50&nocache=true", 3},
+	}
+	for _, step := range steps {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/star/recipient/search?"+step.query, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200: %s", step.query, w.Code, w.Body)
+		}
+		if n := searches.Load(); n != step.wantSearches {
+			t.Errorf("%s: iStar searches = %d, want %d", step.query, n, step.wantSearches)
+		}
+	}
+}
+
+func TestRecipientSearchNotFoundOnEmpty(t *testing.T) {
+	tests := []struct {
+		name            string
+		recipients      []models.Recipient
+		notFoundOnEmpty bool
+		wantStatus      int
+		wantCode        string
+	}{
+		{"empty, 404 enabled", nil, true, http.StatusNotFound, models.CodeRecipientNotFound},
+		{"empty, 404 disabled", nil, false, http.StatusOK, ""},
+		{"populated, 404 enabled", []models.Recipient{{RecipientHash: "hash-alice", Username: "alice_1"}}, true, http.StatusOK, ""},
+		{"populated, 404 disabled", []models.Recipient{{RecipientHash: "hash-alice", Username: "alice_1"}}, false, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			istar := &clientmock.IStarAPI{
+				SearchStarRecipientFunc: func(ctx context.Context, username string, quantity int) (*models.StarRecipientResponse, error) {
+					return &models.StarRecipientResponse{Recipients: tt.recipients}, nil
+				},
+				SearchPremiumRecipientFunc: func(ctx context.Context, username string, months int) (*models.PremiumRecipientResponse, error) {
+					return &models.PremiumRecipientResponse{Username: username, Months: months, Recipients: tt.recipients}, nil
+				},
+			}
+			star := NewStarHandler(nil, istar, tt.notFoundOnEmpty, cache.NewLRU[string, *models.StarRecipientResponse](time.Minute, 10),
+				models.WalletTypes{"ton"}, nil, zap.NewNop())
+			premium := NewPremiumHandler(nil, istar, tt.notFoundOnEmpty, cache.NewLRU[string, *models.PremiumRecipientResponse](time.Minute, 10),
+				models.WalletTypes{"ton"}, nil, zap.NewNop())
+			r := newTestRouter("client-a")
+			r.GET("/star/recipient/search", star.SearchStarRecipientHandler)
+			r.GET("/premium/recipient/search", premium.SearchPremiumRecipientHandler)
+
+			for _, target := range []string{
+				"/star/recipient/search?username=alice_1&quantity=50",
+				"/premium/recipient/search?username=alice_1&months=3",
+			} {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+				if w.Code != tt.wantStatus {
+					t.Fatalf("GET %s: status = %d, want %d: %s", target, w.Code, tt.wantStatus, w.Body)
+				}
+
+				if tt.wantCode != "" {
+					var resp models.ErrorResponse
+					json.Unmarshal(w.Body.Bytes(), &resp)
+					if resp.Code != tt.wantCode {
+						t.Errorf("GET %s: code = %q, want %q", target, resp.Code, tt.wantCode)
+					}
+					continue
+				}
+				// An empty result is still a list, never null
+				var resp struct {
+					Data struct {
+						Recipients []models.Recipient `json:"recipients"`
+					} `json:"data"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("GET %s: decode: %v", target, err)
+				}
+				if resp.Data.Recipients == nil || len(resp.Data.Recipients) != len(tt.recipients) {
+					t.Errorf("GET %s: recipients = %v, want %d of them: %s", target, resp.Data.Recipients, len(tt.recipients), w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestIsValidUsername(t *testing.T) {
+	tests := map[string]bool{
+		"alice_1":               true,
+		"Alice":                 true,
+		strings.Repeat("a", 32): true,
+		"abcd":                  false,
+		strings.Repeat("a", 33): false,
+		"alice 1":               false,
+		"alice&quantity=1":      false,
+		"@alice_1":              false,
+		"alice/../x":            false,
+		"alicé_1":               false,
+		"alice_1\n":             false,
+	}
+	for username, want := range tests {
+		if got := isValidUsername(username); got != want {
+			t.Errorf("isValidUsername(%q) = %v, want %v", username, got, want)
+		}
+	}
+}
+
+func TestRecipientSearchRejectsSpecialCharacters(t *testing.T) {
+	star := NewStarHandler(nil, &clientmock.IStarAPI{}, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	premium := NewPremiumHandler(nil, &clientmock.IStarAPI{}, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/star/recipient/search", star.SearchStarRecipientHandler)
+	r.GET("/premium/recipient/search", premium.SearchPremiumRecipientHandler)
+
+	for _, username := range []string{"alice 1", "alice&quantity=1", "alice%2F1", "alice#1"} {
+		for _, target := range []string{
+			"/star/recipient/search?quantity=50&username=",
+			"/premium/recipient/search?months=3&username=",
+		} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target+url.QueryEscape(username), nil))
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s%q: status = %d, want 400", target, username, w.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/client"
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"github.com/hulupay/istar-api/internal/services"
+	"github.com/hulupay/istar-api/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestCreateOrderSpanHierarchy(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	if _, err := tracing.Setup(context.Background(), ""); err != nil {
+		t.Fatalf("tracing.Setup: %v", err)
+	}
+
+	traceparents := make(chan string, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("Traceparent")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(models.StarOrderResponse{
+			OrderID:   "istar-1",
+			Status:    "pending",
+			Quantity:  50,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		})
+	}))
+	defer upstream.Close()
+
+	istar, err := client.NewIStarClient(config.IStarConfig{
+		APIKey:                  "test-key",
+		BaseURL:                 upstream.URL,
+		Timeout:                 5 * time.Second,
+		BreakerFailureThreshold: 10,
+		BreakerResetTimeout:     time.Minute,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewIStarClient: %v", err)
+	}
+	background, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer func() {
+		cancel()
+		workers.Wait()
+	}()
+	svc := services.NewOrderService(background, repositories.NewInMemoryOrderRepository(), istar, config.OrderConfig{}, &workers, zap.NewNop())
+	h := NewStarHandler(svc, istar, false, nil, models.WalletTypes{"ton"}, nil, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.Use(middleware.Tracing(tracing.ServiceName))
+	r.POST("/orders/star", h.CreateStarGiftAsyncHandler)
+
+	body := `{"username":"alice_1","recipient_hash":"h","quantity":50,"wallet_type":"ton"}`
+	req := httptest.NewRequest(http.MethodPost, "/orders/star", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", w.Code, w.Body)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	server, svcSpan, upstreamSpan := spans["/orders/star"], spans["OrderService.CreateStarOrderAsync"], spans["iStar POST /orders/star"]
+	if server == nil || svcSpan == nil || upstreamSpan == nil {
+		t.Fatalf("ended spans = %v, want server, service and iStar spans", spanNames(recorder.Ended()))
+	}
+
+	if server.SpanKind() != trace.SpanKindServer || upstreamSpan.SpanKind() != trace.SpanKindClient {
+		t.Errorf("span kinds = %s and %s, want server and client", server.SpanKind(), upstreamSpan.SpanKind())
+	}
+	if server.Parent().IsValid() {
+		t.Error("server span has a parent, want it to be the root")
+	}
+	if svcSpan.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("service span is not a child of the server span")
+	}
+	if upstreamSpan.Parent().SpanID() != svcSpan.SpanContext().SpanID() {
+		t.Error("iStar span is not a child of the service span")
+	}
+	traceID := server.SpanContext().TraceID()
+	if svcSpan.SpanContext().TraceID() != traceID || upstreamSpan.SpanContext().TraceID() != traceID {
+		t.Error("spans belong to different traces")
+	}
+
+	var status int64
+	for _, attr := range upstreamSpan.Attributes() {
+		if strings.HasSuffix(string(attr.Key), "status_code") {
+			status = attr.Value.AsInt64()
+		}
+	}
+	if status != http.StatusAccepted {
+		t.Errorf("iStar span status code = %d, want 202", status)
+	}
+	traceparent := <-traceparents
+	if !strings.Contains(traceparent, traceID.String()) || !strings.Contains(traceparent, upstreamSpan.SpanContext().SpanID().String()) {
+		t.Errorf("traceparent = %q, want the iStar span's trace and span ids", traceparent)
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name()
+	}
+	return names
+}
@@ -3,13 +3,20 @@ package handlers
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/hulupay/istar-api/internal/client"
+	"github.com/hulupay/istar-api/internal/models"
 	"go.uber.org/zap"
-	"net/http"
+	"strconv"
+)
+
+// Page sizes for GET /wallet/transactions
+const (
+	defaultWalletTransactionLimit = 50
+	maxWalletTransactionLimit     = 200
 )
 
 // WalletHandler handles wallet-related endpoints
 type WalletHandler struct {
-	istarClient *client.IStarClient
+	istarClient client.IStarAPI
 	logger      *zap.Logger
 }
 
@@ -21,7 +28,7 @@ type WalletHandler struct {
 // @Produce      json
 // @Success      200    {object}  map[string]interface{}
 // @Router       /wallet/balance [get]
-func NewWalletHandler(istarClient *client.IStarClient, logger *zap.Logger) *WalletHandler {
+func NewWalletHandler(istarClient client.IStarAPI, logger *zap.Logger) *WalletHandler {
 	return &WalletHandler{
 		istarClient: istarClient,
 		logger:      logger.Named("wallet_handler"),
@@ -33,11 +40,12 @@ func NewWalletHandler(istarClient *client.IStarClient, logger *zap.Logger) *Wall
 // @Description  Retrieves the wallet balance of the current user
 // @Tags         wallet
 // @Produce      json
-// @Success      200    {object}  map[string]interface{}
+// @Success      200    {object}  models.SuccessResponse{data=models.WalletBalance}
+// @Failure      401    {object}  models.ErrorResponse
+// @Failure      500    {object}  models.ErrorResponse
 // @Router       /wallet/balance [get]
 func (h *WalletHandler) GetWalletBalanceHandler(c *gin.Context) {
-	ctx := c.Request.Context()
-	resp, err := h.istarClient.DoRequest(ctx, "GET", "/wallet/balance", nil)
+	resp, err := h.istarClient.GetWalletBalance(c.Request.Context())
 	if err != nil {
 		h.logger.Error("Failed to retrieve wallet balance", zap.Error(err))
 		c.Error(err)
@@ -45,5 +53,43 @@ func (h *WalletHandler) GetWalletBalanceHandler(c *gin.Context) {
 	}
 
 	h.logger.Info("Wallet balance retrieved")
-	c.JSON(http.StatusOK, resp)
+	respondOK(c, resp)
+}
+
+// GetWalletTransactionsHandler godoc
+// @Summary      List wallet transactions
+// @Description  Returns one page of the partner wallet's transaction history from iStar, newest first. Pass next_cursor from the previous page as cursor to continue.
+// @Tags         wallet
+// @Produce      json
+// @Param        limit   query     int     false  "Page size (1-200, default 50)"
+// @Param        cursor  query     string  false  "next_cursor from the previous page"
+// @Param        type    query     string  false  "Only transactions of this type"
+// @Success      200     {object}  models.SuccessResponse{data=models.WalletTransactionPage}
+// @Failure      400     {object}  models.ErrorResponse
+// @Failure      401     {object}  models.ErrorResponse
+// @Failure      500     {object}  models.ErrorResponse
+// @Router       /wallet/transactions [get]
+func (h *WalletHandler) GetWalletTransactionsHandler(c *gin.Context) {
+	filter := models.WalletTransactionFilter{
+		Limit:  defaultWalletTransactionLimit,
+		Cursor: c.Query("cursor"),
+		Type:   c.Query("type"),
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxWalletTransactionLimit {
+			c.Error(models.ValidationError("limit must be between 1 and 200"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	resp, err := h.istarClient.GetWalletTransactions(c.Request.Context(), filter)
+	if err != nil {
+		h.logger.Error("Failed to retrieve wallet transactions", zap.Error(err))
+		c.Error(err)
+		return
+	}
+
+	respondOK(c, resp)
 }
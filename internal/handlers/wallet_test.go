@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/models"
+	"go.uber.org/zap"
+)
+
+// getWalletBalance serves GET /wallet/balance over istar
+func getWalletBalance(istar *clientmock.IStarAPI) *httptest.ResponseRecorder {
+	h := NewWalletHandler(istar, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/wallet/balance", h.GetWalletBalanceHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wallet/balance", nil))
+	return w
+}
+
+func TestGetWalletBalanceHandler(t *testing.T) {
+	available, _ := models.ParseAmount("12.5")
+	istar := &clientmock.IStarAPI{
+		GetWalletBalanceFunc: func(context.Context) (*models.WalletBalance, error) {
+			return &models.WalletBalance{WalletType: "ton", Currency: "TON", Available: available}, nil
+		},
+	}
+
+	w := getWalletBalance(istar)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data models.WalletBalance `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Data.Currency != "TON" || resp.Data.Available != available {
+		t.Errorf("data = %+v, want the balance from iStar", resp.Data)
+	}
+}
+
+func TestGetWalletBalanceHandlerPassesTypedErrors(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetWalletBalanceFunc: func(context.Context) (*models.WalletBalance, error) {
+			return nil, models.RateLimitedError("iStar rate limit exceeded")
+		},
+	}
+
+	w := getWalletBalance(istar)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429: %s", w.Code, w.Body)
+	}
+}
+
+func TestGetWalletTransactionsHandler(t *testing.T) {
+	var gotFilter models.WalletTransactionFilter
+	amount, _ := models.ParseAmount("1.25")
+	istar := &clientmock.IStarAPI{
+		GetWalletTransactionsFunc: func(ctx context.Context, filter models.WalletTransactionFilter) (*models.WalletTransactionPage, error) {
+			gotFilter = filter
+			return &models.WalletTransactionPage{
+				Transactions: []models.WalletTransaction{{ID: "tx-1", Type: "debit", Amount: amount, Currency: "TON", WalletType: "ton"}},
+				NextCursor:   "page-3",
+			}, nil
+		},
+	}
+	h := NewWalletHandler(istar, zap.NewNop())
+	r := newTestRouter("client-a")
+	r.GET("/wallet/transactions", h.GetWalletTransactionsHandler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wallet/transactions?limit=20&cursor=page-2&type=debit", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if gotFilter != (models.WalletTransactionFilter{Limit: 20, Cursor: "page-2", Type: "debit"}) {
+		t.Errorf("filter = %+v, want the paging params forwarded", gotFilter)
+	}
+	var resp struct {
+		Data models.WalletTransactionPage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Data.NextCursor != "page-3" || len(resp.Data.Transactions) != 1 || resp.Data.Transactions[0].Amount != amount {
+		t.Errorf("data = %+v, want the page from iStar", resp.Data)
+	}
+
+	for _, limit := range []string{"0", "201", "ten"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wallet/transactions?limit="+limit, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("limit=%s: status = %d, want 400", limit, w.Code)
+		}
+	}
+}
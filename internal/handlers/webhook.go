@@ -2,14 +2,21 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
 	"github.com/hulupay/istar-api/internal/models"
-	"github.com/hulupay/istar-api/internal/repositories"
+	"github.com/hulupay/istar-api/internal/services"
+	"github.com/hulupay/istar-api/pkg/requestctx"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,108 +24,222 @@ import (
 
 // WebhookHandler handles webhook events
 type WebhookHandler struct {
-	repo          repositories.OrderRepository
-	webhookSecret string
-	logger        *zap.Logger
+	webhookService services.WebhookService
+	webhookSecret  string
+	logger         *zap.Logger
+
+	// timestampTolerance bounds the age of a stamped delivery; requireTimestamp
+	// rejects unstamped ones instead of checking the legacy signature
+	timestampTolerance time.Duration
+	requireTimestamp   bool
 }
 
+// maxWebhookBodySize caps the webhook body we are willing to read
+const maxWebhookBodySize = 1 << 20
+
+// correlationIDHeader lets iStar (or a proxy) supply the delivery id used to
+// correlate the webhook with the order changes it causes
+const correlationIDHeader = "X-Correlation-ID"
+
+// webhookTimestampHeader carries the Unix time, in seconds, at which iStar
+// signed the delivery; the signature then covers "<timestamp>.<body>"
+const webhookTimestampHeader = "X-iStar-Timestamp"
+
 // NewWebhookHandler godocs
 // @Summary      Create a new webhook handler
 // @Description  Initializes a new WebhookHandler
 // @Tags         webhook
 // @Accept       json
 // @Produce      json
-// @Param        repo     path      repositories.OrderRepository  true  "Order repository"
+// @Param        service  path      services.WebhookService       true  "Webhook service"
 // @Param        secret   path      string                       true  "Webhook secret"
+// @Param        timestampTolerance  path  time.Duration         true  "Allowed age of X-iStar-Timestamp"
+// @Param        requireTimestamp    path  bool                  true  "Reject deliveries without X-iStar-Timestamp"
 // @Param        logger   path      *zap.Logger                  true  "Logger"
 // @Success      200      {object}  *WebhookHandler
 // @Failure      400      {object}  models.ErrorResponse
 // @Router       /webhook [post]
-func NewWebhookHandler(repo repositories.OrderRepository, secret string, logger *zap.Logger) *WebhookHandler {
+func NewWebhookHandler(webhookService services.WebhookService, secret string, timestampTolerance time.Duration, requireTimestamp bool, logger *zap.Logger) *WebhookHandler {
 	return &WebhookHandler{
-		repo:          repo,
-		webhookSecret: secret,
-		logger:        logger.Named("webhook_handler"),
+		webhookService:     webhookService,
+		webhookSecret:      secret,
+		logger:             logger.Named("webhook_handler"),
+		timestampTolerance: timestampTolerance,
+		requireTimestamp:   requireTimestamp,
 	}
 }
 
 // HandleWebhookHandler godoc
 // @Summary      Handle webhook events
-// @Description  Handles webhook events from iStar
+// @Description  Handles webhook events from iStar. Deliveries are signed with X-iStar-Signature over "<X-iStar-Timestamp>.<body>" and rejected when the timestamp is outside the configured tolerance. A JSON array of events is processed as a batch: each event is applied independently and the response lists per-event results (200 when all succeeded, 207 otherwise). The path can be changed with WEBHOOK_PATH.
 // @Tags         webhook
 // @Accept       json
 // @Produce      json
 // @Param        payload  body      models.WebhookPayload  true  "Webhook payload"
+// @Param        X-iStar-Signature  header  string  true   "Hex HMAC-SHA256, optionally prefixed with sha256="
+// @Param        X-iStar-Timestamp  header  string  false  "Unix seconds the delivery was signed at"
 // @Success      200      {object}  map[string]interface{}
+// @Success      207      {object}  models.WebhookBatchResponse
 // @Failure      400      {object}  models.ErrorResponse
+// @Failure      401      {object}  models.ErrorResponse
+// @Router       /webhooks/istar [post]
 func (h *WebhookHandler) HandleWebhookHandler(c *gin.Context) {
+	correlationID := c.GetHeader(correlationIDHeader)
+	if correlationID == "" {
+		correlationID = requestctx.RequestID(c.Request.Context())
+	}
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+	}
+	ctx := requestctx.WithCorrelationID(c.Request.Context(), correlationID)
+	c.Header(correlationIDHeader, correlationID)
+	h.logger.Debug("Webhook received", zap.String("correlation_id", correlationID))
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.logger.Warn("Webhook body too large", zap.Int64("limit", tooLarge.Limit), zap.String("correlation_id", correlationID))
+		c.Error(models.PayloadTooLargeError("Webhook body too large"))
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to read webhook body", zap.Error(err))
+		c.Error(models.InternalServerError("Failed to read webhook body"))
+		return
+	}
+
 	if h.webhookSecret != "" {
-		signature := c.GetHeader("X-iStar-Signature")
-		body, err := c.GetRawData()
-		if err != nil {
-			h.logger.Error("Failed to read webhook body", zap.Error(err))
-			c.Error(models.InternalServerError("Failed to read webhook body"))
+		if err := h.verifyDelivery(c, body, time.Now()); err != nil {
+			h.logger.Warn("Rejected webhook signature", zap.Error(err), zap.String("correlation_id", correlationID))
+			c.Error(err)
 			return
 		}
-		mac := hmac.New(sha256.New, []byte(h.webhookSecret))
-		mac.Write(body)
-		expected := hex.EncodeToString(mac.Sum(nil))
-		if !hmac.Equal([]byte(signature), []byte(expected)) {
-			h.logger.Warn("Invalid webhook signature")
-			c.Error(models.UnauthorizedError("Invalid webhook signature"))
-			return
-		}
-		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
 	}
 
-	var payload models.WebhookPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		h.logger.Error("Invalid webhook payload", zap.Error(err))
-		c.Error(models.ValidationError("Invalid webhook payload"))
+	if isJSONArray(body) {
+		h.handleWebhookBatch(ctx, c, body)
 		return
 	}
 
-	orderID, ok := payload.Order["id"].(string)
-	if !ok {
-		h.logger.Error("Missing order ID in webhook payload")
-		c.Error(models.ValidationError("Missing order ID"))
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		h.logger.Error("Invalid webhook payload", zap.Error(err), zap.String("correlation_id", correlationID))
+		c.Error(models.WebhookPayloadError(err))
 		return
 	}
 
-	status, ok := payload.Order["status"].(string)
-	if !ok {
-		h.logger.Error("Missing status in webhook payload")
-		c.Error(models.ValidationError("Missing status"))
+	if err := h.webhookService.ProcessWebhook(ctx, payload); err != nil {
+		h.logger.Error("Failed to process webhook", zap.Error(err), zap.String("correlation_id", correlationID))
+		c.Error(err)
 		return
 	}
 
-	var txHash *string
-	if payload.TxHash != nil {
-		th := *payload.TxHash
-		txHash = &th
+	h.logger.Info("Webhook processed",
+		zap.String("event_type", string(payload.EventType)),
+		zap.String("correlation_id", correlationID))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleWebhookBatch processes a delivery whose body is an array of events
+func (h *WebhookHandler) handleWebhookBatch(ctx context.Context, c *gin.Context, body []byte) {
+	correlationID := requestctx.CorrelationID(ctx)
+
+	// Only the array itself must parse; each event is decoded on its own so a
+	// malformed one fails alone
+	var events []json.RawMessage
+	if err := json.Unmarshal(body, &events); err != nil {
+		h.logger.Error("Invalid webhook batch payload", zap.Error(err), zap.String("correlation_id", correlationID))
+		c.Error(models.ValidationError("Invalid webhook payload"))
+		return
 	}
+	if len(events) == 0 {
+		c.Error(models.ValidationError("Webhook batch is empty"))
+		return
+	}
+
+	resp := h.webhookService.ProcessWebhookBatch(ctx, events)
 
-	var completedAt *time.Time
-	if payload.CompletedAt != nil {
-		completedAt = payload.CompletedAt
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
 	}
+	c.JSON(status, resp)
+}
 
-	var errorMessage *string
-	if em, ok := payload.Order["error"].(string); ok {
-		errorMessage = &em
+// isJSONArray reports whether body holds a JSON array rather than a single object
+func isJSONArray(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// verifyDelivery checks the signature of a delivery and, when it is stamped,
+// that the stamp is within timestampTolerance of now. An unstamped delivery is
+// checked against the legacy body-only signature unless timestamps are required.
+func (h *WebhookHandler) verifyDelivery(c *gin.Context, body []byte, now time.Time) error {
+	signature := c.GetHeader("X-iStar-Signature")
+	timestamp := strings.TrimSpace(c.GetHeader(webhookTimestampHeader))
+	if timestamp == "" {
+		if h.requireTimestamp {
+			return models.UnauthorizedError("Missing webhook timestamp")
+		}
+		return verifyWebhookSignature(h.webhookSecret, body, signature)
 	}
 
-	err := h.repo.UpdateOrderStatus(c.Request.Context(), orderID, models.OrderStatus(status), txHash, completedAt, errorMessage)
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
 	if err != nil {
-		h.logger.Error("Failed to update order", zap.Error(err))
-		c.Error(models.InternalServerError("Failed to update order"))
-		return
+		return models.UnauthorizedError("Malformed webhook timestamp")
 	}
+	if err := verifyWebhookSignature(h.webhookSecret, timestampedWebhookBody(timestamp, body), signature); err != nil {
+		return err
+	}
+	// Reject stamps from the future too, beyond the same allowance for clock skew
+	if age := now.Sub(time.Unix(signedAt, 0)); age > h.timestampTolerance || age < -h.timestampTolerance {
+		return models.UnauthorizedError("Webhook timestamp is outside the allowed window")
+	}
+	return nil
+}
 
-	h.logger.Info("Webhook processed",
-		zap.String("event_type", payload.EventType),
-		zap.String("order_id", orderID))
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+// timestampedWebhookBody is what a stamped delivery's signature covers
+func timestampedWebhookBody(timestamp string, body []byte) []byte {
+	signed := make([]byte, 0, len(timestamp)+1+len(body))
+	signed = append(signed, timestamp...)
+	signed = append(signed, '.')
+	return append(signed, body...)
+}
+
+// webhookSignaturePrefix is the optional algorithm tag in front of the hex signature
+const webhookSignaturePrefix = "sha256="
+
+// verifyWebhookSignature checks header, a hex HMAC-SHA256 optionally prefixed
+// with "sha256=", against body. Both sides are compared as raw MAC bytes so
+// hex letter case does not matter.
+func verifyWebhookSignature(secret string, body []byte, header string) error {
+	signature := strings.TrimSpace(header)
+	if len(signature) >= len(webhookSignaturePrefix) && strings.EqualFold(signature[:len(webhookSignaturePrefix)], webhookSignaturePrefix) {
+		signature = signature[len(webhookSignaturePrefix):]
+	}
+	if signature == "" {
+		return models.UnauthorizedError("Missing webhook signature")
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) != sha256.Size {
+		return models.UnauthorizedError("Malformed webhook signature")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return models.UnauthorizedError("Invalid webhook signature")
+	}
+	return nil
+}
+
+// computeWebhookSignature returns the hex-encoded HMAC-SHA256 of body under secret
+func computeWebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 /*
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"github.com/hulupay/istar-api/internal/services"
+	"go.uber.org/zap"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"event_type":"order.completed"}`)
+	valid := computeWebhookSignature(testWebhookSecret, body)
+
+	tests := []struct {
+		name      string
+		signature string
+		want      string
+	}{
+		{"correct", valid, ""},
+		{"prefixed", "sha256=" + valid, ""},
+		{"prefixed in upper case", "SHA256=" + strings.ToUpper(valid), ""},
+		{"surrounding spaces", " " + valid + " ", ""},
+		{"wrong", computeWebhookSignature("other-secret", body), "Invalid webhook signature"},
+		{"malformed hex", strings.Repeat("zz", 32), "Malformed webhook signature"},
+		{"truncated", valid[:40], "Malformed webhook signature"},
+		{"missing", "", "Missing webhook signature"},
+		{"prefix only", "sha256=", "Missing webhook signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyWebhookSignature(testWebhookSecret, body, tt.signature)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("verifyWebhookSignature = %v, want nil", err)
+				}
+				return
+			}
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != models.CodeUnauthorized || apiErr.Message != tt.want {
+				t.Errorf("verifyWebhookSignature = %v, want 401 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookSignatureCoversTheBody(t *testing.T) {
+	signature := computeWebhookSignature(testWebhookSecret, []byte(`{"amount":1}`))
+
+	if err := verifyWebhookSignature(testWebhookSecret, []byte(`{"amount":2}`), signature); err == nil {
+		t.Error("a signature for another body was accepted")
+	}
+}
+
+// newTestWebhookRouter serves a webhook handler over a real webhook service
+// and in-memory repository at /webhooks/istar
+func newTestWebhookRouter(t *testing.T, requireTimestamp bool) (http.Handler, repositories.OrderRepository) {
+	t.Helper()
+	repo := repositories.NewInMemoryOrderRepository()
+	svc := services.NewWebhookService(repo, services.UnknownEventIgnore, 0, time.Second, zap.NewNop())
+	h := NewWebhookHandler(svc, testWebhookSecret, 5*time.Minute, requireTimestamp, zap.NewNop())
+	r := newTestRouter("")
+	r.POST("/webhooks/istar", h.HandleWebhookHandler)
+	return r, repo
+}
+
+// postWebhook delivers body signed with the legacy body-only signature
+func postWebhook(r http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/istar", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-iStar-Signature", computeWebhookSignature(testWebhookSecret, []byte(body)))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// storePendingOrder saves a pending order known to iStar as istarOrderID
+func storePendingOrder(t *testing.T, repo repositories.OrderRepository, istarOrderID string) *models.Order {
+	t.Helper()
+	now := time.Now()
+	order := &models.Order{
+		ID:           uuid.New(),
+		IStarOrderID: istarOrderID,
+		Type:         models.OrderTypeStar,
+		Status:       models.StatusPending,
+		WalletType:   "ton",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	return order
+}
+
+func TestWebhookBatchReportsEachEvent(t *testing.T) {
+	r, repo := newTestWebhookRouter(t, false)
+	order := storePendingOrder(t, repo, "istar-1")
+
+	w := postWebhook(r, `[
+		{"event_id":"evt-1","event_type":"order.completed","order":{"id":"istar-1","status":"completed"}},
+		{"event_id":"evt-2","event_type":"order.completed","order":{"id":"istar-unknown","status":"completed"}}
+	]`)
+
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207: %s", w.Code, w.Body)
+	}
+	var resp models.WebhookBatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Succeeded != 1 || resp.Failed != 1 || len(resp.Results) != 2 {
+		t.Fatalf("response = %+v, want one success and one failure", resp)
+	}
+	if got := resp.Results[0]; got.EventID != "evt-1" || got.Status != "ok" {
+		t.Errorf("result 0 = %+v, want evt-1 ok", got)
+	}
+	if got := resp.Results[1]; got.EventID != "evt-2" || got.Status != "error" || got.Code != models.CodeNotFound {
+		t.Errorf("result 1 = %+v, want evt-2 failing with %s", got, models.CodeNotFound)
+	}
+	stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+	if stored.Status != models.StatusCompleted {
+		t.Errorf("status = %s, want the good event applied", stored.Status)
+	}
+}
+
+func TestWebhookBatchAllApplied(t *testing.T) {
+	r, repo := newTestWebhookRouter(t, false)
+	storePendingOrder(t, repo, "istar-1")
+	storePendingOrder(t, repo, "istar-2")
+
+	w := postWebhook(r, `[
+		{"event_id":"evt-1","event_type":"order.completed","order":{"id":"istar-1","status":"completed"}},
+		{"event_id":"evt-2","event_type":"order.failed","order":{"id":"istar-2","status":"failed"}}
+	]`)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestWebhookBatchRejectsEmptyOrBrokenArrays(t *testing.T) {
+	r, _ := newTestWebhookRouter(t, false)
+
+	for _, body := range []string{`[]`, `[{"event_id":"evt-1"},`} {
+		if w := postWebhook(r, body); w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestWebhookRejectsOversizedBody(t *testing.T) {
+	r, _ := newTestWebhookRouter(t, false)
+	body := `{"event_id":"evt-1","padding":"` + strings.Repeat("x", maxWebhookBodySize) + `"}`
+
+	w := postWebhook(r, body)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", w.Code)
+	}
+}
+
+func TestWebhookRejectsMalformedOrder(t *testing.T) {
+	r, repo := newTestWebhookRouter(t, false)
+	order := storePendingOrder(t, repo, "istar-1")
+
+	tests := []struct {
+		name, body, field string
+	}{
+		{"numeric id", `{"event_id":"evt-1","event_type":"order.completed","order":{"id":12345,"status":"completed"}}`, "order.id"},
+		{"missing status", `{"event_id":"evt-2","event_type":"order.completed","order":{"id":"istar-1"}}`, "order.status"},
+		{"amount not a number", `{"event_id":"evt-3","event_type":"order.completed","order":{"id":"istar-1","status":"completed","amount":"ten"}}`, "order.amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postWebhook(r, tt.body)
+
+			var resp models.ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != http.StatusBadRequest || resp.Code != models.CodeValidation {
+				t.Fatalf("got %d %s, want 400 %s", w.Code, w.Body, models.CodeValidation)
+			}
+			if len(resp.Details) != 1 || resp.Details[0].Field != tt.field {
+				t.Errorf("details = %+v, want one error on %s", resp.Details, tt.field)
+			}
+		})
+	}
+
+	stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+	if stored.Status != models.StatusPending {
+		t.Errorf("status = %s, want the order untouched by malformed events", stored.Status)
+	}
+}
+
+func TestWebhookTimestampWindow(t *testing.T) {
+	const body = `{"event_id":"evt-1","event_type":"order.completed","order":{"id":"istar-1","status":"completed"}}`
+	now := time.Now()
+	stamp := func(at time.Time) string { return strconv.FormatInt(at.Unix(), 10) }
+	stamped := func(timestamp string) string {
+		return computeWebhookSignature(testWebhookSecret, timestampedWebhookBody(timestamp, []byte(body)))
+	}
+	legacy := computeWebhookSignature(testWebhookSecret, []byte(body))
+
+	tests := []struct {
+		name             string
+		requireTimestamp bool
+		timestamp        string
+		signature        string
+		want             string
+	}{
+		{"fresh", true, stamp(now), stamped(stamp(now)), ""},
+		{"fresh within skew", true, stamp(now.Add(-4 * time.Minute)), stamped(stamp(now.Add(-4 * time.Minute))), ""},
+		{"stale", true, stamp(now.Add(-10 * time.Minute)), stamped(stamp(now.Add(-10 * time.Minute))), "Webhook timestamp is outside the allowed window"},
+		{"from the future", true, stamp(now.Add(10 * time.Minute)), stamped(stamp(now.Add(10 * time.Minute))), "Webhook timestamp is outside the allowed window"},
+		{"malformed", true, "yesterday", stamped("yesterday"), "Malformed webhook timestamp"},
+		{"legacy signature on a stamped delivery", false, stamp(now), legacy, "Invalid webhook signature"},
+		{"missing while required", true, "", legacy, "Missing webhook timestamp"},
+		{"missing during migration", false, "", legacy, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, repo := newTestWebhookRouter(t, tt.requireTimestamp)
+			order := storePendingOrder(t, repo, "istar-1")
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/istar", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-iStar-Signature", tt.signature)
+			if tt.timestamp != "" {
+				req.Header.Set(webhookTimestampHeader, tt.timestamp)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+			if tt.want == "" {
+				if w.Code != http.StatusOK || stored.Status != models.StatusCompleted {
+					t.Errorf("got %d with order %s, want 200 and the order completed: %s", w.Code, stored.Status, w.Body)
+				}
+				return
+			}
+			var resp models.ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != http.StatusUnauthorized || resp.Code != models.CodeUnauthorized || resp.Error != tt.want {
+				t.Errorf("got %d %s, want 401 %q", w.Code, w.Body, tt.want)
+			}
+			if stored.Status != models.StatusPending {
+				t.Errorf("order status = %s, want a rejected delivery to leave it pending", stored.Status)
+			}
+		})
+	}
+}
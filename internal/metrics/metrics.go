@@ -0,0 +1,101 @@
+// Package metrics defines the Prometheus collectors exported on /metrics.
+package metrics
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry holds every collector served on /metrics. It is separate from the
+// global default registry so tests can scrape it in isolation.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequestsTotal = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"}))
+
+	HTTPRequestDuration = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"}))
+
+	HTTPSlowRequestsTotal = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_slow_requests_total",
+		Help: "HTTP requests slower than the slow request threshold, by method and route.",
+	}, []string{"method", "route"}))
+
+	OrdersCreatedTotal = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Order creation attempts, by order type and resulting status.",
+	}, []string{"type", "status"}))
+
+	IStarRequestsTotal = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "istar_requests_total",
+		Help: "Requests sent to the iStar API, by method, path and status code.",
+	}, []string{"method", "path", "status"}))
+
+	IStarRequestDuration = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "istar_request_duration_seconds",
+		Help:    "Latency of requests to the iStar API, by method and path.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"}))
+
+	IStarRequestErrorsTotal = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "istar_request_errors_total",
+		Help: "Requests to the iStar API that failed before a response was received.",
+	}, []string{"method", "path"}))
+
+	IStarInFlightRequests = register(prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "istar_in_flight_requests",
+		Help: "Requests to the iStar API currently awaiting a response.",
+	}))
+)
+
+func init() {
+	register(collectors.NewGoCollector())
+	register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
+
+// register adds c to Registry, returning the already registered collector
+// instead of panicking if an identical one exists
+func register[C prometheus.Collector](c C) C {
+	if err := Registry.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// orderPathTypes are the static segments that may follow "orders" in an
+// upstream path; anything else there is an iStar order id
+var orderPathTypes = map[string]bool{"star": true, "premium": true}
+
+// PathLabel turns an upstream request path into a low-cardinality label by
+// dropping the query string and replacing UUID segments and iStar order ids
+// with ":id"
+func PathLabel(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if _, err := uuid.Parse(segment); err == nil {
+			segments[i] = ":id"
+		} else if i > 0 && segments[i-1] == "orders" && segment != "" && !orderPathTypes[segment] {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPathLabel(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"/orders/star", "/orders/star"},
+		{"/orders/premium/sync", "/orders/premium/sync"},
+		{"/orders/istar-123", "/orders/:id"},
+		{"/orders/3f1c2a9e-6d0b-4c1e-9b7a-2a1f0e3d4c5b/refund", "/orders/:id/refund"},
+		{"/star/recipient/search?username=alice&quantity=50", "/star/recipient/search"},
+		{"/orders/", "/orders/"},
+	}
+	for _, tt := range tests {
+		if got := PathLabel(tt.path); got != tt.want {
+			t.Errorf("PathLabel(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterReturnsExistingCollector(t *testing.T) {
+	again := register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Order creation attempts, by order type and resulting status.",
+	}, []string{"type", "status"}))
+
+	if again != OrdersCreatedTotal {
+		t.Error("registering an identical collector returned a new one, want the registered one")
+	}
+}
+
+func TestRegistryIsScrapable(t *testing.T) {
+	HTTPRequestsTotal.WithLabelValues("GET", "/health", "200").Inc()
+
+	if n, err := testutil.GatherAndCount(Registry, "http_requests_total"); err != nil || n == 0 {
+		t.Errorf("GatherAndCount = %d, %v, want samples", n, err)
+	}
+}
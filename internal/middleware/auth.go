@@ -1,40 +1,76 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/pkg/requestctx"
 	"go.uber.org/zap"
 )
 
-func APIKeyAuth(validKey string) gin.HandlerFunc {
+// APIKeyAuth admits requests whose API-Key header is one of validKeys and
+// stores a hash of the key in the request context as the caller's client
+// identity, which scopes orders, idempotency keys and daily limits. When no
+// keys are configured every request is rejected.
+func APIKeyAuth(validKeys []string, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		logger := c.MustGet("logger").(*zap.Logger)
-
 		apiKey := GetAPIKey(c)
 		if apiKey == "" {
-			logger.Warn("Missing API key")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "API key required",
-				"code":  "MISSING_API_KEY",
-			})
+			logger.Warn("Missing API key", zap.String("path", c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewAPIError(http.StatusUnauthorized, "MISSING_API_KEY", "API key required"))
 			return
 		}
 
-		if !isValidAPIKey(apiKey, validKey) {
-			logger.Warn("Invalid API key attempt", zap.String("key", apiKey))
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid API key",
-				"code":  "INVALID_API_KEY",
-			})
+		// Every key is compared, so the time taken does not reveal which one matched
+		valid := false
+		for _, validKey := range validKeys {
+			if isValidAPIKey(apiKey, validKey) {
+				valid = true
+			}
+		}
+		if !valid {
+			logger.Warn("Invalid API key attempt", zap.String("path", c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewAPIError(http.StatusUnauthorized, "INVALID_API_KEY", "Invalid API key"))
 			return
 		}
 
+		ctx := requestctx.WithClientID(c.Request.Context(), HashAPIKey(apiKey))
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
 
+// AdminAuth guards administrative endpoints with a separate admin key sent in
+// the Admin-Key header. When no admin key is configured every request is rejected.
+func AdminAuth(adminKey string, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader("Admin-Key"))
+		if key == "" {
+			logger.Warn("Missing admin key", zap.String("path", c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.NewAPIError(http.StatusUnauthorized, "MISSING_ADMIN_KEY", "Admin key required"))
+			return
+		}
+
+		if !isValidAPIKey(key, adminKey) {
+			logger.Warn("Invalid admin key attempt", zap.String("path", c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusForbidden, models.NewAPIError(http.StatusForbidden, "INVALID_ADMIN_KEY", "Invalid admin key"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 of an API key
+func HashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
 // GetAPIKey extracts and sanitizes the API key from headers
 func GetAPIKey(c *gin.Context) string {
 	return strings.TrimSpace(c.GetHeader("API-Key"))
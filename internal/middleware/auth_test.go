@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+	"go.uber.org/zap"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys := []string{"key-a", "key-b"}
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+		wantClient string
+	}{
+		{"missing key", "", http.StatusUnauthorized, ""},
+		{"unknown key", "key-c", http.StatusUnauthorized, ""},
+		{"first key", "key-a", http.StatusOK, HashAPIKey("key-a")},
+		{"second key", " key-b ", http.StatusOK, HashAPIKey("key-b")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotClient string
+			r := gin.New()
+			r.GET("/orders", APIKeyAuth(keys, zap.NewNop()), func(c *gin.Context) {
+				gotClient = requestctx.ClientID(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.key != "" {
+				req.Header.Set("API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if gotClient != tt.wantClient {
+				t.Errorf("client id = %q, want %q", gotClient, tt.wantClient)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthRejectsEverythingWithoutKeys(t *testing.T) {
+	r := gin.New()
+	r.GET("/orders", APIKeyAuth(nil, zap.NewNop()), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("API-Key", "anything")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+}
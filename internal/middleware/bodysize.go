@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+// MaxBodySize rejects request bodies larger than limit with 413. Bodies that
+// declare their length are refused before anything is read; others are cut
+// off by http.MaxBytesReader once limit is passed. Routes in skipPaths (gin
+// route patterns) apply their own cap. A limit of zero or less disables it.
+func MaxBodySize(limit int64, skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, p := range skipPaths {
+		skip[p] = true
+	}
+
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody || skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, models.PayloadTooLargeError("Request body too large"))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
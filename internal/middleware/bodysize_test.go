@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+// serveBody posts body to /orders, or to /webhooks when webhook is set, behind
+// MaxBodySize(limit) with the webhook route exempt. The routes read the whole
+// body and answer 413 themselves when the reader cuts it off. chunked hides
+// the body's length, as a streaming client would.
+func serveBody(limit int64, body string, chunked, webhook bool) (*httptest.ResponseRecorder, int) {
+	read := -1
+	r := gin.New()
+	r.Use(MaxBodySize(limit, "/webhooks"))
+	handler := func(c *gin.Context) {
+		data, err := io.ReadAll(c.Request.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.PayloadTooLargeError("Request body too large"))
+			return
+		}
+		read = len(data)
+		c.Status(http.StatusOK)
+	}
+	r.POST("/orders", handler)
+	r.POST("/webhooks", handler)
+
+	path := "/orders"
+	if webhook {
+		path = "/webhooks"
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if chunked {
+		req.ContentLength = -1
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, read
+}
+
+func TestMaxBodySize(t *testing.T) {
+	const limit = 64
+	small := strings.Repeat("a", limit)
+	large := strings.Repeat("a", limit+1)
+
+	tests := []struct {
+		name     string
+		limit    int64
+		body     string
+		chunked  bool
+		webhook  bool
+		wantCode int
+	}{
+		{"at the limit", limit, small, false, false, http.StatusOK},
+		{"at the limit, chunked", limit, small, true, false, http.StatusOK},
+		{"over the limit", limit, large, false, false, http.StatusRequestEntityTooLarge},
+		{"over the limit, chunked", limit, large, true, false, http.StatusRequestEntityTooLarge},
+		{"exempt webhook route", limit, large, false, true, http.StatusOK},
+		{"limit disabled", 0, large, false, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, read := serveBody(tt.limit, tt.body, tt.chunked, tt.webhook)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusOK {
+				if read != len(tt.body) {
+					t.Errorf("route read %d bytes, want all %d", read, len(tt.body))
+				}
+				return
+			}
+			var body models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != models.CodePayloadTooLarge {
+				t.Errorf("body = %s (%v), want a %s error", w.Body, err, models.CodePayloadTooLarge)
+			}
+		})
+	}
+}
+
+func TestMaxBodySizeRefusesDeclaredLengthUnread(t *testing.T) {
+	w, read := serveBody(64, strings.Repeat("a", 65), false, false)
+
+	if w.Code != http.StatusRequestEntityTooLarge || read != -1 {
+		t.Errorf("status = %d, route read %d bytes, want 413 before the route runs", w.Code, read)
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+// RequireJSON answers 415 when a request carries a body whose Content-Type is
+// not application/json. Parameters such as charset are ignored. Requests
+// without a body, like most GETs, pass through unchecked.
+func RequireJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, models.UnsupportedMediaTypeError("Content-Type must be application/json"))
+			return
+		}
+		c.Next()
+	}
+}
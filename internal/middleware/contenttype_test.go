@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+func TestRequireJSON(t *testing.T) {
+	r := gin.New()
+	r.Use(RequireJSON())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/orders/star", ok)
+	r.GET("/orders", ok)
+
+	tests := []struct {
+		name, method, contentType, body string
+		want                            int
+	}{
+		{"json", http.MethodPost, "application/json", `{"quantity":50}`, http.StatusOK},
+		{"json with charset", http.MethodPost, "application/json; charset=utf-8", `{"quantity":50}`, http.StatusOK},
+		{"json in upper case", http.MethodPost, "Application/JSON", `{"quantity":50}`, http.StatusOK},
+		{"form encoded", http.MethodPost, "application/x-www-form-urlencoded", "quantity=50", http.StatusUnsupportedMediaType},
+		{"plain text", http.MethodPost, "text/plain", `{"quantity":50}`, http.StatusUnsupportedMediaType},
+		{"json suffix type", http.MethodPost, "application/problem+json", `{"quantity":50}`, http.StatusUnsupportedMediaType},
+		{"missing with a body", http.MethodPost, "", `{"quantity":50}`, http.StatusUnsupportedMediaType},
+		{"malformed", http.MethodPost, "application/json; charset", `{"quantity":50}`, http.StatusUnsupportedMediaType},
+		{"missing without a body", http.MethodPost, "", "", http.StatusOK},
+		{"GET without a body", http.MethodGet, "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/orders/star"
+			if tt.method == http.MethodGet {
+				path = "/orders"
+			}
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, path, body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want != http.StatusUnsupportedMediaType {
+				return
+			}
+			var resp models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != models.CodeUnsupportedMedia {
+				t.Errorf("body = %s, want an %s error", w.Body, models.CodeUnsupportedMedia)
+			}
+		})
+	}
+}
+
+func TestRequireJSONChecksChunkedBodies(t *testing.T) {
+	r := gin.New()
+	r.Use(RequireJSON())
+	r.POST("/webhooks/istar", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/istar", strings.NewReader("event=1"))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("status = %d, want 415 for a body of unknown length", w.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+var (
+	corsAllowedMethods = strings.Join([]string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions,
+	}, ", ")
+	corsAllowedHeaders = strings.Join([]string{
+		"API-Key", "Content-Type", "Idempotency-Key", RequestIDHeader, "If-None-Match",
+	}, ", ")
+	corsExposedHeaders = strings.Join([]string{
+		RequestIDHeader, "ETag", "X-Correlation-ID", "Idempotency-Replayed", "Link",
+	}, ", ")
+)
+
+// corsMaxAge lets browsers cache a preflight answer for ten minutes
+const corsMaxAge = "600"
+
+// CORS lets browsers on allowedOrigins call the API. Requests from other
+// origins get no Access-Control-Allow-Origin header, so the browser blocks
+// them. Preflight OPTIONS requests are answered with 204 without reaching the
+// routes. An entry of "*" allows any origin.
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	allowAny := false
+	for _, origin := range allowedOrigins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "*" {
+			allowAny = true
+		} else if origin != "" {
+			allowed[origin] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		originAllowed := allowAny || allowed[origin]
+		if originAllowed {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Expose-Headers", corsExposedHeaders)
+		}
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			if originAllowed {
+				c.Header("Access-Control-Allow-Methods", corsAllowedMethods)
+				c.Header("Access-Control-Allow-Headers", corsAllowedHeaders)
+				c.Header("Access-Control-Max-Age", corsMaxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveCORS sends req through CORS(allowedOrigins) to a route that answers
+// 200 and records whether it ran
+func serveCORS(allowedOrigins []string, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	r := gin.New()
+	r.Use(CORS(allowedOrigins))
+	handler := func(c *gin.Context) {
+		reached = true
+		c.Status(http.StatusOK)
+	}
+	r.GET("/orders", handler)
+	r.OPTIONS("/orders", handler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, reached
+}
+
+func TestCORSPreflight(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/orders", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "API-Key")
+
+	w, reached := serveCORS([]string{"https://dashboard.example.com/"}, req)
+
+	if w.Code != http.StatusNoContent || reached {
+		t.Fatalf("status = %d, reached route = %v, want 204 without the route", w.Code, reached)
+	}
+	h := w.Header()
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://dashboard.example.com" {
+		t.Errorf("Allow-Origin = %q, want the request origin", got)
+	}
+	for _, header := range []string{"API-Key", RequestIDHeader} {
+		if !strings.Contains(h.Get("Access-Control-Allow-Headers"), header) {
+			t.Errorf("Allow-Headers = %q, want it to include %s", h.Get("Access-Control-Allow-Headers"), header)
+		}
+	}
+	if !strings.Contains(h.Get("Access-Control-Allow-Methods"), http.MethodPost) {
+		t.Errorf("Allow-Methods = %q, want it to include POST", h.Get("Access-Control-Allow-Methods"))
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    string
+	}{
+		{"allowed origin", []string{"https://dashboard.example.com"}, "https://dashboard.example.com", "https://dashboard.example.com"},
+		{"disallowed origin", []string{"https://dashboard.example.com"}, "https://evil.example.com", ""},
+		{"wildcard", []string{"*"}, "https://anyone.example.com", "https://anyone.example.com"},
+		{"no origin configured", nil, "https://dashboard.example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			req.Header.Set("Origin", tt.origin)
+
+			w, reached := serveCORS(tt.allowed, req)
+
+			if !reached || w.Code != http.StatusOK {
+				t.Fatalf("status = %d, reached route = %v, want the request served", w.Code, reached)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.want)
+			}
+			if got := w.Header().Get("Vary"); got != "Origin" {
+				t.Errorf("Vary = %q, want Origin", got)
+			}
+		})
+	}
+}
+
+func TestCORSPreflightFromDisallowedOrigin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/orders", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+	w, _ := serveCORS([]string{"https://dashboard.example.com"}, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Allow-Origin = %q, want none", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("Allow-Methods = %q, want none", got)
+	}
+}
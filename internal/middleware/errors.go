@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"github.com/hulupay/istar-api/internal/models"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -15,13 +14,35 @@ func ErrorHandler(logger *zap.Logger) gin.HandlerFunc {
 			err := c.Errors.Last().Err
 			logger.Error("Request processing error",
 				zap.String("path", c.FullPath()),
+				zap.String("request_id", c.GetString(RequestIDKey)),
 				zap.Error(err))
-			switch e := err.(type) {
-			case *models.APIError:
-				c.JSON(e.Code, gin.H{"error": e.Message})
-			default:
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			apiErr, ok := err.(*models.APIError)
+			if !ok {
+				apiErr = models.InternalServerError("Internal server error")
 			}
+			// Copy so a shared error value is never stamped with this request's id
+			body := *apiErr
+			body.RequestID = c.GetString(RequestIDKey)
+			c.JSON(body.StatusCode, &body)
 		}
 	}
 }
+
+// NotFound answers requests for unknown routes with the usual error body
+func NotFound() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Error(models.NotFoundError("No route for " + c.Request.Method + " " + c.Request.URL.Path))
+	}
+}
+
+// MethodNotAllowed answers requests whose path exists under other methods.
+// Gin has already set the Allow header listing them.
+func MethodNotAllowed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		message := "Method " + c.Request.Method + " is not allowed on " + c.Request.URL.Path
+		if allow := c.Writer.Header().Get("Allow"); allow != "" {
+			message += "; use " + allow
+		}
+		c.Error(models.MethodNotAllowedError(message))
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+	"go.uber.org/zap"
+)
+
+// serveError runs a request through RequestID and ErrorHandler to a handler
+// that fails with err, and decodes the error body
+func serveError(t *testing.T, err error) (*httptest.ResponseRecorder, models.ErrorResponse) {
+	t.Helper()
+	r := gin.New()
+	r.Use(RequestID(), ErrorHandler(zap.NewNop()))
+	r.GET("/fail", func(c *gin.Context) { c.Error(err) })
+
+	req := httptest.NewRequest(http.MethodGet, "/fail", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body %q: %v", w.Body, err)
+	}
+	return w, body
+}
+
+func TestErrorHandlerWritesCodeAndMessage(t *testing.T) {
+	shared := models.ConflictError("Order already cancelled")
+
+	w, body := serveError(t, shared)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", w.Code)
+	}
+	if body.Code != models.CodeConflict || body.Error != "Order already cancelled" || body.RequestID != "req-1" {
+		t.Errorf("body = %+v, want the conflict code, message and request id", body)
+	}
+	if shared.RequestID != "" {
+		t.Errorf("shared error was stamped with request id %q", shared.RequestID)
+	}
+}
+
+func TestErrorHandlerHidesUntypedErrors(t *testing.T) {
+	w, body := serveError(t, errors.New("pq: connection refused"))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if body.Code != models.CodeInternal || body.Error != "Internal server error" {
+		t.Errorf("body = %+v, want a generic internal error", body)
+	}
+}
+
+func TestUnknownRoutesAndMethodsGetErrorBodies(t *testing.T) {
+	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	r.Use(RequestID(), ErrorHandler(zap.NewNop()))
+	r.NoRoute(NotFound())
+	r.NoMethod(MethodNotAllowed())
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.HEAD("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name, method, path string
+		wantStatus         int
+		wantCode           string
+		wantMessage        string
+	}{
+		{"unknown path", http.MethodGet, "/nope", http.StatusNotFound, models.CodeNotFound, "No route for GET /nope"},
+		{"wrong method", http.MethodDelete, "/health", http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Method DELETE is not allowed on /health; use GET, HEAD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(RequestIDHeader, "req-404")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want JSON", ct)
+			}
+			var body models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("error body %q: %v", w.Body, err)
+			}
+			if body.Code != tt.wantCode || body.Error != tt.wantMessage || body.RequestID != "req-404" {
+				t.Errorf("body = %+v, want code %s, message %q and the request id", body, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ETagSourceKey is the gin context key of a value the ETag is computed from in
+// place of the response body, for responses that carry per-request fields
+const ETagSourceKey = "etag_source"
+
+// bufferedWriter holds the response in memory so headers derived from the
+// body can still be set before anything reaches the client
+type bufferedWriter struct {
+	gin.ResponseWriter
+	buf     bytes.Buffer
+	status  int
+	written bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+	w.written = true
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.buf.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.buf.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.buf.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// ETag buffers GET and HEAD responses to attach an ETag and Content-Length,
+// answers 304 when If-None-Match matches, and sends no body for HEAD requests.
+// Responses the handler left unwritten (errors reported via c.Error) pass
+// through untouched for ErrorHandler to render.
+func ETag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method != http.MethodGet && method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		if !buffered.written {
+			return
+		}
+
+		status := buffered.Status()
+		body := buffered.buf.Bytes()
+
+		if status == http.StatusOK {
+			source := body
+			if v, ok := c.Get(ETagSourceKey); ok {
+				if b, err := json.Marshal(v); err == nil {
+					source = b
+				}
+			}
+			sum := sha256.Sum256(source)
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			original.Header().Set("ETag", etag)
+			if etagMatches(c.GetHeader("If-None-Match"), etag) {
+				original.WriteHeader(http.StatusNotModified)
+				original.WriteHeaderNow()
+				return
+			}
+		}
+
+		original.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		original.WriteHeader(status)
+		if method == http.MethodHead {
+			original.WriteHeaderNow()
+			return
+		}
+		original.Write(body)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+	"go.uber.org/zap"
+)
+
+// newETagRouter serves /orders/:id for GET and HEAD behind ETag; the order
+// "missing" fails with a 404
+func newETagRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(ErrorHandler(zap.NewNop()))
+	handler := func(c *gin.Context) {
+		if c.Param("id") == "missing" {
+			c.Error(models.NotFoundError("Order not found"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "status": "pending"})
+	}
+	r.GET("/orders/:id", ETag(), handler)
+	r.HEAD("/orders/:id", ETag(), handler)
+	return r
+}
+
+func serveETag(r *gin.Engine, method, path, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHeadMatchesGetWithoutBody(t *testing.T) {
+	r := newETagRouter()
+
+	get := serveETag(r, http.MethodGet, "/orders/o-1", "")
+	head := serveETag(r, http.MethodHead, "/orders/o-1", "")
+
+	if head.Code != http.StatusOK {
+		t.Fatalf("HEAD status = %d, want 200", head.Code)
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("HEAD body = %q, want none", head.Body)
+	}
+	if etag := head.Header().Get("ETag"); etag == "" || etag != get.Header().Get("ETag") {
+		t.Errorf("HEAD ETag = %q, GET ETag = %q, want the same non-empty tag", etag, get.Header().Get("ETag"))
+	}
+	if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+		t.Errorf("HEAD Content-Length = %s, want the GET body length %s", got, want)
+	}
+}
+
+func TestETagAnswersNotModified(t *testing.T) {
+	r := newETagRouter()
+	etag := serveETag(r, http.MethodGet, "/orders/o-1", "").Header().Get("ETag")
+
+	for _, ifNoneMatch := range []string{etag, `"other", W/` + etag, "*"} {
+		w := serveETag(r, http.MethodGet, "/orders/o-1", ifNoneMatch)
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: status %d with %d body bytes, want 304 without body", ifNoneMatch, w.Code, w.Body.Len())
+		}
+	}
+	if w := serveETag(r, http.MethodGet, "/orders/o-1", `"other"`); w.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match: status %d, want 200", w.Code)
+	}
+}
+
+func TestHeadOfMissingResourceHasNoBody(t *testing.T) {
+	r := newETagRouter()
+
+	get := serveETag(r, http.MethodGet, "/orders/missing", "")
+	head := serveETag(r, http.MethodHead, "/orders/missing", "")
+
+	if get.Code != http.StatusNotFound || head.Code != http.StatusNotFound {
+		t.Fatalf("status GET %d, HEAD %d, want 404 for both", get.Code, head.Code)
+	}
+	if get.Body.Len() == 0 {
+		t.Error("GET of a missing order has no error body")
+	}
+	if head.Header().Get("ETag") != "" {
+		t.Error("error response carries an ETag")
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+// IPAllowlist answers 403 unless the client IP falls within one of cidrs. A
+// bare address counts as a single-host range, and an empty list allows every
+// caller. The client IP is gin's ClientIP, so X-Forwarded-For is only honoured
+// through the engine's trusted proxies. Entries are expected to have been
+// checked with ParseIPAllowlist; an invalid one panics.
+func IPAllowlist(cidrs []string) gin.HandlerFunc {
+	prefixes, err := ParseIPAllowlist(cidrs)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		if len(prefixes) == 0 {
+			c.Next()
+			return
+		}
+
+		ip, err := netip.ParseAddr(c.ClientIP())
+		if err == nil {
+			ip = ip.Unmap()
+			for _, prefix := range prefixes {
+				if prefix.Contains(ip) {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, models.ForbiddenError("Client IP not allowed"))
+	}
+}
+
+// ParseIPAllowlist parses CIDR ranges and bare IP addresses
+func ParseIPAllowlist(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP allowlist entry %q", entry)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP allowlist entry %q", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
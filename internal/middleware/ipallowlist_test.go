@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIPAllowlist(t *testing.T) {
+	r := gin.New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatalf("SetTrustedProxies: %v", err)
+	}
+	r.Use(IPAllowlist([]string{"203.0.113.0/24", " 2001:db8::1 "}))
+	r.POST("/webhooks/istar", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         int
+	}{
+		{"inside the range", "203.0.113.7:4000", "", http.StatusOK},
+		{"outside the range", "198.51.100.1:4000", "", http.StatusForbidden},
+		{"bare IPv6 address", "[2001:db8::1]:4000", "", http.StatusOK},
+		{"IPv4-mapped IPv6", "[::ffff:203.0.113.8]:4000", "", http.StatusOK},
+		{"allowed client behind a trusted proxy", "10.0.0.1:4000", "203.0.113.9", http.StatusOK},
+		{"disallowed client behind a trusted proxy", "10.0.0.1:4000", "198.51.100.1", http.StatusForbidden},
+		{"forwarded header from an untrusted peer", "198.51.100.1:4000", "203.0.113.9", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/istar", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestEmptyIPAllowlistAllowsEveryone(t *testing.T) {
+	r := gin.New()
+	r.Use(IPAllowlist(nil))
+	r.GET("/admin/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/orders", nil)
+	req.RemoteAddr = "198.51.100.1:4000"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
+
+func TestParseIPAllowlistRejectsInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"203.0.113.0/33", "example.com", "203.0.113"} {
+		if _, err := ParseIPAllowlist([]string{"10.0.0.0/8", entry}); err == nil {
+			t.Errorf("ParseIPAllowlist accepted %q", entry)
+		}
+	}
+}
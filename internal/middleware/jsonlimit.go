@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/pkg/jsonlimit"
+)
+
+// JSONLimits rejects request bodies that exceed the configured size, nesting
+// depth or container size, then restores the body for the handler to bind
+func JSONLimits(limits jsonlimit.Limits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		body, err := limits.Read(c.Request.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.Is(err, jsonlimit.ErrTooLarge) || errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, models.PayloadTooLargeError("Request body too large"))
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.ValidationError(err.Error()))
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
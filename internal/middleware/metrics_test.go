@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCountsRequestsByRoute(t *testing.T) {
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	matched := metrics.HTTPRequestsTotal.WithLabelValues("GET", "/orders/:id", "204")
+	unmatched := metrics.HTTPRequestsTotal.WithLabelValues("GET", "unmatched", "404")
+	beforeMatched, beforeUnmatched := testutil.ToFloat64(matched), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/orders/a", "/orders/b", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(matched) - beforeMatched; got != 2 {
+		t.Errorf("requests on /orders/:id grew by %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - beforeUnmatched; got != 1 {
+		t.Errorf("unmatched requests grew by %v, want 1", got)
+	}
+}
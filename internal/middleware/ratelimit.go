@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
+	"golang.org/x/time/rate"
+)
+
+// limiterIdleTTL is how long an unused per-IP limiter is kept before pruning
+const limiterIdleTTL = 10 * time.Minute
+
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimit allows each client IP perMinute requests per minute with the given
+// burst, answering 429 once the budget is exhausted.
+func RateLimit(perMinute, burst int) gin.HandlerFunc {
+	var (
+		mu       sync.Mutex
+		limiters = make(map[string]*ipLimiter)
+		every    = rate.Every(time.Minute / time.Duration(max(perMinute, 1)))
+	)
+
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		now := time.Now()
+
+		mu.Lock()
+		for key, l := range limiters {
+			if now.Sub(l.lastSeen) > limiterIdleTTL {
+				delete(limiters, key)
+			}
+		}
+		l, ok := limiters[ip]
+		if !ok {
+			l = &ipLimiter{limiter: rate.NewLimiter(every, max(burst, 1))}
+			limiters[ip] = l
+		}
+		l.lastSeen = now
+		allowed := l.limiter.Allow()
+		mu.Unlock()
+
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.RateLimitedError("Rate limit exceeded"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+)
+
+const (
+	// RequestIDHeader carries the request id in both directions
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the gin context key holding the request id
+	RequestIDKey = "request_id"
+
+	maxRequestIDLength = 128
+)
+
+// RequestID reuses a well-formed incoming X-Request-ID or generates a new one,
+// stores it in the gin and request contexts, and echoes it on the response
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(requestctx.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// isValidRequestID accepts short, printable ASCII ids so a client cannot
+// inject control characters into our logs or upstream headers
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
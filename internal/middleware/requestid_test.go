@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated when missing", "", false},
+		{"passed through", "client-req-42", true},
+		{"replaced when too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"replaced when it has spaces", "two words", false},
+		{"replaced when it has control characters", "id\x01", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ginID, ctxID string
+			r := gin.New()
+			r.Use(RequestID())
+			r.GET("/", func(c *gin.Context) {
+				ginID = c.GetString(RequestIDKey)
+				ctxID = requestctx.RequestID(c.Request.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			echoed := w.Header().Get(RequestIDHeader)
+			if tt.keep && echoed != tt.incoming {
+				t.Errorf("%s = %q, want the incoming %q", RequestIDHeader, echoed, tt.incoming)
+			}
+			if !tt.keep {
+				if _, err := uuid.Parse(echoed); err != nil {
+					t.Errorf("%s = %q, want a generated UUID", RequestIDHeader, echoed)
+				}
+			}
+			if ginID != echoed || ctxID != echoed {
+				t.Errorf("gin context has %q, request context %q, want both to be %q", ginID, ctxID, echoed)
+			}
+		})
+	}
+}
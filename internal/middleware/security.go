@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/models"
 	"io"
 	"net/http"
 )
@@ -13,9 +14,7 @@ import (
 func RequireHTTPS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.TLS == nil && c.Request.URL.Scheme != "https" {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"error": "HTTPS required",
-			})
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ForbiddenError("HTTPS required"))
 			return
 		}
 		c.Next()
@@ -33,9 +32,7 @@ func VerifyWebhookSignature(secret string) gin.HandlerFunc {
 		expected := hex.EncodeToString(mac.Sum(nil))
 
 		if !hmac.Equal([]byte(signature), []byte(expected)) {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid webhook signature",
-			})
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.UnauthorizedError("Invalid webhook signature"))
 			return
 		}
 
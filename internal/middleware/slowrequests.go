@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/metrics"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+	"go.uber.org/zap"
+)
+
+// SlowRequestLog keeps the most recent requests slower than its threshold in
+// a fixed-size ring, for operators to inspect without searching the logs
+type SlowRequestLog struct {
+	threshold time.Duration
+
+	mu      sync.Mutex
+	entries []models.SlowRequest
+	next    int
+	count   int
+}
+
+// NewSlowRequestLog remembers the last size requests slower than threshold.
+// A zero threshold turns slow request tracking off; a zero size still logs and
+// counts slow requests but keeps none.
+func NewSlowRequestLog(threshold time.Duration, size int) *SlowRequestLog {
+	return &SlowRequestLog{threshold: threshold, entries: make([]models.SlowRequest, max(size, 0))}
+}
+
+// Threshold is the latency above which a request counts as slow
+func (l *SlowRequestLog) Threshold() time.Duration {
+	return l.threshold
+}
+
+// Recent returns the remembered slow requests, newest first
+func (l *SlowRequestLog) Recent() []models.SlowRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := make([]models.SlowRequest, 0, l.count)
+	for i := 1; i <= l.count; i++ {
+		recent = append(recent, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return recent
+}
+
+func (l *SlowRequestLog) add(r models.SlowRequest) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) == 0 {
+		return
+	}
+	l.entries[l.next] = r
+	l.next = (l.next + 1) % len(l.entries)
+	l.count = min(l.count+1, len(l.entries))
+}
+
+// SlowRequests warns about, counts and records in log every request that
+// takes longer than log's threshold. Streamed responses count until the last
+// byte is written.
+func SlowRequests(log *SlowRequestLog, logger *zap.Logger) gin.HandlerFunc {
+	logger = logger.Named("slow_requests")
+
+	return func(c *gin.Context) {
+		if log.threshold <= 0 {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+		elapsed := time.Since(start)
+		if elapsed <= log.threshold {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		requestID := requestctx.RequestID(c.Request.Context())
+
+		metrics.HTTPSlowRequestsTotal.WithLabelValues(method, route).Inc()
+		logger.Warn("Slow request",
+			zap.String("method", method),
+			zap.String("route", route),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", c.Writer.Status()),
+			zap.Duration("duration", elapsed),
+			zap.Duration("threshold", log.threshold),
+			zap.String("request_id", requestID))
+		log.add(models.SlowRequest{
+			Method:     method,
+			Route:      route,
+			Path:       c.Request.URL.Path,
+			Status:     c.Writer.Status(),
+			DurationMs: elapsed.Milliseconds(),
+			RequestID:  requestID,
+			StartedAt:  start.UTC(),
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hulupay/istar-api/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// slowRouter serves a deliberately slow route and a fast one through
+// SlowRequests
+func slowRouter(log *SlowRequestLog, logger *zap.Logger) *gin.Engine {
+	r := gin.New()
+	r.Use(SlowRequests(log, logger))
+	r.GET("/slow/:id", func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		c.Status(http.StatusAccepted)
+	})
+	r.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+// get serves a GET for path through r
+func get(r http.Handler, path string) {
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+func TestSlowRequestsAreRecorded(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	log := NewSlowRequestLog(10*time.Millisecond, 2)
+	r := slowRouter(log, zap.New(core))
+	counter := metrics.HTTPSlowRequestsTotal.WithLabelValues(http.MethodGet, "/slow/:id")
+	before := testutil.ToFloat64(counter)
+
+	for _, path := range []string{"/slow/1", "/fast", "/slow/2", "/slow/3"} {
+		get(r, path)
+	}
+
+	// The ring holds two entries, so the first slow request has been dropped
+	recent := log.Recent()
+	if len(recent) != 2 || recent[0].Path != "/slow/3" || recent[1].Path != "/slow/2" {
+		t.Fatalf("Recent() = %+v, want /slow/3 then /slow/2", recent)
+	}
+	for _, got := range recent {
+		if got.Method != http.MethodGet || got.Route != "/slow/:id" || got.Status != http.StatusAccepted || got.DurationMs < 30 {
+			t.Errorf("recorded %+v, want GET /slow/:id answered 202 after at least 30ms", got)
+		}
+	}
+
+	if got := testutil.ToFloat64(counter) - before; got != 3 {
+		t.Errorf("slow request counter rose by %v, want 3", got)
+	}
+	warnings := logs.FilterMessage("Slow request").All()
+	if len(warnings) != 3 {
+		t.Fatalf("logged %d slow request warnings, want 3", len(warnings))
+	}
+	fields := warnings[0].ContextMap()
+	if fields["method"] != http.MethodGet || fields["path"] != "/slow/1" || fields["duration"].(time.Duration) < 30*time.Millisecond {
+		t.Errorf("warning fields = %v, want the method, path and duration", fields)
+	}
+}
+
+func TestSlowRequestsOffWithoutThreshold(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	log := NewSlowRequestLog(0, 2)
+	get(slowRouter(log, zap.New(core)), "/slow/1")
+
+	if recent := log.Recent(); len(recent) != 0 {
+		t.Errorf("Recent() = %+v, want nothing recorded", recent)
+	}
+	if n := logs.Len(); n != 0 {
+		t.Errorf("logged %d entries, want none", n)
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Tracing starts a server span per request, named after the matched route and
+// continuing any trace the caller propagated. Handlers pass the request
+// context on, so service and iStar spans nest under it. Health probes and
+// metrics scrapes are not traced.
+func Tracing(service string) gin.HandlerFunc {
+	return otelgin.Middleware(service, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/metrics" && !strings.HasPrefix(r.URL.Path, "/health")
+	}))
+}
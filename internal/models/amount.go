@@ -0,0 +1,205 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// AmountDecimals is how many decimal places an Amount keeps, enough for TON's
+// nanotons and USDT's six decimals
+const AmountDecimals = 9
+
+// amountScale is 10^AmountDecimals
+const amountScale = 1_000_000_000
+
+// Amount is a monetary value in fixed point: an integer count of 10^-9 units.
+// Sums and comparisons are exact, unlike float64 (0.1 + 0.2 is 0.3). It is
+// written to JSON as a plain number and read from a number or a string
+// without going through float64.
+type Amount int64
+
+// ErrInvalidAmount is returned when parsing a malformed or out-of-range amount
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ParseAmount parses a decimal such as "12", "-0.5" or "1.000000001". Digits
+// beyond AmountDecimals are rejected rather than rounded.
+func ParseAmount(s string) (Amount, error) {
+	return parseAmount(s, false)
+}
+
+// parseAmount parses s; with round set, digits beyond AmountDecimals are
+// rounded half away from zero instead of rejected
+func parseAmount(s string, round bool) (Amount, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrInvalidAmount
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	frac = strings.TrimRight(frac, "0")
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidAmount
+	}
+	var carry int64
+	if len(frac) > AmountDecimals {
+		if !round {
+			return 0, ErrInvalidAmount
+		}
+		if frac[AmountDecimals] >= '5' {
+			carry = 1
+		}
+		frac = frac[:AmountDecimals]
+	}
+
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || w > math.MaxInt64/amountScale {
+		return 0, ErrInvalidAmount
+	}
+	var f int64
+	if frac != "" {
+		f, _ = strconv.ParseInt(frac+strings.Repeat("0", AmountDecimals-len(frac)), 10, 64)
+	}
+	if w*amountScale > math.MaxInt64-f-carry {
+		return 0, ErrInvalidAmount
+	}
+
+	a := Amount(w*amountScale + f + carry)
+	if neg {
+		a = -a
+	}
+	return a, nil
+}
+
+// AmountFromFloat converts f, rounding to AmountDecimals places. Use it only
+// for values that arrive as floats, such as a float8 column; parse decimal
+// text with ParseAmount.
+func AmountFromFloat(f float64) Amount {
+	return Amount(math.Round(f * amountScale))
+}
+
+// Float64 returns a, possibly losing precision; for metrics and logs only
+func (a Amount) Float64() float64 {
+	return float64(a) / amountScale
+}
+
+// String formats a without trailing zeros, e.g. "0.3" or "12"
+func (a Amount) String() string {
+	sign := ""
+	u := uint64(a)
+	if a < 0 {
+		sign, u = "-", uint64(-a)
+	}
+	whole, frac := u/amountScale, u%amountScale
+	if frac == 0 {
+		return sign + strconv.FormatUint(whole, 10)
+	}
+	digits := strings.TrimRight(fmt.Sprintf("%09d", frac), "0")
+	return sign + strconv.FormatUint(whole, 10) + "." + digits
+}
+
+// MarshalJSON writes a as a JSON number
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number or a string holding one. Digits beyond
+// AmountDecimals, such as the tail of a float printed as 0.30000000000000004,
+// are rounded; exponents are accepted when the value is exact at
+// AmountDecimals places.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := parseAmount(s, true)
+	if errors.Is(err, ErrInvalidAmount) && strings.ContainsAny(s, "eE") {
+		v, err = parseExponentAmount(s)
+	}
+	if err != nil {
+		return fmt.Errorf("amount %s: %w", string(data), err)
+	}
+	*a = v
+	return nil
+}
+
+// Value stores a as a decimal string, which Postgres NUMERIC takes exactly
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads a NUMERIC column
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case string:
+		return a.scanString(v)
+	case []byte:
+		return a.scanString(string(v))
+	case int64:
+		*a = Amount(v * amountScale)
+		return nil
+	case float64:
+		*a = AmountFromFloat(v)
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Amount", src)
+	}
+}
+
+func (a *Amount) scanString(s string) error {
+	v, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// parseExponentAmount handles numbers such as "1.5e-3" from upstream JSON
+func parseExponentAmount(s string) (Amount, error) {
+	mantissa, exp, _ := strings.Cut(strings.ToLower(s), "e")
+	e, err := strconv.Atoi(exp)
+	if err != nil || e < -AmountDecimals || e > 18 {
+		return 0, ErrInvalidAmount
+	}
+	m, err := ParseAmount(mantissa)
+	if err != nil {
+		return 0, err
+	}
+	for ; e > 0; e-- {
+		if m > math.MaxInt64/10 || m < math.MinInt64/10 {
+			return 0, ErrInvalidAmount
+		}
+		m *= 10
+	}
+	for ; e < 0; e++ {
+		if m%10 != 0 {
+			return 0, ErrInvalidAmount
+		}
+		m /= 10
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
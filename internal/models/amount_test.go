@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestAmountSumIsExact(t *testing.T) {
+	a, _ := ParseAmount("0.1")
+	b, _ := ParseAmount("0.2")
+	want, _ := ParseAmount("0.3")
+
+	if a+b != want {
+		t.Fatalf("0.1 + 0.2 = %s, want 0.3", a+b)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+	}{
+		{"12", 12 * amountScale},
+		{"-0.5", -amountScale / 2},
+		{"1.000000001", amountScale + 1},
+		{" .25 ", amountScale / 4},
+		{"+3.10", 3*amountScale + amountScale/10},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseAmount(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseAmountRejectsMalformedInput(t *testing.T) {
+	for _, in := range []string{"", ".", "abc", "1.2.3", "1e3", "0.0000000001", "9223372037"} {
+		if _, err := ParseAmount(in); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("ParseAmount(%q) error = %v, want ErrInvalidAmount", in, err)
+		}
+	}
+}
+
+func TestAmountString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0"},
+		{12 * amountScale, "12"},
+		{3 * amountScale / 10, "0.3"},
+		{-amountScale - 1, "-1.000000001"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+}
+
+func TestAmountJSONRoundTrip(t *testing.T) {
+	type body struct {
+		Amount Amount `json:"amount"`
+	}
+	in := body{Amount: 1234*amountScale + 5}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `{"amount":1234.000000005}` {
+		t.Fatalf("Marshal() = %s, want a plain number", data)
+	}
+	var out body
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if out != in {
+		t.Fatalf("round trip = %s, want %s", out.Amount, in.Amount)
+	}
+}
+
+func TestAmountUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+	}{
+		{`"0.3"`, 3 * amountScale / 10},
+		{`0.30000000000000004`, 3 * amountScale / 10},
+		{`1.5e-3`, 1_500_000},
+		{`2E2`, 200 * amountScale},
+		{`null`, 0},
+	}
+	for _, tt := range tests {
+		var got Amount
+		if err := json.Unmarshal([]byte(tt.in), &got); err != nil || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestAmountUnmarshalJSONRejectsOverflowAndInexactExponents(t *testing.T) {
+	for _, in := range []string{`1e19`, `1e-10`, `"9223372037"`, `"ten"`} {
+		var got Amount
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Errorf("Unmarshal(%s) = %s, want an error", in, got)
+		}
+	}
+}
+
+func TestAmountScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Amount
+	}{
+		{"12.5", 12*amountScale + amountScale/2},
+		{[]byte("0.000000001"), 1},
+		{int64(7), 7 * amountScale},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		var got Amount = 99
+		if err := got.Scan(tt.src); err != nil || got != tt.want {
+			t.Errorf("Scan(%#v) = %s, %v, want %s", tt.src, got, err, tt.want)
+		}
+	}
+}
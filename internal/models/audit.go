@@ -0,0 +1,39 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// AuditAction names an order mutation recorded in the audit log
+type AuditAction string
+
+const (
+	AuditOrderCreated       AuditAction = "order.created"
+	AuditOrderCancelled     AuditAction = "order.cancelled"
+	AuditOrderRefunded      AuditAction = "order.refunded"
+	AuditOrderForceFailed   AuditAction = "order.force_failed"
+	AuditOrderStatusChanged AuditAction = "order.status_changed"
+	AuditOrderCorrected     AuditAction = "order.corrected"
+)
+
+// AuditEntry records who changed an order and how. ClientID is the SHA-256
+// hash of the caller's API key, never the key itself, and is empty for changes
+// made by iStar or background workers.
+type AuditEntry struct {
+	ID            uuid.UUID   `json:"id" db:"id"`
+	OrderID       string      `json:"order_id" db:"order_id"`
+	Action        AuditAction `json:"action" db:"action"`
+	ClientID      string      `json:"client_id,omitempty" db:"client_id"`
+	Actor         string      `json:"actor,omitempty" db:"actor"`
+	FromStatus    OrderStatus `json:"from_status,omitempty" db:"from_status"`
+	ToStatus      OrderStatus `json:"to_status" db:"to_status"`
+	CorrelationID string      `json:"correlation_id,omitempty" db:"correlation_id"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+}
+
+// AuditLogResponse is an order's audit trail, oldest entry first
+type AuditLogResponse struct {
+	OrderID string        `json:"order_id"`
+	Entries []*AuditEntry `json:"entries"`
+}
@@ -2,31 +2,108 @@ package models
 
 import "net/http"
 
+// Machine-readable error codes returned in the "code" field of error responses
+const (
+	CodeValidation         = "VALIDATION_ERROR"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeNotFound           = "NOT_FOUND"
+	CodeMethodNotAllowed   = "METHOD_NOT_ALLOWED"
+	CodeConflict           = "CONFLICT"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodePayloadTooLarge    = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedMedia   = "UNSUPPORTED_MEDIA_TYPE"
+	CodeInternal           = "INTERNAL"
+	CodeUnavailable        = "SERVICE_UNAVAILABLE"
+	CodeRecipientNotFound  = "RECIPIENT_NOT_FOUND"
+	CodeRecipientAmbiguous = "RECIPIENT_AMBIGUOUS"
+	CodeAmountBelowMinimum = "AMOUNT_BELOW_MINIMUM"
+	CodeDailyLimitExceeded = "DAILY_LIMIT_EXCEEDED"
+)
+
 type APIError struct {
-	Code    int    `json:"code"`
-	Message string `json:"error"`
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"error"`
+
+	// Details lists per-field problems for request validation errors
+	Details []FieldError `json:"details,omitempty"`
+
+	// RequestID echoes X-Request-ID so a failure can be matched to our logs
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// FieldError describes one request field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse documents the JSON body of every error response
+type ErrorResponse struct {
+	Error     string       `json:"error"`
+	Code      string       `json:"code"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
 }
 
 func (e *APIError) Error() string {
 	return e.Message
 }
 
-func NewAPIError(code int, message string) *APIError {
-	return &APIError{Code: code, Message: message}
+func NewAPIError(statusCode int, code, message string) *APIError {
+	return &APIError{StatusCode: statusCode, Code: code, Message: message}
 }
 
 func ValidationError(message string) *APIError {
-	return NewAPIError(http.StatusBadRequest, message)
+	return NewAPIError(http.StatusBadRequest, CodeValidation, message)
 }
 
 func UnauthorizedError(message string) *APIError {
-	return NewAPIError(http.StatusUnauthorized, message)
+	return NewAPIError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func ForbiddenError(message string) *APIError {
+	return NewAPIError(http.StatusForbidden, CodeForbidden, message)
 }
 
 func NotFoundError(message string) *APIError {
-	return NewAPIError(http.StatusNotFound, message)
+	return NewAPIError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func MethodNotAllowedError(message string) *APIError {
+	return NewAPIError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, message)
+}
+
+func ConflictError(message string) *APIError {
+	return NewAPIError(http.StatusConflict, CodeConflict, message)
+}
+
+func RateLimitedError(message string) *APIError {
+	return NewAPIError(http.StatusTooManyRequests, CodeRateLimited, message)
+}
+
+func PayloadTooLargeError(message string) *APIError {
+	return NewAPIError(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, message)
+}
+
+func UnsupportedMediaTypeError(message string) *APIError {
+	return NewAPIError(http.StatusUnsupportedMediaType, CodeUnsupportedMedia, message)
 }
 
 func InternalServerError(message string) *APIError {
-	return NewAPIError(http.StatusInternalServerError, message)
+	return NewAPIError(http.StatusInternalServerError, CodeInternal, message)
+}
+
+func ServiceUnavailableError(message string) *APIError {
+	return NewAPIError(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+func RecipientNotFoundError(message string) *APIError {
+	return NewAPIError(http.StatusNotFound, CodeRecipientNotFound, message)
+}
+
+func RecipientAmbiguousError(message string) *APIError {
+	return NewAPIError(http.StatusConflict, CodeRecipientAmbiguous, message)
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestErrorHelpersSetStatusAndCode(t *testing.T) {
+	tests := []struct {
+		err    *APIError
+		status int
+		code   string
+	}{
+		{ValidationError("m"), http.StatusBadRequest, CodeValidation},
+		{UnauthorizedError("m"), http.StatusUnauthorized, CodeUnauthorized},
+		{ForbiddenError("m"), http.StatusForbidden, CodeForbidden},
+		{NotFoundError("m"), http.StatusNotFound, CodeNotFound},
+		{MethodNotAllowedError("m"), http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{ConflictError("m"), http.StatusConflict, CodeConflict},
+		{RateLimitedError("m"), http.StatusTooManyRequests, CodeRateLimited},
+		{PayloadTooLargeError("m"), http.StatusRequestEntityTooLarge, CodePayloadTooLarge},
+		{UnsupportedMediaTypeError("m"), http.StatusUnsupportedMediaType, CodeUnsupportedMedia},
+		{InternalServerError("m"), http.StatusInternalServerError, CodeInternal},
+		{ServiceUnavailableError("m"), http.StatusServiceUnavailable, CodeUnavailable},
+		{RecipientNotFoundError("m"), http.StatusNotFound, CodeRecipientNotFound},
+		{RecipientAmbiguousError("m"), http.StatusConflict, CodeRecipientAmbiguous},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if tt.err.StatusCode != tt.status || tt.err.Code != tt.code || tt.err.Message != "m" {
+				t.Errorf("got %d %s %q, want %d %s \"m\"", tt.err.StatusCode, tt.err.Code, tt.err.Message, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestAPIErrorJSON(t *testing.T) {
+	data, err := json.Marshal(ValidationError("Quantity is too small"))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := `{"code":"VALIDATION_ERROR","error":"Quantity is too small"}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+}
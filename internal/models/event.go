@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// OrderEventSource identifies what caused an order state change
+type OrderEventSource string
+
+const (
+	EventSourceWebhook OrderEventSource = "webhook"
+	EventSourceAdmin   OrderEventSource = "admin"
+	EventSourceClient  OrderEventSource = "client"
+)
+
+// OrderEvent records a single state change applied to an order
+type OrderEvent struct {
+	ID            uuid.UUID        `json:"id" db:"id"`
+	OrderID       string           `json:"order_id" db:"order_id"`
+	Source        OrderEventSource `json:"source" db:"source"`
+	EventType     string           `json:"event_type" db:"event_type"`
+	Status        OrderStatus      `json:"status" db:"status"`
+	CorrelationID string           `json:"correlation_id" db:"correlation_id"`
+	Actor         string           `json:"actor,omitempty" db:"actor"`
+	Reason        string           `json:"reason,omitempty" db:"reason"`
+	CreatedAt     time.Time        `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// IdempotencyRecord reserves a client's Idempotency-Key while the order it
+// guards is being placed. OrderID is set once that order is saved.
+type IdempotencyRecord struct {
+	ClientID    string     `db:"client_id"`
+	Key         string     `db:"idempotency_key"`
+	RequestHash string     `db:"request_hash"`
+	OrderID     *uuid.UUID `db:"order_id"`
+	CreatedAt   time.Time  `db:"created_at"`
+}
@@ -18,8 +18,36 @@ const (
 	StatusPending   OrderStatus = "pending"
 	StatusCompleted OrderStatus = "completed"
 	StatusFailed    OrderStatus = "failed"
+	StatusCancelled OrderStatus = "cancelled"
+	StatusRefunded  OrderStatus = "refunded"
 )
 
+// allowedTransitions lists the statuses an order may move to from each status.
+// Statuses without an entry are terminal.
+var allowedTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusCompleted, StatusFailed, StatusCancelled},
+	StatusCompleted: {StatusRefunded},
+}
+
+// Valid reports whether s is a known order status
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case StatusPending, StatusCompleted, StatusFailed, StatusCancelled, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether an order in status s may move to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range allowedTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	ID            uuid.UUID   `json:"id" db:"id"`
 	Type          OrderType   `json:"type" db:"type"`
@@ -28,11 +56,53 @@ type Order struct {
 	RecipientHash string      `json:"recipient_hash"`
 	Quantity      *int        `json:"quantity" db:"quantity"`
 	Months        *int        `json:"months,omitempty"`
-	Amount        float64     `json:"amount" db:"amount"`
-	WalletType    string      `json:"wallet_type" db:"wallet_type"`
+	Amount        Amount      `json:"amount" db:"amount" swaggertype:"number"`
+	WalletType    WalletType  `json:"wallet_type" db:"wallet_type"`
 	TxHash        *string     `json:"tx_hash" db:"tx_hash"`
 	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time   `json:"updated_at"`
 	CompletedAt   *time.Time  `json:"completed_at" db:"completed_at"`
-	ErrorMessage  string      `json:"error_message" db:"error_message"`
+	ErrorMessage  *string     `json:"error_message" db:"error_message"`
+
+	// IStarOrderID is iStar's id for the order, used in every upstream call;
+	// ID is ours and is never sent upstream
+	IStarOrderID string `json:"istar_order_id" db:"istar_order_id"`
+
+	// EstimatedCompletionAt is when a pending order is expected to settle, from
+	// iStar when it says so, otherwise from recent completion times
+	EstimatedCompletionAt *time.Time `json:"estimated_completion_at,omitempty" db:"estimated_completion_at"`
+
+	// Refund details, set once a completed order has been refunded
+	RefundedAt   *time.Time `json:"refunded_at,omitempty" db:"refunded_at"`
+	RefundID     *string    `json:"refund_id,omitempty" db:"refund_id"`
+	RefundAmount *Amount    `json:"refund_amount,omitempty" db:"refund_amount" swaggertype:"number"`
+
+	// TxExplorerURL links TxHash on a block explorer and TxVerified reports
+	// whether the explorer knows the transaction; both are filled in on read
+	// for on-chain wallet types and never stored
+	TxExplorerURL string `json:"tx_explorer_url,omitempty" db:"-"`
+	TxVerified    *bool  `json:"tx_verified,omitempty" db:"-"`
+
+	// Metadata is the integrator's own key/value pairs from the create request,
+	// stored and returned as given and never sent to iStar
+	Metadata map[string]string `json:"metadata,omitempty" db:"metadata"`
+
+	// Replayed is set when the order is returned for a repeated Idempotency-Key,
+	// or because iStar answered with an order we already hold, instead of being
+	// created by this request
+	Replayed bool `json:"replayed,omitempty" db:"-"`
+
+	// Idempotency bookkeeping; never serialized to clients.
+	ClientID       string `json:"-" db:"client_id"`
+	IdempotencyKey string `json:"-" db:"idempotency_key"`
+	RequestHash    string `json:"-" db:"request_hash"`
+}
+
+// UpstreamID is the id iStar knows the order by. Orders stored before
+// IStarOrderID existed used iStar's id as their own.
+func (o *Order) UpstreamID() string {
+	if o.IStarOrderID != "" {
+		return o.IStarOrderID
+	}
+	return o.ID.String()
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+// OrderCursor marks a position in the orders list, which is ordered by
+// (created_at, id) descending. Encoded cursors are opaque to clients.
+type OrderCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// ErrInvalidCursor is returned by DecodeOrderCursor for malformed cursors
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Encode returns the cursor as URL-safe base64 JSON
+func (c OrderCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeOrderCursor parses a cursor produced by Encode
+func DecodeOrderCursor(s string) (*OrderCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c OrderCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.CreatedAt.IsZero() || c.ID == uuid.Nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// OrderListQuery selects a page of orders. After, when set, takes precedence
+// over Offset; callers should not set both. ClientID, when set, limits the
+// orders to those one client placed.
+type OrderListQuery struct {
+	ClientID string
+	Status   OrderStatus
+	Limit    int
+	Offset   int
+	After    *OrderCursor
+}
+
+// OrderListResponse is one page of orders. NextCursor is empty on the last page.
+// Total counts every order matching the client and status filters; Offset is zero for
+// pages fetched by cursor.
+type OrderListResponse struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Total      int      `json:"total"`
+	Limit      int      `json:"limit"`
+	Offset     int      `json:"offset"`
+}
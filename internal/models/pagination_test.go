@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestOrderCursorRoundTrip(t *testing.T) {
+	want := OrderCursor{CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 678, time.UTC), ID: uuid.New()}
+
+	got, err := DecodeOrderCursor(want.Encode())
+	if err != nil {
+		t.Fatalf("DecodeOrderCursor: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeOrderCursorRejectsMalformedCursors(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := map[string]string{
+		"not base64":   "not base64!",
+		"not JSON":     encode("cursor"),
+		"missing time": encode(`{"i":"` + uuid.NewString() + `"}`),
+		"missing id":   encode(`{"c":"2026-01-02T03:04:05Z"}`),
+		"bad id":       encode(`{"c":"2026-01-02T03:04:05Z","i":"order-1"}`),
+	}
+	for name, cursor := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeOrderCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeOrderCursor(%q) = %v, want ErrInvalidCursor", cursor, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import "strings"
+
+// UsernameAllowlist restricts which usernames may receive gifts, e.g. to a set
+// of test accounts in a sandbox. An empty list allows everyone.
+type UsernameAllowlist map[string]bool
+
+// NewUsernameAllowlist builds an allowlist from usernames, ignoring case and a leading @
+func NewUsernameAllowlist(usernames []string) UsernameAllowlist {
+	allow := make(UsernameAllowlist, len(usernames))
+	for _, u := range usernames {
+		allow[normalizeUsername(u)] = true
+	}
+	return allow
+}
+
+// Validate returns a ValidationError when the list is non-empty and username is not on it
+func (a UsernameAllowlist) Validate(username string) error {
+	if len(a) == 0 || a[normalizeUsername(username)] {
+		return nil
+	}
+	return ValidationError("Recipient " + username + " is not on the recipient allowlist")
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUsernameAllowlist(t *testing.T) {
+	allow := NewUsernameAllowlist([]string{"Alice_1", "@bob_test"})
+
+	for _, username := range []string{"alice_1", "ALICE_1", "@alice_1", "bob_test", " Bob_Test "} {
+		if err := allow.Validate(username); err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", username, err)
+		}
+	}
+
+	err := allow.Validate("carol_2")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != CodeValidation {
+		t.Fatalf("Validate(carol_2) = %v, want a validation error", err)
+	}
+	if want := "Recipient carol_2 is not on the recipient allowlist"; apiErr.Message != want {
+		t.Errorf("message = %q, want %q", apiErr.Message, want)
+	}
+}
+
+func TestEmptyUsernameAllowlistAllowsEveryone(t *testing.T) {
+	for _, allow := range []UsernameAllowlist{nil, NewUsernameAllowlist(nil)} {
+		if err := allow.Validate("carol_2"); err != nil {
+			t.Errorf("Validate on an empty allowlist = %v, want nil", err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ReconciliationMismatch describes an order whose local status disagrees with iStar
+type ReconciliationMismatch struct {
+	OrderID        string      `json:"order_id"`
+	LocalStatus    OrderStatus `json:"local_status"`
+	UpstreamStatus string      `json:"upstream_status"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// ReconciliationReport summarises drift between local orders and iStar for a time window
+type ReconciliationReport struct {
+	From       time.Time                `json:"from"`
+	To         time.Time                `json:"to"`
+	Sampled    int                      `json:"sampled"`
+	Matched    int                      `json:"matched"`
+	Mismatches []ReconciliationMismatch `json:"mismatches"`
+	// Unchecked lists orders whose upstream status could not be fetched
+	Unchecked []string `json:"unchecked"`
+	Truncated bool     `json:"truncated"`
+}
@@ -1,15 +1,106 @@
 package models
 
+import "time"
+
+// CreateStarOrderRequest places a star gift. RecipientHash may be omitted, in
+// which case the recipient is resolved from Username.
 type CreateStarOrderRequest struct {
-	Username      string `json:"username" binding:"required"`
-	RecipientHash string `json:"recipient_hash" binding:"required"`
-	Quantity      int    `json:"quantity" binding:"required,min=50,max=1000000"`
-	WalletType    string `json:"wallet_type" binding:"required"`
+	Username      string     `json:"username" binding:"required"`
+	RecipientHash string     `json:"recipient_hash"`
+	Quantity      int        `json:"quantity" binding:"required,min=50,max=1000000"`
+	WalletType    WalletType `json:"wallet_type" binding:"required"`
+
+	// QuoteID optionally locks the price of an earlier quote for this order
+	QuoteID string `json:"quote_id,omitempty"`
+
+	// Metadata is the caller's own reference data, e.g. an invoice id. It is
+	// stored with the order and returned on reads but never sent to iStar.
+	Metadata map[string]string `json:"metadata,omitempty" binding:"omitempty,max=20,dive,keys,min=1,max=40,endkeys,max=500"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header, not the body.
+	IdempotencyKey string `json:"-"`
+
+	// ClientOrderID is our order id, sent to iStar with every create so a sync
+	// order can be looked up if the response never arrives. It is never bound
+	// from the client's body.
+	ClientOrderID string `json:"-"`
+}
+
+// ForIStar returns the request as sent upstream, without Metadata
+func (r CreateStarOrderRequest) ForIStar() CreateStarOrderRequest {
+	r.Metadata = nil
+	return r
 }
 
+// CreatePremiumOrderRequest places a premium gift. RecipientHash may be
+// omitted, in which case the recipient is resolved from Username.
 type CreatePremiumOrderRequest struct {
-	Username      string `json:"username" binding:"required"`
-	RecipientHash string `json:"recipient_hash" binding:"required"`
-	Months        int    `json:"months" binding:"required,oneof=3 6 12"`
-	WalletType    string `json:"wallet_type" binding:"required"`
+	Username      string     `json:"username" binding:"required"`
+	RecipientHash string     `json:"recipient_hash"`
+	Months        int        `json:"months" binding:"required,oneof=3 6 12"`
+	WalletType    WalletType `json:"wallet_type" binding:"required"`
+
+	// QuoteID optionally locks the price of an earlier quote for this order
+	QuoteID string `json:"quote_id,omitempty"`
+
+	// Metadata is the caller's own reference data; see CreateStarOrderRequest.
+	Metadata map[string]string `json:"metadata,omitempty" binding:"omitempty,max=20,dive,keys,min=1,max=40,endkeys,max=500"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header, not the body.
+	IdempotencyKey string `json:"-"`
+
+	// ClientOrderID is our order id; see CreateStarOrderRequest.
+	ClientOrderID string `json:"-"`
+}
+
+// ForIStar returns the request as sent upstream, without Metadata
+func (r CreatePremiumOrderRequest) ForIStar() CreatePremiumOrderRequest {
+	r.Metadata = nil
+	return r
+}
+
+// BatchStarOrderItem is a single recipient in a batch star order. Items are
+// validated individually so one bad entry does not reject the whole batch.
+type BatchStarOrderItem struct {
+	Username      string `json:"username"`
+	RecipientHash string `json:"recipient_hash"`
+	Quantity      int    `json:"quantity"`
+
+	// Weight is the item's share of the batch's TotalQuantity. It is used in
+	// place of Quantity when the batch gives a total.
+	Weight int `json:"weight,omitempty"`
+}
+
+// BatchStarOrderRequest gifts stars to up to 100 recipients from one wallet
+type BatchStarOrderRequest struct {
+	WalletType WalletType           `json:"wallet_type" binding:"required"`
+	Items      []BatchStarOrderItem `json:"items" binding:"required,min=1,max=100"`
+
+	// TotalQuantity, when set, is split across the items by Weight instead of
+	// each item naming its own quantity
+	TotalQuantity int `json:"total_quantity,omitempty" binding:"omitempty,min=50,max=100000000"`
+
+	// IdempotencyKey is taken from the Idempotency-Key header, not the body.
+	// Each item derives its own key from it.
+	IdempotencyKey string `json:"-"`
+}
+
+// ForceFailOrderRequest is the body of the admin force-fail endpoint
+type ForceFailOrderRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// AdminUpdateOrderRequest is the body of the admin order correction endpoint.
+// Omitted fields keep their current value; Reason is recorded with the change.
+type AdminUpdateOrderRequest struct {
+	Status       *OrderStatus `json:"status,omitempty"`
+	TxHash       *string      `json:"tx_hash,omitempty" binding:"omitempty,max=255"`
+	ErrorMessage *string      `json:"error_message,omitempty" binding:"omitempty,max=1000"`
+	CompletedAt  *time.Time   `json:"completed_at,omitempty"`
+	Reason       string       `json:"reason,omitempty" binding:"max=500"`
+}
+
+// Empty reports whether the request changes nothing
+func (r AdminUpdateOrderRequest) Empty() bool {
+	return r.Status == nil && r.TxHash == nil && r.ErrorMessage == nil && r.CompletedAt == nil
 }
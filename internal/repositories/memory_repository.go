@@ -0,0 +1,520 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/internal/models"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryStore holds the data of an in-memory repository. mu guards every
+// field except locks, which has its own mutex so TryLockOrder never waits on
+// a transaction.
+type memoryStore struct {
+	mu        sync.Mutex
+	orders    map[string]*models.Order
+	events    []*models.OrderEvent
+	audit     []*models.AuditEntry
+	processed map[string]string
+	replays   map[string]*models.WebhookReplay
+	// idempotency holds reserved keys by client id and key
+	idempotency map[idempotencyKey]models.IdempotencyRecord
+
+	locksMu sync.Mutex
+	locks   map[string]bool
+}
+
+// inMemoryOrderRepository implements OrderRepository on a memoryStore. Orders
+// and replays are copied in and out, so callers never share memory with the
+// store. inTx is set on the repository handed to a WithTx callback, which
+// already holds the store's mutex.
+type inMemoryOrderRepository struct {
+	store *memoryStore
+	inTx  bool
+}
+
+// NewInMemoryOrderRepository returns an OrderRepository that keeps everything
+// in process memory, for local development and tests. Data is lost on restart
+// and is not shared between replicas.
+func NewInMemoryOrderRepository() OrderRepository {
+	return &inMemoryOrderRepository{store: &memoryStore{
+		orders:    make(map[string]*models.Order),
+		processed: make(map[string]string),
+		replays:   make(map[string]*models.WebhookReplay),
+		locks:     make(map[string]bool),
+
+		idempotency: make(map[idempotencyKey]models.IdempotencyRecord),
+	}}
+}
+
+// idempotencyKey identifies a reservation, like the Postgres unique constraint
+type idempotencyKey struct {
+	clientID, key string
+}
+
+// lock takes the store's mutex unless the caller is inside WithTx
+func (r *inMemoryOrderRepository) lock() func() {
+	if r.inTx {
+		return func() {}
+	}
+	r.store.mu.Lock()
+	return r.store.mu.Unlock
+}
+
+// WithTx runs fn holding the store's mutex, so transactions are serialized.
+// When fn fails, the orders, replays, webhook marks and idempotency keys are
+// restored and events and audit entries written by fn are dropped.
+func (r *inMemoryOrderRepository) WithTx(ctx context.Context, fn func(tx OrderRepository) error) error {
+	if r.inTx {
+		return fn(r)
+	}
+
+	s := r.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Stored values are replaced rather than modified, so shallow copies are a snapshot
+	orders := cloneMap(s.orders)
+	processed := cloneMap(s.processed)
+	replays := cloneMap(s.replays)
+	idempotency := cloneMap(s.idempotency)
+	events, audit := len(s.events), len(s.audit)
+
+	if err := fn(&inMemoryOrderRepository{store: s, inTx: true}); err != nil {
+		s.orders, s.processed, s.replays, s.idempotency = orders, processed, replays, idempotency
+		s.events, s.audit = s.events[:events], s.audit[:audit]
+		return err
+	}
+	return nil
+}
+
+// CreateOrder stores order, enforcing the unique iStar order id like the
+// Postgres index does
+func (r *inMemoryOrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
+	defer r.lock()()
+	for _, stored := range r.store.orders {
+		if stored.IStarOrderID == order.IStarOrderID && stored.ID != order.ID {
+			return ErrDuplicateIStarOrderID
+		}
+	}
+	r.store.orders[order.ID.String()] = copyOrder(order)
+	return nil
+}
+
+// UpdateOrderStatus changes the order's status fields. Like the Postgres
+// update, an unknown id is not an error.
+func (r *inMemoryOrderRepository) UpdateOrderStatus(ctx context.Context, orderID string, status models.OrderStatus, txHash *string, completedAt *time.Time, errorMessage *string) error {
+	defer r.lock()()
+	stored, ok := r.store.orders[orderID]
+	if !ok {
+		return nil
+	}
+	order := copyOrder(stored)
+	order.Status = status
+	order.TxHash = txHash
+	order.CompletedAt = completedAt
+	order.ErrorMessage = errorMessage
+	order.UpdatedAt = time.Now()
+	r.store.orders[orderID] = order
+	return nil
+}
+
+// SetIStarOrderID replaces the iStar id of an order recorded before iStar
+// confirmed it
+func (r *inMemoryOrderRepository) SetIStarOrderID(ctx context.Context, orderID, istarOrderID string) error {
+	defer r.lock()()
+	stored, ok := r.store.orders[orderID]
+	if !ok {
+		return nil
+	}
+	order := copyOrder(stored)
+	order.IStarOrderID = istarOrderID
+	order.UpdatedAt = time.Now()
+	r.store.orders[orderID] = order
+	return nil
+}
+
+// MarkOrderRefunded moves a completed order to refunded; other orders are
+// reported as ErrOrderNotFound, as in Postgres
+func (r *inMemoryOrderRepository) MarkOrderRefunded(ctx context.Context, orderID string, refundedAt time.Time, refundID string, amount models.Amount) error {
+	defer r.lock()()
+	stored, ok := r.store.orders[orderID]
+	if !ok || stored.Status != models.StatusCompleted {
+		return ErrOrderNotFound
+	}
+	order := copyOrder(stored)
+	order.Status = models.StatusRefunded
+	order.RefundedAt = &refundedAt
+	order.RefundID = &refundID
+	order.RefundAmount = &amount
+	order.UpdatedAt = refundedAt
+	r.store.orders[orderID] = order
+	return nil
+}
+
+// GetOrderByTxHash returns every order settled by txHash, oldest first
+func (r *inMemoryOrderRepository) GetOrderByTxHash(ctx context.Context, txHash string) ([]*models.Order, error) {
+	return r.filterOrders(func(o *models.Order) bool {
+		return o.TxHash != nil && *o.TxHash == txHash
+	}, oldestFirst, 0), nil
+}
+
+// GetOrderByIdempotencyKey returns the newest order the client created with
+// key at or after since, or ErrOrderNotFound
+func (r *inMemoryOrderRepository) GetOrderByIdempotencyKey(ctx context.Context, clientID, key string, since time.Time) (*models.Order, error) {
+	orders := r.filterOrders(func(o *models.Order) bool {
+		return o.ClientID == clientID && o.IdempotencyKey == key && !o.CreatedAt.Before(since)
+	}, newestFirst, 1)
+	if len(orders) == 0 {
+		return nil, ErrOrderNotFound
+	}
+	return orders[0], nil
+}
+
+// GetOrderByID returns a single order or ErrOrderNotFound
+func (r *inMemoryOrderRepository) GetOrderByID(ctx context.Context, orderID string) (*models.Order, error) {
+	defer r.lock()()
+	order, ok := r.store.orders[orderID]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	return copyOrder(order), nil
+}
+
+// GetOrderByIStarID returns the order iStar knows as istarOrderID, or ErrOrderNotFound
+func (r *inMemoryOrderRepository) GetOrderByIStarID(ctx context.Context, istarOrderID string) (*models.Order, error) {
+	orders := r.filterOrders(func(o *models.Order) bool {
+		return o.IStarOrderID == istarOrderID
+	}, oldestFirst, 1)
+	if len(orders) == 0 {
+		return nil, ErrOrderNotFound
+	}
+	return orders[0], nil
+}
+
+// ListPendingOrders returns up to limit pending orders created before createdBefore, oldest first
+func (r *inMemoryOrderRepository) ListPendingOrders(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Order, error) {
+	return r.filterOrders(func(o *models.Order) bool {
+		return o.Status == models.StatusPending && o.CreatedAt.Before(createdBefore)
+	}, oldestFirst, limit), nil
+}
+
+// ListOrdersCreatedBetween returns up to limit orders created in [from, to), oldest first
+func (r *inMemoryOrderRepository) ListOrdersCreatedBetween(ctx context.Context, from, to time.Time, limit int) ([]*models.Order, error) {
+	return r.filterOrders(func(o *models.Order) bool {
+		return !o.CreatedAt.Before(from) && o.CreatedAt.Before(to)
+	}, oldestFirst, limit), nil
+}
+
+// ListOrders returns a page of orders, newest first, with the same keyset
+// ordering as the Postgres query
+func (r *inMemoryOrderRepository) ListOrders(ctx context.Context, q models.OrderListQuery) ([]*models.Order, error) {
+	orders := r.filterOrders(func(o *models.Order) bool {
+		if !matchesListFilters(o, q) {
+			return false
+		}
+		if q.After != nil {
+			return o.CreatedAt.Before(q.After.CreatedAt) ||
+				(o.CreatedAt.Equal(q.After.CreatedAt) && bytes.Compare(o.ID[:], q.After.ID[:]) < 0)
+		}
+		return true
+	}, newestFirst, 0)
+
+	offset := q.Offset
+	if q.After != nil {
+		offset = 0
+	}
+	if offset >= len(orders) {
+		return nil, nil
+	}
+	orders = orders[offset:]
+	if q.Limit > 0 && len(orders) > q.Limit {
+		orders = orders[:q.Limit]
+	}
+	return orders, nil
+}
+
+// CountOrders counts the orders matching q's client and status filters,
+// ignoring its paging
+func (r *inMemoryOrderRepository) CountOrders(ctx context.Context, q models.OrderListQuery) (int, error) {
+	orders := r.filterOrders(func(o *models.Order) bool {
+		return matchesListFilters(o, q)
+	}, oldestFirst, 0)
+	return len(orders), nil
+}
+
+// matchesListFilters reports whether o passes q's client and status filters
+func matchesListFilters(o *models.Order, q models.OrderListQuery) bool {
+	return (q.ClientID == "" || o.ClientID == q.ClientID) && (q.Status == "" || o.Status == q.Status)
+}
+
+// MedianCompletionLatency returns the median time from creation to completion
+// of orders paid with walletType that completed after since, or zero when
+// there are none
+func (r *inMemoryOrderRepository) MedianCompletionLatency(ctx context.Context, walletType string, since time.Time) (time.Duration, error) {
+	orders := r.filterOrders(func(o *models.Order) bool {
+		return o.Status == models.StatusCompleted && string(o.WalletType) == walletType &&
+			o.CompletedAt != nil && !o.CompletedAt.Before(since)
+	}, oldestFirst, 0)
+	if len(orders) == 0 {
+		return 0, nil
+	}
+
+	latencies := make([]time.Duration, len(orders))
+	for i, o := range orders {
+		latencies[i] = o.CompletedAt.Sub(o.CreatedAt)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	mid := len(latencies) / 2
+	if len(latencies)%2 == 0 {
+		return (latencies[mid-1] + latencies[mid]) / 2, nil
+	}
+	return latencies[mid], nil
+}
+
+// SumClientSpendSince totals the amounts of clientID's pending and completed
+// orders created at or after since
+func (r *inMemoryOrderRepository) SumClientSpendSince(ctx context.Context, clientID string, since time.Time) (models.Amount, error) {
+	orders := r.filterOrders(func(o *models.Order) bool {
+		return o.ClientID == clientID && !o.CreatedAt.Before(since) &&
+			(o.Status == models.StatusPending || o.Status == models.StatusCompleted)
+	}, oldestFirst, 0)
+
+	var total models.Amount
+	for _, o := range orders {
+		total += o.Amount
+	}
+	return total, nil
+}
+
+// RecordOrderEvent appends an entry to the order's state change history
+func (r *inMemoryOrderRepository) RecordOrderEvent(ctx context.Context, event *models.OrderEvent) error {
+	defer r.lock()()
+	e := *event
+	r.store.events = append(r.store.events, &e)
+	return nil
+}
+
+// RecordAudit appends an entry to the order audit log
+func (r *inMemoryOrderRepository) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	defer r.lock()()
+	e := *entry
+	r.store.audit = append(r.store.audit, &e)
+	return nil
+}
+
+// ListAuditEntries returns an order's audit log, oldest entry first
+func (r *inMemoryOrderRepository) ListAuditEntries(ctx context.Context, orderID string) ([]*models.AuditEntry, error) {
+	defer r.lock()()
+	var entries []*models.AuditEntry
+	for _, e := range r.store.audit {
+		if e.OrderID == orderID {
+			entry := *e
+			entries = append(entries, &entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+		}
+		return bytes.Compare(entries[i].ID[:], entries[j].ID[:]) < 0
+	})
+	return entries, nil
+}
+
+// ReserveIdempotencyKey claims record's key for its client. A reservation made
+// before expiredBefore is taken over; a newer one yields ErrIdempotencyKeyInUse.
+func (r *inMemoryOrderRepository) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, expiredBefore time.Time) error {
+	defer r.lock()()
+	id := idempotencyKey{record.ClientID, record.Key}
+	if stored, ok := r.store.idempotency[id]; ok && !stored.CreatedAt.Before(expiredBefore) {
+		return ErrIdempotencyKeyInUse
+	}
+	reserved := *record
+	reserved.OrderID = nil
+	r.store.idempotency[id] = reserved
+	return nil
+}
+
+// LinkIdempotencyKey records the order a reserved key produced
+func (r *inMemoryOrderRepository) LinkIdempotencyKey(ctx context.Context, clientID, key string, orderID uuid.UUID) error {
+	defer r.lock()()
+	id := idempotencyKey{clientID, key}
+	if stored, ok := r.store.idempotency[id]; ok {
+		stored.OrderID = &orderID
+		r.store.idempotency[id] = stored
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops a reservation that never produced an order
+func (r *inMemoryOrderRepository) ReleaseIdempotencyKey(ctx context.Context, clientID, key string) error {
+	defer r.lock()()
+	id := idempotencyKey{clientID, key}
+	if stored, ok := r.store.idempotency[id]; ok && stored.OrderID == nil {
+		delete(r.store.idempotency, id)
+	}
+	return nil
+}
+
+// IsWebhookProcessed reports whether the webhook event has already been applied
+func (r *inMemoryOrderRepository) IsWebhookProcessed(ctx context.Context, eventID string) (bool, error) {
+	defer r.lock()()
+	_, ok := r.store.processed[eventID]
+	return ok, nil
+}
+
+// MarkWebhookProcessed remembers that the webhook event has been applied.
+// Marking an event twice is not an error.
+func (r *inMemoryOrderRepository) MarkWebhookProcessed(ctx context.Context, eventID, orderID string) error {
+	defer r.lock()()
+	if _, ok := r.store.processed[eventID]; !ok {
+		r.store.processed[eventID] = orderID
+	}
+	return nil
+}
+
+// EnqueueWebhookReplay stores a webhook to be applied again by the replay worker
+func (r *inMemoryOrderRepository) EnqueueWebhookReplay(ctx context.Context, replay *models.WebhookReplay) error {
+	defer r.lock()()
+	rp := *replay
+	r.store.replays[replay.ID] = &rp
+	return nil
+}
+
+// ListDueWebhookReplays returns up to limit queued webhooks due by now that
+// have attempts left, oldest due first
+func (r *inMemoryOrderRepository) ListDueWebhookReplays(ctx context.Context, now time.Time, maxAttempts, limit int) ([]*models.WebhookReplay, error) {
+	defer r.lock()()
+	var replays []*models.WebhookReplay
+	for _, rp := range r.store.replays {
+		if !rp.NextAttemptAt.After(now) && rp.Attempts < maxAttempts {
+			replay := *rp
+			replays = append(replays, &replay)
+		}
+	}
+	sort.Slice(replays, func(i, j int) bool { return replays[i].NextAttemptAt.Before(replays[j].NextAttemptAt) })
+	if limit > 0 && len(replays) > limit {
+		replays = replays[:limit]
+	}
+	return replays, nil
+}
+
+// RescheduleWebhookReplay records a failed replay attempt
+func (r *inMemoryOrderRepository) RescheduleWebhookReplay(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, lastError string) error {
+	defer r.lock()()
+	stored, ok := r.store.replays[id]
+	if !ok {
+		return nil
+	}
+	replay := *stored
+	replay.Attempts = attempts
+	replay.NextAttemptAt = nextAttemptAt
+	replay.LastError = lastError
+	r.store.replays[id] = &replay
+	return nil
+}
+
+// DeleteWebhookReplay removes a webhook that has been applied
+func (r *inMemoryOrderRepository) DeleteWebhookReplay(ctx context.Context, id string) error {
+	defer r.lock()()
+	delete(r.store.replays, id)
+	return nil
+}
+
+// WebhookReplayStats counts queued webhooks with attempts left and those exhausted
+func (r *inMemoryOrderRepository) WebhookReplayStats(ctx context.Context, maxAttempts int) (*models.WebhookQueueStats, error) {
+	defer r.lock()()
+	var stats models.WebhookQueueStats
+	for _, rp := range r.store.replays {
+		if rp.Attempts < maxAttempts {
+			stats.Pending++
+		} else {
+			stats.Exhausted++
+		}
+	}
+	return &stats, nil
+}
+
+// TryLockOrder takes a process-local lock on orderID. It only excludes
+// callers in this process, which is all an in-memory store can serve.
+func (r *inMemoryOrderRepository) TryLockOrder(ctx context.Context, orderID string) (func(), bool, error) {
+	s := r.store
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	if s.locks[orderID] {
+		return nil, false, nil
+	}
+	s.locks[orderID] = true
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.locksMu.Lock()
+			delete(s.locks, orderID)
+			s.locksMu.Unlock()
+		})
+	}, true, nil
+}
+
+// Ping always succeeds; there is nothing to reach
+func (r *inMemoryOrderRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// creationOrder sorts orders by creation time with ties broken by id, as the
+// Postgres queries do
+type creationOrder bool
+
+const (
+	oldestFirst creationOrder = false
+	newestFirst creationOrder = true
+)
+
+// filterOrders returns copies of up to limit orders matching keep, sorted by
+// order; a limit of zero or less returns every match
+func (r *inMemoryOrderRepository) filterOrders(keep func(*models.Order) bool, order creationOrder, limit int) []*models.Order {
+	defer r.lock()()
+	var orders []*models.Order
+	for _, o := range r.store.orders {
+		if keep(o) {
+			orders = append(orders, copyOrder(o))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if order == newestFirst {
+			a, b = b, a
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return bytes.Compare(a.ID[:], b.ID[:]) < 0
+	})
+	if limit > 0 && len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return orders
+}
+
+// copyOrder returns a copy of o holding only what the orders table stores
+func copyOrder(o *models.Order) *models.Order {
+	c := *o
+	c.Metadata = maps.Clone(o.Metadata)
+	c.TxExplorerURL = ""
+	c.TxVerified = nil
+	c.Replayed = false
+	return &c
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,294 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/internal/models"
+)
+
+func newTestOrder(clientID, key string) *models.Order {
+	now := time.Now()
+	id := uuid.New()
+	return &models.Order{
+		ID:             id,
+		IStarOrderID:   "istar-" + id.String(),
+		Type:           models.OrderTypeStar,
+		Status:         models.StatusPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ClientID:       clientID,
+		IdempotencyKey: key,
+	}
+}
+
+func reserve(repo OrderRepository, clientID, key string, at time.Time) error {
+	record := &models.IdempotencyRecord{ClientID: clientID, Key: key, RequestHash: "hash", CreatedAt: at}
+	return repo.ReserveIdempotencyKey(context.Background(), record, at.Add(-24*time.Hour))
+}
+
+func TestWithTxRollsBackEverythingOnFailure(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryOrderRepository()
+	order := newTestOrder("client-a", "key-1")
+	errMidway := errors.New("failed midway")
+
+	err := repo.WithTx(ctx, func(tx OrderRepository) error {
+		if err := reserve(tx, "client-a", "key-1", time.Now()); err != nil {
+			return err
+		}
+		if err := tx.CreateOrder(ctx, order); err != nil {
+			return err
+		}
+		if err := tx.LinkIdempotencyKey(ctx, "client-a", "key-1", order.ID); err != nil {
+			return err
+		}
+		if err := tx.RecordAudit(ctx, &models.AuditEntry{ID: uuid.New(), OrderID: order.ID.String()}); err != nil {
+			return err
+		}
+		return errMidway
+	})
+	if !errors.Is(err, errMidway) {
+		t.Fatalf("WithTx() = %v, want the callback's error", err)
+	}
+
+	if _, err := repo.GetOrderByID(ctx, order.ID.String()); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("order survived the rollback: %v", err)
+	}
+	if entries, _ := repo.ListAuditEntries(ctx, order.ID.String()); len(entries) != 0 {
+		t.Errorf("%d audit entries survived the rollback", len(entries))
+	}
+	if err := reserve(repo, "client-a", "key-1", time.Now()); err != nil {
+		t.Errorf("idempotency key survived the rollback: %v", err)
+	}
+}
+
+func TestWithTxCommitsOnSuccess(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryOrderRepository()
+	order := newTestOrder("client-a", "")
+
+	if err := repo.WithTx(ctx, func(tx OrderRepository) error {
+		return tx.CreateOrder(ctx, order)
+	}); err != nil {
+		t.Fatalf("WithTx() = %v", err)
+	}
+	if _, err := repo.GetOrderByID(ctx, order.ID.String()); err != nil {
+		t.Errorf("committed order not found: %v", err)
+	}
+}
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Run("held key is refused", func(t *testing.T) {
+		repo := NewInMemoryOrderRepository()
+		if err := reserve(repo, "client-a", "key-1", now); err != nil {
+			t.Fatalf("first reservation: %v", err)
+		}
+		if err := reserve(repo, "client-a", "key-1", now); !errors.Is(err, ErrIdempotencyKeyInUse) {
+			t.Errorf("second reservation = %v, want ErrIdempotencyKeyInUse", err)
+		}
+		if err := reserve(repo, "client-b", "key-1", now); err != nil {
+			t.Errorf("another client's reservation of the same key: %v", err)
+		}
+	})
+
+	t.Run("expired key is taken over", func(t *testing.T) {
+		repo := NewInMemoryOrderRepository()
+		if err := reserve(repo, "client-a", "key-1", now.Add(-25*time.Hour)); err != nil {
+			t.Fatalf("first reservation: %v", err)
+		}
+		if err := reserve(repo, "client-a", "key-1", now); err != nil {
+			t.Errorf("reserving an expired key: %v", err)
+		}
+	})
+
+	t.Run("release keeps linked keys", func(t *testing.T) {
+		repo := NewInMemoryOrderRepository()
+		reserve(repo, "client-a", "linked", now)
+		repo.LinkIdempotencyKey(ctx, "client-a", "linked", uuid.New())
+		reserve(repo, "client-a", "unlinked", now)
+
+		repo.ReleaseIdempotencyKey(ctx, "client-a", "linked")
+		repo.ReleaseIdempotencyKey(ctx, "client-a", "unlinked")
+
+		if err := reserve(repo, "client-a", "linked", now); !errors.Is(err, ErrIdempotencyKeyInUse) {
+			t.Errorf("linked key was released: %v", err)
+		}
+		if err := reserve(repo, "client-a", "unlinked", now); err != nil {
+			t.Errorf("unlinked key was not released: %v", err)
+		}
+	})
+}
+
+func TestMarkWebhookProcessed(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryOrderRepository()
+
+	if processed, err := repo.IsWebhookProcessed(ctx, "evt-1"); err != nil || processed {
+		t.Fatalf("IsWebhookProcessed before marking = %v, %v, want false", processed, err)
+	}
+	for range 2 {
+		if err := repo.MarkWebhookProcessed(ctx, "evt-1", "order-1"); err != nil {
+			t.Fatalf("MarkWebhookProcessed: %v", err)
+		}
+	}
+	if processed, err := repo.IsWebhookProcessed(ctx, "evt-1"); err != nil || !processed {
+		t.Errorf("IsWebhookProcessed after marking = %v, %v, want true", processed, err)
+	}
+	if processed, _ := repo.IsWebhookProcessed(ctx, "evt-2"); processed {
+		t.Error("an unrelated event is reported processed")
+	}
+}
+
+func TestCreateAndGetOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryOrderRepository()
+	order := newTestOrder("client-a", "")
+
+	if err := repo.CreateOrder(ctx, order); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	order.Status = models.StatusFailed
+
+	byID, err := repo.GetOrderByID(ctx, order.ID.String())
+	if err != nil || byID.ID != order.ID || byID.Status != models.StatusPending {
+		t.Fatalf("GetOrderByID = %+v, %v; want the order as stored", byID, err)
+	}
+	byID.Status = models.StatusCompleted
+	byIStarID, err := repo.GetOrderByIStarID(ctx, order.IStarOrderID)
+	if err != nil || byIStarID.ID != order.ID || byIStarID.Status != models.StatusPending {
+		t.Errorf("GetOrderByIStarID = %+v, %v; want the stored order untouched by callers' copies", byIStarID, err)
+	}
+
+	if _, err := repo.GetOrderByID(ctx, uuid.NewString()); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("GetOrderByID(unknown) = %v, want ErrOrderNotFound", err)
+	}
+	if _, err := repo.GetOrderByIStarID(ctx, "istar-unknown"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("GetOrderByIStarID(unknown) = %v, want ErrOrderNotFound", err)
+	}
+
+	duplicate := newTestOrder("client-b", "")
+	duplicate.IStarOrderID = order.IStarOrderID
+	if err := repo.CreateOrder(ctx, duplicate); !errors.Is(err, ErrDuplicateIStarOrderID) {
+		t.Errorf("CreateOrder with a taken iStar id = %v, want ErrDuplicateIStarOrderID", err)
+	}
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryOrderRepository()
+	order := newTestOrder("client-a", "")
+	if err := repo.CreateOrder(ctx, order); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	txHash := "tx-1"
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	if err := repo.UpdateOrderStatus(ctx, order.ID.String(), models.StatusCompleted, &txHash, &completedAt, nil); err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusCompleted || stored.TxHash == nil || *stored.TxHash != txHash ||
+		stored.CompletedAt == nil || !stored.CompletedAt.Equal(completedAt) {
+		t.Errorf("stored = %s, tx %v, completed %v; want completed with tx-1", stored.Status, stored.TxHash, stored.CompletedAt)
+	}
+	if !stored.UpdatedAt.After(order.UpdatedAt) {
+		t.Errorf("UpdatedAt = %v, want it moved past %v", stored.UpdatedAt, order.UpdatedAt)
+	}
+	if err := repo.UpdateOrderStatus(ctx, uuid.NewString(), models.StatusCompleted, nil, nil, nil); err != nil {
+		t.Errorf("UpdateOrderStatus(unknown) = %v, want nil as in Postgres", err)
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryOrderRepository()
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var mine []*models.Order
+	for i := range 5 {
+		order := newTestOrder("client-a", "")
+		order.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		if i%2 == 1 {
+			order.Status = models.StatusCompleted
+		}
+		if err := repo.CreateOrder(ctx, order); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		mine = append(mine, order)
+	}
+	if err := repo.CreateOrder(ctx, newTestOrder("client-b", "")); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		q    models.OrderListQuery
+		want []*models.Order
+	}{
+		{"newest first", models.OrderListQuery{ClientID: "client-a"}, []*models.Order{mine[4], mine[3], mine[2], mine[1], mine[0]}},
+		{"by status", models.OrderListQuery{ClientID: "client-a", Status: models.StatusCompleted}, []*models.Order{mine[3], mine[1]}},
+		{"limit and offset", models.OrderListQuery{ClientID: "client-a", Limit: 2, Offset: 1}, []*models.Order{mine[3], mine[2]}},
+		{"after a cursor", models.OrderListQuery{ClientID: "client-a", Limit: 2, After: &models.OrderCursor{CreatedAt: mine[2].CreatedAt, ID: mine[2].ID}}, []*models.Order{mine[1], mine[0]}},
+		{"offset past the end", models.OrderListQuery{ClientID: "client-a", Offset: 5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.ListOrders(ctx, tt.q)
+			if err != nil {
+				t.Fatalf("ListOrders: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ListOrders returned %d orders, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i].ID != tt.want[i].ID {
+					t.Errorf("order %d = %s, want %s", i, got[i].ID, tt.want[i].ID)
+				}
+			}
+		})
+	}
+
+	if n, _ := repo.CountOrders(ctx, models.OrderListQuery{ClientID: "client-a", Limit: 1}); n != 5 {
+		t.Errorf("CountOrders = %d, want 5 regardless of paging", n)
+	}
+	pending, _ := repo.ListPendingOrders(ctx, base.Add(3*time.Minute), 10)
+	if len(pending) != 2 || pending[0].ID != mine[0].ID || pending[1].ID != mine[2].ID {
+		t.Errorf("ListPendingOrders = %d orders, want the two pending ones created before the cut-off, oldest first", len(pending))
+	}
+}
+
+func TestInMemoryRepositoryIsSafeForConcurrentUse(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryOrderRepository()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 25 {
+				order := newTestOrder("client-a", "")
+				if err := repo.CreateOrder(ctx, order); err != nil {
+					t.Errorf("CreateOrder: %v", err)
+					return
+				}
+				repo.UpdateOrderStatus(ctx, order.ID.String(), models.StatusCompleted, nil, nil, nil)
+				repo.GetOrderByID(ctx, order.ID.String())
+				repo.ListOrders(ctx, models.OrderListQuery{ClientID: "client-a", Limit: 10})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, _ := repo.CountOrders(ctx, models.OrderListQuery{Status: models.StatusCompleted}); n != 200 {
+		t.Errorf("CountOrders = %d, want all 200 orders completed", n)
+	}
+}
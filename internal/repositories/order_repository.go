@@ -2,14 +2,58 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/pkg/requestctx"
 	"go.uber.org/zap"
 	"time"
 )
 
+// ErrOrderNotFound is returned by single-order lookups when no row matches
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrDuplicateIStarOrderID is returned by CreateOrder when another order
+// already carries the same iStar order id
+var ErrDuplicateIStarOrderID = errors.New("iStar order id already stored")
+
 type OrderRepository interface {
 	CreateOrder(ctx context.Context, order *models.Order) error
 	UpdateOrderStatus(ctx context.Context, orderID string, status models.OrderStatus, txHash *string, completedAt *time.Time, errorMessage *string) error
+	SetIStarOrderID(ctx context.Context, orderID, istarOrderID string) error
+	MarkOrderRefunded(ctx context.Context, orderID string, refundedAt time.Time, refundID string, amount models.Amount) error
+	GetOrderByTxHash(ctx context.Context, txHash string) ([]*models.Order, error)
+	GetOrderByIdempotencyKey(ctx context.Context, clientID, key string, since time.Time) (*models.Order, error)
+	GetOrderByID(ctx context.Context, orderID string) (*models.Order, error)
+	GetOrderByIStarID(ctx context.Context, istarOrderID string) (*models.Order, error)
+	ListPendingOrders(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Order, error)
+	ListOrdersCreatedBetween(ctx context.Context, from, to time.Time, limit int) ([]*models.Order, error)
+	ListOrders(ctx context.Context, q models.OrderListQuery) ([]*models.Order, error)
+	CountOrders(ctx context.Context, q models.OrderListQuery) (int, error)
+	MedianCompletionLatency(ctx context.Context, walletType string, since time.Time) (time.Duration, error)
+	SumClientSpendSince(ctx context.Context, clientID string, since time.Time) (models.Amount, error)
+	RecordOrderEvent(ctx context.Context, event *models.OrderEvent) error
+	IsWebhookProcessed(ctx context.Context, eventID string) (bool, error)
+	MarkWebhookProcessed(ctx context.Context, eventID, orderID string) error
+	EnqueueWebhookReplay(ctx context.Context, replay *models.WebhookReplay) error
+	ListDueWebhookReplays(ctx context.Context, now time.Time, maxAttempts, limit int) ([]*models.WebhookReplay, error)
+	RescheduleWebhookReplay(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, lastError string) error
+	DeleteWebhookReplay(ctx context.Context, id string) error
+	WebhookReplayStats(ctx context.Context, maxAttempts int) (*models.WebhookQueueStats, error)
+	Ping(ctx context.Context) error
+
+	// TryLockOrder takes a lock on orderID shared by every replica, without
+	// waiting. ok is false when someone else holds it. Call it outside WithTx.
+	TryLockOrder(ctx context.Context, orderID string) (unlock func(), ok bool, err error)
+
+	// Audit entries share the order's transaction
+	AuditRepository
+
+	// Idempotency keys are linked to their order in the order's transaction
+	IdempotencyRepository
+
+	// WithTx runs fn against a repository bound to one transaction, committing
+	// when fn returns nil and rolling back otherwise
+	WithTx(ctx context.Context, fn func(tx OrderRepository) error) error
 }
 
 type orderRepository struct {
@@ -21,16 +65,43 @@ func NewOrderRepository( /*db *pgxpool.Pool,*/ logger *zap.Logger) OrderReposito
 	return &orderRepository{ /*db: db,*/ logger: logger.Named("order_repository")}
 }
 
+func (r *orderRepository) WithTx(ctx context.Context, fn func(tx OrderRepository) error) error {
+	//tx, err := r.db.Begin(ctx)
+	//if err != nil {
+	//	r.logger.Error("Failed to begin transaction", zap.Error(err))
+	//	return err
+	//}
+	//defer tx.Rollback(ctx) // no-op once committed
+	//
+	//if err := fn(&orderRepository{db: tx, logger: r.logger}); err != nil {
+	//	return err
+	//}
+	//if err := tx.Commit(ctx); err != nil {
+	//	r.logger.Error("Failed to commit transaction", zap.Error(err))
+	//	return err
+	//}
+	//return nil
+	return fn(r)
+}
+
 func (r *orderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
 	//query := `
-	//	INSERT INTO orders (id, type, status, username, recipient_hash, quantity, months, amount, wallet_type, created_at, updated_at)
-	//	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	//	INSERT INTO orders (id, type, status, username, recipient_hash, quantity, months, amount, wallet_type, created_at, updated_at,
+	//	                    tx_hash, completed_at, error_message, estimated_completion_at,
+	//	                    client_id, idempotency_key, request_hash, istar_order_id, metadata)
+	//	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NULLIF($17, ''), $18, $19, $20)
 	//`
 	//_, err := r.db.Exec(ctx, query,
 	//	order.ID, order.Type, order.Status, order.Username, order.RecipientHash,
 	//	order.Quantity, order.Months, order.Amount, order.WalletType,
 	//	order.CreatedAt, order.UpdatedAt,
+	//	order.TxHash, order.CompletedAt, order.ErrorMessage, order.EstimatedCompletionAt,
+	//	order.ClientID, order.IdempotencyKey, order.RequestHash, order.IStarOrderID, order.Metadata,
 	//)
+	//var pgErr *pgconn.PgError
+	//if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_orders_istar_order_id" {
+	//	return ErrDuplicateIStarOrderID
+	//}
 	//if err != nil {
 	//	r.logger.Error("Failed to create order", zap.Error(err), zap.String("order_id", order.ID))
 	//	return err
@@ -39,6 +110,10 @@ func (r *orderRepository) CreateOrder(ctx context.Context, order *models.Order)
 }
 
 func (r *orderRepository) UpdateOrderStatus(ctx context.Context, orderID string, status models.OrderStatus, txHash *string, completedAt *time.Time, errorMessage *string) error {
+	r.logger.Debug("Updating order status",
+		zap.String("order_id", orderID),
+		zap.String("status", string(status)),
+		zap.String("correlation_id", requestctx.CorrelationID(ctx)))
 	//query := `
 	//	UPDATE orders
 	//	SET status = $1, tx_hash = $2, completed_at = $3, error_message = $4, updated_at = $5
@@ -46,8 +121,485 @@ func (r *orderRepository) UpdateOrderStatus(ctx context.Context, orderID string,
 	//`
 	//_, err := r.db.Exec(ctx, query, status, txHash, completedAt, errorMessage, time.Now(), orderID)
 	//if err != nil {
-	//	r.logger.Error("Failed to update order status", zap.Error(err), zap.String("order_id", orderID))
+	//	r.logger.Error("Failed to update order status", zap.Error(err), zap.String("order_id", orderID),
+	//		zap.String("correlation_id", requestctx.CorrelationID(ctx)))
+	//	return err
+	//}
+	return nil
+}
+
+// SetIStarOrderID replaces the iStar id of an order recorded before iStar
+// confirmed it
+func (r *orderRepository) SetIStarOrderID(ctx context.Context, orderID, istarOrderID string) error {
+	//query := `UPDATE orders SET istar_order_id = $1, updated_at = $2 WHERE id = $3`
+	//_, err := r.db.Exec(ctx, query, istarOrderID, time.Now(), orderID)
+	//if err != nil {
+	//	r.logger.Error("Failed to set iStar order id", zap.Error(err), zap.String("order_id", orderID))
+	//	return err
+	//}
+	return nil
+}
+
+// MarkOrderRefunded moves a completed order to refunded and stores the refund details.
+// The status guard keeps a concurrent transition from being overwritten.
+func (r *orderRepository) MarkOrderRefunded(ctx context.Context, orderID string, refundedAt time.Time, refundID string, amount models.Amount) error {
+	//query := `
+	//	UPDATE orders
+	//	SET status = 'refunded', refunded_at = $1, refund_id = $2, refund_amount = $3, updated_at = $1
+	//	WHERE id = $4 AND status = 'completed'
+	//`
+	//tag, err := r.db.Exec(ctx, query, refundedAt, refundID, amount, orderID)
+	//if err != nil {
+	//	r.logger.Error("Failed to mark order refunded", zap.Error(err), zap.String("order_id", orderID))
+	//	return err
+	//}
+	//if tag.RowsAffected() == 0 {
+	//	return ErrOrderNotFound
+	//}
+	return nil
+}
+
+// GetOrderByTxHash returns every order settled by the given transaction hash.
+// A single on-chain transaction may batch several orders, so the result is a slice.
+func (r *orderRepository) GetOrderByTxHash(ctx context.Context, txHash string) ([]*models.Order, error) {
+	//query := `
+	//	SELECT id, istar_order_id, type, status, username, recipient_hash, quantity, months, amount, wallet_type,
+	//	       tx_hash, created_at, updated_at, completed_at, error_message
+	//	FROM orders
+	//	WHERE tx_hash = $1
+	//	ORDER BY created_at
+	//`
+	//rows, err := r.db.Query(ctx, query, txHash)
+	//if err != nil {
+	//	r.logger.Error("Failed to query orders by tx hash", zap.Error(err), zap.String("tx_hash", txHash))
+	//	return nil, err
+	//}
+	//defer rows.Close()
+	//
+	//var orders []*models.Order
+	//for rows.Next() {
+	//	var order models.Order
+	//	if err := rows.Scan(&order.ID, &order.IStarOrderID, &order.Type, &order.Status, &order.Username, &order.RecipientHash,
+	//		&order.Quantity, &order.Months, &order.Amount, &order.WalletType, &order.TxHash,
+	//		&order.CreatedAt, &order.UpdatedAt, &order.CompletedAt, &order.ErrorMessage); err != nil {
+	//		return nil, err
+	//	}
+	//	orders = append(orders, &order)
+	//}
+	//return orders, rows.Err()
+	return nil, nil
+}
+
+// GetOrderByIdempotencyKey returns the order a client created with the given
+// Idempotency-Key at or after since, or ErrOrderNotFound.
+func (r *orderRepository) GetOrderByIdempotencyKey(ctx context.Context, clientID, key string, since time.Time) (*models.Order, error) {
+	//query := `
+	//	SELECT id, istar_order_id, type, status, username, recipient_hash, quantity, months, amount, wallet_type,
+	//	       tx_hash, created_at, updated_at, completed_at, error_message, metadata,
+	//	       client_id, idempotency_key, request_hash
+	//	FROM orders
+	//	WHERE client_id = $1 AND idempotency_key = $2 AND created_at >= $3
+	//	ORDER BY created_at DESC
+	//	LIMIT 1
+	//`
+	//var order models.Order
+	//err := r.db.QueryRow(ctx, query, clientID, key, since).Scan(
+	//	&order.ID, &order.IStarOrderID, &order.Type, &order.Status, &order.Username, &order.RecipientHash,
+	//	&order.Quantity, &order.Months, &order.Amount, &order.WalletType, &order.TxHash,
+	//	&order.CreatedAt, &order.UpdatedAt, &order.CompletedAt, &order.ErrorMessage, &order.Metadata,
+	//	&order.ClientID, &order.IdempotencyKey, &order.RequestHash,
+	//)
+	//if errors.Is(err, pgx.ErrNoRows) {
+	//	return nil, ErrOrderNotFound
+	//}
+	//if err != nil {
+	//	r.logger.Error("Failed to get order by idempotency key", zap.Error(err))
+	//	return nil, err
+	//}
+	//return &order, nil
+	return nil, ErrOrderNotFound
+}
+
+// GetOrderByID returns a single order or ErrOrderNotFound
+func (r *orderRepository) GetOrderByID(ctx context.Context, orderID string) (*models.Order, error) {
+	//query := `
+	//	SELECT id, istar_order_id, type, status, username, recipient_hash, quantity, months, amount, wallet_type,
+	//	       tx_hash, created_at, updated_at, completed_at, error_message, estimated_completion_at,
+	//	       refunded_at, refund_id, refund_amount, metadata,
+	//	       client_id, idempotency_key, request_hash
+	//	FROM orders
+	//	WHERE id = $1
+	//`
+	//var order models.Order
+	//err := r.db.QueryRow(ctx, query, orderID).Scan(
+	//	&order.ID, &order.IStarOrderID, &order.Type, &order.Status, &order.Username, &order.RecipientHash,
+	//	&order.Quantity, &order.Months, &order.Amount, &order.WalletType, &order.TxHash,
+	//	&order.CreatedAt, &order.UpdatedAt, &order.CompletedAt, &order.ErrorMessage, &order.EstimatedCompletionAt,
+	//	&order.RefundedAt, &order.RefundID, &order.RefundAmount, &order.Metadata,
+	//	&order.ClientID, &order.IdempotencyKey, &order.RequestHash,
+	//)
+	//if errors.Is(err, pgx.ErrNoRows) {
+	//	return nil, ErrOrderNotFound
+	//}
+	//if err != nil {
+	//	r.logger.Error("Failed to get order", zap.Error(err), zap.String("order_id", orderID))
+	//	return nil, err
+	//}
+	//return &order, nil
+	return nil, ErrOrderNotFound
+}
+
+// GetOrderByIStarID returns the order iStar knows as istarOrderID, or ErrOrderNotFound
+func (r *orderRepository) GetOrderByIStarID(ctx context.Context, istarOrderID string) (*models.Order, error) {
+	//query := `
+	//	SELECT id, istar_order_id, type, status, username, recipient_hash, quantity, months, amount, wallet_type,
+	//	       tx_hash, created_at, updated_at, completed_at, error_message, estimated_completion_at,
+	//	       refunded_at, refund_id, refund_amount, metadata,
+	//	       client_id, idempotency_key, request_hash
+	//	FROM orders
+	//	WHERE istar_order_id = $1
+	//`
+	//var order models.Order
+	//err := r.db.QueryRow(ctx, query, istarOrderID).Scan(
+	//	&order.ID, &order.IStarOrderID, &order.Type, &order.Status, &order.Username, &order.RecipientHash,
+	//	&order.Quantity, &order.Months, &order.Amount, &order.WalletType, &order.TxHash,
+	//	&order.CreatedAt, &order.UpdatedAt, &order.CompletedAt, &order.ErrorMessage, &order.EstimatedCompletionAt,
+	//	&order.RefundedAt, &order.RefundID, &order.RefundAmount, &order.Metadata,
+	//	&order.ClientID, &order.IdempotencyKey, &order.RequestHash,
+	//)
+	//if errors.Is(err, pgx.ErrNoRows) {
+	//	return nil, ErrOrderNotFound
+	//}
+	//if err != nil {
+	//	r.logger.Error("Failed to get order by iStar id", zap.Error(err), zap.String("istar_order_id", istarOrderID))
+	//	return nil, err
+	//}
+	//return &order, nil
+	return nil, ErrOrderNotFound
+}
+
+// ListPendingOrders returns up to limit pending orders created before createdBefore, oldest first
+func (r *orderRepository) ListPendingOrders(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Order, error) {
+	//query := `
+	//	SELECT id, istar_order_id, type, status, username, recipient_hash, quantity, months, amount, wallet_type,
+	//	       tx_hash, created_at, updated_at, completed_at, error_message
+	//	FROM orders
+	//	WHERE status = 'pending' AND created_at < $1
+	//	ORDER BY created_at
+	//	LIMIT $2
+	//`
+	//rows, err := r.db.Query(ctx, query, createdBefore, limit)
+	//if err != nil {
+	//	r.logger.Error("Failed to list pending orders", zap.Error(err))
+	//	return nil, err
+	//}
+	//defer rows.Close()
+	//
+	//var orders []*models.Order
+	//for rows.Next() {
+	//	var order models.Order
+	//	if err := rows.Scan(&order.ID, &order.IStarOrderID, &order.Type, &order.Status, &order.Username, &order.RecipientHash,
+	//		&order.Quantity, &order.Months, &order.Amount, &order.WalletType, &order.TxHash,
+	//		&order.CreatedAt, &order.UpdatedAt, &order.CompletedAt, &order.ErrorMessage); err != nil {
+	//		return nil, err
+	//	}
+	//	orders = append(orders, &order)
+	//}
+	//return orders, rows.Err()
+	return nil, nil
+}
+
+// ListOrders returns a page of orders, newest first. Ties on created_at are
+// broken by id so keyset pages neither skip nor repeat rows.
+func (r *orderRepository) ListOrders(ctx context.Context, q models.OrderListQuery) ([]*models.Order, error) {
+	//query := `
+	//	SELECT id, istar_order_id, type, status, username, recipient_hash, quantity, months, amount, wallet_type,
+	//	       tx_hash, created_at, updated_at, completed_at, error_message
+	//	FROM orders
+	//	WHERE ($1 = '' OR status = $1)
+	//	  AND ($2::timestamptz IS NULL OR (created_at, id) < ($2, $3))
+	//	  AND ($6 = '' OR client_id = $6)
+	//	ORDER BY created_at DESC, id DESC
+	//	LIMIT $4 OFFSET $5
+	//`
+	//var afterCreatedAt *time.Time
+	//var afterID *uuid.UUID
+	//offset := q.Offset
+	//if q.After != nil {
+	//	afterCreatedAt, afterID, offset = &q.After.CreatedAt, &q.After.ID, 0
+	//}
+	//rows, err := r.db.Query(ctx, query, q.Status, afterCreatedAt, afterID, q.Limit, offset, q.ClientID)
+	//if err != nil {
+	//	r.logger.Error("Failed to list orders", zap.Error(err))
+	//	return nil, err
+	//}
+	//defer rows.Close()
+	//
+	//var orders []*models.Order
+	//for rows.Next() {
+	//	var order models.Order
+	//	if err := rows.Scan(&order.ID, &order.IStarOrderID, &order.Type, &order.Status, &order.Username, &order.RecipientHash,
+	//		&order.Quantity, &order.Months, &order.Amount, &order.WalletType, &order.TxHash,
+	//		&order.CreatedAt, &order.UpdatedAt, &order.CompletedAt, &order.ErrorMessage); err != nil {
+	//		return nil, err
+	//	}
+	//	orders = append(orders, &order)
+	//}
+	//return orders, rows.Err()
+	return nil, nil
+}
+
+// CountOrders counts the orders matching q's client and status filters,
+// ignoring its paging
+func (r *orderRepository) CountOrders(ctx context.Context, q models.OrderListQuery) (int, error) {
+	//query := `SELECT COUNT(*) FROM orders WHERE ($1 = '' OR status = $1) AND ($2 = '' OR client_id = $2)`
+	//var count int
+	//if err := r.db.QueryRow(ctx, query, q.Status, q.ClientID).Scan(&count); err != nil {
+	//	r.logger.Error("Failed to count orders", zap.Error(err))
+	//	return 0, err
+	//}
+	//return count, nil
+	return 0, nil
+}
+
+// ListOrdersCreatedBetween returns up to limit orders created in [from, to), oldest first
+func (r *orderRepository) ListOrdersCreatedBetween(ctx context.Context, from, to time.Time, limit int) ([]*models.Order, error) {
+	//query := `
+	//	SELECT id, istar_order_id, type, status, username, recipient_hash, quantity, months, amount, wallet_type,
+	//	       tx_hash, created_at, updated_at, completed_at, error_message
+	//	FROM orders
+	//	WHERE created_at >= $1 AND created_at < $2
+	//	ORDER BY created_at
+	//	LIMIT $3
+	//`
+	//rows, err := r.db.Query(ctx, query, from, to, limit)
+	//if err != nil {
+	//	r.logger.Error("Failed to list orders", zap.Error(err))
+	//	return nil, err
+	//}
+	//defer rows.Close()
+	//
+	//var orders []*models.Order
+	//for rows.Next() {
+	//	var order models.Order
+	//	if err := rows.Scan(&order.ID, &order.IStarOrderID, &order.Type, &order.Status, &order.Username, &order.RecipientHash,
+	//		&order.Quantity, &order.Months, &order.Amount, &order.WalletType, &order.TxHash,
+	//		&order.CreatedAt, &order.UpdatedAt, &order.CompletedAt, &order.ErrorMessage); err != nil {
+	//		return nil, err
+	//	}
+	//	orders = append(orders, &order)
+	//}
+	//return orders, rows.Err()
+	return nil, nil
+}
+
+// MedianCompletionLatency returns the median time from creation to completion
+// of orders paid with walletType that completed after since, or zero when
+// there are none
+func (r *orderRepository) MedianCompletionLatency(ctx context.Context, walletType string, since time.Time) (time.Duration, error) {
+	//query := `
+	//	SELECT percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM completed_at - created_at))
+	//	FROM orders
+	//	WHERE status = 'completed' AND wallet_type = $1 AND completed_at >= $2
+	//`
+	//var seconds *float64
+	//if err := r.db.QueryRow(ctx, query, walletType, since).Scan(&seconds); err != nil {
+	//	r.logger.Error("Failed to compute completion latency", zap.Error(err), zap.String("wallet_type", walletType))
+	//	return 0, err
+	//}
+	//if seconds == nil {
+	//	return 0, nil
+	//}
+	//return time.Duration(*seconds * float64(time.Second)), nil
+	return 0, nil
+}
+
+// SumClientSpendSince totals the amounts of clientID's pending and completed
+// orders created at or after since
+func (r *orderRepository) SumClientSpendSince(ctx context.Context, clientID string, since time.Time) (models.Amount, error) {
+	//query := `
+	//	SELECT COALESCE(SUM(amount), 0)
+	//	FROM orders
+	//	WHERE client_id = $1 AND created_at >= $2 AND status IN ('pending', 'completed')
+	//`
+	//var total models.Amount
+	//if err := r.db.QueryRow(ctx, query, clientID, since).Scan(&total); err != nil {
+	//	r.logger.Error("Failed to sum client spend", zap.Error(err))
+	//	return 0, err
+	//}
+	//return total, nil
+	return 0, nil
+}
+
+// RecordOrderEvent appends an entry to the order's state change history
+func (r *orderRepository) RecordOrderEvent(ctx context.Context, event *models.OrderEvent) error {
+	r.logger.Debug("Recording order event",
+		zap.String("order_id", event.OrderID),
+		zap.String("event_type", event.EventType),
+		zap.String("correlation_id", event.CorrelationID))
+	//query := `
+	//	INSERT INTO order_events (id, order_id, source, event_type, status, correlation_id, actor, reason, created_at)
+	//	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9)
+	//`
+	//_, err := r.db.Exec(ctx, query,
+	//	event.ID, event.OrderID, event.Source, event.EventType, event.Status, event.CorrelationID,
+	//	event.Actor, event.Reason, event.CreatedAt,
+	//)
+	//if err != nil {
+	//	r.logger.Error("Failed to record order event", zap.Error(err), zap.String("order_id", event.OrderID))
+	//	return err
+	//}
+	return nil
+}
+
+// IsWebhookProcessed reports whether the webhook event has already been applied
+func (r *orderRepository) IsWebhookProcessed(ctx context.Context, eventID string) (bool, error) {
+	//query := `SELECT EXISTS (SELECT 1 FROM processed_webhooks WHERE event_id = $1)`
+	//var processed bool
+	//if err := r.db.QueryRow(ctx, query, eventID).Scan(&processed); err != nil {
+	//	r.logger.Error("Failed to check processed webhook", zap.Error(err), zap.String("event_id", eventID))
+	//	return false, err
+	//}
+	//return processed, nil
+	return false, nil
+}
+
+// MarkWebhookProcessed remembers that the webhook event has been applied.
+// Marking an event twice is not an error.
+func (r *orderRepository) MarkWebhookProcessed(ctx context.Context, eventID, orderID string) error {
+	//query := `
+	//	INSERT INTO processed_webhooks (event_id, order_id, processed_at)
+	//	VALUES ($1, $2, now())
+	//	ON CONFLICT (event_id) DO NOTHING
+	//`
+	//if _, err := r.db.Exec(ctx, query, eventID, orderID); err != nil {
+	//	r.logger.Error("Failed to mark webhook processed", zap.Error(err), zap.String("event_id", eventID))
 	//	return err
 	//}
 	return nil
 }
+
+// EnqueueWebhookReplay stores a webhook to be applied again by the replay worker
+func (r *orderRepository) EnqueueWebhookReplay(ctx context.Context, replay *models.WebhookReplay) error {
+	//query := `
+	//	INSERT INTO webhook_replay_queue (id, payload, attempts, next_attempt_at, last_error, created_at)
+	//	VALUES ($1, $2, $3, $4, $5, $6)
+	//`
+	//_, err := r.db.Exec(ctx, query, replay.ID, replay.Payload, replay.Attempts, replay.NextAttemptAt, replay.LastError, replay.CreatedAt)
+	//if err != nil {
+	//	r.logger.Error("Failed to enqueue webhook replay", zap.Error(err))
+	//	return err
+	//}
+	return nil
+}
+
+// ListDueWebhookReplays returns up to limit queued webhooks due by now that
+// have attempts left, oldest due first
+func (r *orderRepository) ListDueWebhookReplays(ctx context.Context, now time.Time, maxAttempts, limit int) ([]*models.WebhookReplay, error) {
+	//query := `
+	//	SELECT id, payload, attempts, next_attempt_at, last_error, created_at
+	//	FROM webhook_replay_queue
+	//	WHERE next_attempt_at <= $1 AND attempts < $2
+	//	ORDER BY next_attempt_at
+	//	LIMIT $3
+	//`
+	//rows, err := r.db.Query(ctx, query, now, maxAttempts, limit)
+	//if err != nil {
+	//	r.logger.Error("Failed to list webhook replays", zap.Error(err))
+	//	return nil, err
+	//}
+	//defer rows.Close()
+	//
+	//var replays []*models.WebhookReplay
+	//for rows.Next() {
+	//	var replay models.WebhookReplay
+	//	if err := rows.Scan(&replay.ID, &replay.Payload, &replay.Attempts, &replay.NextAttemptAt,
+	//		&replay.LastError, &replay.CreatedAt); err != nil {
+	//		return nil, err
+	//	}
+	//	replays = append(replays, &replay)
+	//}
+	//return replays, rows.Err()
+	return nil, nil
+}
+
+// RescheduleWebhookReplay records a failed replay attempt
+func (r *orderRepository) RescheduleWebhookReplay(ctx context.Context, id string, attempts int, nextAttemptAt time.Time, lastError string) error {
+	//query := `
+	//	UPDATE webhook_replay_queue
+	//	SET attempts = $1, next_attempt_at = $2, last_error = $3
+	//	WHERE id = $4
+	//`
+	//_, err := r.db.Exec(ctx, query, attempts, nextAttemptAt, lastError, id)
+	//if err != nil {
+	//	r.logger.Error("Failed to reschedule webhook replay", zap.Error(err), zap.String("replay_id", id))
+	//	return err
+	//}
+	return nil
+}
+
+// DeleteWebhookReplay removes a webhook that has been applied
+func (r *orderRepository) DeleteWebhookReplay(ctx context.Context, id string) error {
+	//_, err := r.db.Exec(ctx, `DELETE FROM webhook_replay_queue WHERE id = $1`, id)
+	//if err != nil {
+	//	r.logger.Error("Failed to delete webhook replay", zap.Error(err), zap.String("replay_id", id))
+	//	return err
+	//}
+	return nil
+}
+
+// WebhookReplayStats counts queued webhooks with attempts left and those exhausted
+func (r *orderRepository) WebhookReplayStats(ctx context.Context, maxAttempts int) (*models.WebhookQueueStats, error) {
+	//query := `
+	//	SELECT count(*) FILTER (WHERE attempts < $1), count(*) FILTER (WHERE attempts >= $1)
+	//	FROM webhook_replay_queue
+	//`
+	//var stats models.WebhookQueueStats
+	//if err := r.db.QueryRow(ctx, query, maxAttempts).Scan(&stats.Pending, &stats.Exhausted); err != nil {
+	//	r.logger.Error("Failed to count webhook replays", zap.Error(err))
+	//	return nil, err
+	//}
+	//return &stats, nil
+	return &models.WebhookQueueStats{}, nil
+}
+
+// TryLockOrder takes a session-level Postgres advisory lock keyed by the order
+// id. The lock is held on a connection pinned for the caller until unlock, so
+// no transaction stays open across the upstream call it protects.
+func (r *orderRepository) TryLockOrder(ctx context.Context, orderID string) (func(), bool, error) {
+	//conn, err := r.db.Acquire(ctx)
+	//if err != nil {
+	//	r.logger.Error("Failed to acquire connection for order lock", zap.Error(err), zap.String("order_id", orderID))
+	//	return nil, false, err
+	//}
+	//
+	//var locked bool
+	//query := `SELECT pg_try_advisory_lock(hashtextextended($1, 0))`
+	//if err := conn.QueryRow(ctx, query, orderID).Scan(&locked); err != nil {
+	//	conn.Release()
+	//	r.logger.Error("Failed to take order lock", zap.Error(err), zap.String("order_id", orderID))
+	//	return nil, false, err
+	//}
+	//if !locked {
+	//	conn.Release()
+	//	return nil, false, nil
+	//}
+	//
+	//return func() {
+	//	// The caller's context may already be cancelled; unlocking must still happen
+	//	if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, orderID); err != nil {
+	//		r.logger.Error("Failed to release order lock", zap.Error(err), zap.String("order_id", orderID))
+	//		// Ending the session is the only other way to drop its locks
+	//		conn.Hijack().Close(context.Background())
+	//		return
+	//	}
+	//	conn.Release()
+	//}, true, nil
+	return func() {}, true, nil
+}
+
+// Ping checks that the database is reachable
+func (r *orderRepository) Ping(ctx context.Context) error {
+	//return r.db.Ping(ctx)
+	return nil
+}
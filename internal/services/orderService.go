@@ -2,14 +2,55 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
-	"github.com/hulupay/
+	"github.com/hulupay/istar-api/config"
 	"github.com/hulupay/istar-api/internal/client"
+	"github.com/hulupay/istar-api/internal/metrics"
 	"github.com/hulupay/istar-api/internal/models"
 	"github.com/hulupay/istar-api/internal/repositories"
-	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/pkg/cache"
+	"github.com/hulupay/istar-api/pkg/requestctx"
 
 	"go.uber.org/zap"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idempotencyKeyTTL is how long an Idempotency-Key is honoured for a client
+const idempotencyKeyTTL = 24 * time.Hour
+
+const (
+	// completionHistoryWindow is how far back completed orders inform ETAs
+	completionHistoryWindow = 7 * 24 * time.Hour
+	// completionEstimateTTL is how long a per-wallet median latency is reused
+	completionEstimateTTL = 5 * time.Minute
+	// txVerifiedTTL is how long a confirmed transaction is remembered
+	txVerifiedTTL = 10 * time.Minute
+)
+
+// batchOrderWorkers bounds how many items of a batch order are sent to iStar at once
+const batchOrderWorkers = 5
+
+const (
+	// minStarQuantity and maxStarQuantity bound the stars in one star order
+	minStarQuantity = 50
+	maxStarQuantity = 1000000
+)
+
+const (
+	// reconcilePendingLimit bounds how many pending orders one bulk reconcile picks up
+	reconcilePendingLimit = 1000
+	// reconcilePendingWorkers bounds concurrent iStar lookups during a bulk reconcile
+	reconcilePendingWorkers = 8
 )
 
 // OrderService defines the interface for order-related business logic
@@ -18,29 +59,105 @@ type OrderService interface {
 	CreateStarOrderSync(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error)
 	CreatePremiumOrderAsync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.Order, error)
 	CreatePremiumOrderSync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.Order, error)
+	GetOrder(ctx context.Context, orderID string) (*models.Order, error)
+	GetOrderByIStarID(ctx context.Context, istarOrderID string) (*models.Order, error)
+	GetOrderAudit(ctx context.Context, orderID string) (*models.AuditLogResponse, error)
+	ListOrders(ctx context.Context, q models.OrderListQuery) (*models.OrderListResponse, error)
+	GetOrdersByTxHash(ctx context.Context, txHash string) ([]*models.Order, error)
+	ResyncOrder(ctx context.Context, orderID string) (*models.Order, error)
+	PollOrderStatus(ctx context.Context, orderID string) (*models.Order, error)
+	ReconcilePending(ctx context.Context, olderThan time.Duration) (reconciled, failed int, err error)
+	ForceFailOrder(ctx context.Context, orderID, reason, actor string) (*models.Order, error)
+	AdminUpdateOrder(ctx context.Context, orderID string, req models.AdminUpdateOrderRequest, actor string) (*models.Order, error)
+	GetRefundEligibility(ctx context.Context, orderID string) (*models.RefundEligibilityResponse, error)
+	CancelOrder(ctx context.Context, orderID string) (*models.Order, error)
+	QuoteStarOrder(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error)
+	QuotePremiumOrder(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.OrderQuoteResponse, error)
+	RefundOrder(ctx context.Context, orderID string) (*models.Order, error)
+	CreateStarOrdersBatch(ctx context.Context, req models.BatchStarOrderRequest) (*models.BatchOrderResponse, error)
 }
 
 // orderService implements the OrderService interface
 type orderService struct {
-	repo        repositories.OrderRepository
-	istarClient *client.IStarClient
-	logger      *zap.Logger
+	repo              repositories.OrderRepository
+	istarClient       client.IStarAPI
+	cfg               config.OrderConfig
+	refundEligibility *cache.TTLCache[string, *models.RefundEligibilityResponse]
+	// completionLatency caches the median completion latency per wallet type
+	completionLatency *cache.TTLCache[string, time.Duration]
+	// quotes holds issued quotes by quote ID until they expire
+	quotes *cache.TTLCache[string, lockedQuote]
+	// txVerified remembers transaction hashes an explorer has confirmed
+	txVerified *cache.TTLCache[string, bool]
+	// polling holds the IDs of orders currently being polled, so the poller,
+	// resync and bulk reconcile never apply the same order concurrently
+	polling sync.Map
+	// background is the service's lifetime, for work it schedules itself
+	background context.Context
+	// workers tracks that work, so shutdown can wait for it
+	workers *sync.WaitGroup
+	logger  *zap.Logger
+}
+
+// lockedQuote is an issued quote an order may reference to lock its price
+type lockedQuote struct {
+	orderType models.OrderType
+	quote     *models.OrderQuoteResponse
+	expiresAt time.Time
 }
 
-// NewOrderService initializes a new OrderService with dependencies
-func NewOrderService(repo repositories.OrderRepository, istarClient *client.IStarClient, logger *zap.Logger) OrderService {
+// NewOrderService initializes a new OrderService with dependencies. Background
+// work such as cache cleanup stops when ctx is cancelled; lookups the service
+// schedules itself are added to workers.
+func NewOrderService(ctx context.Context, repo repositories.OrderRepository, istarClient client.IStarAPI, cfg config.OrderConfig, workers *sync.WaitGroup, logger *zap.Logger) OrderService {
 	return &orderService{
-		repo:        repo,
-		istarClient: istarClient,
-		logger:      logger.Named("order_service"),
+		repo:              repo,
+		istarClient:       istarClient,
+		cfg:               cfg,
+		refundEligibility: cache.NewTTL[string, *models.RefundEligibilityResponse](ctx, cfg.RefundEligibilityTTL, time.Minute),
+		completionLatency: cache.NewTTL[string, time.Duration](ctx, completionEstimateTTL, completionEstimateTTL),
+		quotes:            cache.NewTTL[string, lockedQuote](ctx, cfg.QuoteTTL, time.Minute),
+		txVerified:        cache.NewTTL[string, bool](ctx, txVerifiedTTL, time.Minute),
+		background:        ctx,
+		workers:           workers,
+		logger:            logger.Named("order_service"),
 	}
 }
 
 // CreateStarOrderAsync creates an asynchronous star gift order
-func (s *orderService) CreateStarOrderAsync(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error) {
+func (s *orderService) CreateStarOrderAsync(ctx context.Context, req models.CreateStarOrderRequest) (_ *models.Order, err error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreateStarOrderAsync")
+	defer func() { endSpan(span, err) }()
+
+	requestHash, existing, err := s.checkIdempotency(ctx, req.IdempotencyKey, req)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+	release, err := s.reserveIdempotencyKey(ctx, req.IdempotencyKey, requestHash)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := s.resolveStarRecipient(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.checkPrice(ctx, models.OrderTypeStar, req.QuoteID, req.WalletType, func() (*models.OrderQuoteResponse, error) {
+		return s.istarClient.QuoteStarOrder(ctx, req)
+	}); err != nil {
+		return nil, err
+	}
+
+	orderID := uuid.New()
+	req.ClientOrderID = orderID.String()
 	resp, err := s.istarClient.CreateStarOrderAsync(ctx, req)
 	if err != nil {
 		s.logger.Error("Failed to create star order via iStar API", zap.Error(err))
+		metrics.OrdersCreatedTotal.WithLabelValues(string(models.OrderTypeStar), "error").Inc()
 		return nil, err
 	}
 
@@ -50,14 +167,14 @@ func (s *orderService) CreateStarOrderAsync(ctx context.Context, req models.Crea
 		return nil, models.InternalServerError("Invalid created_at timestamp")
 	}
 
-	orderID, err := uuid.Parse(resp.OrderID)
-	if err != nil {
-		s.logger.Error("Invalid order_id from iStar", zap.Error(err))
+	if resp.OrderID == "" {
+		s.logger.Error("Missing order_id from iStar")
 		return nil, models.InternalServerError("Invalid order_id")
 	}
 
 	order := &models.Order{
 		ID:            orderID,
+		IStarOrderID:  resp.OrderID,
 		Type:          models.OrderTypeStar,
 		Status:        models.StatusPending,
 		Username:      req.Username,
@@ -67,22 +184,86 @@ func (s *orderService) CreateStarOrderAsync(ctx context.Context, req models.Crea
 		WalletType:    req.WalletType,
 		CreatedAt:     createdAt,
 		UpdatedAt:     createdAt,
+
+		EstimatedCompletionAt: s.estimateCompletion(ctx, req.WalletType, createdAt, resp.EstimatedCompletionAt),
+
+		Metadata: req.Metadata,
+
+		ClientID:       requestctx.ClientID(ctx),
+		IdempotencyKey: req.IdempotencyKey,
+		RequestHash:    requestHash,
 	}
 
-	if err := s.repo.CreateOrder(ctx, order); err != nil {
-		s.logger.Error("Failed to save order to database", zap.Error(err))
-		return nil, models.InternalServerError("Failed to save order")
+	order, err = s.saveNewOrder(ctx, order, nil)
+	if err != nil {
+		return nil, err
 	}
+	if order.Replayed {
+		return order, nil
+	}
+	metrics.OrdersCreatedTotal.WithLabelValues(string(order.Type), string(order.Status)).Inc()
 
-	s.logger.Info("Star order created (async)", zap.String("order_id", order.ID.String()))
+	s.logger.Info("Star order created (async)", zap.String("order_id", order.ID.String()), zap.String("istar_order_id", order.IStarOrderID))
 	return order, nil
 }
 
 // CreateStarOrderSync creates a synchronous star gift order
-func (s *orderService) CreateStarOrderSync(ctx context.Context, req models.CreateStarOrderRequest) (*models.Order, error) {
+func (s *orderService) CreateStarOrderSync(ctx context.Context, req models.CreateStarOrderRequest) (_ *models.Order, err error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreateStarOrderSync")
+	defer func() { endSpan(span, err) }()
+
+	requestHash, existing, err := s.checkIdempotency(ctx, req.IdempotencyKey, req)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+	release, err := s.reserveIdempotencyKey(ctx, req.IdempotencyKey, requestHash)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := s.resolveStarRecipient(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	quoted, err := s.checkPrice(ctx, models.OrderTypeStar, req.QuoteID, req.WalletType, func() (*models.OrderQuoteResponse, error) {
+		return s.istarClient.QuoteStarOrder(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	orderID := uuid.New()
+	req.ClientOrderID = orderID.String()
 	resp, err := s.istarClient.CreateStarOrderSync(ctx, req)
+	if syncOutcomeUnknown(err) {
+		now := time.Now()
+		return s.recordUnconfirmedOrder(ctx, err, &models.Order{
+			ID:            orderID,
+			IStarOrderID:  orderID.String(),
+			Type:          models.OrderTypeStar,
+			Status:        models.StatusPending,
+			Username:      req.Username,
+			RecipientHash: req.RecipientHash,
+			Quantity:      &req.Quantity,
+			Amount:        quotedAmount(quoted),
+			WalletType:    req.WalletType,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+
+			Metadata: req.Metadata,
+
+			ClientID:       requestctx.ClientID(ctx),
+			IdempotencyKey: req.IdempotencyKey,
+			RequestHash:    requestHash,
+		})
+	}
 	if err != nil {
 		s.logger.Error("Failed to create star order via iStar API", zap.Error(err))
+		metrics.OrdersCreatedTotal.WithLabelValues(string(models.OrderTypeStar), "error").Inc()
 		return nil, err
 	}
 
@@ -102,20 +283,16 @@ func (s *orderService) CreateStarOrderSync(ctx context.Context, req models.Creat
 		completedAt = &t
 	}
 
-	status := models.OrderStatus(resp.Status)
-	if status != models.StatusCompleted && status != models.StatusFailed {
-		s.logger.Warn("Unexpected status from iStar", zap.String("status", resp.Status))
-		status = models.StatusFailed
-	}
+	status, errorMessage := s.syncOutcome(resp.Status, resp.Error)
 
-	orderID, err := uuid.Parse(resp.OrderID)
-	if err != nil {
-		s.logger.Error("Invalid order_id from iStar", zap.Error(err))
+	if resp.OrderID == "" {
+		s.logger.Error("Missing order_id from iStar")
 		return nil, models.InternalServerError("Invalid order_id")
 	}
 
 	order := &models.Order{
 		ID:            orderID,
+		IStarOrderID:  resp.OrderID,
 		Type:          models.OrderTypeStar,
 		Status:        status,
 		Username:      req.Username,
@@ -127,22 +304,62 @@ func (s *orderService) CreateStarOrderSync(ctx context.Context, req models.Creat
 		CreatedAt:     createdAt,
 		UpdatedAt:     time.Now(),
 		CompletedAt:   completedAt,
+		ErrorMessage:  errorMessage,
+
+		Metadata: req.Metadata,
+
+		ClientID:       requestctx.ClientID(ctx),
+		IdempotencyKey: req.IdempotencyKey,
+		RequestHash:    requestHash,
 	}
 
-	if err := s.repo.CreateOrder(ctx, order); err != nil {
-		s.logger.Error("Failed to save order to database", zap.Error(err))
-		return nil, models.InternalServerError("Failed to save order")
+	order, err = s.saveNewOrder(ctx, order, nil)
+	if err != nil {
+		return nil, err
 	}
+	if order.Replayed {
+		return order, nil
+	}
+	metrics.OrdersCreatedTotal.WithLabelValues(string(order.Type), string(order.Status)).Inc()
 
-	s.logger.Info("Star order created (sync)", zap.String("order_id", order.ID.String()))
+	s.logger.Info("Star order created (sync)", zap.String("order_id", order.ID.String()), zap.String("istar_order_id", order.IStarOrderID))
 	return order, nil
 }
 
 // CreatePremiumOrderAsync creates an asynchronous premium gift order
-func (s *orderService) CreatePremiumOrderAsync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.Order, error) {
+func (s *orderService) CreatePremiumOrderAsync(ctx context.Context, req models.CreatePremiumOrderRequest) (_ *models.Order, err error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreatePremiumOrderAsync")
+	defer func() { endSpan(span, err) }()
+
+	requestHash, existing, err := s.checkIdempotency(ctx, req.IdempotencyKey, req)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+	release, err := s.reserveIdempotencyKey(ctx, req.IdempotencyKey, requestHash)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := s.resolvePremiumRecipient(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.checkPrice(ctx, models.OrderTypePremium, req.QuoteID, req.WalletType, func() (*models.OrderQuoteResponse, error) {
+		return s.istarClient.QuotePremiumOrder(ctx, req)
+	}); err != nil {
+		return nil, err
+	}
+
+	orderID := uuid.New()
+	req.ClientOrderID = orderID.String()
 	resp, err := s.istarClient.CreatePremiumOrderAsync(ctx, req)
 	if err != nil {
 		s.logger.Error("Failed to create premium order via iStar API", zap.Error(err))
+		metrics.OrdersCreatedTotal.WithLabelValues(string(models.OrderTypePremium), "error").Inc()
 		return nil, err
 	}
 
@@ -152,14 +369,14 @@ func (s *orderService) CreatePremiumOrderAsync(ctx context.Context, req models.C
 		return nil, models.InternalServerError("Invalid created_at timestamp")
 	}
 
-	orderID, err := uuid.Parse(resp.OrderID)
-	if err != nil {
-		s.logger.Error("Invalid order_id from iStar", zap.Error(err))
+	if resp.OrderID == "" {
+		s.logger.Error("Missing order_id from iStar")
 		return nil, models.InternalServerError("Invalid order_id")
 	}
 
 	order := &models.Order{
 		ID:            orderID,
+		IStarOrderID:  resp.OrderID,
 		Type:          models.OrderTypePremium,
 		Status:        models.StatusPending,
 		Username:      req.Username,
@@ -169,22 +386,86 @@ func (s *orderService) CreatePremiumOrderAsync(ctx context.Context, req models.C
 		WalletType:    req.WalletType,
 		CreatedAt:     createdAt,
 		UpdatedAt:     createdAt,
+
+		EstimatedCompletionAt: s.estimateCompletion(ctx, req.WalletType, createdAt, resp.EstimatedCompletionAt),
+
+		Metadata: req.Metadata,
+
+		ClientID:       requestctx.ClientID(ctx),
+		IdempotencyKey: req.IdempotencyKey,
+		RequestHash:    requestHash,
 	}
 
-	if err := s.repo.CreateOrder(ctx, order); err != nil {
-		s.logger.Error("Failed to save order to database", zap.Error(err))
-		return nil, models.InternalServerError("Failed to save order")
+	order, err = s.saveNewOrder(ctx, order, nil)
+	if err != nil {
+		return nil, err
 	}
+	if order.Replayed {
+		return order, nil
+	}
+	metrics.OrdersCreatedTotal.WithLabelValues(string(order.Type), string(order.Status)).Inc()
 
-	s.logger.Info("Premium order created (async)", zap.String("order_id", order.ID.String()))
+	s.logger.Info("Premium order created (async)", zap.String("order_id", order.ID.String()), zap.String("istar_order_id", order.IStarOrderID))
 	return order, nil
 }
 
 // CreatePremiumOrderSync creates a synchronous premium gift order
-func (s *orderService) CreatePremiumOrderSync(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.Order, error) {
+func (s *orderService) CreatePremiumOrderSync(ctx context.Context, req models.CreatePremiumOrderRequest) (_ *models.Order, err error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreatePremiumOrderSync")
+	defer func() { endSpan(span, err) }()
+
+	requestHash, existing, err := s.checkIdempotency(ctx, req.IdempotencyKey, req)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+	release, err := s.reserveIdempotencyKey(ctx, req.IdempotencyKey, requestHash)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := s.resolvePremiumRecipient(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	quoted, err := s.checkPrice(ctx, models.OrderTypePremium, req.QuoteID, req.WalletType, func() (*models.OrderQuoteResponse, error) {
+		return s.istarClient.QuotePremiumOrder(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	orderID := uuid.New()
+	req.ClientOrderID = orderID.String()
 	resp, err := s.istarClient.CreatePremiumOrderSync(ctx, req)
+	if syncOutcomeUnknown(err) {
+		now := time.Now()
+		return s.recordUnconfirmedOrder(ctx, err, &models.Order{
+			ID:            orderID,
+			IStarOrderID:  orderID.String(),
+			Type:          models.OrderTypePremium,
+			Status:        models.StatusPending,
+			Username:      req.Username,
+			RecipientHash: req.RecipientHash,
+			Months:        &req.Months,
+			Amount:        quotedAmount(quoted),
+			WalletType:    req.WalletType,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+
+			Metadata: req.Metadata,
+
+			ClientID:       requestctx.ClientID(ctx),
+			IdempotencyKey: req.IdempotencyKey,
+			RequestHash:    requestHash,
+		})
+	}
 	if err != nil {
 		s.logger.Error("Failed to create premium order via iStar API", zap.Error(err))
+		metrics.OrdersCreatedTotal.WithLabelValues(string(models.OrderTypePremium), "error").Inc()
 		return nil, err
 	}
 
@@ -204,20 +485,16 @@ func (s *orderService) CreatePremiumOrderSync(ctx context.Context, req models.Cr
 		completedAt = &t
 	}
 
-	status := models.OrderStatus(resp.Status)
-	if status != models.StatusCompleted && status != models.StatusFailed {
-		s.logger.Warn("Unexpected status from iStar", zap.String("status", resp.Status))
-		status = models.StatusFailed
-	}
+	status, errorMessage := s.syncOutcome(resp.Status, resp.Error)
 
-	orderID, err := uuid.Parse(resp.OrderID)
-	if err != nil {
-		s.logger.Error("Invalid order_id from iStar", zap.Error(err))
+	if resp.OrderID == "" {
+		s.logger.Error("Missing order_id from iStar")
 		return nil, models.InternalServerError("Invalid order_id")
 	}
 
 	order := &models.Order{
 		ID:            orderID,
+		IStarOrderID:  resp.OrderID,
 		Type:          models.OrderTypePremium,
 		Status:        status,
 		Username:      req.Username,
@@ -229,13 +506,1478 @@ func (s *orderService) CreatePremiumOrderSync(ctx context.Context, req models.Cr
 		CreatedAt:     createdAt,
 		UpdatedAt:     time.Now(),
 		CompletedAt:   completedAt,
+		ErrorMessage:  errorMessage,
+
+		Metadata: req.Metadata,
+
+		ClientID:       requestctx.ClientID(ctx),
+		IdempotencyKey: req.IdempotencyKey,
+		RequestHash:    requestHash,
+	}
+
+	order, err = s.saveNewOrder(ctx, order, nil)
+	if err != nil {
+		return nil, err
+	}
+	if order.Replayed {
+		return order, nil
+	}
+	metrics.OrdersCreatedTotal.WithLabelValues(string(order.Type), string(order.Status)).Inc()
+
+	s.logger.Info("Premium order created (sync)", zap.String("order_id", order.ID.String()), zap.String("istar_order_id", order.IStarOrderID))
+	return order, nil
+}
+
+// syncOutcomeUnknown reports whether a sync create failed in a way that leaves
+// open whether iStar placed the order: it ran out of time after the request
+// was sent
+func syncOutcomeUnknown(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, client.ErrRequestNotSent)
+}
+
+// quotedAmount is the price checkPrice relied on, or zero when no check needed
+// one. A timed-out create is recorded at that price rather than quoted first,
+// so a failing quote never holds up an order the price checks did not need.
+func quotedAmount(quoted *models.OrderQuoteResponse) models.Amount {
+	if quoted == nil {
+		return 0
+	}
+	return quoted.Amount
+}
+
+// recordUnconfirmedOrder stores order as pending after its sync create timed
+// out, with an event saying so, and schedules a lookup at iStar. iStar may well
+// have placed the order, so failing outright would invite the client to retry
+// and pay twice; the caller is told the order is still being processed
+// instead. Until iStar confirms it, the order is keyed by its own id, which was
+// sent as client_order_id.
+func (s *orderService) recordUnconfirmedOrder(ctx context.Context, cause error, order *models.Order) (*models.Order, error) {
+	// The request's own deadline may be what ran out
+	ctx = context.WithoutCancel(ctx)
+	event := &models.OrderEvent{
+		ID:            uuid.New(),
+		OrderID:       order.ID.String(),
+		Source:        models.EventSourceClient,
+		EventType:     "order.sync_timed_out",
+		Status:        order.Status,
+		CorrelationID: requestctx.CorrelationID(ctx),
+		Actor:         order.ClientID,
+		Reason:        "sync create timed out",
+		CreatedAt:     order.CreatedAt,
+	}
+	// The iStar id is still our own, so it cannot collide
+	if _, err := s.saveNewOrder(ctx, order, event); err != nil {
+		return nil, err
+	}
+	metrics.OrdersCreatedTotal.WithLabelValues(string(order.Type), string(order.Status)).Inc()
+
+	orderID := order.ID.String()
+	s.logger.Warn("Sync order timed out at iStar; recorded as pending",
+		zap.String("order_id", orderID),
+		zap.Error(cause),
+		zap.Duration("reconcile_after", s.cfg.SyncFallbackReconcileAfter))
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		timer := time.NewTimer(s.cfg.SyncFallbackReconcileAfter)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			s.reconcileUnconfirmedOrder(orderID)
+		case <-s.background.Done():
+			// The status poller picks the order up after a restart
+		}
+	}()
+	return order, nil
+}
+
+// reconcileUnconfirmedOrder looks up an order recorded by
+// recordUnconfirmedOrder. If iStar cannot answer yet, the order stays pending
+// for the status poller.
+func (s *orderService) reconcileUnconfirmedOrder(orderID string) {
+	if s.background.Err() != nil {
+		return
+	}
+	order, err := s.PollOrderStatus(s.background, orderID)
+	if err != nil {
+		s.logger.Warn("Failed to reconcile timed-out sync order", zap.String("order_id", orderID), zap.Error(err))
+		return
 	}
+	s.logger.Info("Reconciled timed-out sync order",
+		zap.String("order_id", orderID),
+		zap.String("istar_order_id", order.IStarOrderID),
+		zap.String("status", string(order.Status)))
+}
 
-	if err := s.repo.CreateOrder(ctx, order); err != nil {
+// saveNewOrder stores a freshly created order together with its audit entry
+// and, when given, an event explaining how it came about. It returns the order
+// to answer with. When iStar hands back an order id we already hold, nothing
+// is written and the stored order is returned, marked as replayed.
+func (s *orderService) saveNewOrder(ctx context.Context, order *models.Order, event *models.OrderEvent) (*models.Order, error) {
+	entry := newAuditEntry(ctx, order.ID.String(), models.AuditOrderCreated, "", order.Status, "")
+	err := s.repo.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		if err := tx.CreateOrder(ctx, order); err != nil {
+			return err
+		}
+		if order.IdempotencyKey != "" {
+			if err := tx.LinkIdempotencyKey(ctx, order.ClientID, order.IdempotencyKey, order.ID); err != nil {
+				return err
+			}
+		}
+		if event != nil {
+			if err := tx.RecordOrderEvent(ctx, event); err != nil {
+				return err
+			}
+		}
+		return tx.RecordAudit(ctx, entry)
+	})
+	if errors.Is(err, repositories.ErrDuplicateIStarOrderID) {
+		return s.existingIStarOrder(ctx, order)
+	}
+	if err != nil {
 		s.logger.Error("Failed to save order to database", zap.Error(err))
 		return nil, models.InternalServerError("Failed to save order")
 	}
+	return order, nil
+}
+
+// existingIStarOrder returns the stored order sharing order's iStar id. An
+// order that belongs to another client is never handed out; that points at a
+// fault upstream and is reported as a conflict.
+func (s *orderService) existingIStarOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
+	existing, err := s.repo.GetOrderByIStarID(ctx, order.IStarOrderID)
+	if err != nil {
+		s.logger.Error("Failed to load order for duplicate iStar order id", zap.Error(err),
+			zap.String("istar_order_id", order.IStarOrderID))
+		return nil, models.InternalServerError("Failed to save order")
+	}
+	if existing.ClientID != order.ClientID || existing.Type != order.Type {
+		s.logger.Error("iStar returned an order id already used by another order",
+			zap.String("istar_order_id", order.IStarOrderID),
+			zap.String("existing_order_id", existing.ID.String()))
+		return nil, models.ConflictError("iStar returned an order that is already recorded for another request")
+	}
+
+	s.logger.Info("Returning stored order for repeated iStar order id",
+		zap.String("order_id", existing.ID.String()),
+		zap.String("istar_order_id", existing.IStarOrderID))
+	existing.Replayed = true
+	return existing, nil
+}
+
+// checkIdempotency returns the order the calling client previously created with
+// the same Idempotency-Key, along with the fingerprint of the current request.
+// Reusing a key with a different request body is rejected as a conflict.
+func (s *orderService) checkIdempotency(ctx context.Context, key string, req interface{}) (string, *models.Order, error) {
+	if key == "" {
+		return "", nil, nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		s.logger.Error("Failed to fingerprint request", zap.Error(err))
+		return "", nil, models.InternalServerError("Failed to process idempotency key")
+	}
+	sum := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(sum[:])
+
+	existing, err := s.repo.GetOrderByIdempotencyKey(ctx, requestctx.ClientID(ctx), key, time.Now().Add(-idempotencyKeyTTL))
+	if errors.Is(err, repositories.ErrOrderNotFound) {
+		return requestHash, nil, nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to look up idempotency key", zap.Error(err))
+		return "", nil, models.InternalServerError("Failed to process idempotency key")
+	}
+
+	if existing.RequestHash != requestHash {
+		s.logger.Warn("Idempotency key reused with a different request", zap.String("order_id", existing.ID.String()))
+		return "", nil, models.ConflictError("Idempotency-Key has already been used with a different request")
+	}
+
+	s.logger.Info("Returning order for repeated idempotency key", zap.String("order_id", existing.ID.String()))
+	existing.Replayed = true
+	return requestHash, existing, nil
+}
+
+// reserveIdempotencyKey claims key for the calling client before iStar is
+// called, so a concurrent request with the same key is turned away rather than
+// placing a second order. The returned release frees the key again unless
+// saveNewOrder linked it to an order; call it once the create is over.
+func (s *orderService) reserveIdempotencyKey(ctx context.Context, key, requestHash string) (release func(), err error) {
+	if key == "" {
+		return func() {}, nil
+	}
+
+	clientID := requestctx.ClientID(ctx)
+	now := time.Now()
+	record := &models.IdempotencyRecord{ClientID: clientID, Key: key, RequestHash: requestHash, CreatedAt: now}
+	err = s.repo.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		return tx.ReserveIdempotencyKey(ctx, record, now.Add(-idempotencyKeyTTL))
+	})
+	if errors.Is(err, repositories.ErrIdempotencyKeyInUse) {
+		s.logger.Warn("Idempotency key is held by a request in progress")
+		return nil, models.ConflictError("A request with this Idempotency-Key is still being processed")
+	}
+	if err != nil {
+		s.logger.Error("Failed to reserve idempotency key", zap.Error(err))
+		return nil, models.InternalServerError("Failed to process idempotency key")
+	}
+
+	return func() {
+		// The request may already be cancelled; the key must still be freed
+		if err := s.repo.ReleaseIdempotencyKey(context.WithoutCancel(ctx), clientID, key); err != nil {
+			s.logger.Error("Failed to release idempotency key", zap.Error(err))
+		}
+	}, nil
+}
+
+// ListOrders returns a page of the calling client's orders, newest first. One
+// extra row is fetched to tell whether another page follows.
+func (s *orderService) ListOrders(ctx context.Context, q models.OrderListQuery) (*models.OrderListResponse, error) {
+	q.ClientID = requestctx.ClientID(ctx)
+	limit := q.Limit
+	q.Limit = limit + 1
+	orders, err := s.repo.ListOrders(ctx, q)
+	if err != nil {
+		s.logger.Error("Failed to list orders", zap.Error(err))
+		return nil, models.InternalServerError("Failed to list orders")
+	}
+	total, err := s.repo.CountOrders(ctx, q)
+	if err != nil {
+		s.logger.Error("Failed to count orders", zap.Error(err))
+		return nil, models.InternalServerError("Failed to list orders")
+	}
+
+	resp := &models.OrderListResponse{Orders: orders, Total: total, Limit: limit}
+	if q.After == nil {
+		resp.Offset = q.Offset
+	}
+	if len(orders) > limit {
+		resp.Orders = orders[:limit]
+		last := resp.Orders[limit-1]
+		resp.NextCursor = models.OrderCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	if resp.Orders == nil {
+		resp.Orders = []*models.Order{}
+	}
+	for _, order := range resp.Orders {
+		s.describeTx(ctx, order, false)
+	}
+	return resp, nil
+}
+
+// GetOrder returns one of the calling client's orders
+func (s *orderService) GetOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	order, err := s.clientOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	s.describeOrder(ctx, order)
+	return order, nil
+}
+
+// GetOrderByIStarID returns one of the calling client's orders by the id iStar
+// assigned to it
+func (s *orderService) GetOrderByIStarID(ctx context.Context, istarOrderID string) (*models.Order, error) {
+	order, err := s.repo.GetOrderByIStarID(ctx, istarOrderID)
+	if errors.Is(err, repositories.ErrOrderNotFound) || (err == nil && !ownedByCaller(ctx, order)) {
+		return nil, models.NotFoundError("Order not found")
+	}
+	if err != nil {
+		s.logger.Error("Failed to load order", zap.Error(err), zap.String("istar_order_id", istarOrderID))
+		return nil, models.InternalServerError("Failed to load order")
+	}
+	s.describeOrder(ctx, order)
+	return order, nil
+}
+
+// loadOrder returns a stored order whoever placed it, for operators and
+// background work
+func (s *orderService) loadOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	order, err := s.repo.GetOrderByID(ctx, orderID)
+	if errors.Is(err, repositories.ErrOrderNotFound) {
+		return nil, models.NotFoundError("Order not found")
+	}
+	if err != nil {
+		s.logger.Error("Failed to load order", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to load order")
+	}
+	return order, nil
+}
 
-	s.logger.Info("Premium order created (sync)", zap.String("order_id", order.ID.String()))
+// clientOrder returns a stored order on behalf of the calling client. Another
+// client's order is reported as not found, so order ids cannot be probed.
+func (s *orderService) clientOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	order, err := s.loadOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !ownedByCaller(ctx, order) {
+		s.logger.Warn("Order requested by another client", zap.String("order_id", orderID))
+		return nil, models.NotFoundError("Order not found")
+	}
 	return order, nil
 }
+
+// ownedByCaller reports whether order was placed by the client in ctx
+func ownedByCaller(ctx context.Context, order *models.Order) bool {
+	return order.ClientID == requestctx.ClientID(ctx)
+}
+
+// describeOrder fills in the completion estimate of a pending order and the
+// explorer link and verification of its transaction
+func (s *orderService) describeOrder(ctx context.Context, order *models.Order) {
+	if order.Status == models.StatusPending && order.EstimatedCompletionAt == nil {
+		order.EstimatedCompletionAt = s.estimateCompletion(ctx, order.WalletType, order.CreatedAt, nil)
+	}
+	s.describeTx(ctx, order, true)
+}
+
+// describeTx links an on-chain order's transaction on a block explorer and,
+// when verify is set and verification is enabled, records whether the explorer
+// knows it. A failed check leaves TxVerified unset rather than failing the read.
+func (s *orderService) describeTx(ctx context.Context, order *models.Order, verify bool) {
+	if order.TxHash == nil || *order.TxHash == "" || !order.WalletType.OnChain() {
+		return
+	}
+	txHash := *order.TxHash
+	order.TxExplorerURL = s.istarClient.TransactionURL(order.WalletType, txHash)
+	if !verify {
+		return
+	}
+
+	cacheKey := string(order.WalletType) + "|" + txHash
+	if _, ok := s.txVerified.Get(cacheKey); ok {
+		verified := true
+		order.TxVerified = &verified
+		return
+	}
+	verified, err := s.istarClient.VerifyTransaction(ctx, order.WalletType, txHash)
+	if errors.Is(err, client.ErrTxVerificationUnavailable) {
+		return
+	}
+	if err != nil {
+		s.logger.Warn("Failed to verify transaction", zap.Error(err), zap.String("order_id", order.ID.String()))
+		return
+	}
+	if verified {
+		s.txVerified.Set(cacheKey, true)
+	}
+	order.TxVerified = &verified
+}
+
+// GetOrderAudit returns the audit trail of one of the calling client's orders
+func (s *orderService) GetOrderAudit(ctx context.Context, orderID string) (*models.AuditLogResponse, error) {
+	if _, err := s.clientOrder(ctx, orderID); err != nil {
+		return nil, err
+	}
+
+	entries, err := s.repo.ListAuditEntries(ctx, orderID)
+	if err != nil {
+		s.logger.Error("Failed to load audit log", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to load audit log")
+	}
+	if entries == nil {
+		entries = []*models.AuditEntry{}
+	}
+	return &models.AuditLogResponse{OrderID: orderID, Entries: entries}, nil
+}
+
+// newAuditEntry describes a change to an order made on behalf of the caller in
+// ctx. The caller is identified by the hash of their API key, never the key.
+func newAuditEntry(ctx context.Context, orderID string, action models.AuditAction, from, to models.OrderStatus, actor string) *models.AuditEntry {
+	correlationID := requestctx.CorrelationID(ctx)
+	if correlationID == "" {
+		correlationID = requestctx.RequestID(ctx)
+	}
+	return &models.AuditEntry{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		Action:        action,
+		ClientID:      requestctx.ClientID(ctx),
+		Actor:         actor,
+		FromStatus:    from,
+		ToStatus:      to,
+		CorrelationID: correlationID,
+		CreatedAt:     time.Now(),
+	}
+}
+
+// estimateCompletion returns when an order created at createdAt should settle.
+// iStar's own estimate wins; otherwise the median completion latency of recent
+// orders with the same wallet type is used. Nil means no estimate is available.
+func (s *orderService) estimateCompletion(ctx context.Context, walletType models.WalletType, createdAt time.Time, upstream *string) *time.Time {
+	if upstream != nil {
+		if t, err := time.Parse(time.RFC3339, *upstream); err == nil {
+			return &t
+		}
+		s.logger.Warn("Ignoring malformed estimated_completion_at from iStar", zap.String("value", *upstream))
+	}
+
+	latency, ok := s.completionLatency.Get(string(walletType))
+	if !ok {
+		var err error
+		latency, err = s.repo.MedianCompletionLatency(ctx, string(walletType), time.Now().Add(-completionHistoryWindow))
+		if err != nil {
+			// An estimate is a nicety; never fail the request over it.
+			s.logger.Warn("Failed to estimate completion time", zap.Error(err), zap.String("wallet_type", string(walletType)))
+			return nil
+		}
+		s.completionLatency.Set(string(walletType), latency)
+	}
+	if latency <= 0 {
+		return nil
+	}
+
+	eta := createdAt.Add(latency)
+	return &eta
+}
+
+// GetOrdersByTxHash returns the calling client's orders settled by a
+// transaction hash
+func (s *orderService) GetOrdersByTxHash(ctx context.Context, txHash string) ([]*models.Order, error) {
+	orders, err := s.repo.GetOrderByTxHash(ctx, txHash)
+	if err != nil {
+		s.logger.Error("Failed to look up orders by tx hash", zap.Error(err), zap.String("tx_hash", txHash))
+		return nil, models.InternalServerError("Failed to look up orders")
+	}
+	orders = slices.DeleteFunc(orders, func(order *models.Order) bool {
+		return !ownedByCaller(ctx, order)
+	})
+
+	if len(orders) == 0 {
+		return nil, models.NotFoundError("No orders found for transaction hash")
+	}
+
+	for _, order := range orders {
+		s.describeTx(ctx, order, false)
+	}
+	return orders, nil
+}
+
+// ResyncOrder polls one of the calling client's orders; see PollOrderStatus
+func (s *orderService) ResyncOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	if _, err := s.clientOrder(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.PollOrderStatus(ctx, orderID)
+}
+
+// PollOrderStatus fetches the upstream state of a pending order and applies it
+// locally. Orders that are no longer pending are returned untouched. Only one
+// caller across all replicas polls a given order at a time; the others get a
+// conflict error.
+func (s *orderService) PollOrderStatus(ctx context.Context, orderID string) (*models.Order, error) {
+	if _, busy := s.polling.LoadOrStore(orderID, struct{}{}); busy {
+		return nil, models.ConflictError("Order is already being reconciled")
+	}
+	defer s.polling.Delete(orderID)
+
+	// Other replicas poll too; the order is loaded only once the lock is held
+	// so a replica that lost the race sees the status the winner applied
+	unlock, locked, err := s.repo.TryLockOrder(ctx, orderID)
+	if err != nil {
+		s.logger.Error("Failed to lock order", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to lock order")
+	}
+	if !locked {
+		s.logger.Debug("Order is being reconciled by another replica", zap.String("order_id", orderID))
+		return nil, models.ConflictError("Order is already being reconciled")
+	}
+	defer unlock()
+
+	order, err := s.repo.GetOrderByID(ctx, orderID)
+	if errors.Is(err, repositories.ErrOrderNotFound) {
+		return nil, models.NotFoundError("Order not found")
+	}
+	if err != nil {
+		s.logger.Error("Failed to load order", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to load order")
+	}
+
+	if order.Status != models.StatusPending {
+		s.logger.Debug("Order already settled, skipping poll", zap.String("order_id", orderID), zap.String("status", string(order.Status)))
+		return order, nil
+	}
+
+	resp, err := s.istarClient.GetOrder(ctx, order.UpstreamID())
+	if err != nil {
+		s.logger.Error("Failed to fetch order from iStar", zap.Error(err), zap.String("order_id", orderID))
+		var apiErr *models.APIError
+		if errors.As(err, &apiErr) && apiErr.Code == models.CodeNotFound {
+			if s.neverReachedIStar(order) {
+				return s.failUnconfirmedOrder(ctx, order)
+			}
+			return nil, models.NotFoundError("Order exists locally but is unknown to iStar")
+		}
+		return nil, err
+	}
+
+	// An order recorded after a timed-out sync create is keyed by our own id
+	// until iStar's is known
+	if resp.OrderID != "" && resp.OrderID != order.IStarOrderID {
+		if err := s.repo.SetIStarOrderID(ctx, orderID, resp.OrderID); err != nil {
+			s.logger.Error("Failed to store iStar order id", zap.Error(err), zap.String("order_id", orderID))
+			return nil, models.InternalServerError("Failed to update order")
+		}
+		order.IStarOrderID = resp.OrderID
+	}
+
+	status, ok := mapUpstreamStatus(resp.Status)
+	if !ok {
+		s.logger.Warn("Unexpected status from iStar", zap.String("order_id", orderID), zap.String("status", resp.Status))
+		return order, nil
+	}
+	if status == models.StatusPending {
+		return order, nil
+	}
+
+	var completedAt *time.Time
+	if resp.CompletedAt != nil {
+		t, err := time.Parse(time.RFC3339, *resp.CompletedAt)
+		if err != nil {
+			s.logger.Error("Failed to parse completed_at", zap.Error(err))
+			return nil, models.InternalServerError("Invalid completed_at timestamp")
+		}
+		completedAt = &t
+	}
+
+	entry := newAuditEntry(ctx, orderID, models.AuditOrderStatusChanged, order.Status, status, "")
+	if err := s.repo.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		if err := tx.UpdateOrderStatus(ctx, orderID, status, resp.TxHash, completedAt, resp.Error); err != nil {
+			return err
+		}
+		return tx.RecordAudit(ctx, entry)
+	}); err != nil {
+		s.logger.Error("Failed to update order status", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to update order")
+	}
+
+	order.Status = status
+	order.TxHash = resp.TxHash
+	order.CompletedAt = completedAt
+	order.UpdatedAt = time.Now()
+	if resp.Error != nil {
+		order.ErrorMessage = resp.Error
+	}
+
+	s.logger.Info("Order status reconciled", zap.String("order_id", orderID), zap.String("status", string(status)))
+	return order, nil
+}
+
+// neverReachedIStar reports whether order, which iStar has just answered 404
+// for, was recorded after a timed-out sync create that evidently never arrived.
+// Such an order is still keyed by its own id, sent as client_order_id, and is
+// looked up by it on the assumption that iStar's GET /orders/{id} resolves a
+// client_order_id as well as its own id. Past SyncFallbackFailAfter a 404 is
+// no longer put down to iStar not having caught up.
+func (s *orderService) neverReachedIStar(order *models.Order) bool {
+	return s.cfg.SyncFallbackFailAfter > 0 &&
+		order.IStarOrderID == order.ID.String() &&
+		time.Since(order.CreatedAt) > s.cfg.SyncFallbackFailAfter
+}
+
+// failUnconfirmedOrder moves an order that never reached iStar to failed, so it
+// stops being polled and no longer counts toward its client's daily spend. The
+// caller holds the order's lock.
+func (s *orderService) failUnconfirmedOrder(ctx context.Context, order *models.Order) (*models.Order, error) {
+	orderID := order.ID.String()
+	reason := "iStar has no record of the order; its sync create timed out and never arrived"
+	entry := newAuditEntry(ctx, orderID, models.AuditOrderStatusChanged, order.Status, models.StatusFailed, "")
+	if err := s.repo.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		if err := tx.UpdateOrderStatus(ctx, orderID, models.StatusFailed, nil, nil, &reason); err != nil {
+			return err
+		}
+		return tx.RecordAudit(ctx, entry)
+	}); err != nil {
+		s.logger.Error("Failed to update order status", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to update order")
+	}
+
+	order.Status = models.StatusFailed
+	order.ErrorMessage = &reason
+	order.UpdatedAt = entry.CreatedAt
+
+	s.logger.Warn("Timed-out sync order is unknown to iStar; marked failed",
+		zap.String("order_id", orderID),
+		zap.Duration("age", time.Since(order.CreatedAt)))
+	return order, nil
+}
+
+// ReconcilePending polls every order that has been pending for longer than
+// olderThan, with bounded concurrency. reconciled counts orders that left
+// pending; orders already being polled elsewhere are skipped, not failed.
+func (s *orderService) ReconcilePending(ctx context.Context, olderThan time.Duration) (reconciled, failed int, err error) {
+	orders, err := s.repo.ListPendingOrders(ctx, time.Now().Add(-olderThan), reconcilePendingLimit)
+	if err != nil {
+		s.logger.Error("Failed to list pending orders", zap.Error(err))
+		return 0, 0, models.InternalServerError("Failed to list pending orders")
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, reconcilePendingWorkers)
+	)
+	for _, order := range orders {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(orderID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			updated, err := s.PollOrderStatus(ctx, orderID)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case isConflict(err):
+			case err != nil:
+				failed++
+			case updated.Status != models.StatusPending:
+				reconciled++
+			}
+		}(order.ID.String())
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return reconciled, failed, err
+	}
+
+	s.logger.Info("Pending orders reconciled",
+		zap.Int("pending", len(orders)),
+		zap.Int("reconciled", reconciled),
+		zap.Int("failed", failed))
+	return reconciled, failed, nil
+}
+
+// isConflict reports whether err is a conflict, such as an order already being
+// polled elsewhere
+func isConflict(err error) bool {
+	var apiErr *models.APIError
+	return errors.As(err, &apiErr) && apiErr.Code == models.CodeConflict
+}
+
+// mapUpstreamStatus converts an iStar order status into a local OrderStatus
+func mapUpstreamStatus(status string) (models.OrderStatus, bool) {
+	switch models.OrderStatus(status) {
+	case models.StatusPending, models.StatusCompleted, models.StatusFailed, models.StatusCancelled, models.StatusRefunded:
+		return models.OrderStatus(status), true
+	default:
+		return "", false
+	}
+}
+
+// syncOutcome maps the final status of a synchronous upstream order to a local
+// status and failure reason. Anything other than completed is treated as a
+// failure, keeping iStar's error detail when it gives one.
+func (s *orderService) syncOutcome(upstreamStatus string, upstreamError *string) (models.OrderStatus, *string) {
+	switch models.OrderStatus(upstreamStatus) {
+	case models.StatusCompleted:
+		return models.StatusCompleted, nil
+	case models.StatusFailed:
+		if upstreamError != nil && *upstreamError != "" {
+			return models.StatusFailed, upstreamError
+		}
+		reason := "Order failed at iStar without a reason"
+		return models.StatusFailed, &reason
+	default:
+		s.logger.Warn("Unexpected status from iStar", zap.String("status", upstreamStatus))
+		reason := "Unexpected status from iStar: " + upstreamStatus
+		return models.StatusFailed, &reason
+	}
+}
+
+// QuoteStarOrder prices a star order without placing it
+func (s *orderService) QuoteStarOrder(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+	req.QuoteID = ""
+	if err := s.resolveStarRecipient(ctx, &req); err != nil {
+		return nil, err
+	}
+	q, err := s.istarClient.QuoteStarOrder(ctx, req)
+	if err != nil {
+		s.logger.Error("Failed to quote star order", zap.Error(err))
+		return nil, err
+	}
+	return s.issueQuote(models.OrderTypeStar, req.WalletType, q), nil
+}
+
+// QuotePremiumOrder prices a premium order without placing it
+func (s *orderService) QuotePremiumOrder(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.OrderQuoteResponse, error) {
+	req.QuoteID = ""
+	if err := s.resolvePremiumRecipient(ctx, &req); err != nil {
+		return nil, err
+	}
+	q, err := s.istarClient.QuotePremiumOrder(ctx, req)
+	if err != nil {
+		s.logger.Error("Failed to quote premium order", zap.Error(err))
+		return nil, err
+	}
+	return s.issueQuote(models.OrderTypePremium, req.WalletType, q), nil
+}
+
+// resolveStarRecipient fills in req.RecipientHash from a recipient search on
+// req.Username when the caller did not supply one
+func (s *orderService) resolveStarRecipient(ctx context.Context, req *models.CreateStarOrderRequest) error {
+	if req.RecipientHash != "" {
+		return nil
+	}
+	resp, err := s.istarClient.SearchStarRecipient(ctx, req.Username, req.Quantity)
+	if err != nil {
+		s.logger.Error("Failed to resolve star recipient", zap.Error(err), zap.String("username", req.Username))
+		return err
+	}
+	req.RecipientHash, err = s.pickRecipient(req.Username, resp.Recipients)
+	return err
+}
+
+// resolvePremiumRecipient fills in req.RecipientHash from a recipient search on
+// req.Username when the caller did not supply one
+func (s *orderService) resolvePremiumRecipient(ctx context.Context, req *models.CreatePremiumOrderRequest) error {
+	if req.RecipientHash != "" {
+		return nil
+	}
+	resp, err := s.istarClient.SearchPremiumRecipient(ctx, req.Username, req.Months)
+	if err != nil {
+		s.logger.Error("Failed to resolve premium recipient", zap.Error(err), zap.String("username", req.Username))
+		return err
+	}
+	req.RecipientHash, err = s.pickRecipient(req.Username, resp.Recipients)
+	return err
+}
+
+// pickRecipient chooses the recipient a username refers to. A single result is
+// taken as-is; among several, exactly one must match the username exactly
+// (ignoring case and a leading @), otherwise the request is ambiguous.
+func (s *orderService) pickRecipient(username string, recipients []models.Recipient) (string, error) {
+	switch len(recipients) {
+	case 0:
+		s.logger.Info("No recipient found for username", zap.String("username", username))
+		return "", models.RecipientNotFoundError("No recipient found for username " + username)
+	case 1:
+		return recipients[0].RecipientHash, nil
+	}
+
+	want := strings.TrimPrefix(username, "@")
+	var hash string
+	for _, r := range recipients {
+		if !strings.EqualFold(strings.TrimPrefix(r.Username, "@"), want) {
+			continue
+		}
+		if hash != "" {
+			hash = ""
+			break
+		}
+		hash = r.RecipientHash
+	}
+	if hash == "" {
+		s.logger.Info("Username matches several recipients", zap.String("username", username), zap.Int("matches", len(recipients)))
+		return "", models.RecipientAmbiguousError("Username " + username + " matches several recipients; pass recipient_hash")
+	}
+	return hash, nil
+}
+
+// issueQuote caps the quote's expiry at QuoteTTL and remembers it so an order
+// can reference it. Quotes without an upstream ID cannot lock a price and are
+// returned as-is apart from the expiry.
+func (s *orderService) issueQuote(orderType models.OrderType, walletType models.WalletType, q *models.OrderQuoteResponse) *models.OrderQuoteResponse {
+	if q.WalletType == "" {
+		q.WalletType = walletType
+	}
+
+	expiresAt := time.Now().Add(s.cfg.QuoteTTL)
+	if upstream, err := time.Parse(time.RFC3339, q.ExpiresAt); err == nil && upstream.Before(expiresAt) {
+		expiresAt = upstream
+	}
+	q.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+
+	if q.QuoteID != "" {
+		s.quotes.Set(q.QuoteID, lockedQuote{orderType: orderType, quote: q, expiresAt: expiresAt})
+	}
+	return q
+}
+
+// checkPrice validates the quote an order references, if any, then applies the
+// wallet minimum, the per-order maximum, the caller's daily limit and the
+// balance pre-check. A referenced quote stands in for a fresh one; otherwise
+// the checks share at most one upstream quote. The quote they relied on is
+// returned for a caller that wants the price, or nil when none of them needed
+// one; no quote is requested just to fill it in.
+func (s *orderService) checkPrice(ctx context.Context, orderType models.OrderType, quoteID string, walletType models.WalletType, quote func() (*models.OrderQuoteResponse, error)) (*models.OrderQuoteResponse, error) {
+	var quoted *models.OrderQuoteResponse
+	if quoteID != "" {
+		locked, ok := s.quotes.Get(quoteID)
+		if !ok || time.Now().After(locked.expiresAt) {
+			s.logger.Warn("Order references an unknown or expired quote", zap.String("quote_id", quoteID))
+			return nil, models.ValidationError("Quote " + quoteID + " is unknown or has expired")
+		}
+		if locked.orderType != orderType || locked.quote.WalletType != walletType {
+			return nil, models.ValidationError("Quote " + quoteID + " was issued for a different order type or wallet type")
+		}
+		quoted = locked.quote
+		quote = func() (*models.OrderQuoteResponse, error) { return locked.quote, nil }
+	}
+
+	fetch := sync.OnceValues(quote)
+	quote = func() (*models.OrderQuoteResponse, error) {
+		q, err := fetch()
+		if err == nil {
+			quoted = q
+		}
+		return q, err
+	}
+	if err := s.checkMinimumAmount(ctx, walletType, quote); err != nil {
+		return nil, err
+	}
+	if err := s.checkMaximumAmount(walletType, quote); err != nil {
+		return nil, err
+	}
+	if err := s.checkDailyLimit(ctx, walletType, quote); err != nil {
+		return nil, err
+	}
+	if err := s.checkBalance(ctx, walletType, quote); err != nil {
+		return nil, err
+	}
+	return quoted, nil
+}
+
+// checkBalance rejects an order whose quoted amount exceeds the partner
+// wallet's available balance, saving a create call that would fail upstream.
+// It runs only when CheckBalance is enabled. A failed balance lookup, or a
+// balance reported for another wallet type, lets the order through.
+func (s *orderService) checkBalance(ctx context.Context, walletType models.WalletType, quote func() (*models.OrderQuoteResponse, error)) error {
+	if !s.cfg.CheckBalance {
+		return nil
+	}
+
+	q, err := quote()
+	if err != nil {
+		s.logger.Error("Failed to quote order", zap.Error(err), zap.String("wallet_type", string(walletType)))
+		return err
+	}
+
+	balance, err := s.istarClient.GetWalletBalance(ctx)
+	if err != nil {
+		s.logger.Warn("Skipping balance check", zap.Error(err), zap.String("wallet_type", string(walletType)))
+		return nil
+	}
+	if balance.WalletType != "" && !strings.EqualFold(balance.WalletType, string(walletType)) {
+		s.logger.Debug("Balance is for another wallet type, skipping check",
+			zap.String("wallet_type", string(walletType)),
+			zap.String("balance_wallet_type", balance.WalletType))
+		return nil
+	}
+
+	if q.Amount > balance.Available {
+		s.logger.Warn("Insufficient balance for order",
+			zap.String("wallet_type", string(walletType)),
+			zap.Stringer("amount", q.Amount),
+			zap.Stringer("available", balance.Available))
+		return models.ValidationError(fmt.Sprintf("Insufficient balance: order costs %s but only %s %s is available",
+			q.Amount, balance.Available, balance.Currency))
+	}
+	return nil
+}
+
+// checkMinimumAmount quotes the order and rejects it when the amount is below
+// the configured minimum for its wallet type. No quote is requested for wallet
+// types without a minimum.
+func (s *orderService) checkMinimumAmount(ctx context.Context, walletType models.WalletType, quote func() (*models.OrderQuoteResponse, error)) error {
+	minimum, ok := s.cfg.MinAmountByWallet[string(walletType)]
+	if !ok {
+		return nil
+	}
+
+	q, err := quote()
+	if err != nil {
+		s.logger.Error("Failed to quote order", zap.Error(err), zap.String("wallet_type", string(walletType)))
+		return err
+	}
+
+	if q.Amount < minimum {
+		s.logger.Warn("Order below minimum amount",
+			zap.String("wallet_type", string(walletType)),
+			zap.Stringer("amount", q.Amount),
+			zap.Stringer("minimum", minimum))
+		return models.NewAPIError(http.StatusBadRequest, models.CodeAmountBelowMinimum,
+			fmt.Sprintf("Order amount %s is below the minimum of %s for wallet type %s",
+				q.Amount, minimum, string(walletType)))
+	}
+	return nil
+}
+
+// checkMaximumAmount rejects an order whose quoted amount exceeds MaxOrderAmount,
+// guarding against mistyped quantities or a misused key. No quote is requested
+// when no maximum is configured.
+func (s *orderService) checkMaximumAmount(walletType models.WalletType, quote func() (*models.OrderQuoteResponse, error)) error {
+	if s.cfg.MaxOrderAmount <= 0 {
+		return nil
+	}
+	maximum := s.cfg.MaxOrderAmount
+
+	q, err := quote()
+	if err != nil {
+		s.logger.Error("Failed to quote order", zap.Error(err), zap.String("wallet_type", string(walletType)))
+		return err
+	}
+
+	if q.Amount > maximum {
+		s.logger.Warn("Order above maximum amount",
+			zap.String("wallet_type", string(walletType)),
+			zap.Stringer("amount", q.Amount),
+			zap.Stringer("maximum", maximum))
+		return models.ValidationError(fmt.Sprintf("Order amount %s exceeds the maximum of %s per order", q.Amount, maximum))
+	}
+	return nil
+}
+
+// checkDailyLimit rejects an order that would take the caller's spend since
+// UTC midnight past DailyLimit. Spend counts pending and completed orders, so
+// failed and cancelled ones free their share again. Orders made at the same
+// moment may both pass; the limit is a guardrail, not a ledger. Spend is keyed
+// on the identity APIKeyAuth authenticated, so an order without one is refused
+// rather than let through unmetered.
+func (s *orderService) checkDailyLimit(ctx context.Context, walletType models.WalletType, quote func() (*models.OrderQuoteResponse, error)) error {
+	if s.cfg.DailyLimit <= 0 {
+		return nil
+	}
+	clientID := requestctx.ClientID(ctx)
+	if clientID == "" {
+		s.logger.Warn("Refusing order without an authenticated client while a daily limit is set")
+		return models.UnauthorizedError("An API key is required to place orders")
+	}
+	limit := s.cfg.DailyLimit
+
+	q, err := quote()
+	if err != nil {
+		s.logger.Error("Failed to quote order", zap.Error(err), zap.String("wallet_type", string(walletType)))
+		return err
+	}
+
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	spent, err := s.repo.SumClientSpendSince(ctx, clientID, midnight)
+	if err != nil {
+		s.logger.Error("Failed to sum daily spend", zap.Error(err))
+		return models.InternalServerError("Failed to check daily limit")
+	}
+
+	if spent+q.Amount > limit {
+		remaining := max(limit-spent, 0)
+		s.logger.Warn("Order exceeds daily limit",
+			zap.String("wallet_type", string(walletType)),
+			zap.Stringer("amount", q.Amount),
+			zap.Stringer("spent", spent),
+			zap.Stringer("limit", limit))
+		apiErr := models.NewAPIError(http.StatusForbidden, models.CodeDailyLimitExceeded,
+			fmt.Sprintf("Order amount %s exceeds the remaining daily allowance of %s (limit %s, resets at 00:00 UTC)",
+				q.Amount, remaining, limit))
+		apiErr.Details = []models.FieldError{{
+			Field:   "amount",
+			Rule:    "daily_limit",
+			Message: "Remaining allowance today is " + remaining.String(),
+		}}
+		return apiErr
+	}
+	return nil
+}
+
+// ForceFailOrder lets an operator terminate a stuck order. The order moves to
+// failed with reason as its error message and the intervention is recorded as
+// an order event. Orders that can no longer fail (e.g. completed) are rejected.
+func (s *orderService) ForceFailOrder(ctx context.Context, orderID, reason, actor string) (*models.Order, error) {
+	order, err := s.loadOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	s.describeOrder(ctx, order)
+
+	if !order.Status.CanTransitionTo(models.StatusFailed) {
+		s.logger.Warn("Refusing to force-fail order",
+			zap.String("order_id", orderID),
+			zap.String("status", string(order.Status)),
+			zap.String("actor", actor))
+		return nil, models.ValidationError("Order in status " + string(order.Status) + " cannot be failed")
+	}
+
+	event := &models.OrderEvent{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		Source:        models.EventSourceAdmin,
+		EventType:     "order.force_failed",
+		Status:        models.StatusFailed,
+		CorrelationID: requestctx.CorrelationID(ctx),
+		Actor:         actor,
+		Reason:        reason,
+		CreatedAt:     time.Now(),
+	}
+	entry := newAuditEntry(ctx, orderID, models.AuditOrderForceFailed, order.Status, models.StatusFailed, actor)
+	if err := s.repo.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		if err := tx.UpdateOrderStatus(ctx, orderID, models.StatusFailed, order.TxHash, nil, &reason); err != nil {
+			return err
+		}
+		if err := tx.RecordOrderEvent(ctx, event); err != nil {
+			return err
+		}
+		return tx.RecordAudit(ctx, entry)
+	}); err != nil {
+		s.logger.Error("Failed to update order status", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to update order")
+	}
+
+	order.Status = models.StatusFailed
+	order.ErrorMessage = &reason
+	order.UpdatedAt = event.CreatedAt
+
+	s.logger.Info("Order force-failed",
+		zap.String("order_id", orderID),
+		zap.String("actor", actor),
+		zap.String("reason", reason))
+	return order, nil
+}
+
+// AdminUpdateOrder lets an operator correct an order's status, tx hash, error
+// message or completion time. A status change must be a legal transition, and
+// refunds must go through RefundOrder so their details are recorded. The
+// change is written with an event and an audit entry in one transaction.
+func (s *orderService) AdminUpdateOrder(ctx context.Context, orderID string, req models.AdminUpdateOrderRequest, actor string) (*models.Order, error) {
+	if req.Empty() {
+		return nil, models.ValidationError("No fields to update")
+	}
+
+	order, err := s.loadOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	s.describeOrder(ctx, order)
+
+	from, status := order.Status, order.Status
+	if req.Status != nil && *req.Status != order.Status {
+		status = *req.Status
+		if !status.Valid() {
+			return nil, models.ValidationError("Invalid status " + string(status))
+		}
+		if status == models.StatusRefunded {
+			return nil, models.ValidationError("Use the refund endpoint to refund an order")
+		}
+		if !order.Status.CanTransitionTo(status) {
+			s.logger.Warn("Refusing illegal order correction",
+				zap.String("order_id", orderID),
+				zap.String("status", string(order.Status)),
+				zap.String("requested_status", string(status)),
+				zap.String("actor", actor))
+			return nil, models.ValidationError("Order in status " + string(order.Status) + " cannot move to " + string(status))
+		}
+	}
+
+	txHash, errorMessage, completedAt := order.TxHash, order.ErrorMessage, order.CompletedAt
+	if req.TxHash != nil {
+		txHash = req.TxHash
+	}
+	if req.ErrorMessage != nil {
+		errorMessage = req.ErrorMessage
+	}
+	if req.CompletedAt != nil {
+		completedAt = req.CompletedAt
+	}
+
+	event := &models.OrderEvent{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		Source:        models.EventSourceAdmin,
+		EventType:     string(models.AuditOrderCorrected),
+		Status:        status,
+		CorrelationID: requestctx.CorrelationID(ctx),
+		Actor:         actor,
+		Reason:        req.Reason,
+		CreatedAt:     time.Now(),
+	}
+	entry := newAuditEntry(ctx, orderID, models.AuditOrderCorrected, from, status, actor)
+	if err := s.repo.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		if err := tx.UpdateOrderStatus(ctx, orderID, status, txHash, completedAt, errorMessage); err != nil {
+			return err
+		}
+		if err := tx.RecordOrderEvent(ctx, event); err != nil {
+			return err
+		}
+		return tx.RecordAudit(ctx, entry)
+	}); err != nil {
+		s.logger.Error("Failed to correct order", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to update order")
+	}
+
+	order.Status = status
+	order.TxHash = txHash
+	order.ErrorMessage = errorMessage
+	order.CompletedAt = completedAt
+	order.UpdatedAt = event.CreatedAt
+	s.refundEligibility.Delete(orderID)
+
+	s.logger.Info("Order corrected",
+		zap.String("order_id", orderID),
+		zap.String("from_status", string(from)),
+		zap.String("status", string(status)),
+		zap.String("actor", actor))
+	return order, nil
+}
+
+// GetRefundEligibility asks iStar whether a local order can be refunded. Answers
+// are cached briefly so a refund flow that checks repeatedly costs one upstream call.
+func (s *orderService) GetRefundEligibility(ctx context.Context, orderID string) (*models.RefundEligibilityResponse, error) {
+	order, err := s.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached, ok := s.refundEligibility.Get(orderID); ok {
+		return cached, nil
+	}
+
+	eligibility, err := s.istarClient.GetRefundEligibility(ctx, order.UpstreamID())
+	if err != nil {
+		s.logger.Error("Failed to fetch refund eligibility", zap.Error(err), zap.String("order_id", orderID))
+		return nil, err
+	}
+
+	s.refundEligibility.Set(orderID, eligibility)
+	return eligibility, nil
+}
+
+// CancelOrder asks iStar to cancel a pending order and, once upstream accepts,
+// moves the local record to cancelled. Orders that have already settled are rejected.
+func (s *orderService) CancelOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	order, err := s.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !order.Status.CanTransitionTo(models.StatusCancelled) {
+		s.logger.Warn("Refusing to cancel order",
+			zap.String("order_id", orderID),
+			zap.String("status", string(order.Status)))
+		return nil, models.ValidationError("Order in status " + string(order.Status) + " can no longer be cancelled")
+	}
+
+	if err := s.istarClient.CancelOrder(ctx, order.UpstreamID()); err != nil {
+		s.logger.Error("Failed to cancel order upstream", zap.Error(err), zap.String("order_id", orderID))
+		return nil, err
+	}
+
+	event := &models.OrderEvent{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		Source:        models.EventSourceClient,
+		EventType:     "order.cancelled",
+		Status:        models.StatusCancelled,
+		CorrelationID: requestctx.CorrelationID(ctx),
+		Actor:         requestctx.ClientID(ctx),
+		CreatedAt:     time.Now(),
+	}
+	entry := newAuditEntry(ctx, orderID, models.AuditOrderCancelled, order.Status, models.StatusCancelled, "")
+	if err := s.repo.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		if err := tx.UpdateOrderStatus(ctx, orderID, models.StatusCancelled, order.TxHash, nil, nil); err != nil {
+			return err
+		}
+		if err := tx.RecordOrderEvent(ctx, event); err != nil {
+			return err
+		}
+		return tx.RecordAudit(ctx, entry)
+	}); err != nil {
+		s.logger.Error("Failed to update order status", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to update order")
+	}
+
+	order.Status = models.StatusCancelled
+	order.UpdatedAt = event.CreatedAt
+	s.refundEligibility.Delete(orderID)
+
+	s.logger.Info("Order cancelled", zap.String("order_id", orderID))
+	return order, nil
+}
+
+// RefundOrder asks iStar to refund a completed order and, once upstream accepts,
+// moves the local record to refunded with the refund details. Orders in any
+// other status, or that iStar reports as ineligible, are rejected. The order is
+// locked from the status check to the write, so two concurrent refunds cannot
+// both reach iStar; the loser gets a conflict error.
+func (s *orderService) RefundOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	unlock, locked, err := s.repo.TryLockOrder(ctx, orderID)
+	if err != nil {
+		s.logger.Error("Failed to lock order", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to lock order")
+	}
+	if !locked {
+		return nil, models.ConflictError("Order is already being updated")
+	}
+	defer unlock()
+
+	order, err := s.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !order.Status.CanTransitionTo(models.StatusRefunded) {
+		s.logger.Warn("Refusing to refund order",
+			zap.String("order_id", orderID),
+			zap.String("status", string(order.Status)))
+		return nil, models.ValidationError("Order in status " + string(order.Status) + " cannot be refunded; only completed orders can")
+	}
+
+	eligibility, err := s.GetRefundEligibility(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !eligibility.Eligible {
+		s.logger.Warn("Refusing to refund ineligible order",
+			zap.String("order_id", orderID),
+			zap.String("reason", eligibility.Reason))
+		msg := "Order is not eligible for a refund"
+		if eligibility.Reason != "" {
+			msg += ": " + eligibility.Reason
+		}
+		return nil, models.ValidationError(msg)
+	}
+
+	// A refund POST is never resent by the client, so a failure here cannot
+	// have refunded twice
+	refund, err := s.istarClient.RefundOrder(ctx, order.UpstreamID())
+	if err != nil {
+		s.logger.Error("Failed to refund order upstream", zap.Error(err), zap.String("order_id", orderID))
+		return nil, err
+	}
+
+	refundedAt := time.Now()
+	event := &models.OrderEvent{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		Source:        models.EventSourceClient,
+		EventType:     "order.refunded",
+		Status:        models.StatusRefunded,
+		CorrelationID: requestctx.CorrelationID(ctx),
+		Actor:         requestctx.ClientID(ctx),
+		CreatedAt:     refundedAt,
+	}
+	entry := newAuditEntry(ctx, orderID, models.AuditOrderRefunded, order.Status, models.StatusRefunded, "")
+	if err := s.repo.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		if err := tx.MarkOrderRefunded(ctx, orderID, refundedAt, refund.RefundID, refund.Amount); err != nil {
+			return err
+		}
+		if err := tx.RecordOrderEvent(ctx, event); err != nil {
+			return err
+		}
+		return tx.RecordAudit(ctx, entry)
+	}); err != nil {
+		s.logger.Error("Failed to mark order refunded", zap.Error(err), zap.String("order_id", orderID))
+		return nil, models.InternalServerError("Failed to update order")
+	}
+
+	order.Status = models.StatusRefunded
+	order.UpdatedAt = refundedAt
+	order.RefundedAt = &refundedAt
+	order.RefundID = &refund.RefundID
+	order.RefundAmount = &refund.Amount
+	s.refundEligibility.Delete(orderID)
+
+	s.logger.Info("Order refunded",
+		zap.String("order_id", orderID),
+		zap.String("refund_id", refund.RefundID),
+		zap.Stringer("amount", refund.Amount))
+	return order, nil
+}
+
+// CreateStarOrdersBatch creates one async star order per item using a bounded
+// worker pool. Items succeed or fail independently; results keep request order.
+// A batch with a total_quantity is first split across its items by weight; a
+// split that cannot be made rejects the whole batch.
+func (s *orderService) CreateStarOrdersBatch(ctx context.Context, req models.BatchStarOrderRequest) (*models.BatchOrderResponse, error) {
+	if err := weighBatchItems(&req); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BatchOrderItemResult, len(req.Items))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(batchOrderWorkers, len(req.Items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = s.createBatchItem(ctx, req, i)
+			}
+		}()
+	}
+	for i := range req.Items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	resp := &models.BatchOrderResponse{Results: results}
+	for _, result := range results {
+		if result.Order != nil {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+
+	s.logger.Info("Star batch processed",
+		zap.Int("items", len(req.Items)),
+		zap.Int("succeeded", resp.Succeeded),
+		zap.Int("failed", resp.Failed))
+	return resp, nil
+}
+
+// weighBatchItems fills in the quantity of every item of a weighted batch.
+// Items of a batch without total_quantity must not carry weights.
+func weighBatchItems(req *models.BatchStarOrderRequest) error {
+	if req.TotalQuantity == 0 {
+		for _, item := range req.Items {
+			if item.Weight != 0 {
+				return models.ValidationError("Weight is only allowed together with total_quantity")
+			}
+		}
+		return nil
+	}
+
+	weights := make([]int, len(req.Items))
+	for i, item := range req.Items {
+		if item.Quantity != 0 {
+			return models.ValidationError("Items of a batch with total_quantity take a weight, not a quantity")
+		}
+		if item.Weight < 1 || item.Weight > maxStarQuantity {
+			return models.ValidationError(fmt.Sprintf("Item %d: weight must be between 1 and 1,000,000", i))
+		}
+		weights[i] = item.Weight
+	}
+
+	quantities, err := splitByWeight(req.TotalQuantity, weights)
+	if err != nil {
+		return err
+	}
+	// The caller's items are left alone
+	req.Items = slices.Clone(req.Items)
+	for i := range req.Items {
+		req.Items[i].Quantity = quantities[i]
+	}
+	return nil
+}
+
+// splitByWeight divides total into one share per weight, in proportion to the
+// weights, with every share at least minStarQuantity and the shares summing to
+// total exactly. Items whose proportional share falls below the minimum are
+// raised to it and the rest is divided among the others again. Fractions are
+// settled by largest remainder, ties going to the earlier item. Weights must be
+// positive, so every split has a share to divide by.
+func splitByWeight(total int, weights []int) ([]int, error) {
+	if len(weights) == 0 {
+		return nil, models.ValidationError("A weighted batch needs at least one recipient")
+	}
+	for i, w := range weights {
+		if w < 1 {
+			return nil, models.ValidationError(fmt.Sprintf("Item %d: weight must be at least 1", i))
+		}
+	}
+	if total < minStarQuantity*len(weights) {
+		return nil, models.ValidationError(fmt.Sprintf(
+			"total_quantity must be at least %d to give each of the %d recipients %d stars",
+			minStarQuantity*len(weights), len(weights), minStarQuantity))
+	}
+
+	shares := make([]int, len(weights))
+	raisedToMin := make([]bool, len(weights))
+	for {
+		remaining, weightSum := total, 0
+		for i, w := range weights {
+			if raisedToMin[i] {
+				remaining -= minStarQuantity
+			} else {
+				weightSum += w
+			}
+		}
+
+		// Raise every share the proportional split would leave short, then split
+		// again. One item always stays, as total covers the minimum for all.
+		raised := false
+		for i, w := range weights {
+			if !raisedToMin[i] && remaining*w < minStarQuantity*weightSum {
+				shares[i], raisedToMin[i] = minStarQuantity, true
+				raised = true
+			}
+		}
+		if raised {
+			continue
+		}
+
+		type fraction struct{ index, remainder int }
+		fractions := make([]fraction, 0, len(weights))
+		left := remaining
+		for i, w := range weights {
+			if raisedToMin[i] {
+				continue
+			}
+			shares[i] = remaining * w / weightSum
+			left -= shares[i]
+			fractions = append(fractions, fraction{i, remaining * w % weightSum})
+		}
+		slices.SortStableFunc(fractions, func(a, b fraction) int { return b.remainder - a.remainder })
+		for _, f := range fractions[:left] {
+			shares[f.index]++
+		}
+		break
+	}
+
+	for _, share := range shares {
+		if share > maxStarQuantity {
+			return nil, models.ValidationError("total_quantity would give a recipient more than 1,000,000 stars")
+		}
+	}
+	return shares, nil
+}
+
+// createBatchItem validates and creates the order for item i of a batch
+func (s *orderService) createBatchItem(ctx context.Context, req models.BatchStarOrderRequest, i int) models.BatchOrderItemResult {
+	item := req.Items[i]
+	result := models.BatchOrderItemResult{Index: i}
+
+	if item.Username == "" || item.Quantity < minStarQuantity || item.Quantity > maxStarQuantity {
+		result.Code = models.CodeValidation
+		result.Error = "Invalid item: username, quantity (50-1,000,000) required"
+		return result
+	}
+
+	orderReq := models.CreateStarOrderRequest{
+		Username:      item.Username,
+		RecipientHash: item.RecipientHash,
+		Quantity:      item.Quantity,
+		WalletType:    req.WalletType,
+	}
+	if req.IdempotencyKey != "" {
+		orderReq.IdempotencyKey = req.IdempotencyKey + ":" + strconv.Itoa(i)
+	}
+
+	order, err := s.CreateStarOrderAsync(ctx, orderReq)
+	if err != nil {
+		var apiErr *models.APIError
+		if errors.As(err, &apiErr) {
+			result.Code = apiErr.Code
+			result.Error = apiErr.Message
+		} else {
+			result.Code = models.CodeInternal
+			result.Error = "Failed to create order"
+		}
+		return result
+	}
+
+	result.Order = order
+	return result
+}
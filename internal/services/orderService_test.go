@@ -0,0 +1,2123 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/metrics"
+	"github.com/hulupay/istar-api/internal/middleware"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/goleak"
+	"go.uber.org/zap"
+)
+
+// newTestOrderService returns a service over a fresh in-memory repository.
+// Its background work stops, and is waited for, when the test ends.
+func newTestOrderService(t *testing.T, istar *clientmock.IStarAPI, cfg config.OrderConfig) (*orderService, repositories.OrderRepository) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	t.Cleanup(func() {
+		cancel()
+		workers.Wait()
+	})
+	repo := repositories.NewInMemoryOrderRepository()
+	return NewOrderService(ctx, repo, istar, cfg, &workers, zap.NewNop()).(*orderService), repo
+}
+
+// clientContext returns a context attributed to clientID
+func clientContext(clientID string) context.Context {
+	return requestctx.WithClientID(context.Background(), clientID)
+}
+
+// countingStarCreates answers every async star create with a new iStar order
+// and counts the calls
+func countingStarCreates(calls *atomic.Int32) func(context.Context, models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+	return func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+		n := calls.Add(1)
+		return &models.StarOrderResponse{
+			OrderID:   "istar-" + strconv.Itoa(int(n)),
+			Status:    "pending",
+			Quantity:  req.Quantity,
+			Amount:    models.Amount(100),
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		}, nil
+	}
+}
+
+// wantAPIStatus fails the test unless err is an APIError with status
+func wantAPIStatus(t *testing.T, err error, status int) {
+	t.Helper()
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != status {
+		t.Fatalf("err = %v, want an APIError with status %d", err, status)
+	}
+}
+
+func starRequest(key string, quantity int) models.CreateStarOrderRequest {
+	return models.CreateStarOrderRequest{
+		Username:       "alice_1",
+		RecipientHash:  "hash-alice",
+		Quantity:       quantity,
+		WalletType:     "ton",
+		IdempotencyKey: key,
+	}
+}
+
+func TestIdempotencyKeyReplaysSameRequest(t *testing.T) {
+	var calls atomic.Int32
+	svc, _ := newTestOrderService(t, &clientmock.IStarAPI{CreateStarOrderAsyncFunc: countingStarCreates(&calls)}, config.OrderConfig{})
+	ctx := clientContext("client-a")
+
+	first, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50))
+	if err != nil {
+		t.Fatalf("first create: %v", err)
+	}
+	second, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50))
+	if err != nil {
+		t.Fatalf("repeated create: %v", err)
+	}
+
+	if second.ID != first.ID {
+		t.Errorf("repeated create returned order %s, want %s", second.ID, first.ID)
+	}
+	if !second.Replayed {
+		t.Error("repeated create is not marked as replayed")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("iStar was called %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyKeyConflictsOnDifferentRequest(t *testing.T) {
+	var calls atomic.Int32
+	svc, _ := newTestOrderService(t, &clientmock.IStarAPI{CreateStarOrderAsyncFunc: countingStarCreates(&calls)}, config.OrderConfig{})
+	ctx := clientContext("client-a")
+
+	if _, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50)); err != nil {
+		t.Fatalf("first create: %v", err)
+	}
+	_, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 60))
+	wantAPIStatus(t, err, http.StatusConflict)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("iStar was called %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyKeyIsScopedPerClient(t *testing.T) {
+	var calls atomic.Int32
+	svc, _ := newTestOrderService(t, &clientmock.IStarAPI{CreateStarOrderAsyncFunc: countingStarCreates(&calls)}, config.OrderConfig{})
+
+	a, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("key-1", 50))
+	if err != nil {
+		t.Fatalf("create for client a: %v", err)
+	}
+	b, err := svc.CreateStarOrderAsync(clientContext("client-b"), starRequest("key-1", 50))
+	if err != nil {
+		t.Fatalf("create for client b: %v", err)
+	}
+
+	if a.ID == b.ID || b.Replayed {
+		t.Error("another client's key was replayed")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("iStar was called %d times, want 2", n)
+	}
+}
+
+func TestCreateStarOrderAsyncSendsOrderIDAsClientOrderID(t *testing.T) {
+	var sent string
+	istar := &clientmock.IStarAPI{
+		CreateStarOrderAsyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			sent = req.ClientOrderID
+			return &models.StarOrderResponse{OrderID: "istar-1", Quantity: req.Quantity, CreatedAt: time.Now().UTC().Format(time.RFC3339)}, nil
+		},
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+
+	order, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50))
+	if err != nil {
+		t.Fatalf("CreateStarOrderAsync: %v", err)
+	}
+	if sent == "" || sent != order.ID.String() {
+		t.Errorf("client_order_id = %q, want the order id %s", sent, order.ID)
+	}
+}
+
+// repeatingStarCreates answers every async star create with the same iStar
+// order id, as iStar does when it deduplicates a resent order
+func repeatingStarCreates(calls *atomic.Int32) func(context.Context, models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+	return func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+		calls.Add(1)
+		return &models.StarOrderResponse{
+			OrderID:   "istar-dup",
+			Status:    "pending",
+			Quantity:  req.Quantity,
+			Amount:    models.Amount(100),
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		}, nil
+	}
+}
+
+func TestRepeatedIStarOrderIDReturnsStoredOrder(t *testing.T) {
+	var calls atomic.Int32
+	svc, repo := newTestOrderService(t, &clientmock.IStarAPI{CreateStarOrderAsyncFunc: repeatingStarCreates(&calls)}, config.OrderConfig{})
+	ctx := clientContext("client-a")
+
+	first, err := svc.CreateStarOrderAsync(ctx, starRequest("", 50))
+	if err != nil {
+		t.Fatalf("first create: %v", err)
+	}
+	second, err := svc.CreateStarOrderAsync(ctx, starRequest("", 50))
+	if err != nil {
+		t.Fatalf("create with a repeated iStar id = %v, want the stored order", err)
+	}
+
+	if second.ID != first.ID {
+		t.Errorf("repeated iStar id returned order %s, want %s", second.ID, first.ID)
+	}
+	if !second.Replayed {
+		t.Error("order returned for a repeated iStar id is not marked as replayed")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("iStar was called %d times, want 2", n)
+	}
+	if n, _ := repo.CountOrders(ctx, models.OrderListQuery{}); n != 1 {
+		t.Errorf("%d orders were stored, want 1", n)
+	}
+	if entries, _ := repo.ListAuditEntries(ctx, first.ID.String()); len(entries) != 1 {
+		t.Errorf("%d audit entries were recorded, want 1", len(entries))
+	}
+}
+
+func TestRepeatedIStarOrderIDFromAnotherClientConflicts(t *testing.T) {
+	var calls atomic.Int32
+	svc, repo := newTestOrderService(t, &clientmock.IStarAPI{CreateStarOrderAsyncFunc: repeatingStarCreates(&calls)}, config.OrderConfig{})
+
+	first, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50))
+	if err != nil {
+		t.Fatalf("create for client a: %v", err)
+	}
+	_, err = svc.CreateStarOrderAsync(clientContext("client-b"), starRequest("", 50))
+	wantAPIStatus(t, err, http.StatusConflict)
+
+	stored, err := repo.GetOrderByIStarID(context.Background(), "istar-dup")
+	if err != nil || stored.ID != first.ID || stored.ClientID != "client-a" {
+		t.Errorf("iStar id points at %+v (%v), want client a's order %s", stored, err, first.ID)
+	}
+}
+
+// storeOrder saves an order placed by clientID directly in repo
+func storeOrder(t *testing.T, repo repositories.OrderRepository, clientID string, status models.OrderStatus) *models.Order {
+	t.Helper()
+	now := time.Now()
+	order := &models.Order{
+		ID:         uuid.New(),
+		Type:       models.OrderTypeStar,
+		Status:     status,
+		Username:   "alice_1",
+		Amount:     models.Amount(100),
+		WalletType: "ton",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ClientID:   clientID,
+	}
+	order.IStarOrderID = "istar-" + order.ID.String()
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	return order
+}
+
+func TestOrdersAreHiddenFromOtherClients(t *testing.T) {
+	svc, repo := newTestOrderService(t, &clientmock.IStarAPI{}, config.OrderConfig{})
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	id := order.ID.String()
+	other := clientContext("client-b")
+
+	checks := map[string]func() error{
+		"get":           func() error { _, err := svc.GetOrder(other, id); return err },
+		"by istar id":   func() error { _, err := svc.GetOrderByIStarID(other, order.IStarOrderID); return err },
+		"audit":         func() error { _, err := svc.GetOrderAudit(other, id); return err },
+		"cancel":        func() error { _, err := svc.CancelOrder(other, id); return err },
+		"refund":        func() error { _, err := svc.RefundOrder(other, id); return err },
+		"eligibility":   func() error { _, err := svc.GetRefundEligibility(other, id); return err },
+		"resync":        func() error { _, err := svc.ResyncOrder(other, id); return err },
+		"unknown order": func() error { _, err := svc.GetOrder(other, uuid.NewString()); return err },
+	}
+	for name, check := range checks {
+		t.Run(name, func(t *testing.T) {
+			wantAPIStatus(t, check(), http.StatusNotFound)
+		})
+	}
+
+	if _, err := svc.GetOrder(clientContext("client-a"), id); err != nil {
+		t.Errorf("owner cannot read its order: %v", err)
+	}
+}
+
+func TestListOrdersOnlyListsCallersOrders(t *testing.T) {
+	svc, repo := newTestOrderService(t, &clientmock.IStarAPI{}, config.OrderConfig{})
+	mine := storeOrder(t, repo, "client-a", models.StatusPending)
+	storeOrder(t, repo, "client-b", models.StatusPending)
+
+	resp, err := svc.ListOrders(clientContext("client-a"), models.OrderListQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListOrders: %v", err)
+	}
+	if resp.Total != 1 || len(resp.Orders) != 1 || resp.Orders[0].ID != mine.ID {
+		t.Errorf("listed %d of total %d, want only order %s", len(resp.Orders), resp.Total, mine.ID)
+	}
+}
+
+func TestGetOrdersByTxHashSkipsOtherClients(t *testing.T) {
+	svc, repo := newTestOrderService(t, &clientmock.IStarAPI{}, config.OrderConfig{})
+	txHash := "0xabc"
+	for _, clientID := range []string{"client-a", "client-b"} {
+		order := storeOrder(t, repo, clientID, models.StatusPending)
+		if err := repo.UpdateOrderStatus(context.Background(), order.ID.String(), models.StatusCompleted, &txHash, nil, nil); err != nil {
+			t.Fatalf("UpdateOrderStatus: %v", err)
+		}
+	}
+
+	orders, err := svc.GetOrdersByTxHash(clientContext("client-a"), txHash)
+	if err != nil {
+		t.Fatalf("GetOrdersByTxHash: %v", err)
+	}
+	if len(orders) != 1 || orders[0].ClientID != "client-a" {
+		t.Errorf("got %d orders, want only client-a's", len(orders))
+	}
+
+	_, err = svc.GetOrdersByTxHash(clientContext("client-c"), txHash)
+	wantAPIStatus(t, err, http.StatusNotFound)
+}
+
+func TestRefundOrderTransitions(t *testing.T) {
+	tests := []struct {
+		status models.OrderStatus
+		want   int
+	}{
+		{models.StatusCompleted, http.StatusOK},
+		{models.StatusPending, http.StatusBadRequest},
+		{models.StatusFailed, http.StatusBadRequest},
+		{models.StatusRefunded, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			var calls int
+			istar := &clientmock.IStarAPI{
+				GetRefundEligibilityFunc: eligibleForRefund,
+				RefundOrderFunc: func(ctx context.Context, id string) (*models.RefundResponse, error) {
+					calls++
+					return &models.RefundResponse{RefundID: "refund-1", Amount: models.Amount(100)}, nil
+				},
+			}
+			svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+			ctx := clientContext("client-a")
+			order := storeOrder(t, repo, "client-a", tt.status)
+
+			refunded, err := svc.RefundOrder(ctx, order.ID.String())
+			if tt.want != http.StatusOK {
+				wantAPIStatus(t, err, tt.want)
+				if calls != 0 {
+					t.Errorf("iStar was asked to refund a %s order", tt.status)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("RefundOrder: %v", err)
+			}
+			if refunded.Status != models.StatusRefunded || refunded.RefundedAt == nil || *refunded.RefundID != "refund-1" {
+				t.Errorf("refunded order = %+v, want status refunded with refund details", refunded)
+			}
+			stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+			if stored.Status != models.StatusRefunded {
+				t.Errorf("stored status = %s, want refunded", stored.Status)
+			}
+		})
+	}
+}
+
+func eligibleForRefund(ctx context.Context, id string) (*models.RefundEligibilityResponse, error) {
+	return &models.RefundEligibilityResponse{Eligible: true, MaxRefundable: models.Amount(100)}, nil
+}
+
+func TestRefundOrderRejectsIneligibleOrder(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetRefundEligibilityFunc: func(ctx context.Context, id string) (*models.RefundEligibilityResponse, error) {
+			return &models.RefundEligibilityResponse{Eligible: false, Reason: "refund window has closed"}, nil
+		},
+		RefundOrderFunc: func(ctx context.Context, id string) (*models.RefundResponse, error) {
+			t.Errorf("iStar was asked to refund ineligible order %s", id)
+			return &models.RefundResponse{RefundID: "refund-1"}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	ctx := clientContext("client-a")
+	order := storeOrder(t, repo, "client-a", models.StatusCompleted)
+
+	_, err := svc.RefundOrder(ctx, order.ID.String())
+	wantAPIStatus(t, err, http.StatusBadRequest)
+	if err == nil || !strings.Contains(err.Error(), "refund window has closed") {
+		t.Errorf("RefundOrder error = %v, want iStar's reason", err)
+	}
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusCompleted {
+		t.Errorf("stored status = %s, want completed", stored.Status)
+	}
+}
+
+func TestRefundOrderYieldsWhileOrderIsLocked(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetRefundEligibilityFunc: eligibleForRefund,
+		RefundOrderFunc: func(ctx context.Context, id string) (*models.RefundResponse, error) {
+			t.Errorf("iStar was asked to refund %s while another request holds it", id)
+			return &models.RefundResponse{RefundID: "refund-1"}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	order := storeOrder(t, repo, "client-a", models.StatusCompleted)
+
+	unlock, ok, err := repo.TryLockOrder(context.Background(), order.ID.String())
+	if err != nil || !ok {
+		t.Fatalf("TryLockOrder = %v, %v; want the lock", ok, err)
+	}
+	defer unlock()
+
+	_, err = svc.RefundOrder(clientContext("client-a"), order.ID.String())
+	wantAPIStatus(t, err, http.StatusConflict)
+}
+
+// failingAuditRepo fails every audit write made inside a transaction
+type failingAuditRepo struct {
+	repositories.OrderRepository
+}
+
+func (r failingAuditRepo) WithTx(ctx context.Context, fn func(tx repositories.OrderRepository) error) error {
+	return r.OrderRepository.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		return fn(failingAuditTx{tx})
+	})
+}
+
+type failingAuditTx struct {
+	repositories.OrderRepository
+}
+
+func (failingAuditTx) RecordAudit(context.Context, *models.AuditEntry) error {
+	return errors.New("audit log unavailable")
+}
+
+func TestCreateOrderCommitsNothingWhenTransactionFails(t *testing.T) {
+	var calls atomic.Int32
+	istar := &clientmock.IStarAPI{CreateStarOrderAsyncFunc: countingStarCreates(&calls)}
+	repo := repositories.NewInMemoryOrderRepository()
+	background, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := NewOrderService(background, failingAuditRepo{repo}, istar, config.OrderConfig{}, &sync.WaitGroup{}, zap.NewNop())
+	ctx := clientContext("client-a")
+
+	_, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50))
+	wantAPIStatus(t, err, http.StatusInternalServerError)
+
+	if n, _ := repo.CountOrders(ctx, models.OrderListQuery{}); n != 0 {
+		t.Errorf("%d orders were committed, want none", n)
+	}
+	if _, err := repo.GetOrderByIdempotencyKey(ctx, "client-a", "key-1", time.Time{}); !errors.Is(err, repositories.ErrOrderNotFound) {
+		t.Errorf("idempotency key points at an order: %v", err)
+	}
+	// The key was released, so the client can retry with it
+	record := &models.IdempotencyRecord{ClientID: "client-a", Key: "key-1", CreatedAt: time.Now()}
+	if err := repo.ReserveIdempotencyKey(ctx, record, time.Now().Add(-time.Hour)); err != nil {
+		t.Errorf("idempotency key is still reserved: %v", err)
+	}
+}
+
+func TestIdempotencyKeyInProgressIsRefused(t *testing.T) {
+	started, proceed := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	create := countingStarCreates(&calls)
+	istar := &clientmock.IStarAPI{
+		CreateStarOrderAsyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			close(started)
+			<-proceed
+			return create(ctx, req)
+		},
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+	ctx := clientContext("client-a")
+
+	done := make(chan error)
+	go func() {
+		_, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50))
+		done <- err
+	}()
+	<-started
+
+	_, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50))
+	wantAPIStatus(t, err, http.StatusConflict)
+
+	close(proceed)
+	if err := <-done; err != nil {
+		t.Fatalf("first create: %v", err)
+	}
+	replay, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50))
+	if err != nil || !replay.Replayed {
+		t.Errorf("create after the first finished = %v, %v; want a replay", replay, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("iStar was called %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyKeyIsFreedWhenIStarFails(t *testing.T) {
+	var calls atomic.Int32
+	istar := &clientmock.IStarAPI{
+		CreateStarOrderAsyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			calls.Add(1)
+			return nil, models.ServiceUnavailableError("iStar is temporarily unavailable")
+		},
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+	ctx := clientContext("client-a")
+
+	for i := 0; i < 2; i++ {
+		_, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50))
+		wantAPIStatus(t, err, http.StatusServiceUnavailable)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("iStar was called %d times, want a retry with the same key to reach it", n)
+	}
+}
+
+// searchReturning answers star recipient searches with recipients
+func searchReturning(recipients ...models.Recipient) func(context.Context, string, int) (*models.StarRecipientResponse, error) {
+	return func(ctx context.Context, username string, quantity int) (*models.StarRecipientResponse, error) {
+		return &models.StarRecipientResponse{Username: username, Quantity: quantity, Recipients: recipients}, nil
+	}
+}
+
+func TestCreateStarOrderResolvesRecipientFromUsername(t *testing.T) {
+	var sentHash string
+	istar := &clientmock.IStarAPI{
+		SearchStarRecipientFunc: searchReturning(models.Recipient{Username: "alice_1", RecipientHash: "hash-from-search"}),
+		CreateStarOrderAsyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			sentHash = req.RecipientHash
+			return &models.StarOrderResponse{OrderID: "istar-1", Quantity: req.Quantity, CreatedAt: time.Now().UTC().Format(time.RFC3339)}, nil
+		},
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+
+	req := starRequest("", 50)
+	req.RecipientHash = ""
+	order, err := svc.CreateStarOrderAsync(clientContext("client-a"), req)
+	if err != nil {
+		t.Fatalf("CreateStarOrderAsync: %v", err)
+	}
+	if sentHash != "hash-from-search" || order.RecipientHash != "hash-from-search" {
+		t.Errorf("sent %q and stored %q, want the searched hash", sentHash, order.RecipientHash)
+	}
+}
+
+func TestCreateStarOrderRecipientResolutionFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		recipients []models.Recipient
+		wantCode   string
+	}{
+		{"not found", nil, models.CodeRecipientNotFound},
+		{"ambiguous", []models.Recipient{
+			{Username: "alice_10", RecipientHash: "h1"},
+			{Username: "alice_11", RecipientHash: "h2"},
+		}, models.CodeRecipientAmbiguous},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			istar := &clientmock.IStarAPI{SearchStarRecipientFunc: searchReturning(tt.recipients...)}
+			svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+
+			req := starRequest("", 50)
+			req.RecipientHash = ""
+			_, err := svc.CreateStarOrderAsync(clientContext("client-a"), req)
+
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.wantCode {
+				t.Errorf("err = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestBatchItemResolvesRecipientFromUsername(t *testing.T) {
+	var calls atomic.Int32
+	istar := &clientmock.IStarAPI{
+		SearchStarRecipientFunc:  searchReturning(models.Recipient{Username: "bob_22", RecipientHash: "hash-bob"}),
+		CreateStarOrderAsyncFunc: countingStarCreates(&calls),
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+
+	resp, err := svc.CreateStarOrdersBatch(clientContext("client-a"), models.BatchStarOrderRequest{
+		WalletType: "ton",
+		Items:      []models.BatchStarOrderItem{{Username: "bob_22", Quantity: 50}},
+	})
+	if err != nil {
+		t.Fatalf("CreateStarOrdersBatch: %v", err)
+	}
+	if resp.Succeeded != 1 || resp.Results[0].Order.RecipientHash != "hash-bob" {
+		t.Errorf("batch result = %+v, want one order for hash-bob", resp.Results[0])
+	}
+}
+
+// quotingStarCreates quotes every star order at amount and creates it for the
+// same amount, as iStar does
+func quotingStarCreates(istar *clientmock.IStarAPI, amount models.Amount) {
+	istar.QuoteStarOrderFunc = func(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+		return &models.OrderQuoteResponse{WalletType: req.WalletType, Amount: amount}, nil
+	}
+	istar.CreateStarOrderAsyncFunc = func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+		return &models.StarOrderResponse{
+			OrderID:   "istar-" + req.ClientOrderID,
+			Quantity:  req.Quantity,
+			Amount:    amount,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		}, nil
+	}
+}
+
+func TestDailyLimitRejectsOrderPastTheLimit(t *testing.T) {
+	istar := &clientmock.IStarAPI{}
+	quotingStarCreates(istar, models.Amount(40))
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{DailyLimit: models.Amount(100)})
+	ctx := clientContext("client-a")
+
+	for i := range 2 {
+		if _, err := svc.CreateStarOrderAsync(ctx, starRequest("", 50)); err != nil {
+			t.Fatalf("order %d: %v", i+1, err)
+		}
+	}
+
+	_, err := svc.CreateStarOrderAsync(ctx, starRequest("", 50))
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || apiErr.Code != models.CodeDailyLimitExceeded {
+		t.Fatalf("third order err = %v, want %s", err, models.CodeDailyLimitExceeded)
+	}
+	if len(apiErr.Details) != 1 || apiErr.Details[0].Message != "Remaining allowance today is "+models.Amount(20).String() {
+		t.Errorf("details = %+v, want the remaining allowance", apiErr.Details)
+	}
+
+	if _, err := svc.CreateStarOrderAsync(clientContext("client-b"), starRequest("", 50)); err != nil {
+		t.Errorf("another client's order: %v, want it under its own limit", err)
+	}
+}
+
+func TestDailyLimitIgnoresFailedOrders(t *testing.T) {
+	istar := &clientmock.IStarAPI{}
+	quotingStarCreates(istar, models.Amount(40))
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{DailyLimit: models.Amount(100)})
+	storeOrder(t, repo, "client-a", models.StatusFailed)
+	storeOrder(t, repo, "client-a", models.StatusCancelled)
+
+	for i := range 2 {
+		if _, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50)); err != nil {
+			t.Fatalf("order %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestDailyLimitRequiresAnAuthenticatedClient(t *testing.T) {
+	var creates atomic.Int32
+	istar := &clientmock.IStarAPI{}
+	quotingStarCreates(istar, models.Amount(40))
+	istar.CreateStarOrderAsyncFunc = countingStarCreates(&creates)
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{DailyLimit: models.Amount(100)})
+
+	_, err := svc.CreateStarOrderAsync(context.Background(), starRequest("", 50))
+	wantAPIStatus(t, err, http.StatusUnauthorized)
+	if n := creates.Load(); n != 0 {
+		t.Errorf("iStar creates = %d, want 0", n)
+	}
+}
+
+// timingOutStarSyncs quotes star orders at amount and lets every sync create
+// time out after it reached iStar
+func timingOutStarSyncs(istar *clientmock.IStarAPI, amount models.Amount) {
+	istar.QuoteStarOrderFunc = func(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+		return &models.OrderQuoteResponse{WalletType: req.WalletType, Amount: amount}, nil
+	}
+	istar.CreateStarOrderSyncFunc = func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+		return nil, fmt.Errorf("create star order: %w", context.DeadlineExceeded)
+	}
+}
+
+func TestSyncTimeoutRecordsPendingOrderAndReconcilesIt(t *testing.T) {
+	lookups := make(chan string, 1)
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			lookups <- id
+			return &models.OrderStatusResponse{OrderID: "istar-1", Status: "completed"}, nil
+		},
+	}
+	timingOutStarSyncs(istar, models.Amount(40))
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{
+		// The maximum check quotes the order, so its price is known
+		MaxOrderAmount:             models.Amount(1000),
+		SyncFallbackReconcileAfter: 10 * time.Millisecond,
+	})
+	ctx := clientContext("client-a")
+
+	order, err := svc.CreateStarOrderSync(ctx, starRequest("", 50))
+	if err != nil {
+		t.Fatalf("CreateStarOrderSync: %v", err)
+	}
+	if order.Status != models.StatusPending || order.Amount != models.Amount(40) {
+		t.Fatalf("order = %s for %s, want pending for the quoted 40", order.Status, order.Amount)
+	}
+
+	select {
+	case id := <-lookups:
+		if id != order.ID.String() {
+			t.Errorf("looked up %q, want the order id sent as client_order_id", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the timed-out order was never looked up at iStar")
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		stored, err := repo.GetOrderByID(ctx, order.ID.String())
+		if err != nil {
+			t.Fatalf("GetOrderByID: %v", err)
+		}
+		if stored.Status == models.StatusCompleted && stored.IStarOrderID == "istar-1" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stored order = %s with iStar id %q, want completed with istar-1", stored.Status, stored.IStarOrderID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// eventRecordingRepo keeps a copy of every order event written inside a transaction
+type eventRecordingRepo struct {
+	repositories.OrderRepository
+	mu     *sync.Mutex
+	events *[]models.OrderEvent
+}
+
+func (r eventRecordingRepo) WithTx(ctx context.Context, fn func(tx repositories.OrderRepository) error) error {
+	return r.OrderRepository.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		return fn(eventRecordingRepo{OrderRepository: tx, mu: r.mu, events: r.events})
+	})
+}
+
+func (r eventRecordingRepo) RecordOrderEvent(ctx context.Context, event *models.OrderEvent) error {
+	r.mu.Lock()
+	*r.events = append(*r.events, *event)
+	r.mu.Unlock()
+	return r.OrderRepository.RecordOrderEvent(ctx, event)
+}
+
+func TestSyncTimeoutRecordsWhyTheOrderIsPending(t *testing.T) {
+	istar := &clientmock.IStarAPI{}
+	timingOutStarSyncs(istar, models.Amount(40))
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{SyncFallbackReconcileAfter: time.Hour})
+	var (
+		mu     sync.Mutex
+		events []models.OrderEvent
+	)
+	svc.repo = eventRecordingRepo{OrderRepository: repo, mu: &mu, events: &events}
+
+	order, err := svc.CreateStarOrderSync(clientContext("client-a"), starRequest("", 50))
+	if err != nil {
+		t.Fatalf("CreateStarOrderSync: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0].OrderID != order.ID.String() || events[0].Reason != "sync create timed out" {
+		t.Fatalf("events = %+v, want one for the order saying the sync create timed out", events)
+	}
+	if events[0].Status != models.StatusPending || events[0].Actor != "client-a" {
+		t.Errorf("event = %+v, want pending, attributed to client-a", events[0])
+	}
+}
+
+func TestSyncTimeoutReconcileStopsWithTheService(t *testing.T) {
+	var lookups atomic.Int32
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			lookups.Add(1)
+			return &models.OrderStatusResponse{Status: "pending"}, nil
+		},
+	}
+	timingOutStarSyncs(istar, models.Amount(40))
+	background, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	svc := NewOrderService(background, repositories.NewInMemoryOrderRepository(), istar,
+		config.OrderConfig{SyncFallbackReconcileAfter: time.Hour}, &workers, zap.NewNop())
+
+	if _, err := svc.CreateStarOrderSync(clientContext("client-a"), starRequest("", 50)); err != nil {
+		t.Fatalf("CreateStarOrderSync: %v", err)
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the scheduled reconcile kept shutdown waiting")
+	}
+	if n := lookups.Load(); n != 0 {
+		t.Errorf("iStar lookups = %d, want 0 after shutdown", n)
+	}
+}
+
+func TestSyncTimeoutIsNotQuotedWithoutPriceChecks(t *testing.T) {
+	var quotes atomic.Int32
+	istar := &clientmock.IStarAPI{
+		QuoteStarOrderFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+			quotes.Add(1)
+			return nil, errors.New("quotes are down")
+		},
+		CreateStarOrderSyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			return nil, fmt.Errorf("create star order: %w", context.DeadlineExceeded)
+		},
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{SyncFallbackReconcileAfter: time.Hour})
+
+	order, err := svc.CreateStarOrderSync(clientContext("client-a"), starRequest("", 50))
+	if err != nil {
+		t.Fatalf("CreateStarOrderSync: %v", err)
+	}
+	if order.Status != models.StatusPending || order.Amount != 0 {
+		t.Errorf("order = %s for %s, want pending without a price", order.Status, order.Amount)
+	}
+	if n := quotes.Load(); n != 0 {
+		t.Errorf("iStar quotes = %d, want 0 with no price check configured", n)
+	}
+}
+
+func TestTimedOutOrderUnknownToIStarFailsAfterGracePeriod(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			return nil, models.NotFoundError("Resource not found")
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{SyncFallbackFailAfter: 15 * time.Minute})
+
+	tests := []struct {
+		name       string
+		age        time.Duration
+		ownID      bool
+		wantFailed bool
+	}{
+		{"timed out within the grace period", time.Minute, true, false},
+		{"timed out past the grace period", time.Hour, true, true},
+		{"confirmed by iStar", time.Hour, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := storeStalePendingOrder(t, repo, tt.age)
+			if tt.ownID {
+				// Recorded after a timed-out sync create, keyed by client_order_id
+				if err := repo.SetIStarOrderID(context.Background(), order.ID.String(), order.ID.String()); err != nil {
+					t.Fatalf("SetIStarOrderID: %v", err)
+				}
+			}
+
+			got, err := svc.PollOrderStatus(context.Background(), order.ID.String())
+			stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+			if !tt.wantFailed {
+				wantAPIStatus(t, err, http.StatusNotFound)
+				if stored.Status != models.StatusPending {
+					t.Errorf("stored status = %s, want pending", stored.Status)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("PollOrderStatus: %v", err)
+			}
+			if got.Status != models.StatusFailed || stored.Status != models.StatusFailed {
+				t.Errorf("status = %s, stored %s; want failed", got.Status, stored.Status)
+			}
+			if stored.ErrorMessage == nil || !strings.Contains(*stored.ErrorMessage, "never arrived") {
+				t.Errorf("error message = %v, want the reason the order failed", stored.ErrorMessage)
+			}
+		})
+	}
+}
+
+func TestSplitByWeight(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int
+		weights []int
+		want    []int
+	}{
+		{"single recipient", 1234, []int{7}, []int{1234}},
+		{"even weights", 300, []int{1, 1, 1}, []int{100, 100, 100}},
+		{"uneven weights", 600, []int{1, 2, 3}, []int{100, 200, 300}},
+		{"remainder to the largest fractions", 1000, []int{1, 1, 1}, []int{334, 333, 333}},
+		{"remainder by size, not position", 1001, []int{2, 3, 5}, []int{200, 300, 501}},
+		{"ties go to the earlier item", 202, []int{1, 1, 1, 1}, []int{51, 51, 50, 50}},
+		{"small shares raised to the minimum", 1000, []int{1, 1, 98}, []int{50, 50, 900}},
+		{"minimum for everyone exactly", 150, []int{1, 5, 100}, []int{50, 50, 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitByWeight(tt.total, tt.weights)
+			if err != nil {
+				t.Fatalf("splitByWeight(%d, %v): %v", tt.total, tt.weights, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitByWeight(%d, %v) = %v, want %v", tt.total, tt.weights, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitByWeightRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int
+		weights []int
+	}{
+		{"no recipients", 100, nil},
+		{"zero weights", 100, []int{0, 0}},
+		{"one zero weight", 100, []int{1, 0}},
+		{"negative weight", 100, []int{3, -1}},
+		{"total below the minimum for everyone", 149, []int{1, 1, 1}},
+		{"share above the maximum", 2_000_100, []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := splitByWeight(tt.total, tt.weights)
+			wantAPIStatus(t, err, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestSplitByWeightSumsToTotal(t *testing.T) {
+	weights := []int{3, 1, 4, 1, 5, 9, 2, 6}
+	for total := 400; total <= 5000; total += 37 {
+		shares, err := splitByWeight(total, weights)
+		if err != nil {
+			t.Fatalf("splitByWeight(%d): %v", total, err)
+		}
+		sum := 0
+		for _, share := range shares {
+			if share < minStarQuantity {
+				t.Fatalf("splitByWeight(%d) = %v, a share is below the minimum", total, shares)
+			}
+			sum += share
+		}
+		if sum != total {
+			t.Fatalf("splitByWeight(%d) = %v, sums to %d", total, shares, sum)
+		}
+	}
+}
+
+func TestWeighBatchItems(t *testing.T) {
+	items := []models.BatchStarOrderItem{{Username: "alice_1", Weight: 1}, {Username: "bob_22", Weight: 3}}
+	req := models.BatchStarOrderRequest{TotalQuantity: 400, Items: items}
+
+	if err := weighBatchItems(&req); err != nil {
+		t.Fatalf("weighBatchItems: %v", err)
+	}
+	if req.Items[0].Quantity != 100 || req.Items[1].Quantity != 300 {
+		t.Errorf("quantities = %d, %d, want 100, 300", req.Items[0].Quantity, req.Items[1].Quantity)
+	}
+	if items[0].Quantity != 0 {
+		t.Error("weighBatchItems changed the caller's items")
+	}
+
+	mixed := models.BatchStarOrderRequest{TotalQuantity: 400, Items: []models.BatchStarOrderItem{{Username: "alice_1", Quantity: 100}}}
+	wantAPIStatus(t, weighBatchItems(&mixed), http.StatusBadRequest)
+	unweighted := models.BatchStarOrderRequest{Items: []models.BatchStarOrderItem{{Username: "alice_1", Quantity: 100, Weight: 2}}}
+	wantAPIStatus(t, weighBatchItems(&unweighted), http.StatusBadRequest)
+}
+
+func TestMapUpstreamStatus(t *testing.T) {
+	tests := []struct {
+		upstream string
+		want     models.OrderStatus
+		ok       bool
+	}{
+		{"pending", models.StatusPending, true},
+		{"completed", models.StatusCompleted, true},
+		{"failed", models.StatusFailed, true},
+		{"cancelled", models.StatusCancelled, true},
+		{"refunded", models.StatusRefunded, true},
+		{"processing", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := mapUpstreamStatus(tt.upstream)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("mapUpstreamStatus(%q) = %q, %v, want %q, %v", tt.upstream, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPollOrderStatusSkipsSettledOrders(t *testing.T) {
+	var lookups atomic.Int32
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			lookups.Add(1)
+			return &models.OrderStatusResponse{Status: "failed"}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	order := storeOrder(t, repo, "client-a", models.StatusCompleted)
+
+	got, err := svc.PollOrderStatus(context.Background(), order.ID.String())
+	if err != nil {
+		t.Fatalf("PollOrderStatus: %v", err)
+	}
+	if got.Status != models.StatusCompleted {
+		t.Errorf("status = %s, want completed", got.Status)
+	}
+	if n := lookups.Load(); n != 0 {
+		t.Errorf("iStar lookups = %d, want 0 for a settled order", n)
+	}
+}
+
+func TestPollOrderStatusAppliesUpstreamStatus(t *testing.T) {
+	txHash := "tx-1"
+	completedAt := "2026-01-02T03:04:05Z"
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			return &models.OrderStatusResponse{OrderID: id, Status: "completed", TxHash: &txHash, CompletedAt: &completedAt}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	id := order.ID.String()
+
+	if _, err := svc.PollOrderStatus(context.Background(), id); err != nil {
+		t.Fatalf("PollOrderStatus: %v", err)
+	}
+
+	stored, _ := repo.GetOrderByID(context.Background(), id)
+	if stored.Status != models.StatusCompleted || stored.TxHash == nil || *stored.TxHash != txHash || stored.CompletedAt == nil {
+		t.Errorf("stored order = %s, tx %v, completed %v, want completed with tx-1", stored.Status, stored.TxHash, stored.CompletedAt)
+	}
+	entries, _ := repo.ListAuditEntries(context.Background(), id)
+	if len(entries) != 1 || entries[0].Action != models.AuditOrderStatusChanged {
+		t.Errorf("audit entries = %+v, want one status change", entries)
+	}
+}
+
+func TestPollOrderStatusLeavesUnknownStatusesPending(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			return &models.OrderStatusResponse{OrderID: id, Status: "processing"}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+
+	got, err := svc.PollOrderStatus(context.Background(), order.ID.String())
+	if err != nil {
+		t.Fatalf("PollOrderStatus: %v", err)
+	}
+	if got.Status != models.StatusPending {
+		t.Errorf("status = %s, want pending", got.Status)
+	}
+}
+
+func TestCreateOrderCountsInMetrics(t *testing.T) {
+	var calls atomic.Int32
+	istar := &clientmock.IStarAPI{CreateStarOrderAsyncFunc: countingStarCreates(&calls)}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+	counter := metrics.OrdersCreatedTotal.WithLabelValues(string(models.OrderTypeStar), string(models.StatusPending))
+	before := testutil.ToFloat64(counter)
+
+	if _, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50)); err != nil {
+		t.Fatalf("CreateStarOrderAsync: %v", err)
+	}
+
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("orders_created_total{type=star,status=pending} grew by %v, want 1", got)
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	var cancelled []string
+	istar := &clientmock.IStarAPI{
+		CancelOrderFunc: func(ctx context.Context, id string) error {
+			cancelled = append(cancelled, id)
+			return nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	ctx := clientContext("client-a")
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	id := order.ID.String()
+
+	got, err := svc.CancelOrder(ctx, id)
+	if err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	if got.Status != models.StatusCancelled {
+		t.Errorf("status = %s, want cancelled", got.Status)
+	}
+	if len(cancelled) != 1 || cancelled[0] != order.IStarOrderID {
+		t.Errorf("iStar cancels = %v, want one for %s", cancelled, order.IStarOrderID)
+	}
+	stored, _ := repo.GetOrderByID(ctx, id)
+	if stored.Status != models.StatusCancelled {
+		t.Errorf("stored status = %s, want cancelled", stored.Status)
+	}
+	entries, _ := repo.ListAuditEntries(ctx, id)
+	if len(entries) != 1 || entries[0].Action != models.AuditOrderCancelled {
+		t.Errorf("audit entries = %+v, want one cancellation", entries)
+	}
+}
+
+func TestCancelOrderTooLate(t *testing.T) {
+	for _, status := range []models.OrderStatus{models.StatusCompleted, models.StatusFailed, models.StatusCancelled, models.StatusRefunded} {
+		t.Run(string(status), func(t *testing.T) {
+			var calls atomic.Int32
+			istar := &clientmock.IStarAPI{
+				CancelOrderFunc: func(ctx context.Context, id string) error {
+					calls.Add(1)
+					return nil
+				},
+			}
+			svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+			order := storeOrder(t, repo, "client-a", status)
+
+			_, err := svc.CancelOrder(clientContext("client-a"), order.ID.String())
+			wantAPIStatus(t, err, http.StatusBadRequest)
+			if n := calls.Load(); n != 0 {
+				t.Errorf("iStar was asked to cancel a %s order", status)
+			}
+		})
+	}
+}
+
+func TestCancelOrderRefusedByIStarStaysPending(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		CancelOrderFunc: func(ctx context.Context, id string) error {
+			return models.ConflictError("Order is already being processed")
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	ctx := clientContext("client-a")
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+
+	_, err := svc.CancelOrder(ctx, order.ID.String())
+	wantAPIStatus(t, err, http.StatusConflict)
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusPending {
+		t.Errorf("stored status = %s, want pending", stored.Status)
+	}
+}
+
+func TestBatchReportsEachItemSeparately(t *testing.T) {
+	var calls atomic.Int32
+	create := countingStarCreates(&calls)
+	istar := &clientmock.IStarAPI{
+		CreateStarOrderAsyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			if req.Username == "broke_wallet" {
+				return nil, models.ConflictError("Insufficient balance")
+			}
+			return create(ctx, req)
+		},
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+
+	resp, err := svc.CreateStarOrdersBatch(clientContext("client-a"), models.BatchStarOrderRequest{
+		WalletType: "ton",
+		Items: []models.BatchStarOrderItem{
+			{Username: "alice_1", RecipientHash: "hash-alice", Quantity: 50},
+			{Username: "broke_wallet", RecipientHash: "hash-broke", Quantity: 50},
+			{Username: "carol_3", RecipientHash: "hash-carol", Quantity: 10},
+			{Username: "dave_44", RecipientHash: "hash-dave", Quantity: 75},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateStarOrdersBatch: %v", err)
+	}
+
+	if resp.Succeeded != 2 || resp.Failed != 2 {
+		t.Errorf("succeeded %d, failed %d, want 2 and 2", resp.Succeeded, resp.Failed)
+	}
+	wantCodes := []string{"", models.CodeConflict, models.CodeValidation, ""}
+	for i, result := range resp.Results {
+		if result.Index != i || result.Code != wantCodes[i] || (result.Order != nil) != (wantCodes[i] == "") {
+			t.Errorf("result %d = %+v, want index %d with code %q", i, result, i, wantCodes[i])
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("successful iStar creates = %d, want 2", n)
+	}
+}
+
+func TestBatchBoundsConcurrentCreates(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	var calls atomic.Int32
+	create := countingStarCreates(&calls)
+	istar := &clientmock.IStarAPI{
+		CreateStarOrderAsyncFunc: func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return create(ctx, req)
+		},
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+
+	items := make([]models.BatchStarOrderItem, 4*batchOrderWorkers)
+	for i := range items {
+		items[i] = models.BatchStarOrderItem{Username: "user_" + strconv.Itoa(i), RecipientHash: "hash", Quantity: 50}
+	}
+	resp, err := svc.CreateStarOrdersBatch(clientContext("client-a"), models.BatchStarOrderRequest{WalletType: "ton", Items: items})
+	if err != nil {
+		t.Fatalf("CreateStarOrdersBatch: %v", err)
+	}
+
+	if resp.Succeeded != len(items) {
+		t.Errorf("succeeded = %d, want %d", resp.Succeeded, len(items))
+	}
+	if p := peak.Load(); p > batchOrderWorkers {
+		t.Errorf("peak concurrent creates = %d, want at most %d", p, batchOrderWorkers)
+	}
+	if p := peak.Load(); p < 2 {
+		t.Errorf("peak concurrent creates = %d, want items sent in parallel", p)
+	}
+}
+
+func TestBatchItemsDeriveIdempotencyKeys(t *testing.T) {
+	var calls atomic.Int32
+	istar := &clientmock.IStarAPI{CreateStarOrderAsyncFunc: countingStarCreates(&calls)}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	req := models.BatchStarOrderRequest{
+		WalletType:     "ton",
+		IdempotencyKey: "batch-1",
+		Items: []models.BatchStarOrderItem{
+			{Username: "alice_1", RecipientHash: "hash-alice", Quantity: 50},
+			{Username: "bob_22", RecipientHash: "hash-bob", Quantity: 60},
+		},
+	}
+	ctx := clientContext("client-a")
+
+	for range 2 {
+		if _, err := svc.CreateStarOrdersBatch(ctx, req); err != nil {
+			t.Fatalf("CreateStarOrdersBatch: %v", err)
+		}
+	}
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("iStar creates = %d, want 2: a retried batch must replay its items", n)
+	}
+	orders, _ := repo.ListOrders(ctx, models.OrderListQuery{Limit: 10})
+	var keys []string
+	for _, o := range orders {
+		keys = append(keys, o.IdempotencyKey)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"batch-1:0", "batch-1:1"}) {
+		t.Errorf("stored keys = %v, want batch-1:0 and batch-1:1", keys)
+	}
+}
+
+func TestMinimumAmountRejectsDustOrders(t *testing.T) {
+	var creates atomic.Int32
+	istar := &clientmock.IStarAPI{}
+	quotingStarCreates(istar, models.Amount(40))
+	istar.CreateStarOrderAsyncFunc = countingStarCreates(&creates)
+	minimum, _ := models.ParseAmount("0.5")
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{
+		MinAmountByWallet: map[string]models.Amount{"ton": minimum},
+	})
+
+	_, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50))
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != models.CodeAmountBelowMinimum {
+		t.Fatalf("err = %v, want %s", err, models.CodeAmountBelowMinimum)
+	}
+	if !strings.Contains(apiErr.Message, "0.5") {
+		t.Errorf("message = %q, want it to name the minimum", apiErr.Message)
+	}
+	if n := creates.Load(); n != 0 {
+		t.Errorf("iStar creates = %d, want 0", n)
+	}
+}
+
+func TestMinimumAmountAppliesPerWalletType(t *testing.T) {
+	tests := []struct {
+		name     string
+		minimums map[string]models.Amount
+	}{
+		{"at the minimum", map[string]models.Amount{"ton": models.Amount(40)}},
+		{"minimum for another wallet", map[string]models.Amount{"usdt": models.Amount(1000)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			istar := &clientmock.IStarAPI{}
+			quotingStarCreates(istar, models.Amount(40))
+			svc, _ := newTestOrderService(t, istar, config.OrderConfig{MinAmountByWallet: tt.minimums})
+
+			if _, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50)); err != nil {
+				t.Fatalf("CreateStarOrderAsync: %v, want the order accepted", err)
+			}
+		})
+	}
+}
+
+func TestMinimumAmountSkipsQuoteWithoutMinimum(t *testing.T) {
+	var creates atomic.Int32
+	istar := &clientmock.IStarAPI{CreateStarOrderAsyncFunc: countingStarCreates(&creates)}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{
+		MinAmountByWallet: map[string]models.Amount{"usdt": models.Amount(1000)},
+	})
+
+	// QuoteStarOrderFunc is unset, so a quote would fail the order
+	if _, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50)); err != nil {
+		t.Fatalf("CreateStarOrderAsync: %v, want no quote for a ton order", err)
+	}
+}
+
+func TestServiceCachesStopWithTheService(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	NewOrderService(ctx, repositories.NewInMemoryOrderRepository(), &clientmock.IStarAPI{}, config.OrderConfig{}, &workers, zap.NewNop())
+	cancel()
+	workers.Wait()
+}
+
+// failingStarSyncs quotes star orders and answers every sync create with a
+// failed order carrying reason, or no reason when it is empty
+func failingStarSyncs(istar *clientmock.IStarAPI, reason string) {
+	istar.QuoteStarOrderFunc = func(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+		return &models.OrderQuoteResponse{WalletType: req.WalletType, Amount: models.Amount(40)}, nil
+	}
+	istar.CreateStarOrderSyncFunc = func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+		resp := &models.StarOrderResponse{
+			OrderID:   "istar-1",
+			Status:    "failed",
+			Quantity:  req.Quantity,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		}
+		if reason != "" {
+			resp.Error = &reason
+		}
+		return resp, nil
+	}
+}
+
+func TestFailedSyncOrderKeepsItsReason(t *testing.T) {
+	tests := []struct {
+		name, upstream, want string
+	}{
+		{"with a reason", "Recipient cannot receive gifts", "Recipient cannot receive gifts"},
+		{"without a reason", "", "Order failed at iStar without a reason"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			istar := &clientmock.IStarAPI{}
+			failingStarSyncs(istar, tt.upstream)
+			svc, _ := newTestOrderService(t, istar, config.OrderConfig{})
+			ctx := clientContext("client-a")
+
+			created, err := svc.CreateStarOrderSync(ctx, starRequest("", 50))
+			if err != nil {
+				t.Fatalf("CreateStarOrderSync: %v", err)
+			}
+			got, err := svc.GetOrder(ctx, created.ID.String())
+			if err != nil {
+				t.Fatalf("GetOrder: %v", err)
+			}
+			if got.Status != models.StatusFailed || got.ErrorMessage == nil || *got.ErrorMessage != tt.want {
+				t.Fatalf("order = %s %v, want failed with %q", got.Status, got.ErrorMessage, tt.want)
+			}
+
+			body, _ := json.Marshal(got)
+			var decoded struct {
+				ErrorMessage string `json:"error_message"`
+			}
+			json.Unmarshal(body, &decoded)
+			if decoded.ErrorMessage != tt.want {
+				t.Errorf("error_message = %q, want %q", decoded.ErrorMessage, tt.want)
+			}
+		})
+	}
+}
+
+func TestResyncOrderCompletesStuckPendingOrder(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			return &models.OrderStatusResponse{OrderID: id, Status: "completed"}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+
+	got, err := svc.ResyncOrder(clientContext("client-a"), order.ID.String())
+	if err != nil {
+		t.Fatalf("ResyncOrder: %v", err)
+	}
+	if got.Status != models.StatusCompleted {
+		t.Errorf("returned status = %s, want completed", got.Status)
+	}
+	stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+	if stored.Status != models.StatusCompleted {
+		t.Errorf("stored status = %s, want completed", stored.Status)
+	}
+}
+
+func TestResyncOrderNotFound(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			return nil, models.NotFoundError("Resource not found")
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+
+	tests := []struct {
+		name, clientID, orderID, want string
+	}{
+		{"unknown locally", "client-a", uuid.NewString(), "Order not found"},
+		{"another client's order", "client-b", order.ID.String(), "Order not found"},
+		{"unknown to iStar", "client-a", order.ID.String(), "Order exists locally but is unknown to iStar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.ResyncOrder(clientContext(tt.clientID), tt.orderID)
+
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != tt.want {
+				t.Errorf("err = %v, want 404 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func premiumRequest(key string, months int) models.CreatePremiumOrderRequest {
+	return models.CreatePremiumOrderRequest{
+		Username:       "alice_1",
+		RecipientHash:  "hash-alice",
+		Months:         months,
+		WalletType:     "ton",
+		IdempotencyKey: key,
+	}
+}
+
+func TestCreateOrderPropagatesIStarErrors(t *testing.T) {
+	upstreamErr := models.RateLimitedError("iStar rate limit exceeded")
+	quote := &models.OrderQuoteResponse{WalletType: "ton", Amount: models.Amount(40)}
+	istar := &clientmock.IStarAPI{
+		QuoteStarOrderFunc: func(context.Context, models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+			return quote, nil
+		},
+		QuotePremiumOrderFunc: func(context.Context, models.CreatePremiumOrderRequest) (*models.OrderQuoteResponse, error) {
+			return quote, nil
+		},
+		CreateStarOrderAsyncFunc: func(context.Context, models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			return nil, upstreamErr
+		},
+		CreateStarOrderSyncFunc: func(context.Context, models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+			return nil, upstreamErr
+		},
+		CreatePremiumOrderAsyncFunc: func(context.Context, models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error) {
+			return nil, upstreamErr
+		},
+		CreatePremiumOrderSyncFunc: func(context.Context, models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error) {
+			return nil, upstreamErr
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	ctx := clientContext("client-a")
+
+	creates := map[string]func() (*models.Order, error){
+		"star async":    func() (*models.Order, error) { return svc.CreateStarOrderAsync(ctx, starRequest("", 50)) },
+		"star sync":     func() (*models.Order, error) { return svc.CreateStarOrderSync(ctx, starRequest("", 50)) },
+		"premium async": func() (*models.Order, error) { return svc.CreatePremiumOrderAsync(ctx, premiumRequest("", 3)) },
+		"premium sync":  func() (*models.Order, error) { return svc.CreatePremiumOrderSync(ctx, premiumRequest("", 3)) },
+	}
+	for name, create := range creates {
+		t.Run(name, func(t *testing.T) {
+			order, err := create()
+			if order != nil || !errors.Is(err, upstreamErr) {
+				t.Errorf("got %v, %v, want iStar's error returned as is", order, err)
+			}
+		})
+	}
+
+	if orders, _ := repo.ListOrders(ctx, models.OrderListQuery{Limit: 10}); len(orders) != 0 {
+		t.Errorf("stored %d orders, want none for failed creates", len(orders))
+	}
+}
+
+func TestCreateOrderPropagatesQuoteErrors(t *testing.T) {
+	quoteErr := models.ValidationError("Invalid request parameters")
+	istar := &clientmock.IStarAPI{
+		QuoteStarOrderFunc: func(context.Context, models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+			return nil, quoteErr
+		},
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{MaxOrderAmount: models.Amount(1000)})
+
+	if _, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50)); !errors.Is(err, quoteErr) {
+		t.Errorf("err = %v, want the quote error returned as is", err)
+	}
+}
+
+// countingStarQuotes quotes every star order as quote-<n> at amount, expiring
+// at upstreamExpiry when it is set, and counts the calls
+func countingStarQuotes(calls *atomic.Int32, amount models.Amount, upstreamExpiry string) func(context.Context, models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+	return func(ctx context.Context, req models.CreateStarOrderRequest) (*models.OrderQuoteResponse, error) {
+		n := calls.Add(1)
+		return &models.OrderQuoteResponse{QuoteID: "quote-" + strconv.Itoa(int(n)), Amount: amount, ExpiresAt: upstreamExpiry}, nil
+	}
+}
+
+func TestQuoteStarOrder(t *testing.T) {
+	soon := time.Now().Add(30 * time.Second).UTC().Truncate(time.Second)
+	tests := []struct {
+		name           string
+		upstreamExpiry string
+		want           time.Time
+	}{
+		{"capped at the quote TTL", "", time.Now().Add(2 * time.Minute)},
+		{"upstream expiry when sooner", soon.Format(time.RFC3339), soon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var quotes, creates atomic.Int32
+			istar := &clientmock.IStarAPI{
+				QuoteStarOrderFunc:       countingStarQuotes(&quotes, models.Amount(40), tt.upstreamExpiry),
+				CreateStarOrderAsyncFunc: countingStarCreates(&creates),
+			}
+			svc, _ := newTestOrderService(t, istar, config.OrderConfig{QuoteTTL: 2 * time.Minute})
+
+			q, err := svc.QuoteStarOrder(clientContext("client-a"), starRequest("", 50))
+			if err != nil {
+				t.Fatalf("QuoteStarOrder: %v", err)
+			}
+			if q.QuoteID != "quote-1" || q.Amount != models.Amount(40) || q.WalletType != "ton" {
+				t.Errorf("quote = %+v, want quote-1 for 40 on ton", q)
+			}
+			expiresAt, err := time.Parse(time.RFC3339, q.ExpiresAt)
+			if err != nil || expiresAt.Sub(tt.want).Abs() > 2*time.Second {
+				t.Errorf("expires_at = %q, want about %s", q.ExpiresAt, tt.want.Format(time.RFC3339))
+			}
+			if n := creates.Load(); n != 0 {
+				t.Errorf("iStar creates = %d, want a quote to place no order", n)
+			}
+		})
+	}
+}
+
+func TestOrderWithQuoteIDUsesTheLockedQuote(t *testing.T) {
+	var quotes, creates atomic.Int32
+	istar := &clientmock.IStarAPI{
+		QuoteStarOrderFunc:       countingStarQuotes(&quotes, models.Amount(40), ""),
+		CreateStarOrderAsyncFunc: countingStarCreates(&creates),
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{QuoteTTL: time.Minute, MaxOrderAmount: models.Amount(1000)})
+	ctx := clientContext("client-a")
+	q, err := svc.QuoteStarOrder(ctx, starRequest("", 50))
+	if err != nil {
+		t.Fatalf("QuoteStarOrder: %v", err)
+	}
+
+	req := starRequest("", 50)
+	req.QuoteID = q.QuoteID
+	if _, err := svc.CreateStarOrderAsync(ctx, req); err != nil {
+		t.Fatalf("CreateStarOrderAsync: %v", err)
+	}
+	if n := quotes.Load(); n != 1 {
+		t.Errorf("iStar quotes = %d, want the order checked against the locked quote", n)
+	}
+}
+
+func TestOrderWithUnusableQuoteIDIsRejected(t *testing.T) {
+	var quotes, creates atomic.Int32
+	istar := &clientmock.IStarAPI{
+		QuoteStarOrderFunc:       countingStarQuotes(&quotes, models.Amount(40), ""),
+		CreateStarOrderAsyncFunc: countingStarCreates(&creates),
+	}
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{QuoteTTL: 20 * time.Millisecond})
+	ctx := clientContext("client-a")
+	q, err := svc.QuoteStarOrder(ctx, starRequest("", 50))
+	if err != nil {
+		t.Fatalf("QuoteStarOrder: %v", err)
+	}
+
+	t.Run("wrong order type", func(t *testing.T) {
+		req := premiumRequest("", 3)
+		req.QuoteID = q.QuoteID
+		_, err := svc.CreatePremiumOrderAsync(ctx, req)
+		wantAPIStatus(t, err, http.StatusBadRequest)
+	})
+	t.Run("unknown", func(t *testing.T) {
+		req := starRequest("", 50)
+		req.QuoteID = "quote-unknown"
+		_, err := svc.CreateStarOrderAsync(ctx, req)
+		wantAPIStatus(t, err, http.StatusBadRequest)
+	})
+	t.Run("expired", func(t *testing.T) {
+		time.Sleep(30 * time.Millisecond)
+		req := starRequest("", 50)
+		req.QuoteID = q.QuoteID
+		_, err := svc.CreateStarOrderAsync(ctx, req)
+		wantAPIStatus(t, err, http.StatusBadRequest)
+	})
+	if n := creates.Load(); n != 0 {
+		t.Errorf("iStar creates = %d, want 0", n)
+	}
+}
+
+// seedOrders stores n orders for clientID, created in pairs that share a
+// timestamp so paging has to break ties on the id
+func seedOrders(t *testing.T, repo repositories.OrderRepository, clientID string, n int) map[uuid.UUID]bool {
+	t.Helper()
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ids := make(map[uuid.UUID]bool, n)
+	for i := range n {
+		createdAt := base.Add(time.Duration(i/2) * time.Minute)
+		order := &models.Order{
+			ID:         uuid.New(),
+			Type:       models.OrderTypeStar,
+			Status:     models.StatusPending,
+			Username:   "alice_1",
+			WalletType: "ton",
+			CreatedAt:  createdAt,
+			UpdatedAt:  createdAt,
+			ClientID:   clientID,
+		}
+		order.IStarOrderID = "istar-" + order.ID.String()
+		if err := repo.CreateOrder(context.Background(), order); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		ids[order.ID] = true
+	}
+	return ids
+}
+
+func TestListOrdersCursorPagesWithoutDuplicatesOrGaps(t *testing.T) {
+	svc, repo := newTestOrderService(t, &clientmock.IStarAPI{}, config.OrderConfig{})
+	want := seedOrders(t, repo, "client-a", 23)
+	seedOrders(t, repo, "client-b", 4)
+	ctx := clientContext("client-a")
+
+	seen := make(map[uuid.UUID]bool)
+	var previous *models.Order
+	q := models.OrderListQuery{Limit: 5}
+	for pages := 1; ; pages++ {
+		resp, err := svc.ListOrders(ctx, q)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		for _, o := range resp.Orders {
+			if seen[o.ID] {
+				t.Fatalf("page %d repeats order %s", pages, o.ID)
+			}
+			seen[o.ID] = true
+			if previous != nil && (o.CreatedAt.After(previous.CreatedAt) ||
+				(o.CreatedAt.Equal(previous.CreatedAt) && bytes.Compare(o.ID[:], previous.ID[:]) > 0)) {
+				t.Fatalf("page %d: order %s is out of (created_at, id) descending order", pages, o.ID)
+			}
+			previous = o
+		}
+		if resp.NextCursor == "" {
+			if pages != 5 {
+				t.Errorf("pages = %d, want 5 for 23 orders at 5 a page", pages)
+			}
+			break
+		}
+		if q.After, err = models.DecodeOrderCursor(resp.NextCursor); err != nil {
+			t.Fatalf("page %d next_cursor: %v", pages, err)
+		}
+	}
+
+	if len(seen) != len(want) {
+		t.Errorf("paged through %d orders, want %d", len(seen), len(want))
+	}
+	for id := range want {
+		if !seen[id] {
+			t.Errorf("order %s was skipped", id)
+		}
+	}
+}
+
+func TestListOrdersOffsetMatchesCursorPages(t *testing.T) {
+	svc, repo := newTestOrderService(t, &clientmock.IStarAPI{}, config.OrderConfig{})
+	seedOrders(t, repo, "client-a", 12)
+	ctx := clientContext("client-a")
+
+	first, err := svc.ListOrders(ctx, models.OrderListQuery{Limit: 5})
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	after, _ := models.DecodeOrderCursor(first.NextCursor)
+	byCursor, err := svc.ListOrders(ctx, models.OrderListQuery{Limit: 5, After: after})
+	if err != nil {
+		t.Fatalf("cursor page: %v", err)
+	}
+	byOffset, err := svc.ListOrders(ctx, models.OrderListQuery{Limit: 5, Offset: 5})
+	if err != nil {
+		t.Fatalf("offset page: %v", err)
+	}
+
+	for i := range byCursor.Orders {
+		if byCursor.Orders[i].ID != byOffset.Orders[i].ID {
+			t.Fatalf("row %d: cursor page has %s, offset page has %s", i, byCursor.Orders[i].ID, byOffset.Orders[i].ID)
+		}
+	}
+	if byOffset.Offset != 5 || byCursor.Offset != 0 || byOffset.Total != 12 {
+		t.Errorf("offset = %d and %d, total %d, want 5, 0 and 12", byOffset.Offset, byCursor.Offset, byOffset.Total)
+	}
+}
+
+// storeStalePendingOrder stores a pending order created age ago
+func storeStalePendingOrder(t *testing.T, repo repositories.OrderRepository, age time.Duration) *models.Order {
+	t.Helper()
+	createdAt := time.Now().Add(-age)
+	order := &models.Order{
+		ID:         uuid.New(),
+		Type:       models.OrderTypeStar,
+		Status:     models.StatusPending,
+		Username:   "alice_1",
+		Amount:     models.Amount(100),
+		WalletType: "ton",
+		CreatedAt:  createdAt,
+		UpdatedAt:  createdAt,
+		ClientID:   "client-a",
+	}
+	order.IStarOrderID = "istar-" + order.ID.String()
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	return order
+}
+
+func TestReconcilePendingAppliesMixedUpstreamStatuses(t *testing.T) {
+	upstream := make(map[string]string)
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			status, ok := upstream[id]
+			if !ok {
+				return nil, errors.New("connection reset")
+			}
+			return &models.OrderStatusResponse{OrderID: id, Status: status}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+
+	completed := storeStalePendingOrder(t, repo, time.Hour)
+	upstream[completed.IStarOrderID] = "completed"
+	failed := storeStalePendingOrder(t, repo, time.Hour)
+	upstream[failed.IStarOrderID] = "failed"
+	stillPending := storeStalePendingOrder(t, repo, time.Hour)
+	upstream[stillPending.IStarOrderID] = "pending"
+	unreachable := storeStalePendingOrder(t, repo, time.Hour)
+	fresh := storeStalePendingOrder(t, repo, time.Minute)
+	upstream[fresh.IStarOrderID] = "completed"
+
+	reconciled, failures, err := svc.ReconcilePending(context.Background(), 30*time.Minute)
+	if err != nil {
+		t.Fatalf("ReconcilePending: %v", err)
+	}
+	if reconciled != 2 || failures != 1 {
+		t.Errorf("ReconcilePending = %d reconciled, %d failed, want 2 and 1", reconciled, failures)
+	}
+
+	want := map[*models.Order]models.OrderStatus{
+		completed:    models.StatusCompleted,
+		failed:       models.StatusFailed,
+		stillPending: models.StatusPending,
+		unreachable:  models.StatusPending,
+		fresh:        models.StatusPending,
+	}
+	for order, status := range want {
+		stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+		if stored.Status != status {
+			t.Errorf("order %s status = %s, want %s", order.IStarOrderID, stored.Status, status)
+		}
+	}
+}
+
+func TestReconcilePendingSkipsOrdersPolledElsewhere(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			return &models.OrderStatusResponse{OrderID: id, Status: "completed"}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	busy := storeStalePendingOrder(t, repo, time.Hour)
+	storeStalePendingOrder(t, repo, time.Hour)
+	svc.polling.Store(busy.ID.String(), struct{}{})
+
+	reconciled, failures, err := svc.ReconcilePending(context.Background(), 30*time.Minute)
+	if err != nil {
+		t.Fatalf("ReconcilePending: %v", err)
+	}
+	if reconciled != 1 || failures != 0 {
+		t.Errorf("ReconcilePending = %d reconciled, %d failed, want 1 and 0", reconciled, failures)
+	}
+	stored, _ := repo.GetOrderByID(context.Background(), busy.ID.String())
+	if stored.Status != models.StatusPending {
+		t.Errorf("order being polled elsewhere = %s, want it left pending", stored.Status)
+	}
+}
+
+func TestGetOrderDescribesOnChainTransaction(t *testing.T) {
+	var verifications atomic.Int32
+	istar := &clientmock.IStarAPI{
+		TransactionURLFunc: func(w models.WalletType, txHash string) string {
+			return "https://explorer.example.com/" + string(w) + "/" + txHash
+		},
+		VerifyTransactionFunc: func(ctx context.Context, w models.WalletType, txHash string) (bool, error) {
+			verifications.Add(1)
+			return true, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	ctx := clientContext("client-a")
+
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	txHash := "0xabc"
+	if err := repo.UpdateOrderStatus(context.Background(), order.ID.String(), models.StatusCompleted, &txHash, nil, nil); err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+
+	for range 2 {
+		got, err := svc.GetOrder(ctx, order.ID.String())
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		if got.TxExplorerURL != "https://explorer.example.com/ton/0xabc" {
+			t.Errorf("TxExplorerURL = %q, want the TON explorer link", got.TxExplorerURL)
+		}
+		if got.TxVerified == nil || !*got.TxVerified {
+			t.Errorf("TxVerified = %v, want true", got.TxVerified)
+		}
+	}
+	if n := verifications.Load(); n != 1 {
+		t.Errorf("explorer was asked %d times, want a confirmed hash checked once", n)
+	}
+}
+
+func TestGetOrderSkipsOffChainTransaction(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		TransactionURLFunc: func(w models.WalletType, txHash string) string {
+			t.Errorf("TransactionURL called for a %s order", w)
+			return ""
+		},
+		VerifyTransactionFunc: func(ctx context.Context, w models.WalletType, txHash string) (bool, error) {
+			t.Errorf("VerifyTransaction called for a %s order", w)
+			return false, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	txHash := "ledger-42"
+	order := &models.Order{
+		ID:         uuid.New(),
+		Type:       models.OrderTypeStar,
+		Status:     models.StatusCompleted,
+		Username:   "alice_1",
+		WalletType: models.WalletInternal,
+		TxHash:     &txHash,
+		CreatedAt:  time.Now(),
+		ClientID:   "client-a",
+	}
+	if err := repo.CreateOrder(context.Background(), order); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	got, err := svc.GetOrder(clientContext("client-a"), order.ID.String())
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if got.TxExplorerURL != "" || got.TxVerified != nil {
+		t.Errorf("off-chain order got explorer URL %q and verified %v, want neither", got.TxExplorerURL, got.TxVerified)
+	}
+}
+
+func TestOrderAuditRecordsCreateAndStatusChange(t *testing.T) {
+	var calls atomic.Int32
+	istar := &clientmock.IStarAPI{CreateStarOrderAsyncFunc: countingStarCreates(&calls)}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	clientID := middleware.HashAPIKey("client-key")
+	ctx := requestctx.WithRequestID(clientContext(clientID), "req-create")
+
+	order, err := svc.CreateStarOrderAsync(ctx, starRequest("key-1", 50))
+	if err != nil {
+		t.Fatalf("CreateStarOrderAsync: %v", err)
+	}
+	id := order.ID.String()
+
+	webhooks := NewWebhookService(repo, UnknownEventIgnore, 0, time.Second, zap.NewNop())
+	if err := webhooks.ProcessWebhook(context.Background(), orderWebhook("evt-1", order.IStarOrderID, "completed")); err != nil {
+		t.Fatalf("ProcessWebhook: %v", err)
+	}
+
+	audit, err := svc.GetOrderAudit(ctx, id)
+	if err != nil {
+		t.Fatalf("GetOrderAudit: %v", err)
+	}
+	if len(audit.Entries) != 2 {
+		t.Fatalf("audit entries = %+v, want a create and a status change", audit.Entries)
+	}
+
+	created, changed := audit.Entries[0], audit.Entries[1]
+	if created.Action != models.AuditOrderCreated || created.FromStatus != "" || created.ToStatus != models.StatusPending {
+		t.Errorf("first entry = %s %q -> %q, want order.created -> pending", created.Action, created.FromStatus, created.ToStatus)
+	}
+	if created.ClientID != clientID || created.CorrelationID != "req-create" {
+		t.Errorf("create entry client %q, correlation %q; want the hashed key and req-create", created.ClientID, created.CorrelationID)
+	}
+	if changed.Action != models.AuditOrderStatusChanged || changed.FromStatus != models.StatusPending || changed.ToStatus != models.StatusCompleted {
+		t.Errorf("second entry = %s %q -> %q, want order.status_changed pending -> completed", changed.Action, changed.FromStatus, changed.ToStatus)
+	}
+	if changed.ClientID != "" {
+		t.Errorf("webhook entry client = %q, want none for a change made by iStar", changed.ClientID)
+	}
+	for _, entry := range audit.Entries {
+		if entry.OrderID != id || strings.Contains(entry.ClientID, "client-key") {
+			t.Errorf("entry %+v is for another order or holds the plain API key", entry)
+		}
+	}
+}
+
+func TestBalanceCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		checkBalance  bool
+		balance       *models.WalletBalance
+		balanceErr    error
+		wantRejection bool
+	}{
+		{"sufficient", true, &models.WalletBalance{WalletType: "ton", Currency: "TON", Available: models.Amount(50)}, nil, false},
+		{"exactly enough", true, &models.WalletBalance{WalletType: "ton", Currency: "TON", Available: models.Amount(40)}, nil, false},
+		{"insufficient", true, &models.WalletBalance{WalletType: "ton", Currency: "TON", Available: models.Amount(30)}, nil, true},
+		{"balance of another wallet", true, &models.WalletBalance{WalletType: "usdt", Currency: "USDT", Available: models.Amount(0)}, nil, false},
+		{"balance lookup fails", true, nil, errors.New("connection reset"), false},
+		{"check disabled", false, &models.WalletBalance{WalletType: "ton", Currency: "TON", Available: models.Amount(0)}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var creates, lookups atomic.Int32
+			istar := &clientmock.IStarAPI{
+				GetWalletBalanceFunc: func(context.Context) (*models.WalletBalance, error) {
+					lookups.Add(1)
+					return tt.balance, tt.balanceErr
+				},
+			}
+			quotingStarCreates(istar, models.Amount(40))
+			created := istar.CreateStarOrderAsyncFunc
+			istar.CreateStarOrderAsyncFunc = func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+				creates.Add(1)
+				return created(ctx, req)
+			}
+			svc, _ := newTestOrderService(t, istar, config.OrderConfig{CheckBalance: tt.checkBalance})
+
+			_, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50))
+
+			if !tt.wantRejection {
+				if err != nil || creates.Load() != 1 {
+					t.Fatalf("err = %v after %d creates, want the order placed", err, creates.Load())
+				}
+				if !tt.checkBalance && lookups.Load() != 0 {
+					t.Errorf("balance looked up %d times with the check disabled", lookups.Load())
+				}
+				return
+			}
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || !strings.Contains(apiErr.Message, "Insufficient balance") {
+				t.Fatalf("err = %v, want an insufficient balance validation error", err)
+			}
+			if n := creates.Load(); n != 0 {
+				t.Errorf("iStar creates = %d, want 0", n)
+			}
+		})
+	}
+}
+
+func TestBalanceAndMinimumShareOneQuote(t *testing.T) {
+	var quotes atomic.Int32
+	istar := &clientmock.IStarAPI{
+		GetWalletBalanceFunc: func(context.Context) (*models.WalletBalance, error) {
+			return &models.WalletBalance{WalletType: "ton", Available: models.Amount(1000)}, nil
+		},
+	}
+	quotingStarCreates(istar, models.Amount(40))
+	istar.QuoteStarOrderFunc = countingStarQuotes(&quotes, models.Amount(40), "")
+	svc, _ := newTestOrderService(t, istar, config.OrderConfig{
+		CheckBalance:      true,
+		MinAmountByWallet: map[string]models.Amount{"ton": models.Amount(10)},
+	})
+
+	if _, err := svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50)); err != nil {
+		t.Fatalf("CreateStarOrderAsync: %v", err)
+	}
+	if n := quotes.Load(); n != 1 {
+		t.Errorf("quotes = %d, want the minimum and balance checks to share one", n)
+	}
+}
+
+func TestAdminUpdateOrderCorrectsStuckOrder(t *testing.T) {
+	svc, repo := newTestOrderService(t, &clientmock.IStarAPI{}, config.OrderConfig{})
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	id := order.ID.String()
+
+	completed := models.StatusCompleted
+	txHash := "tx-fixed"
+	completedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	req := models.AdminUpdateOrderRequest{Status: &completed, TxHash: &txHash, CompletedAt: &completedAt, Reason: "confirmed on chain"}
+
+	got, err := svc.AdminUpdateOrder(context.Background(), id, req, "ops@example.com")
+	if err != nil {
+		t.Fatalf("AdminUpdateOrder: %v", err)
+	}
+	if got.Status != models.StatusCompleted || got.TxHash == nil || *got.TxHash != txHash {
+		t.Errorf("returned order = %s with tx %v, want completed with tx-fixed", got.Status, got.TxHash)
+	}
+	stored, _ := repo.GetOrderByID(context.Background(), id)
+	if stored.Status != models.StatusCompleted || stored.CompletedAt == nil || !stored.CompletedAt.Equal(completedAt) {
+		t.Errorf("stored order = %s completed at %v, want completed at %v", stored.Status, stored.CompletedAt, completedAt)
+	}
+
+	entries, _ := repo.ListAuditEntries(context.Background(), id)
+	if len(entries) != 1 {
+		t.Fatalf("audit entries = %+v, want one correction", entries)
+	}
+	if e := entries[0]; e.Action != models.AuditOrderCorrected || e.Actor != "ops@example.com" ||
+		e.FromStatus != models.StatusPending || e.ToStatus != models.StatusCompleted {
+		t.Errorf("audit entry = %+v, want ops@example.com correcting pending to completed", e)
+	}
+}
+
+func TestAdminUpdateOrderRefusesIllegalCorrections(t *testing.T) {
+	pending, refunded, bogus := models.StatusPending, models.StatusRefunded, models.OrderStatus("lost")
+	tests := []struct {
+		name string
+		req  models.AdminUpdateOrderRequest
+		want string
+	}{
+		{"completed back to pending", models.AdminUpdateOrderRequest{Status: &pending}, "Order in status completed cannot move to pending"},
+		{"refund outside the refund flow", models.AdminUpdateOrderRequest{Status: &refunded}, "Use the refund endpoint to refund an order"},
+		{"unknown status", models.AdminUpdateOrderRequest{Status: &bogus}, "Invalid status lost"},
+		{"nothing to change", models.AdminUpdateOrderRequest{Reason: "just looking"}, "No fields to update"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := newTestOrderService(t, &clientmock.IStarAPI{}, config.OrderConfig{})
+			order := storeOrder(t, repo, "client-a", models.StatusCompleted)
+			id := order.ID.String()
+
+			_, err := svc.AdminUpdateOrder(context.Background(), id, tt.req, "ops@example.com")
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != tt.want {
+				t.Fatalf("AdminUpdateOrder = %v, want 400 %q", err, tt.want)
+			}
+
+			stored, _ := repo.GetOrderByID(context.Background(), id)
+			if stored.Status != models.StatusCompleted {
+				t.Errorf("status = %s, want it left completed", stored.Status)
+			}
+			if entries, _ := repo.ListAuditEntries(context.Background(), id); len(entries) != 0 {
+				t.Errorf("audit entries = %d, want none for a refused correction", len(entries))
+			}
+		})
+	}
+}
+
+func TestMaxOrderAmount(t *testing.T) {
+	limit := models.AmountFromFloat(10)
+	tests := []struct {
+		name    string
+		max     models.Amount
+		amount  models.Amount
+		premium bool
+		wantErr bool
+	}{
+		{"star below the cap", limit, models.AmountFromFloat(9.5), false, false},
+		{"star at the cap", limit, limit, false, false},
+		{"star above the cap", limit, limit + 1, false, true},
+		{"premium below the cap", limit, models.AmountFromFloat(9.5), true, false},
+		{"premium above the cap", limit, models.AmountFromFloat(250), true, true},
+		{"no cap", 0, models.AmountFromFloat(1_000_000), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var creates atomic.Int32
+			istar := &clientmock.IStarAPI{}
+			quotingStarCreates(istar, tt.amount)
+			istar.QuotePremiumOrderFunc = func(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.OrderQuoteResponse, error) {
+				return &models.OrderQuoteResponse{WalletType: req.WalletType, Amount: tt.amount}, nil
+			}
+			istar.CreatePremiumOrderAsyncFunc = func(ctx context.Context, req models.CreatePremiumOrderRequest) (*models.PremiumOrderResponse, error) {
+				creates.Add(1)
+				return &models.PremiumOrderResponse{
+					OrderID:   "istar-" + req.ClientOrderID,
+					Months:    req.Months,
+					Amount:    tt.amount,
+					CreatedAt: time.Now().UTC().Format(time.RFC3339),
+				}, nil
+			}
+			createStar := istar.CreateStarOrderAsyncFunc
+			istar.CreateStarOrderAsyncFunc = func(ctx context.Context, req models.CreateStarOrderRequest) (*models.StarOrderResponse, error) {
+				creates.Add(1)
+				return createStar(ctx, req)
+			}
+			svc, _ := newTestOrderService(t, istar, config.OrderConfig{MaxOrderAmount: tt.max})
+
+			var err error
+			if tt.premium {
+				_, err = svc.CreatePremiumOrderAsync(clientContext("client-a"), premiumRequest("", 3))
+			} else {
+				_, err = svc.CreateStarOrderAsync(clientContext("client-a"), starRequest("", 50))
+			}
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("create: %v", err)
+				}
+				return
+			}
+			var apiErr *models.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != models.CodeValidation || !strings.Contains(apiErr.Message, "exceeds the maximum of 10") {
+				t.Fatalf("err = %v, want a validation error naming the cap", err)
+			}
+			if n := creates.Load(); n != 0 {
+				t.Errorf("iStar creates = %d, want the order refused before reaching iStar", n)
+			}
+		})
+	}
+}
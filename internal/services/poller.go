@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// pollBatchSize bounds how many pending orders are reconciled per tick
+const pollBatchSize = 100
+
+// OrderStatusPoller periodically reconciles pending orders whose webhook may
+// have been missed by asking iStar for their current status.
+type OrderStatusPoller struct {
+	orderService OrderService
+	repo         repositories.OrderRepository
+	interval     time.Duration
+	staleAfter   time.Duration
+	logger       *zap.Logger
+}
+
+// NewOrderStatusPoller initializes a poller that runs every interval and picks
+// up orders that have been pending for longer than staleAfter
+func NewOrderStatusPoller(orderService OrderService, repo repositories.OrderRepository, interval, staleAfter time.Duration, logger *zap.Logger) *OrderStatusPoller {
+	return &OrderStatusPoller{
+		orderService: orderService,
+		repo:         repo,
+		interval:     interval,
+		staleAfter:   staleAfter,
+		logger:       logger.Named("order_poller"),
+	}
+}
+
+// Run blocks, polling until ctx is cancelled
+func (p *OrderStatusPoller) Run(ctx context.Context) {
+	p.logger.Info("Order status poller started",
+		zap.Duration("interval", p.interval),
+		zap.Duration("stale_after", p.staleAfter))
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("Order status poller stopped")
+			return
+		case <-ticker.C:
+			p.pollOnce(ctx)
+		}
+	}
+}
+
+// pollOnce reconciles a single batch of stale pending orders
+func (p *OrderStatusPoller) pollOnce(ctx context.Context) {
+	orders, err := p.repo.ListPendingOrders(ctx, time.Now().Add(-p.staleAfter), pollBatchSize)
+	if err != nil {
+		p.logger.Error("Failed to list pending orders", zap.Error(err))
+		return
+	}
+
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			return
+		}
+		_, err := p.orderService.PollOrderStatus(ctx, order.ID.String())
+		if isConflict(err) {
+			// Another replica or an admin resync has it
+			continue
+		}
+		if err != nil {
+			p.logger.Warn("Failed to reconcile order", zap.String("order_id", order.ID.String()), zap.Error(err))
+		}
+	}
+
+	if len(orders) > 0 {
+		p.logger.Info("Reconciled pending orders", zap.Int("count", len(orders)))
+	}
+}
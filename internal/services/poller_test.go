@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/config"
+	"github.com/hulupay/istar-api/internal/client/clientmock"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// newReplica returns an order service over repo, standing in for another API
+// replica that shares the database
+func newReplica(t *testing.T, repo repositories.OrderRepository, istar *clientmock.IStarAPI) OrderService {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	t.Cleanup(func() {
+		cancel()
+		workers.Wait()
+	})
+	return NewOrderService(ctx, repo, istar, config.OrderConfig{}, &workers, zap.NewNop())
+}
+
+func TestConcurrentPollersProcessEachOrderOnce(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			mu.Lock()
+			fetches[id]++
+			mu.Unlock()
+			// Keep the lock held long enough for the other loop to reach the order
+			time.Sleep(2 * time.Millisecond)
+			return &models.OrderStatusResponse{OrderID: id, Status: "completed"}, nil
+		},
+	}
+	repo := repositories.NewInMemoryOrderRepository()
+	var orders []*models.Order
+	for range 25 {
+		orders = append(orders, storeStalePendingOrder(t, repo, time.Hour))
+	}
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 2 {
+		poller := NewOrderStatusPoller(newReplica(t, repo, istar), repo, time.Minute, 30*time.Minute, zap.NewNop())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			poller.pollOnce(context.Background())
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, order := range orders {
+		if n := fetches[order.IStarOrderID]; n != 1 {
+			t.Errorf("order %s fetched from iStar %d times, want once", order.ID, n)
+		}
+		entries, _ := repo.ListAuditEntries(context.Background(), order.ID.String())
+		if len(entries) != 1 {
+			t.Errorf("order %s has %d status changes, want one", order.ID, len(entries))
+		}
+		stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+		if stored.Status != models.StatusCompleted {
+			t.Errorf("order %s = %s, want completed", order.ID, stored.Status)
+		}
+	}
+}
+
+func TestPollOrderStatusYieldsToAnotherReplica(t *testing.T) {
+	istar := &clientmock.IStarAPI{
+		GetOrderFunc: func(ctx context.Context, id string) (*models.OrderStatusResponse, error) {
+			t.Errorf("iStar asked about %s while another replica holds it", id)
+			return &models.OrderStatusResponse{OrderID: id, Status: "completed"}, nil
+		},
+	}
+	svc, repo := newTestOrderService(t, istar, config.OrderConfig{})
+	order := storeStalePendingOrder(t, repo, time.Hour)
+
+	unlock, ok, err := repo.TryLockOrder(context.Background(), order.ID.String())
+	if err != nil || !ok {
+		t.Fatalf("TryLockOrder = %v, %v; want the lock", ok, err)
+	}
+	defer unlock()
+
+	_, err = svc.PollOrderStatus(context.Background(), order.ID.String())
+	if !isConflict(err) {
+		t.Errorf("PollOrderStatus = %v, want a conflict", err)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/client"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxReconciliationWindow caps the time range a single report may cover
+	MaxReconciliationWindow = 7 * 24 * time.Hour
+	// reconciliationSampleSize bounds how many orders one report checks upstream
+	reconciliationSampleSize = 500
+	// reconciliationConcurrency bounds in-flight iStar lookups per report
+	reconciliationConcurrency = 8
+)
+
+// ReconciliationService compares local order state with iStar without changing either
+type ReconciliationService interface {
+	Report(ctx context.Context, from, to time.Time) (*models.ReconciliationReport, error)
+}
+
+type reconciliationService struct {
+	repo        repositories.OrderRepository
+	istarClient client.IStarAPI
+	logger      *zap.Logger
+}
+
+// NewReconciliationService initializes a new ReconciliationService with dependencies
+func NewReconciliationService(repo repositories.OrderRepository, istarClient client.IStarAPI, logger *zap.Logger) ReconciliationService {
+	return &reconciliationService{
+		repo:        repo,
+		istarClient: istarClient,
+		logger:      logger.Named("reconciliation_service"),
+	}
+}
+
+// Report samples orders created in [from, to), fetches each one's upstream
+// status and lists the orders where the two disagree. Nothing is updated.
+func (s *reconciliationService) Report(ctx context.Context, from, to time.Time) (*models.ReconciliationReport, error) {
+	if !from.Before(to) {
+		return nil, models.ValidationError("from must be before to")
+	}
+	if to.Sub(from) > MaxReconciliationWindow {
+		return nil, models.ValidationError("Reconciliation window must not exceed " + MaxReconciliationWindow.String())
+	}
+
+	orders, err := s.repo.ListOrdersCreatedBetween(ctx, from, to, reconciliationSampleSize+1)
+	if err != nil {
+		s.logger.Error("Failed to list orders for reconciliation", zap.Error(err))
+		return nil, models.InternalServerError("Failed to load orders")
+	}
+
+	report := &models.ReconciliationReport{
+		From:       from,
+		To:         to,
+		Mismatches: []models.ReconciliationMismatch{},
+		Unchecked:  []string{},
+	}
+	if len(orders) > reconciliationSampleSize {
+		orders = orders[:reconciliationSampleSize]
+		report.Truncated = true
+	}
+	report.Sampled = len(orders)
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, reconciliationConcurrency)
+	)
+	for _, order := range orders {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(order *models.Order) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			orderID := order.ID.String()
+			resp, err := s.istarClient.GetOrder(ctx, order.UpstreamID())
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				s.logger.Warn("Failed to fetch upstream order", zap.Error(err), zap.String("order_id", orderID))
+				report.Unchecked = append(report.Unchecked, orderID)
+				return
+			}
+			if models.OrderStatus(resp.Status) == order.Status {
+				report.Matched++
+				return
+			}
+			report.Mismatches = append(report.Mismatches, models.ReconciliationMismatch{
+				OrderID:        orderID,
+				LocalStatus:    order.Status,
+				UpstreamStatus: resp.Status,
+				CreatedAt:      order.CreatedAt,
+			})
+		}(order)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Reconciliation report generated",
+		zap.Time("from", from),
+		zap.Time("to", to),
+		zap.Int("sampled", report.Sampled),
+		zap.Int("mismatches", len(report.Mismatches)),
+		zap.Int("unchecked", len(report.Unchecked)))
+	return report, nil
+}
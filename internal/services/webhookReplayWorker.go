@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/repositories"
+	"go.uber.org/zap"
+)
+
+const (
+	// replayBatchSize bounds how many queued webhooks are retried per tick
+	replayBatchSize = 100
+	// maxReplayDelay caps the backoff between attempts at one queued webhook
+	maxReplayDelay = time.Hour
+)
+
+// WebhookReplayWorker periodically retries webhooks whose local processing
+// failed, backing off exponentially between attempts at each one.
+type WebhookReplayWorker struct {
+	webhookService WebhookService
+	repo           repositories.OrderRepository
+	interval       time.Duration
+	maxAttempts    int
+	logger         *zap.Logger
+}
+
+// NewWebhookReplayWorker initializes a worker that runs every interval and
+// gives up on a webhook after maxAttempts replays
+func NewWebhookReplayWorker(webhookService WebhookService, repo repositories.OrderRepository, interval time.Duration, maxAttempts int, logger *zap.Logger) *WebhookReplayWorker {
+	return &WebhookReplayWorker{
+		webhookService: webhookService,
+		repo:           repo,
+		interval:       interval,
+		maxAttempts:    maxAttempts,
+		logger:         logger.Named("webhook_replay_worker"),
+	}
+}
+
+// Run blocks, replaying queued webhooks until ctx is cancelled
+func (w *WebhookReplayWorker) Run(ctx context.Context) {
+	w.logger.Info("Webhook replay worker started",
+		zap.Duration("interval", w.interval),
+		zap.Int("max_attempts", w.maxAttempts))
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Webhook replay worker stopped")
+			return
+		case <-ticker.C:
+			w.replayOnce(ctx)
+		}
+	}
+}
+
+// replayOnce retries a single batch of due webhooks
+func (w *WebhookReplayWorker) replayOnce(ctx context.Context) {
+	now := time.Now()
+	replays, err := w.repo.ListDueWebhookReplays(ctx, now, w.maxAttempts, replayBatchSize)
+	if err != nil {
+		w.logger.Error("Failed to list queued webhooks", zap.Error(err))
+		return
+	}
+
+	for _, replay := range replays {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := w.webhookService.Replay(ctx, replay); err != nil {
+			attempts := replay.Attempts + 1
+			next := now.Add(w.backoff(attempts))
+			w.logger.Warn("Webhook replay failed",
+				zap.String("replay_id", replay.ID),
+				zap.Int("attempts", attempts),
+				zap.Time("next_attempt_at", next),
+				zap.Error(err))
+			if err := w.repo.RescheduleWebhookReplay(ctx, replay.ID, attempts, next, err.Error()); err != nil {
+				w.logger.Error("Failed to reschedule webhook replay", zap.Error(err), zap.String("replay_id", replay.ID))
+			}
+			continue
+		}
+
+		if err := w.repo.DeleteWebhookReplay(ctx, replay.ID); err != nil {
+			// Replaying again is harmless: applied events are deduplicated
+			w.logger.Error("Failed to delete replayed webhook", zap.Error(err), zap.String("replay_id", replay.ID))
+			continue
+		}
+		w.logger.Info("Webhook replayed", zap.String("replay_id", replay.ID))
+	}
+}
+
+// backoff is the delay before the next attempt after the given number of
+// failed attempts: interval doubled per attempt, capped at maxReplayDelay
+func (w *WebhookReplayWorker) backoff(attempts int) time.Duration {
+	delay := w.interval
+	for i := 1; i < attempts && delay < maxReplayDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxReplayDelay)
+}
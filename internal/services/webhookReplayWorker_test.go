@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// flakyRepo fails every transaction while down is set, as a briefly
+// unavailable database would
+type flakyRepo struct {
+	repositories.OrderRepository
+	down atomic.Bool
+}
+
+func (r *flakyRepo) WithTx(ctx context.Context, fn func(tx repositories.OrderRepository) error) error {
+	if r.down.Load() {
+		return errors.New("connection refused")
+	}
+	return r.OrderRepository.WithTx(ctx, fn)
+}
+
+func TestFailedWebhookIsQueuedAndReplayed(t *testing.T) {
+	repo := &flakyRepo{OrderRepository: repositories.NewInMemoryOrderRepository()}
+	svc := NewWebhookService(repo, UnknownEventIgnore, 3, time.Second, zap.NewNop())
+	worker := NewWebhookReplayWorker(svc, repo, time.Millisecond, 3, zap.NewNop())
+	ctx := context.Background()
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+
+	repo.down.Store(true)
+	if err := svc.ProcessWebhook(ctx, orderWebhook("evt-1", order.IStarOrderID, "completed")); err != nil {
+		t.Fatalf("ProcessWebhook = %v, want the delivery acknowledged once queued", err)
+	}
+	if stats, _ := svc.ReplayQueueStats(ctx); stats.Pending != 1 {
+		t.Fatalf("queue = %+v, want one pending webhook", stats)
+	}
+
+	worker.replayOnce(ctx)
+	replays, _ := repo.ListDueWebhookReplays(ctx, time.Now().Add(time.Hour), 3, 0)
+	if len(replays) != 1 || replays[0].Attempts != 1 || replays[0].LastError == "" {
+		t.Fatalf("queued = %+v, want one webhook with a failed attempt recorded", replays)
+	}
+
+	repo.down.Store(false)
+	time.Sleep(5 * time.Millisecond)
+	worker.replayOnce(ctx)
+
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusCompleted {
+		t.Errorf("status = %s, want the replayed webhook applied", stored.Status)
+	}
+	if stats, _ := svc.ReplayQueueStats(ctx); stats.Pending != 0 || stats.Exhausted != 0 {
+		t.Errorf("queue = %+v, want it empty after a successful replay", stats)
+	}
+}
+
+func TestWebhookIsNotQueuedWhenReplayIsDisabled(t *testing.T) {
+	repo := &flakyRepo{OrderRepository: repositories.NewInMemoryOrderRepository()}
+	svc := NewWebhookService(repo, UnknownEventIgnore, 0, time.Second, zap.NewNop())
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	repo.down.Store(true)
+
+	err := svc.ProcessWebhook(context.Background(), orderWebhook("evt-1", order.IStarOrderID, "completed"))
+
+	var apiErr *models.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != models.CodeInternal {
+		t.Errorf("ProcessWebhook = %v, want an internal error so iStar redelivers", err)
+	}
+}
+
+func TestWebhookReplayBackoff(t *testing.T) {
+	w := NewWebhookReplayWorker(nil, nil, time.Minute, 10, zap.NewNop())
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{7, maxReplayDelay},
+		{50, maxReplayDelay},
+	}
+	for _, tt := range tests {
+		if got := w.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"github.com/hulupay/istar-api/pkg/requestctx"
+	"go.uber.org/zap"
+	"time"
+)
+
+// WebhookService applies iStar webhook events to local orders
+type WebhookService interface {
+	ProcessWebhook(ctx context.Context, payload models.WebhookPayload) error
+	ProcessWebhookBatch(ctx context.Context, events []json.RawMessage) *models.WebhookBatchResponse
+	Replay(ctx context.Context, replay *models.WebhookReplay) error
+	ReplayQueueStats(ctx context.Context) (*models.WebhookQueueStats, error)
+}
+
+// UnknownEventPolicy decides what happens to webhooks with an unrecognised event type
+type UnknownEventPolicy string
+
+const (
+	// UnknownEventIgnore acknowledges the delivery without applying it
+	UnknownEventIgnore UnknownEventPolicy = "ignore"
+	// UnknownEventReject answers with a validation error
+	UnknownEventReject UnknownEventPolicy = "reject"
+)
+
+// webhookService implements the WebhookService interface
+type webhookService struct {
+	repo          repositories.OrderRepository
+	unknownEvents UnknownEventPolicy
+	// replayMaxAttempts is how often a queued webhook is retried; zero disables the replay queue
+	replayMaxAttempts int
+	// writeTimeout bounds the transaction that applies an event
+	writeTimeout time.Duration
+	logger       *zap.Logger
+}
+
+// NewWebhookService initializes a new WebhookService with dependencies. When
+// replayMaxAttempts is positive, webhooks that fail with an internal error are
+// queued for the replay worker instead of being rejected. writeTimeout bounds
+// the database transaction that applies each event.
+func NewWebhookService(repo repositories.OrderRepository, unknownEvents UnknownEventPolicy, replayMaxAttempts int, writeTimeout time.Duration, logger *zap.Logger) WebhookService {
+	return &webhookService{
+		repo:              repo,
+		unknownEvents:     unknownEvents,
+		replayMaxAttempts: replayMaxAttempts,
+		writeTimeout:      writeTimeout,
+		logger:            logger.Named("webhook_service"),
+	}
+}
+
+// ProcessWebhook applies the payload. If that fails for an internal reason
+// (e.g. the database is briefly unavailable) and the replay queue is enabled,
+// the payload is queued and the delivery acknowledged.
+func (s *webhookService) ProcessWebhook(ctx context.Context, payload models.WebhookPayload) error {
+	err := s.dispatch(ctx, payload)
+	var apiErr *models.APIError
+	if err == nil || s.replayMaxAttempts <= 0 || (errors.As(err, &apiErr) && apiErr.Code != models.CodeInternal) {
+		return err
+	}
+	return s.enqueueReplay(ctx, payload, err)
+}
+
+// Replay applies a queued webhook again. It is not re-queued on failure; the
+// replay worker reschedules it instead.
+func (s *webhookService) Replay(ctx context.Context, replay *models.WebhookReplay) error {
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(replay.Payload, &payload); err != nil {
+		s.logger.Error("Failed to decode queued webhook", zap.Error(err), zap.String("replay_id", replay.ID))
+		return models.ValidationError("Invalid queued webhook payload")
+	}
+	return s.dispatch(ctx, payload)
+}
+
+// ReplayQueueStats reports how many webhooks are waiting in the replay queue
+func (s *webhookService) ReplayQueueStats(ctx context.Context) (*models.WebhookQueueStats, error) {
+	stats, err := s.repo.WebhookReplayStats(ctx, s.replayMaxAttempts)
+	if err != nil {
+		s.logger.Error("Failed to read webhook replay queue", zap.Error(err))
+		return nil, models.InternalServerError("Failed to read webhook replay queue")
+	}
+	return stats, nil
+}
+
+// enqueueReplay stores payload for the replay worker. If it cannot be stored
+// either, the original error is returned so iStar redelivers.
+func (s *webhookService) enqueueReplay(ctx context.Context, payload models.WebhookPayload, cause error) error {
+	correlationID := requestctx.CorrelationID(ctx)
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		s.logger.Error("Failed to encode webhook for replay", zap.Error(err), zap.String("correlation_id", correlationID))
+		return cause
+	}
+
+	now := time.Now()
+	replay := &models.WebhookReplay{
+		ID:            uuid.NewString(),
+		Payload:       raw,
+		NextAttemptAt: now,
+		LastError:     cause.Error(),
+		CreatedAt:     now,
+	}
+	if err := s.repo.EnqueueWebhookReplay(ctx, replay); err != nil {
+		s.logger.Error("Failed to enqueue webhook for replay", zap.Error(err), zap.String("correlation_id", correlationID))
+		return cause
+	}
+
+	s.logger.Warn("Webhook queued for replay",
+		zap.String("replay_id", replay.ID),
+		zap.String("event_id", payload.EventID),
+		zap.NamedError("cause", cause),
+		zap.String("correlation_id", correlationID))
+	return nil
+}
+
+// dispatch applies the payload according to its event type. Unknown event
+// types are ignored or rejected according to the configured UnknownEventPolicy.
+func (s *webhookService) dispatch(ctx context.Context, payload models.WebhookPayload) error {
+	switch payload.EventType {
+	case models.WebhookOrderUpdated,
+		models.WebhookOrderCompleted,
+		models.WebhookOrderFailed,
+		models.WebhookOrderCancelled:
+		return s.applyOrderEvent(ctx, payload)
+	}
+
+	s.logger.Warn("Unknown webhook event type",
+		zap.String("event_type", string(payload.EventType)),
+		zap.String("policy", string(s.unknownEvents)),
+		zap.String("correlation_id", requestctx.CorrelationID(ctx)))
+	if s.unknownEvents == UnknownEventReject {
+		return models.ValidationError("Unknown event type " + string(payload.EventType))
+	}
+	return nil
+}
+
+// applyOrderEvent updates the order referenced by the payload and records the
+// change as an order event tagged with the delivery's correlation id.
+// Redelivered events and events that would move an order backwards (e.g. a
+// late "pending" after "completed") are acknowledged without being applied.
+//
+// The writes run in one transaction under their own writeTimeout, detached from
+// ctx: iStar giving up on the delivery must not cut a commit short. Either all
+// of them commit and nil is returned, or none do and iStar redelivers.
+func (s *webhookService) applyOrderEvent(ctx context.Context, payload models.WebhookPayload) error {
+	correlationID := requestctx.CorrelationID(ctx)
+
+	if payload.EventID != "" {
+		processed, err := s.repo.IsWebhookProcessed(ctx, payload.EventID)
+		if err != nil {
+			s.logger.Error("Failed to check webhook event", zap.Error(err), zap.String("correlation_id", correlationID))
+			return models.InternalServerError("Failed to check webhook event")
+		}
+		if processed {
+			s.logger.Info("Skipping duplicate webhook",
+				zap.String("event_id", payload.EventID),
+				zap.String("correlation_id", correlationID))
+			return nil
+		}
+	}
+
+	if err := payload.Order.Validate(); err != nil {
+		s.logger.Error("Incomplete order in webhook payload", zap.Error(err), zap.String("correlation_id", correlationID))
+		return err
+	}
+	istarOrderID, rawStatus := payload.Order.ID, payload.Order.Status
+
+	status, ok := mapUpstreamStatus(rawStatus)
+	if !ok {
+		s.logger.Error("Unknown status in webhook payload", zap.String("status", rawStatus), zap.String("correlation_id", correlationID))
+		return models.ValidationError("Unknown status " + rawStatus)
+	}
+
+	// The webhook carries iStar's order id, not ours
+	order, err := s.repo.GetOrderByIStarID(ctx, istarOrderID)
+	if errors.Is(err, repositories.ErrOrderNotFound) {
+		s.logger.Warn("Webhook for unknown order", zap.String("istar_order_id", istarOrderID), zap.String("correlation_id", correlationID))
+		return models.NotFoundError("Order not found")
+	}
+	if err != nil {
+		s.logger.Error("Failed to load order", zap.Error(err), zap.String("correlation_id", correlationID))
+		return models.InternalServerError("Failed to load order")
+	}
+	orderID := order.ID.String()
+
+	if !order.Status.CanTransitionTo(status) {
+		s.logger.Info("Ignoring stale webhook",
+			zap.String("order_id", orderID),
+			zap.String("current_status", string(order.Status)),
+			zap.String("status", string(status)),
+			zap.String("correlation_id", correlationID))
+		s.markProcessed(ctx, payload.EventID, orderID)
+		return nil
+	}
+
+	var txHash *string
+	if payload.TxHash != nil {
+		th := *payload.TxHash
+		txHash = &th
+	}
+
+	var completedAt *time.Time
+	if payload.CompletedAt != nil {
+		completedAt = payload.CompletedAt
+	}
+
+	var errorMessage *string
+	if payload.Order.Error != nil {
+		em := *payload.Order.Error
+		errorMessage = &em
+	}
+
+	s.logger.Info("Applying webhook to order",
+		zap.String("order_id", orderID),
+		zap.String("status", string(status)),
+		zap.String("correlation_id", correlationID))
+
+	event := &models.OrderEvent{
+		ID:            uuid.New(),
+		OrderID:       orderID,
+		Source:        models.EventSourceWebhook,
+		EventType:     string(payload.EventType),
+		Status:        status,
+		CorrelationID: correlationID,
+		CreatedAt:     time.Now(),
+	}
+	entry := newAuditEntry(ctx, orderID, models.AuditOrderStatusChanged, order.Status, status, "istar")
+
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.writeTimeout)
+	defer cancel()
+	if err := s.repo.WithTx(writeCtx, func(tx repositories.OrderRepository) error {
+		if err := tx.UpdateOrderStatus(writeCtx, orderID, status, txHash, completedAt, errorMessage); err != nil {
+			return err
+		}
+		if err := tx.RecordOrderEvent(writeCtx, event); err != nil {
+			return err
+		}
+		if err := tx.RecordAudit(writeCtx, entry); err != nil {
+			return err
+		}
+		if payload.EventID == "" {
+			return nil
+		}
+		return tx.MarkWebhookProcessed(writeCtx, payload.EventID, orderID)
+	}); err != nil {
+		s.logger.Error("Failed to apply webhook to order",
+			zap.Error(err),
+			zap.String("order_id", orderID),
+			zap.Bool("timed_out", errors.Is(err, context.DeadlineExceeded)),
+			zap.String("correlation_id", correlationID))
+		return models.InternalServerError("Failed to update order")
+	}
+
+	return nil
+}
+
+// ProcessWebhookBatch applies each event of a batched delivery in order. Events
+// are decoded one by one and succeed or fail independently, with the same dedup
+// and state checks as ProcessWebhook, so a malformed event is reported in its
+// own result without holding back the rest.
+func (s *webhookService) ProcessWebhookBatch(ctx context.Context, events []json.RawMessage) *models.WebhookBatchResponse {
+	resp := &models.WebhookBatchResponse{Results: make([]models.WebhookEventResult, len(events))}
+
+	for i, raw := range events {
+		result := models.WebhookEventResult{Index: i, Status: "ok"}
+		var payload models.WebhookPayload
+		err := json.Unmarshal(raw, &payload)
+		if err != nil {
+			result.EventID = rawEventID(raw)
+			s.logger.Warn("Malformed event in webhook batch",
+				zap.Error(err),
+				zap.Int("index", i),
+				zap.String("event_id", result.EventID),
+				zap.String("correlation_id", requestctx.CorrelationID(ctx)))
+			err = models.WebhookPayloadError(err)
+		} else {
+			result.EventID = payload.EventID
+			err = s.ProcessWebhook(ctx, payload)
+		}
+		if err != nil {
+			result.Status = "error"
+			var apiErr *models.APIError
+			if errors.As(err, &apiErr) {
+				result.Code = apiErr.Code
+				result.Error = apiErr.Message
+			} else {
+				result.Code = models.CodeInternal
+				result.Error = "Failed to process event"
+			}
+			resp.Failed++
+		} else {
+			resp.Succeeded++
+		}
+		resp.Results[i] = result
+	}
+
+	s.logger.Info("Webhook batch processed",
+		zap.Int("events", len(events)),
+		zap.Int("succeeded", resp.Succeeded),
+		zap.Int("failed", resp.Failed),
+		zap.String("correlation_id", requestctx.CorrelationID(ctx)))
+	return resp
+}
+
+// rawEventID digs the event id out of an event that failed to decode, so its
+// batch result can still be matched to the delivery; empty when unavailable
+func rawEventID(raw json.RawMessage) string {
+	var probe struct {
+		EventID json.RawMessage `json:"event_id"`
+	}
+	if json.Unmarshal(raw, &probe) != nil {
+		return ""
+	}
+	var id string
+	if json.Unmarshal(probe.EventID, &id) != nil {
+		return ""
+	}
+	return id
+}
+
+// markProcessed records the id of an event that needed no order change. A
+// failure is logged rather than returned; at worst a redelivery is re-checked
+// against the order's status.
+func (s *webhookService) markProcessed(ctx context.Context, eventID, orderID string) {
+	if eventID == "" {
+		return
+	}
+	if err := s.repo.MarkWebhookProcessed(ctx, eventID, orderID); err != nil {
+		s.logger.Error("Failed to mark webhook processed",
+			zap.Error(err),
+			zap.String("event_id", eventID),
+			zap.String("correlation_id", requestctx.CorrelationID(ctx)))
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hulupay/istar-api/internal/models"
+	"github.com/hulupay/istar-api/internal/repositories"
+	"go.uber.org/zap"
+)
+
+// newTestWebhookService returns a webhook service over a fresh in-memory
+// repository, queueing failed webhooks for up to replayAttempts replays
+func newTestWebhookService(replayAttempts int) (*webhookService, repositories.OrderRepository) {
+	repo := repositories.NewInMemoryOrderRepository()
+	svc := NewWebhookService(repo, UnknownEventIgnore, replayAttempts, time.Second, zap.NewNop())
+	return svc.(*webhookService), repo
+}
+
+// orderWebhook builds an event moving the iStar order istarOrderID to status
+func orderWebhook(eventID, istarOrderID, status string) models.WebhookPayload {
+	return models.WebhookPayload{
+		EventID:   eventID,
+		EventType: models.WebhookOrderUpdated,
+		Order:     models.WebhookOrder{ID: istarOrderID, Status: status},
+	}
+}
+
+func TestDuplicateWebhookIsAppliedOnce(t *testing.T) {
+	svc, repo := newTestWebhookService(0)
+	ctx := context.Background()
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	payload := orderWebhook("evt-1", order.IStarOrderID, "completed")
+
+	for i := range 2 {
+		if err := svc.ProcessWebhook(ctx, payload); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusCompleted {
+		t.Errorf("status = %s, want completed", stored.Status)
+	}
+	entries, _ := repo.ListAuditEntries(ctx, order.ID.String())
+	if len(entries) != 1 {
+		t.Errorf("audit entries = %d, want 1 for two deliveries of the same event", len(entries))
+	}
+	if processed, _ := repo.IsWebhookProcessed(ctx, "evt-1"); !processed {
+		t.Error("evt-1 is not marked processed")
+	}
+}
+
+func TestStaleWebhookIsAcknowledgedWithoutApplying(t *testing.T) {
+	svc, repo := newTestWebhookService(0)
+	ctx := context.Background()
+	order := storeOrder(t, repo, "client-a", models.StatusCompleted)
+
+	if err := svc.ProcessWebhook(ctx, orderWebhook("evt-late", order.IStarOrderID, "pending")); err != nil {
+		t.Fatalf("ProcessWebhook: %v", err)
+	}
+
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusCompleted {
+		t.Errorf("status = %s, want it to stay completed", stored.Status)
+	}
+	if entries, _ := repo.ListAuditEntries(ctx, order.ID.String()); len(entries) != 0 {
+		t.Errorf("audit entries = %d, want none for a stale event", len(entries))
+	}
+	if processed, _ := repo.IsWebhookProcessed(ctx, "evt-late"); !processed {
+		t.Error("the stale event is not marked processed")
+	}
+}
+
+func TestWebhookWithoutEventIDIsStillApplied(t *testing.T) {
+	svc, repo := newTestWebhookService(0)
+	ctx := context.Background()
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+
+	if err := svc.ProcessWebhook(ctx, orderWebhook("", order.IStarOrderID, "failed")); err != nil {
+		t.Fatalf("ProcessWebhook: %v", err)
+	}
+
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusFailed {
+		t.Errorf("status = %s, want failed", stored.Status)
+	}
+}
+
+func TestUnknownEventTypeFollowsPolicy(t *testing.T) {
+	tests := []struct {
+		policy  UnknownEventPolicy
+		wantErr bool
+	}{
+		{UnknownEventIgnore, false},
+		{UnknownEventReject, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			repo := repositories.NewInMemoryOrderRepository()
+			svc := NewWebhookService(repo, tt.policy, 0, time.Second, zap.NewNop())
+			ctx := context.Background()
+			order := storeOrder(t, repo, "client-a", models.StatusPending)
+			payload := orderWebhook("evt-1", order.IStarOrderID, "completed")
+			payload.EventType = "order.shipped"
+
+			err := svc.ProcessWebhook(ctx, payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProcessWebhook = %v, want error %v", err, tt.wantErr)
+			}
+			stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+			if stored.Status != models.StatusPending {
+				t.Errorf("status = %s, want the unknown event left unapplied", stored.Status)
+			}
+		})
+	}
+}
+
+func TestWebhookBatchIsolatesMalformedEvents(t *testing.T) {
+	svc, repo := newTestWebhookService(0)
+	ctx := context.Background()
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	good := json.RawMessage(`{"event_id":"evt-1","event_type":"order.updated","order":{"id":"` + order.IStarOrderID + `","status":"completed"}}`)
+
+	resp := svc.ProcessWebhookBatch(ctx, []json.RawMessage{
+		good,
+		json.RawMessage(`{"event_id":"evt-2","event_type":"order.updated","order":{"id":42,"status":"completed"}}`),
+		json.RawMessage(`"not an event"`),
+		good,
+	})
+
+	if resp.Succeeded != 2 || resp.Failed != 2 || len(resp.Results) != 4 {
+		t.Fatalf("response = %+v, want two successes and two failures", resp)
+	}
+	want := []models.WebhookEventResult{
+		{Index: 0, EventID: "evt-1", Status: "ok"},
+		{Index: 1, EventID: "evt-2", Status: "error", Code: models.CodeValidation},
+		{Index: 2, Status: "error", Code: models.CodeValidation},
+		{Index: 3, EventID: "evt-1", Status: "ok"},
+	}
+	for i, got := range resp.Results {
+		got.Error = ""
+		if got != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, resp.Results[i], want[i])
+		}
+	}
+	if resp.Results[1].Error == "" {
+		t.Error("the malformed event's result carries no error message")
+	}
+
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusCompleted {
+		t.Errorf("status = %s, want the good event applied", stored.Status)
+	}
+	entries, _ := repo.ListAuditEntries(ctx, order.ID.String())
+	if len(entries) != 1 {
+		t.Errorf("audit entries = %d, want the redelivered event applied once", len(entries))
+	}
+}
+
+// slowEventRepo delays recording order events inside transactions by delay,
+// giving up early when the write's context ends
+type slowEventRepo struct {
+	repositories.OrderRepository
+	delay time.Duration
+}
+
+func (r *slowEventRepo) WithTx(ctx context.Context, fn func(tx repositories.OrderRepository) error) error {
+	return r.OrderRepository.WithTx(ctx, func(tx repositories.OrderRepository) error {
+		return fn(&slowEventRepo{OrderRepository: tx, delay: r.delay})
+	})
+}
+
+func (r *slowEventRepo) RecordOrderEvent(ctx context.Context, event *models.OrderEvent) error {
+	select {
+	case <-time.After(r.delay):
+		return r.OrderRepository.RecordOrderEvent(ctx, event)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestSlowWebhookWriteTimesOutAndRollsBack(t *testing.T) {
+	repo := repositories.NewInMemoryOrderRepository()
+	svc := NewWebhookService(&slowEventRepo{OrderRepository: repo, delay: time.Second}, UnknownEventIgnore, 0, 50*time.Millisecond, zap.NewNop())
+	ctx := context.Background()
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+
+	start := time.Now()
+	err := svc.ProcessWebhook(ctx, orderWebhook("evt-1", order.IStarOrderID, "completed"))
+
+	wantAPIStatus(t, err, http.StatusInternalServerError)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("ProcessWebhook took %v, want it cut off at the 50ms write timeout", elapsed)
+	}
+	stored, _ := repo.GetOrderByID(ctx, order.ID.String())
+	if stored.Status != models.StatusPending {
+		t.Errorf("status = %s, want the status update rolled back", stored.Status)
+	}
+	if processed, _ := repo.IsWebhookProcessed(ctx, "evt-1"); processed {
+		t.Error("evt-1 is marked processed, want it left for redelivery")
+	}
+	if entries, _ := repo.ListAuditEntries(ctx, order.ID.String()); len(entries) != 0 {
+		t.Errorf("audit entries = %d, want none after a rollback", len(entries))
+	}
+}
+
+func TestWebhookWriteOutlivesCancelledRequest(t *testing.T) {
+	repo := repositories.NewInMemoryOrderRepository()
+	svc := NewWebhookService(&slowEventRepo{OrderRepository: repo, delay: 100 * time.Millisecond}, UnknownEventIgnore, 0, time.Second, zap.NewNop())
+	order := storeOrder(t, repo, "client-a", models.StatusPending)
+	ctx, cancel := context.WithCancel(context.Background())
+	// The sender hangs up while the transaction is still running
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if err := svc.ProcessWebhook(ctx, orderWebhook("evt-1", order.IStarOrderID, "completed")); err != nil {
+		t.Fatalf("ProcessWebhook: %v", err)
+	}
+
+	stored, _ := repo.GetOrderByID(context.Background(), order.ID.String())
+	if stored.Status != models.StatusCompleted {
+		t.Errorf("status = %s, want the write committed despite the cancelled request", stored.Status)
+	}
+}
@@ -0,0 +1,3 @@
+-- Support lookups of orders by settlement transaction hash.
+-- Not unique: one batched transaction can settle several orders.
+CREATE INDEX IF NOT EXISTS idx_orders_tx_hash ON orders (tx_hash) WHERE tx_hash IS NOT NULL;
@@ -0,0 +1,9 @@
+-- Idempotency keys are scoped per client (hashed API key) and honoured for 24 hours.
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS client_id       TEXT,
+    ADD COLUMN IF NOT EXISTS idempotency_key TEXT,
+    ADD COLUMN IF NOT EXISTS request_hash    TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_orders_idempotency
+    ON orders (client_id, idempotency_key, created_at DESC)
+    WHERE idempotency_key IS NOT NULL;
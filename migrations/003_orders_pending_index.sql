@@ -0,0 +1,2 @@
+-- Lets the status poller find stale pending orders without a full scan.
+CREATE INDEX IF NOT EXISTS idx_orders_pending_created_at ON orders (created_at) WHERE status = 'pending';
@@ -0,0 +1,14 @@
+-- History of order state changes, tagged with the correlation id of the
+-- request or webhook delivery that caused them.
+CREATE TABLE IF NOT EXISTS order_events (
+    id             UUID PRIMARY KEY,
+    order_id       UUID        NOT NULL,
+    source         TEXT        NOT NULL,
+    event_type     TEXT        NOT NULL,
+    status         TEXT        NOT NULL,
+    correlation_id TEXT        NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_events_order_id ON order_events (order_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_order_events_correlation_id ON order_events (correlation_id);
@@ -0,0 +1,4 @@
+-- Manual interventions record who acted and why.
+ALTER TABLE order_events
+    ADD COLUMN IF NOT EXISTS actor  TEXT,
+    ADD COLUMN IF NOT EXISTS reason TEXT;
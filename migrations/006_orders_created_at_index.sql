@@ -0,0 +1,2 @@
+-- Lets reconciliation reports scan an order window by creation time.
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders (created_at);
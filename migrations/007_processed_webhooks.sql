@@ -0,0 +1,7 @@
+-- Webhook deliveries already applied, so redelivered events are acknowledged
+-- without being applied twice.
+CREATE TABLE IF NOT EXISTS processed_webhooks (
+    event_id     TEXT PRIMARY KEY,
+    order_id     UUID        NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
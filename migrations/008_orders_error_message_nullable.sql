@@ -0,0 +1,3 @@
+-- Orders without a failure have no error message; store NULL rather than ''.
+ALTER TABLE orders ALTER COLUMN error_message DROP NOT NULL;
+UPDATE orders SET error_message = NULL WHERE error_message = '';
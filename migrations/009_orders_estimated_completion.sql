@@ -0,0 +1,5 @@
+-- Settlement estimate shown for pending orders.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS estimated_completion_at TIMESTAMPTZ;
+
+-- Lets the per-wallet median completion latency be computed from recent completions.
+CREATE INDEX IF NOT EXISTS idx_orders_completed_wallet ON orders (wallet_type, completed_at) WHERE status = 'completed';
@@ -0,0 +1,4 @@
+-- Refund details recorded when a completed order is refunded.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refund_id TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refund_amount NUMERIC(20, 9);
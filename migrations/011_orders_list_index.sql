@@ -0,0 +1,2 @@
+-- Keyset pagination for GET /orders walks (created_at, id) newest first.
+CREATE INDEX IF NOT EXISTS idx_orders_created_at_id ON orders (created_at DESC, id DESC);
@@ -0,0 +1,12 @@
+-- Webhooks whose local processing failed (e.g. the database was briefly
+-- unavailable), retried with backoff by the replay worker.
+CREATE TABLE IF NOT EXISTS webhook_replay_queue (
+    id              UUID PRIMARY KEY,
+    payload         JSONB       NOT NULL,
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_replay_due ON webhook_replay_queue (next_attempt_at);
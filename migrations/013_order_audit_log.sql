@@ -0,0 +1,15 @@
+-- Compliance trail of order mutations: who (hashed API key or operator) did
+-- what, and the status before and after. Rows are never updated or deleted.
+CREATE TABLE IF NOT EXISTS order_audit_log (
+    id             UUID PRIMARY KEY,
+    order_id       UUID        NOT NULL,
+    action         TEXT        NOT NULL,
+    client_id      TEXT,
+    actor          TEXT,
+    from_status    TEXT,
+    to_status      TEXT        NOT NULL,
+    correlation_id TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_audit_log_order_id ON order_audit_log (order_id, created_at);
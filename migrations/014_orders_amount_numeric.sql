@@ -0,0 +1,2 @@
+-- Store order amounts exactly, at the nine decimal places models.Amount keeps.
+ALTER TABLE orders ALTER COLUMN amount TYPE NUMERIC(20, 9) USING round(amount::numeric, 9);
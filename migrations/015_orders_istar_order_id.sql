@@ -0,0 +1,6 @@
+-- iStar's order id, kept apart from our own UUID. Orders created before this
+-- used the upstream id as their UUID.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS istar_order_id TEXT;
+UPDATE orders SET istar_order_id = id::text WHERE istar_order_id IS NULL;
+ALTER TABLE orders ALTER COLUMN istar_order_id SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_istar_order_id ON orders (istar_order_id);
@@ -0,0 +1,2 @@
+-- Lets a client's spend since UTC midnight be summed for the daily limit.
+CREATE INDEX IF NOT EXISTS idx_orders_client_created_at ON orders (client_id, created_at) WHERE status IN ('pending', 'completed');
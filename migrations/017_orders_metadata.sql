@@ -0,0 +1,2 @@
+-- Integrator-supplied key/value pairs attached to an order at creation
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS metadata JSONB;
@@ -0,0 +1,12 @@
+-- Idempotency keys are reserved here before iStar is called, so two requests
+-- racing with the same key cannot both place an order. order_id is set in the
+-- transaction that saves the order; a reservation older than 24 hours may be
+-- taken over by a new request.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    client_id       TEXT        NOT NULL,
+    idempotency_key TEXT        NOT NULL,
+    request_hash    TEXT        NOT NULL,
+    order_id        UUID        REFERENCES orders (id),
+    created_at      TIMESTAMPTZ NOT NULL,
+    CONSTRAINT idempotency_keys_client_key UNIQUE (client_id, idempotency_key)
+);
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUHitAndMiss(t *testing.T) {
+	c := NewLRU[string, int](time.Minute, 10)
+	c.Set("a", 1)
+
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %d, %v, want 1, true", v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("Get(b) hit, want a miss")
+	}
+
+	c.Set("a", 2)
+	if v, _ := c.Get("a"); v != 2 || c.Len() != 1 {
+		t.Errorf("after overwrite Get(a) = %d with %d entries, want 2 with 1", v, c.Len())
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	c := NewLRU[string, int](10*time.Millisecond, 10)
+	c.Set("a", 1)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get(a) hit after the TTL, want a miss")
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len = %d, want the expired entry dropped", n)
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU[string, int](time.Minute, 2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b is still cached, want it evicted as least recently used")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s was evicted, want it kept", key)
+		}
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len = %d, want 2", n)
+	}
+}
+
+func TestLRUDisabled(t *testing.T) {
+	for _, c := range []*LRU[string, int]{NewLRU[string, int](0, 10), NewLRU[string, int](time.Minute, 0)} {
+		c.Set("a", 1)
+		if _, ok := c.Get("a"); ok {
+			t.Error("Get(a) hit on a disabled cache")
+		}
+	}
+}
+
+func TestLRUConcurrentUse(t *testing.T) {
+	c := NewLRU[string, int](time.Minute, 16)
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				key := strconv.Itoa((i + j) % 32)
+				c.Set(key, j)
+				c.Get(key)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := c.Len(); n > 16 {
+		t.Errorf("Len = %d, want at most 16", n)
+	}
+}
@@ -0,0 +1,97 @@
+// Package cache provides small in-memory caches for short-lived upstream data.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTLCache is a concurrency-safe map whose entries expire after a fixed TTL.
+// A background janitor removes expired entries until its context is cancelled
+// or Stop is called; expired entries are never returned either way.
+type TTLCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[K]entry[V]
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewTTL creates a cache whose entries live for ttl, sweeping expired entries
+// every cleanupInterval until ctx is cancelled
+func NewTTL[K comparable, V any](ctx context.Context, ttl, cleanupInterval time.Duration) *TTLCache[K, V] {
+	c := &TTLCache[K, V]{
+		ttl:     ttl,
+		entries: make(map[K]entry[V]),
+		stop:    make(chan struct{}),
+	}
+	if cleanupInterval > 0 {
+		go c.janitor(ctx, cleanupInterval)
+	}
+	return c
+}
+
+// Get returns the cached value for key if present and not expired
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value under key for the cache's TTL
+func (c *TTLCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry[V]{value: value, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// Delete removes key from the cache
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Stop terminates the janitor goroutine. It is safe to call more than once.
+func (c *TTLCache[K, V]) Stop() {
+	c.once.Do(func() { close(c.stop) })
+}
+
+func (c *TTLCache[K, V]) janitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.removeExpired()
+		}
+	}
+}
+
+func (c *TTLCache[K, V]) removeExpired() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestTTLStopEndsJanitor(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	c := NewTTL[string, int](context.Background(), time.Minute, time.Millisecond)
+	c.Set("a", 1)
+	c.Stop()
+	c.Stop()
+}
+
+func TestTTLContextCancelEndsJanitor(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	NewTTL[string, int](ctx, time.Minute, time.Millisecond)
+	cancel()
+}
@@ -0,0 +1,99 @@
+// Package jsonlimit rejects JSON documents whose shape could be used to
+// exhaust memory or CPU, before they reach a full decoder.
+package jsonlimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Limits bounds the shape of an accepted JSON document. Zero values disable
+// the corresponding check.
+type Limits struct {
+	// MaxBytes caps the raw document size
+	MaxBytes int64
+	// MaxDepth caps how deeply objects and arrays may nest
+	MaxDepth int
+	// MaxElements caps the number of members of any single object or array
+	MaxElements int
+}
+
+// ErrTooLarge is returned when the document exceeds MaxBytes
+var ErrTooLarge = errors.New("JSON body too large")
+
+// Read reads at most MaxBytes from r and validates the document's nesting depth
+// and container sizes, returning the raw bytes for a subsequent full decode
+func (l Limits) Read(r io.Reader) ([]byte, error) {
+	if l.MaxBytes > 0 {
+		r = io.LimitReader(r, l.MaxBytes+1)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if l.MaxBytes > 0 && int64(len(body)) > l.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	if err := l.Check(body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// container tracks an open object or array while walking a document
+type container struct {
+	object    bool
+	members   int
+	expectKey bool
+}
+
+// Check walks the document token by token without materialising it and
+// reports the first limit it exceeds. Syntax errors are left to the decoder
+// that consumes the body afterwards.
+func (l Limits) Check(body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	var stack []*container
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+
+		var top *container
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		// Object members are counted by key; array members by value.
+		if top != nil {
+			switch {
+			case top.object && top.expectKey:
+				top.members++
+				top.expectKey = false
+			case top.object:
+				top.expectKey = true
+			default:
+				top.members++
+			}
+			if l.MaxElements > 0 && top.members > l.MaxElements {
+				return fmt.Errorf("JSON object or array exceeds maximum of %d elements", l.MaxElements)
+			}
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			stack = append(stack, &container{object: delim == '{', expectKey: delim == '{'})
+			if l.MaxDepth > 0 && len(stack) > l.MaxDepth {
+				return fmt.Errorf("JSON nesting exceeds maximum depth of %d", l.MaxDepth)
+			}
+		}
+	}
+}
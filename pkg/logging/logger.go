@@ -1,37 +1,42 @@
 package logging
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"os"
 	"time"
 )
 
-func initLogger() *zap.Logger {
-	env := os.Getenv("ENV")
-	var config zap.Config
+// Config selects the minimum level ("debug", "info", "warn", "error") and the
+// output format ("json" or "console") of the root logger
+type Config struct {
+	Level  string
+	Format string
+}
+
+// New builds the root logger. JSON output suits log shipping; console output
+// is colourised and meant for local development.
+func New(cfg Config) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
 
-	if env == "production" {
+	var config zap.Config
+	switch cfg.Format {
+	case "json", "":
 		config = zap.NewProductionConfig()
 		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	} else {
+	case "console":
 		config = zap.NewDevelopmentConfig()
 		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be json or console", cfg.Format)
 	}
+	config.Level = zap.NewAtomicLevelAt(level)
 
-	// Add caller information in production
-	if env == "production" {
-		config.DisableCaller = false
-		config.DisableStacktrace = false
-	}
-
-	logger, err := config.Build()
-	if err != nil {
-		panic("Failed to initialize logger: " + err.Error())
-	}
-
-	return logger
+	return config.Build()
 }
 
 func LoggerMiddleware(logger *zap.SugaredLogger) gin.HandlerFunc {
@@ -56,6 +61,7 @@ func LoggerMiddleware(logger *zap.SugaredLogger) gin.HandlerFunc {
 			"latency", latency,
 			"client_ip", c.ClientIP(),
 			"user_agent", c.Request.UserAgent(),
+			"request_id", c.GetString("request_id"),
 		)
 	}
 }
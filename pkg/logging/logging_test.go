@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewRespectsLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  zapcore.Level
+	}{
+		{"debug", zapcore.DebugLevel},
+		{"info", zapcore.InfoLevel},
+		{"warn", zapcore.WarnLevel},
+		{"error", zapcore.ErrorLevel},
+	}
+	for _, format := range []string{"json", "console"} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.level, func(t *testing.T) {
+				logger, err := New(Config{Level: tt.level, Format: format})
+				if err != nil {
+					t.Fatalf("New: %v", err)
+				}
+
+				core := logger.Core()
+				if !core.Enabled(tt.want) {
+					t.Errorf("%s is disabled", tt.want)
+				}
+				if tt.want > zapcore.DebugLevel && core.Enabled(tt.want-1) {
+					t.Errorf("%s is enabled below %s", tt.want-1, tt.want)
+				}
+				if got := zapcore.LevelOf(core); got != tt.want {
+					t.Errorf("LevelOf(core) = %s, want %s", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	for _, cfg := range []Config{
+		{Level: "verbose", Format: "json"},
+		{Level: "info", Format: "xml"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) = nil error, want it rejected", cfg)
+		}
+	}
+}
+
+func TestNewSugarSharesTheRootCore(t *testing.T) {
+	logger, err := New(Config{Level: "warn", Format: "json"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if got := zapcore.LevelOf(logger.Sugar().Desugar().Core()); got != zapcore.WarnLevel {
+		t.Errorf("sugared logger level = %s, want warn", got)
+	}
+}
@@ -0,0 +1,48 @@
+// Package requestctx carries request-scoped values from the HTTP layer down to
+// services and the iStar client without threading extra parameters everywhere.
+package requestctx
+
+import "context"
+
+type ctxKey int
+
+const (
+	clientIDKey ctxKey = iota
+	correlationIDKey
+	requestIDKey
+)
+
+// WithClientID returns a copy of ctx carrying the caller's client identifier
+// (a hash of the API key, never the key itself).
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDKey, clientID)
+}
+
+// ClientID returns the caller's client identifier, or "" if none was set.
+func ClientID(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey).(string)
+	return id
+}
+
+// WithCorrelationID returns a copy of ctx carrying the id that ties log lines
+// and stored events back to the request or webhook delivery that caused them.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// CorrelationID returns the correlation id carried by ctx, or "" if none was set.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
+
+// WithRequestID returns a copy of ctx carrying the inbound request's id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request id carried by ctx, or "" if none was set.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
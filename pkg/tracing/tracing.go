@@ -0,0 +1,48 @@
+// Package tracing sets up OpenTelemetry tracing. Spans are exported over
+// OTLP/HTTP when an endpoint is configured; otherwise the global tracer
+// provider stays a no-op and instrumented code pays next to nothing.
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hulupay/istar-api/pkg/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ServiceName identifies this service in exported traces
+const ServiceName = "istar-api"
+
+// Setup installs W3C trace context propagation and, when endpoint is set, a
+// tracer provider exporting to it. endpoint is the collector's OTLP/HTTP base
+// URL such as "http://otel-collector:4318"; spans go to its /v1/traces, and
+// the exporter's other OTEL_EXPORTER_OTLP_* variables (headers, timeout) still
+// apply. The returned function flushes buffered spans and should be called on
+// shutdown.
+func Setup(ctx context.Context, endpoint string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimRight(endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(sdkresource.NewSchemaless(
+			semconv.ServiceName(ServiceName),
+			semconv.ServiceVersion(version.Version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,12 @@
+// Package version holds build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X github.com/hulupay/istar-api/pkg/version.Version=1.2.3" ./cmd/api
+package version
+
+// Version is the release this binary was built from; "dev" for local builds
+var Version = "dev"
+
+// UserAgent identifies this service on outbound requests
+func UserAgent() string {
+	return "istar-api/" + Version
+}